@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/export"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+// runExportCommand implements `codex-mcp-go export [-format F] [-o FILE] <SESSION_ID>`,
+// which renders a session persisted in sessions.state_dir, with its events read from
+// sessions.diagnostics_dir when set. It only reads the store and the log, so it can run
+// next to a live server.
+func runExportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config file (optional). Can also be set via CODEX_MCP_CONFIG.")
+	stateDir := fs.String("state-dir", "", "Session state directory (defaults to sessions.state_dir / CODEX_MCP_STATE_DIR).")
+	diagnosticsDir := fs.String("diagnostics-dir", "", "Diagnostics log directory (defaults to sessions.diagnostics_dir / CODEX_MCP_DIAGNOSTICS_DIR).")
+	formatFlag := fs.String("format", string(export.FormatMarkdown), "Export format: markdown, html, json, tar or zip.")
+	outPath := fs.String("o", "", "Write the export to this file instead of stdout.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: codex-mcp-go export [-config FILE] [-state-dir DIR] [-diagnostics-dir DIR] [-format markdown|html|json|tar|zip] [-o FILE] <SESSION_ID>")
+		return 2
+	}
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 2
+	}
+
+	dir := strings.TrimSpace(*stateDir)
+	diagDir := strings.TrimSpace(*diagnosticsDir)
+	if dir == "" || diagDir == "" {
+		path := strings.TrimSpace(*configPath)
+		if path == "" {
+			path = strings.TrimSpace(os.Getenv("CODEX_MCP_CONFIG"))
+		}
+		cfg, err := config.Load(path)
+		switch {
+		case err == nil:
+			if dir == "" {
+				dir = strings.TrimSpace(cfg.Sessions.StateDir)
+			}
+			if diagDir == "" {
+				diagDir = strings.TrimSpace(cfg.Sessions.DiagnosticsDir)
+			}
+		case dir == "":
+			fmt.Fprintf(os.Stderr, "export: failed to load config: %v\n", err)
+			return 1
+		}
+	}
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "export: no state directory; set -state-dir, sessions.state_dir or CODEX_MCP_STATE_DIR")
+		return 2
+	}
+
+	snaps, err := session.ReadJSONLStore(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	snap, ok := findSnapshot(snaps, strings.TrimSpace(fs.Arg(0)))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "export: session %q not found in %s\n", fs.Arg(0), dir)
+		return 1
+	}
+	if diagDir != "" {
+		logged, err := session.ReadDiagnosticsLog(diagDir, snap.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v; using the diagnostics kept in the state dir\n", err)
+		} else {
+			snap = export.WithLoggedDiagnostics(snap, logged)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.OpenFile(*outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if err := export.Write(bw, export.Build(snap, time.Now()), format); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// findSnapshot returns the snapshot with the given ID or alias.
+func findSnapshot(snaps []session.Snapshot, id string) (session.Snapshot, bool) {
+	for _, s := range snaps {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	for _, s := range snaps {
+		for _, alias := range s.Aliases {
+			if alias == id {
+				return s, true
+			}
+		}
+	}
+	return session.Snapshot{}, false
+}
@@ -11,9 +11,22 @@ import (
 	"github.com/w31r4/codex-mcp-go/internal/config"
 	"github.com/w31r4/codex-mcp-go/internal/logging"
 	server "github.com/w31r4/codex-mcp-go/internal/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/replay"
 )
 
 func main() {
+	// Running as a fake codex (see runReplayAsCodex); the variable must only be set in
+	// the environment of the codex child, via a wrapper script.
+	if path := strings.TrimSpace(os.Getenv(replay.EnvFile)); path != "" {
+		os.Exit(runReplayAsCodex(path, os.Args[1:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplayCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExportCommand(os.Args[2:]))
+	}
+
 	configPath := flag.String("config", "", "Path to config file (optional). Can also be set via CODEX_MCP_CONFIG.")
 	safeLocal := flag.Bool("safe-local", false, "Enable safer defaults for local usage (read-only default sandbox, disable yolo, restrict work dirs to $HOME unless overridden). Can also be set via CODEX_SAFE_LOCAL=true.")
 	safeLocalRoot := flag.String("safe-local-root", "", "Comma-separated allowed workdir prefixes when --safe-local is enabled. Can also be set via CODEX_SAFE_LOCAL_ROOT.")
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/w31r4/codex-mcp-go/internal/replay"
+)
+
+// runReplayAsCodex acts as a fake codex binary backed by a recording
+// (selected via CODEX_MCP_REPLAY_FILE). To reproduce a recorded run against the server,
+// point codex.executable_path at a wrapper script that sets the variable and execs this
+// binary; the server passes its own environment to codex, so setting the variable for
+// the server would make the server itself play the recording:
+//
+//	#!/bin/sh
+//	CODEX_MCP_REPLAY_FILE=/path/to/recording.jsonl exec /path/to/codex-mcp-go "$@"
+func runReplayAsCodex(path string, args []string) int {
+	scale, err := replay.ParseScale(os.Getenv(replay.EnvScale))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	return replay.RunFake(context.Background(), path, args, os.Stdout, os.Stderr, scale)
+}
+
+// runReplayCommand implements `codex-mcp-go replay [-scale N] <recording.jsonl>`, which plays
+// a recording to stdout/stderr and exits with the recorded exit code.
+func runReplayCommand(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	defaultScale := strings.TrimSpace(os.Getenv(replay.EnvScale))
+	scaleFlag := fs.String("scale", defaultScale, "Time scale for recorded delays (1 = original timing, 0.5 = twice as fast, 0 = no delays). Can also be set via CODEX_MCP_REPLAY_SCALE.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: codex-mcp-go replay [-scale N] <recording.jsonl>")
+		return 2
+	}
+	scale, err := replay.ParseScale(*scaleFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	return replay.RunFake(context.Background(), fs.Arg(0), []string{"exec"}, os.Stdout, os.Stderr, scale)
+}
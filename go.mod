@@ -2,11 +2,13 @@ module github.com/w31r4/codex-mcp-go
 
 go 1.24.5
 
-require github.com/modelcontextprotocol/go-sdk v1.1.0
+require (
+	github.com/google/jsonschema-go v0.3.0
+	github.com/modelcontextprotocol/go-sdk v1.1.0
+	github.com/pelletier/go-toml/v2 v2.2.4
+)
 
 require (
-	github.com/google/jsonschema-go v0.3.0 // indirect
-	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
 )
@@ -1,19 +1,19 @@
 package codex
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
 	"github.com/w31r4/codex-mcp-go/internal/progress"
+	"github.com/w31r4/codex-mcp-go/internal/replay"
 )
 
 const (
@@ -31,6 +31,38 @@ const (
 // ValidSandboxModes contains all valid sandbox mode values
 var ValidSandboxModes = []string{SandboxReadOnly, SandboxWorkspaceWrite, SandboxDangerFullAccess}
 
+// Reasoning effort constants (model_reasoning_effort)
+const (
+	ReasoningEffortMinimal = "minimal"
+	ReasoningEffortLow     = "low"
+	ReasoningEffortMedium  = "medium"
+	ReasoningEffortHigh    = "high"
+)
+
+// ValidReasoningEfforts contains all valid reasoning effort values
+var ValidReasoningEfforts = []string{ReasoningEffortMinimal, ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh}
+
+// Approval policy constants (approval_policy)
+const (
+	ApprovalUntrusted = "untrusted"
+	ApprovalOnFailure = "on-failure"
+	ApprovalOnRequest = "on-request"
+	ApprovalNever     = "never"
+)
+
+// ValidApprovalPolicies contains all valid approval policy values
+var ValidApprovalPolicies = []string{ApprovalUntrusted, ApprovalOnFailure, ApprovalOnRequest, ApprovalNever}
+
+// SandboxRank orders sandbox modes from most to least restrictive (-1 for unknown values).
+func SandboxRank(sandbox string) int {
+	for i, valid := range ValidSandboxModes {
+		if sandbox == valid {
+			return i
+		}
+	}
+	return -1
+}
+
 // IsValidSandbox checks if the given sandbox value is valid
 func IsValidSandbox(sandbox string) bool {
 	for _, valid := range ValidSandboxModes {
@@ -58,11 +90,31 @@ type Options struct {
 	ExecutablePath    string
 	MaxBufferedLines  int
 	Reporter          progress.Reporter
-
-	// OnRawLine receives each trimmed stdout/stderr line from Codex (best-effort).
+	// AddDirs are extra writable directories (--add-dir).
+	AddDirs []string
+	// WebSearch toggles the web search tool (nil leaves the codex default).
+	WebSearch *bool
+	// ReasoningEffort overrides model_reasoning_effort (see ValidReasoningEfforts).
+	ReasoningEffort string
+	// ApprovalPolicy overrides approval_policy (see ValidApprovalPolicies).
+	ApprovalPolicy string
+	// OSS selects the local open-source model provider (--oss).
+	OSS bool
+	// Limits bounds the resources of the codex process tree (best-effort, see Limits).
+	Limits Limits
+	// RecordDir, when set, saves a replay recording of the run under this directory.
+	RecordDir string
+	// Control, when set, lets the caller suspend and resume the running process.
+	Control *Control
+
+	// OnRawLine receives each trimmed stdout (JSONL) line from Codex (best-effort).
 	OnRawLine func(line []byte)
+	// OnStderrLine receives each trimmed stderr line with its severity (best-effort).
+	OnStderrLine func(line []byte, severity string)
 	// OnThreadID is called when a thread_id is observed (best-effort).
 	OnThreadID func(threadID string)
+	// OnUsage is called when codex reports token usage (best-effort).
+	OnUsage func(usage Usage)
 }
 
 // Result represents the parsed result from Codex CLI output
@@ -73,6 +125,41 @@ type Result struct {
 	AllMessages   []map[string]interface{}
 	ToolCallCount int
 	Error         string
+	// CodexVersion is the detected codex CLI version (empty when unknown).
+	CodexVersion string
+	// RecordingPath is the replay recording written for this run (empty when disabled).
+	RecordingPath string
+	// Usage is the token usage reported by turn.completed (nil when not reported).
+	Usage *Usage
+	// Timings are when the run reached each phase.
+	Timings Timings
+	// LimitEnforcement is the mechanism applying Limits (see EnforcementNone), and
+	// LimitNote explains why some limits are enforced weaker than configured.
+	LimitEnforcement string
+	LimitNote        string
+}
+
+// Timings records when a run reached each phase (zero when it did not).
+type Timings struct {
+	Spawned           time.Time
+	FirstOutput       time.Time
+	ThreadID          time.Time
+	FirstAgentMessage time.Time
+	Exited            time.Time
+}
+
+// Usage is the token usage reported by codex for a turn.
+type Usage struct {
+	InputTokens           int64
+	CachedInputTokens     int64
+	OutputTokens          int64
+	ReasoningOutputTokens int64
+}
+
+// Total returns the billable token count (cached input is a subset of input and
+// reasoning output a subset of output).
+func (u Usage) Total() int64 {
+	return u.InputTokens + u.OutputTokens
 }
 
 // Run executes the Codex CLI with the given options and returns the result.
@@ -94,8 +181,12 @@ func Run(ctx context.Context, opts Options) (*Result, error) {
 	if opts.NoOutputTimeout <= 0 {
 		opts.NoOutputTimeout = defaultNoOutputTimeout
 	}
-	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
-	defer cancel()
+	// The timeout is a pausable timer rather than a context deadline so that it stops
+	// while the run is suspended (see Control).
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	deadline := newPausableTimer(opts.Timeout, func() { cancel(context.DeadlineExceeded) })
+	defer deadline.Stop()
 	reporter.Report(ctx, "initializing")
 
 	prompt := opts.Prompt
@@ -109,6 +200,14 @@ func Run(ctx context.Context, opts Options) (*Result, error) {
 	if !IsValidSandbox(sandbox) {
 		return nil, cerrors.ErrInvalidSandboxMode(sandbox, ValidSandboxModes)
 	}
+	if opts.ReasoningEffort != "" && !containsValue(ValidReasoningEfforts, opts.ReasoningEffort) {
+		return nil, cerrors.ErrInvalidParams("invalid reasoning_effort: "+opts.ReasoningEffort).
+			WithData("valid", ValidReasoningEfforts)
+	}
+	if opts.ApprovalPolicy != "" && !containsValue(ValidApprovalPolicies, opts.ApprovalPolicy) {
+		return nil, cerrors.ErrInvalidParams("invalid approval_policy: "+opts.ApprovalPolicy).
+			WithData("valid", ValidApprovalPolicies)
+	}
 
 	codexPath := strings.TrimSpace(opts.ExecutablePath)
 	if codexPath == "" {
@@ -119,56 +218,62 @@ func Run(ctx context.Context, opts Options) (*Result, error) {
 		codexPath = lookPath
 	}
 
-	// Build the base command
-	cmd := exec.CommandContext(ctx, codexPath, "exec", "--sandbox", sandbox, "--cd", opts.WorkingDir, "--json")
-	configureProcess(cmd)
-	reporter.Report(ctx, "starting codex")
-
-	// Add optional flags
-	if len(opts.ImagePaths) > 0 {
-		cmd.Args = append(cmd.Args, "--image", strings.Join(opts.ImagePaths, ","))
-	}
-	if opts.Model != "" {
-		cmd.Args = append(cmd.Args, "--model", opts.Model)
-	}
-	if opts.Profile != "" {
-		cmd.Args = append(cmd.Args, "--profile", opts.Profile)
-	}
-	if opts.Yolo {
-		cmd.Args = append(cmd.Args, "--yolo")
-	}
-	if opts.SkipGitRepoCheck {
-		cmd.Args = append(cmd.Args, "--skip-git-repo-check")
+	// Negotiate flags with the installed CLI (cached; unreadable help assumes full support).
+	caps, _ := DetectCapabilities(ctx, codexPath)
+	args, err := buildArgs(opts, sandbox, prompt, caps)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add session resume or prompt
-	if opts.SessionID != "" {
-		cmd.Args = append(cmd.Args, "resume", opts.SessionID)
+	cmd := exec.CommandContext(ctx, codexPath, args...)
+	if !caps.Supports(FeatureCd) {
+		cmd.Dir = opts.WorkingDir
 	}
+	configureProcess(cmd)
+	lim := newLimiter(opts.Limits)
+	defer lim.release()
+	reporter.Report(ctx, "starting codex")
 
-	// Add the prompt at the end
-	cmd.Args = append(cmd.Args, "--", prompt)
-
-	// Capture stdout and stderr
+	// Capture stdout (JSONL events) and stderr (logs/warnings) separately.
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, cerrors.ErrCodexExecutionFailed("failed to create stdout pipe", err)
 	}
-	// Combine stderr into stdout so we surface all messages
-	cmd.Stderr = cmd.Stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, cerrors.ErrCodexExecutionFailed("failed to create stderr pipe", err)
+	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		return nil, cerrors.ErrCodexExecutionFailed("failed to start codex command", err)
 	}
+	lim.started(cmd)
 	reporter.Report(ctx, "codex running")
 
 	// Parse the output
 	result := &Result{
 		Success:     true,
 		AllMessages: make([]map[string]interface{}, 0),
+		Timings:     Timings{Spawned: time.Now()},
+	}
+	if caps.HasVersion() {
+		result.CodexVersion = caps.Version.String()
+	}
+	result.LimitEnforcement = lim.enforcement()
+	result.LimitNote = lim.note()
+	var rec *replay.Recorder
+	if opts.RecordDir != "" {
+		rec = replay.Start(opts.RecordDir, cmd.Args, opts.WorkingDir, result.CodexVersion)
 	}
 	var runErr *cerrors.Error
+	var outputBytes int64
+	limitExceeded := func(name string) {
+		result.Success = false
+		result.Error = "resource limit exceeded: " + name
+		runErr = cerrors.ErrResourceLimitExceeded(name, opts.Limits.value(name)).
+			WithData("enforcement", lim.enforcement())
+	}
 
 	agentMessages := make([]string, 0)
 	recentLines := make([]string, 0)
@@ -177,53 +282,33 @@ func Run(ctx context.Context, opts Options) (*Result, error) {
 		bufferLimit = opts.MaxBufferedLines
 	}
 
-	lineCh := make(chan []byte)
-	readErrCh := make(chan error, 1)
+	stderrErrors := make([]string, 0)
+
+	lineCh := make(chan outputLine)
+	readErrCh := make(chan error, 2)
+	// readersDone unblocks the readers when the drain loop exits early (timeout, cancel, limit).
+	readersDone := make(chan struct{})
+	defer close(readersDone)
 
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go readLines(stdout, replay.StreamStdout, lineCh, readErrCh, readersDone, &readers)
+	go readLines(stderr, replay.StreamStderr, lineCh, readErrCh, readersDone, &readers)
 	go func() {
-		defer close(lineCh)
-		defer close(readErrCh)
-		reader := bufio.NewReader(stdout)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if len(line) > 0 {
-				lineCh <- bytes.TrimSpace(line)
-			}
-			if err != nil {
-				if !errors.Is(err, io.EOF) {
-					readErrCh <- err
-				}
-				return
-			}
-		}
+		readers.Wait()
+		close(lineCh)
+		close(readErrCh)
 	}()
 
-	var noOutputTimer *time.Timer
-	var noOutputCh <-chan time.Time
+	var watchdog *pausableTimer
+	var noOutputCh chan struct{}
 	lastOutput := time.Now()
-
-	resetNoOutputTimer := func() {}
 	if opts.NoOutputTimeout > 0 {
-		noOutputTimer = time.NewTimer(opts.NoOutputTimeout)
-		noOutputCh = noOutputTimer.C
-		resetNoOutputTimer = func() {
-			if noOutputTimer == nil {
-				return
-			}
-			if !noOutputTimer.Stop() {
-				select {
-				case <-noOutputTimer.C:
-				default:
-				}
-			}
-			noOutputTimer.Reset(opts.NoOutputTimeout)
-		}
+		noOutputCh = make(chan struct{}, 1)
+		watchdog = newPausableTimer(opts.NoOutputTimeout, func() { noOutputCh <- struct{}{} })
 	}
-	defer func() {
-		if noOutputTimer != nil {
-			noOutputTimer.Stop()
-		}
-	}()
+	defer watchdog.Stop()
+	opts.Control.attach(cmd, deadline, watchdog)
 
 	var progressTicker *time.Ticker
 	if reporter != progress.Nop {
@@ -237,25 +322,54 @@ func Run(ctx context.Context, opts Options) (*Result, error) {
 drainLoop:
 	for {
 		select {
-		case trimmed, ok := <-lineCh:
+		case line, ok := <-lineCh:
 			if !ok {
 				break drainLoop
 			}
-			resetNoOutputTimer()
+			watchdog.Reset()
 			lastOutput = time.Now()
+			trimmed := line.text
 			if len(trimmed) == 0 {
 				continue
 			}
+			rec.Line(line.stream, trimmed)
 
-			if opts.OnRawLine != nil {
-				safeCallBytes(opts.OnRawLine, trimmed)
+			outputBytes += int64(len(trimmed)) + 1
+			if opts.Limits.MaxOutputBytes > 0 && outputBytes > opts.Limits.MaxOutputBytes {
+				limitExceeded(LimitMaxOutputBytes)
+				killProcessTree(cmd)
+				break drainLoop
 			}
 
 			if !reportedFirstOutput {
 				reportedFirstOutput = true
+				result.Timings.FirstOutput = lastOutput
 				reporter.Report(ctx, "received output")
 			}
 
+			if line.stream == replay.StreamStderr {
+				// Stderr carries logs and warnings; it never decides success on its own.
+				severity := ClassifyStderr(string(trimmed))
+				if severity == StderrError {
+					stderrErrors = append(stderrErrors, string(trimmed))
+					if len(stderrErrors) > bufferLimit {
+						stderrErrors = stderrErrors[1:]
+					}
+				}
+				if opts.OnStderrLine != nil {
+					safeCallStderr(opts.OnStderrLine, trimmed, severity)
+				}
+				recentLines = append(recentLines, "[stderr] "+string(trimmed))
+				if len(recentLines) > bufferLimit {
+					recentLines = recentLines[1:]
+				}
+				continue
+			}
+
+			if opts.OnRawLine != nil {
+				safeCallBytes(opts.OnRawLine, trimmed)
+			}
+
 			recentLines = append(recentLines, string(trimmed))
 			if len(recentLines) > bufferLimit {
 				recentLines = recentLines[1:]
@@ -278,6 +392,8 @@ drainLoop:
 			if opts.ReturnAllMessages {
 				result.AllMessages = append(result.AllMessages, lineData)
 			}
+			// The event format can't be probed up front, so legacy lines are recognised one by one.
+			lineData = normalizeLegacyEvent(lineData)
 
 			// Best-effort tool call counting (independent of opts.ReturnAllMessages).
 			if item, ok := lineData["item"].(map[string]interface{}); ok {
@@ -294,6 +410,7 @@ drainLoop:
 				result.SessionID = threadID
 				if !reportedThreadID {
 					reportedThreadID = true
+					result.Timings.ThreadID = time.Now()
 					reporter.Report(ctx, "received SESSION_ID")
 					if opts.OnThreadID != nil {
 						safeCallString(opts.OnThreadID, threadID)
@@ -308,12 +425,23 @@ drainLoop:
 						agentMessages = append(agentMessages, text)
 						if !reportedAgentMessage {
 							reportedAgentMessage = true
+							result.Timings.FirstAgentMessage = time.Now()
 							reporter.Report(ctx, "received agent message")
 						}
 					}
 				}
 			}
 
+			// Token usage is reported once per turn.
+			if lineType, _ := lineData["type"].(string); lineType == "turn.completed" {
+				if u, ok := lineData["usage"].(map[string]interface{}); ok {
+					result.Usage = parseUsage(u)
+					if opts.OnUsage != nil {
+						safeCallUsage(opts.OnUsage, *result.Usage)
+					}
+				}
+			}
+
 			// Check for errors
 			if lineType, ok := lineData["type"].(string); ok {
 				if strings.Contains(lineType, "fail") || strings.Contains(lineType, "error") {
@@ -347,7 +475,10 @@ drainLoop:
 					runErr = cerrors.ErrCodexExecutionFailed(result.Error, readErr)
 				}
 			}
-			readErrCh = nil
+		case name := <-lim.breaches():
+			limitExceeded(name)
+			killProcessTree(cmd)
+			break drainLoop
 		case <-noOutputCh:
 			result.Success = false
 			if result.Error == "" {
@@ -365,7 +496,7 @@ drainLoop:
 				result.Error = "codex execution canceled"
 			}
 			if runErr == nil {
-				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
 					runErr = cerrors.ErrCodexTimeout(opts.Timeout)
 				} else {
 					runErr = cerrors.ErrCodexExecutionFailed(result.Error, ctx.Err())
@@ -379,29 +510,48 @@ drainLoop:
 			}
 			return progressTicker.C
 		}():
-			reporter.Report(ctx, "running")
+			if opts.Control.Suspended() {
+				reporter.Report(ctx, "suspended")
+			} else {
+				reporter.Report(ctx, "running")
+			}
 		}
 	}
+	opts.Control.detach()
 
 	result.AgentMessages = strings.Join(agentMessages, "\n")
 	reporter.Report(ctx, "finalizing")
 
 	// Wait for command to finish
-	if err := cmd.Wait(); err != nil {
+	waitErr := cmd.Wait()
+	result.Timings.Exited = time.Now()
+	if waitErr != nil {
+		// A limit breach explains the exit better than any generic failure seen so far.
+		if runErr == nil || runErr.Code == cerrors.CodexExecutionFailed {
+			if name := lim.exited(cmd.ProcessState); name != "" {
+				limitExceeded(name)
+			}
+		}
 		result.Success = false
 		if result.Error == "" {
 			result.Error = "codex command failed"
 		}
 		if runErr == nil {
-			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
 				runErr = cerrors.ErrCodexTimeout(opts.Timeout)
 			} else if errors.Is(ctx.Err(), context.Canceled) {
 				runErr = cerrors.ErrCodexExecutionFailed("codex execution canceled", ctx.Err())
 			} else {
-				runErr = cerrors.ErrCodexExecutionFailed(result.Error, err)
+				runErr = cerrors.ErrCodexExecutionFailed(result.Error, waitErr)
 			}
 		}
 	}
+	if rec != nil {
+		// Best-effort: a failed recording must not fail the run.
+		if path, err := rec.Finish(cmd.ProcessState.ExitCode()); err == nil {
+			result.RecordingPath = path
+		}
+	}
 
 	// Post-process validation
 	if result.SessionID == "" {
@@ -428,11 +578,136 @@ drainLoop:
 		if len(recentLines) > 0 {
 			runErr.WithData("recent_output", recentLines)
 		}
+		if len(stderrErrors) > 0 {
+			runErr.WithData("stderr_errors", stderrErrors)
+		}
 		return result, runErr
 	}
 	return result, nil
 }
 
+// buildArgs assembles the `codex exec` argv for the negotiated capabilities.
+// Features that can be emulated degrade gracefully (e.g. --cd falls back to the
+// process working directory); required features that are missing fail with
+// CodexVersionUnsupported.
+func buildArgs(opts Options, sandbox string, prompt string, caps Capabilities) ([]string, error) {
+	unsupported := func(feature string) error {
+		return cerrors.ErrCodexVersionUnsupported(caps.Version.String(), feature)
+	}
+	// JSONL output and sandboxing can't be emulated.
+	for _, feature := range []string{FeatureJSON, FeatureSandbox} {
+		if !caps.Supports(feature) {
+			return nil, unsupported(feature)
+		}
+	}
+
+	args := []string{"exec", "--sandbox", sandbox}
+	if caps.Supports(FeatureCd) {
+		args = append(args, "--cd", opts.WorkingDir)
+	}
+	args = append(args, "--json")
+
+	// Add optional flags
+	if len(opts.ImagePaths) > 0 {
+		if !caps.Supports(FeatureImage) {
+			return nil, unsupported(FeatureImage)
+		}
+		args = append(args, "--image", strings.Join(opts.ImagePaths, ","))
+	}
+	if opts.Model != "" {
+		if !caps.Supports(FeatureModel) {
+			return nil, unsupported(FeatureModel)
+		}
+		args = append(args, "--model", opts.Model)
+	}
+	if opts.Profile != "" {
+		if !caps.Supports(FeatureProfile) {
+			return nil, unsupported(FeatureProfile)
+		}
+		args = append(args, "--profile", opts.Profile)
+	}
+	if opts.Yolo {
+		if caps.Supports(FeatureYolo) {
+			args = append(args, "--yolo")
+		} else {
+			// --yolo is an alias introduced after the long form.
+			args = append(args, "--dangerously-bypass-approvals-and-sandbox")
+		}
+	}
+	if opts.SkipGitRepoCheck && caps.Supports(FeatureSkipGitRepoCheck) {
+		// Older CLIs never checked for a Git repository, so the flag can be dropped.
+		args = append(args, "--skip-git-repo-check")
+	}
+	if len(opts.AddDirs) > 0 {
+		if !caps.Supports(FeatureAddDir) {
+			return nil, unsupported(FeatureAddDir)
+		}
+		for _, dir := range opts.AddDirs {
+			args = append(args, "--add-dir", dir)
+		}
+	}
+	if opts.OSS {
+		if !caps.Supports(FeatureOSS) {
+			return nil, unsupported(FeatureOSS)
+		}
+		args = append(args, "--oss")
+	}
+	// Options without a dedicated exec flag are passed as config overrides (TOML values).
+	if opts.WebSearch != nil || opts.ReasoningEffort != "" || opts.ApprovalPolicy != "" {
+		if !caps.Supports(FeatureConfigOverride) {
+			return nil, unsupported(FeatureConfigOverride)
+		}
+	}
+	if opts.WebSearch != nil {
+		args = append(args, "-c", "tools.web_search="+strconv.FormatBool(*opts.WebSearch))
+	}
+	if opts.ReasoningEffort != "" {
+		args = append(args, "-c", "model_reasoning_effort="+strconv.Quote(opts.ReasoningEffort))
+	}
+	if opts.ApprovalPolicy != "" {
+		args = append(args, "-c", "approval_policy="+strconv.Quote(opts.ApprovalPolicy))
+	}
+
+	// Add session resume or prompt
+	if opts.SessionID != "" {
+		if !caps.Supports(FeatureResume) {
+			return nil, unsupported(FeatureResume)
+		}
+		args = append(args, "resume", opts.SessionID)
+	}
+
+	// Add the prompt at the end
+	args = append(args, "--", prompt)
+	return args, nil
+}
+
+// normalizeLegacyEvent maps a legacy {"id":..,"msg":{..}} event onto the
+// thread_id/item shape the parser understands. Other lines are returned as-is.
+func normalizeLegacyEvent(line map[string]interface{}) map[string]interface{} {
+	msg, ok := line["msg"].(map[string]interface{})
+	if !ok {
+		return line
+	}
+	msgType, _ := msg["type"].(string)
+	switch msgType {
+	case "session_configured":
+		if id, ok := msg["session_id"].(string); ok {
+			return map[string]interface{}{"thread_id": id}
+		}
+	case "agent_message":
+		if text, ok := msg["message"].(string); ok {
+			return map[string]interface{}{"item": map[string]interface{}{"type": "agent_message", "text": text}}
+		}
+	case "exec_command_begin", "mcp_tool_call_begin":
+		return map[string]interface{}{"item": map[string]interface{}{"type": "tool_call"}}
+	case "error", "stream_error":
+		if text, ok := msg["message"].(string); ok {
+			return map[string]interface{}{"type": "error", "message": text}
+		}
+	}
+	return map[string]interface{}{}
+}
+
 // escapePrompt mirrors the Python implementation to avoid Windows shell quoting issues.
 func escapePrompt(prompt string) string {
 	replacer := strings.NewReplacer(
@@ -448,12 +723,46 @@ func escapePrompt(prompt string) string {
 	return replacer.Replace(prompt)
 }
 
+func parseUsage(u map[string]interface{}) *Usage {
+	n := func(key string) int64 {
+		if v, ok := u[key].(float64); ok {
+			return int64(v)
+		}
+		return 0
+	}
+	return &Usage{
+		InputTokens:           n("input_tokens"),
+		CachedInputTokens:     n("cached_input_tokens"),
+		OutputTokens:          n("output_tokens"),
+		ReasoningOutputTokens: n("reasoning_output_tokens"),
+	}
+}
+
+func containsValue(values []string, needle string) bool {
+	for _, v := range values {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
 func safeCallBytes(fn func([]byte), b []byte) {
 	defer func() { _ = recover() }()
 	fn(b)
 }
 
+func safeCallStderr(fn func([]byte, string), b []byte, severity string) {
+	defer func() { _ = recover() }()
+	fn(b, severity)
+}
+
 func safeCallString(fn func(string), s string) {
 	defer func() { _ = recover() }()
 	fn(s)
 }
+
+func safeCallUsage(fn func(Usage), u Usage) {
+	defer func() { _ = recover() }()
+	fn(u)
+}
@@ -3,6 +3,7 @@ package codex
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
@@ -100,3 +101,82 @@ func TestRun_CodexNotFound_ReturnsStructuredError(t *testing.T) {
 		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.CodexNotFound)
 	}
 }
+
+func TestBuildArgs_ExecOptions(t *testing.T) {
+	webSearch := true
+	caps := mustParseExecHelp(t, fakeExecHelp)
+
+	args, err := buildArgs(Options{
+		WorkingDir:      "/work",
+		AddDirs:         []string{"/extra/a", "/extra/b"},
+		WebSearch:       &webSearch,
+		ReasoningEffort: ReasoningEffortHigh,
+		ApprovalPolicy:  ApprovalNever,
+		OSS:             true,
+	}, SandboxWorkspaceWrite, "hi", caps)
+	if err != nil {
+		t.Fatalf("buildArgs() failed: %v", err)
+	}
+	want := []string{
+		"exec", "--sandbox", "workspace-write", "--cd", "/work", "--json",
+		"--add-dir", "/extra/a", "--add-dir", "/extra/b",
+		"--oss",
+		"-c", "tools.web_search=true",
+		"-c", `model_reasoning_effort="high"`,
+		"-c", `approval_policy="never"`,
+		"--", "hi",
+	}
+	if strings.Join(args, "\x00") != strings.Join(want, "\x00") {
+		t.Fatalf("args=%q\nwant %q", args, want)
+	}
+}
+
+func TestBuildArgs_WebSearchDisabled(t *testing.T) {
+	webSearch := false
+	args, err := buildArgs(Options{WorkingDir: "/work", WebSearch: &webSearch}, SandboxReadOnly, "hi", Capabilities{})
+	if err != nil {
+		t.Fatalf("buildArgs() failed: %v", err)
+	}
+	if !containsValue(args, "tools.web_search=false") {
+		t.Fatalf("args=%q, want tools.web_search=false override", args)
+	}
+}
+
+func TestBuildArgs_OmitsUnsetExecOptions(t *testing.T) {
+	args, err := buildArgs(Options{WorkingDir: "/work"}, SandboxReadOnly, "hi", Capabilities{})
+	if err != nil {
+		t.Fatalf("buildArgs() failed: %v", err)
+	}
+	for _, flag := range []string{"--add-dir", "--oss", "-c"} {
+		if containsValue(args, flag) {
+			t.Fatalf("args=%q, unexpected %s", args, flag)
+		}
+	}
+}
+
+func TestBuildArgs_AddDirUnsupportedOnOldCLI(t *testing.T) {
+	caps := mustParseExecHelp(t, oldExecHelp)
+	_, err := buildArgs(Options{WorkingDir: "/work", AddDirs: []string{"/extra"}}, SandboxReadOnly, "hi", caps)
+	var cerr *cerrors.Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	if cerr.Code != cerrors.CodexVersionUnsupported {
+		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.CodexVersionUnsupported)
+	}
+}
+
+func TestRun_InvalidReasoningEffort_ReturnsStructuredError(t *testing.T) {
+	_, err := Run(context.Background(), Options{
+		Prompt:          "hi",
+		WorkingDir:      ".",
+		ReasoningEffort: "extreme",
+	})
+	var cerr *cerrors.Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	if cerr.Code != cerrors.InvalidParams {
+		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.InvalidParams)
+	}
+}
@@ -0,0 +1,220 @@
+package codex
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ErrNotRunning is returned by Control when no codex process is attached.
+var ErrNotRunning = errors.New("codex process is not running")
+
+// Control suspends and resumes the codex process tree of a run (see Options.Control).
+// While suspended, the run's timeout and no-output watchdog are paused. It is safe for
+// concurrent use.
+type Control struct {
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	timers    []*pausableTimer
+	suspended bool
+}
+
+func NewControl() *Control {
+	return &Control{}
+}
+
+// Suspended reports whether the process is currently suspended.
+func (c *Control) Suspended() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.suspended
+}
+
+// Suspend stops the process tree (SIGSTOP). It reports false if it was already suspended.
+func (c *Control) Suspend() (bool, error) {
+	return c.set(true)
+}
+
+// Resume continues the process tree (SIGCONT). It reports false if it was not suspended.
+func (c *Control) Resume() (bool, error) {
+	return c.set(false)
+}
+
+func (c *Control) set(suspend bool) (bool, error) {
+	if c == nil {
+		return false, ErrNotRunning
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil {
+		return false, ErrNotRunning
+	}
+	if c.suspended == suspend {
+		return false, nil
+	}
+	if suspend {
+		if err := suspendProcessTree(c.cmd); err != nil {
+			return false, err
+		}
+		for _, t := range c.timers {
+			t.Pause()
+		}
+	} else {
+		if err := resumeProcessTree(c.cmd); err != nil {
+			return false, err
+		}
+		for _, t := range c.timers {
+			t.Resume()
+		}
+	}
+	c.suspended = suspend
+	return true, nil
+}
+
+// AfterFunc calls fn once d of unsuspended time has elapsed, like the run's own
+// timeout, and returns a function that cancels it. Use it for deadlines that must not
+// count the time the process spends suspended (e.g. execution budgets).
+func (c *Control) AfterFunc(d time.Duration, fn func()) (stop func()) {
+	t := newPausableTimer(d, fn)
+	if c == nil {
+		return t.Stop
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.suspended {
+		t.Pause()
+	}
+	c.timers = append(c.timers, t)
+	return t.Stop
+}
+
+// attach binds a started process and the timers to pause with it.
+func (c *Control) attach(cmd *exec.Cmd, timers ...*pausableTimer) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmd = cmd
+	for _, t := range timers {
+		if t != nil {
+			c.timers = append(c.timers, t)
+		}
+	}
+}
+
+// detach unbinds the process once the run stops reading its output.
+func (c *Control) detach() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmd = nil
+	c.timers = nil
+	c.suspended = false
+}
+
+// pausableTimer calls fn once d of unpaused time has elapsed. Reset restarts the full
+// duration. A nil *pausableTimer is a disabled timer.
+type pausableTimer struct {
+	mu       sync.Mutex
+	d        time.Duration
+	fn       func()
+	timer    *time.Timer
+	gen      uint64
+	deadline time.Time
+	left     time.Duration
+	paused   bool
+	fired    bool
+}
+
+func newPausableTimer(d time.Duration, fn func()) *pausableTimer {
+	p := &pausableTimer{d: d, fn: fn}
+	p.mu.Lock()
+	p.startLocked(d)
+	p.mu.Unlock()
+	return p
+}
+
+func (p *pausableTimer) startLocked(d time.Duration) {
+	p.gen++
+	gen := p.gen
+	p.deadline = time.Now().Add(d)
+	p.timer = time.AfterFunc(d, func() {
+		p.mu.Lock()
+		// A stale callback (the timer was reset, paused or stopped meanwhile) is ignored.
+		if p.gen != gen || p.paused || p.fired {
+			p.mu.Unlock()
+			return
+		}
+		p.fired = true
+		p.mu.Unlock()
+		p.fn()
+	})
+}
+
+func (p *pausableTimer) stopLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+}
+
+func (p *pausableTimer) Reset() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fired {
+		return
+	}
+	if p.paused {
+		p.left = p.d
+		return
+	}
+	p.stopLocked()
+	p.startLocked(p.d)
+}
+
+func (p *pausableTimer) Pause() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused || p.fired {
+		return
+	}
+	p.stopLocked()
+	p.left = max(time.Until(p.deadline), 0)
+	p.paused = true
+}
+
+func (p *pausableTimer) Resume() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	p.startLocked(p.left)
+}
+
+func (p *pausableTimer) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+}
@@ -0,0 +1,91 @@
+package codex
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Limit names reported when a run is terminated for exceeding a resource limit.
+// They match the keys of the [codex.limits] config section.
+const (
+	LimitMaxMemory      = "max_memory_mb"
+	LimitMaxCPUSeconds  = "max_cpu_seconds"
+	LimitMaxOpenFiles   = "max_open_files"
+	LimitMaxProcesses   = "max_processes"
+	LimitMaxOutputBytes = "max_output_bytes"
+)
+
+// Enforcement mechanisms used for Limits.
+const (
+	EnforcementNone   = "none"
+	EnforcementRlimit = "rlimit"
+	EnforcementCgroup = "cgroup"
+)
+
+// Limits bounds the resources the codex process tree may use. Zero values disable a limit.
+//
+// On Linux, memory, process and CPU time limits are applied through a per-run cgroup v2
+// subtree of CgroupParent, so they bound the whole process tree. Without one, MaxProcesses
+// falls back to RLIMIT_NPROC, MaxMemoryMB is not enforced (RLIMIT_AS would break runtimes
+// that reserve large address ranges) and MaxCPUSeconds only bounds each process on its own.
+// CPU time and open files are always also applied as rlimits (per process). MaxOutputBytes
+// is enforced by the server itself on every platform.
+type Limits struct {
+	MaxMemoryMB    int
+	MaxCPUSeconds  int
+	MaxOpenFiles   int
+	MaxProcesses   int
+	MaxOutputBytes int64
+
+	// CgroupParent is a delegated cgroup v2 directory to create per-run subtrees in. It is
+	// required for cgroup enforcement, and must not contain processes itself.
+	CgroupParent string
+}
+
+// IsZero reports whether no limit is configured.
+func (l Limits) IsZero() bool {
+	return l.MaxMemoryMB <= 0 && l.MaxCPUSeconds <= 0 && l.MaxOpenFiles <= 0 && l.MaxProcesses <= 0 && l.MaxOutputBytes <= 0
+}
+
+func (l Limits) value(name string) int64 {
+	switch name {
+	case LimitMaxMemory:
+		return int64(l.MaxMemoryMB)
+	case LimitMaxCPUSeconds:
+		return int64(l.MaxCPUSeconds)
+	case LimitMaxOpenFiles:
+		return int64(l.MaxOpenFiles)
+	case LimitMaxProcesses:
+		return int64(l.MaxProcesses)
+	case LimitMaxOutputBytes:
+		return l.MaxOutputBytes
+	default:
+		return 0
+	}
+}
+
+// limiter applies Limits to a single codex process tree.
+// Implementations are best-effort: failing to apply a limit MUST NOT fail the run.
+type limiter interface {
+	// started is called right after the process has started.
+	started(cmd *exec.Cmd)
+	// breaches delivers the name of a limit hit while the process is running (may be nil).
+	breaches() <-chan string
+	// exited reports the name of a limit that explains the process exit, if any.
+	exited(state *os.ProcessState) string
+	// enforcement returns the mechanism in use (EnforcementNone, EnforcementRlimit, EnforcementCgroup).
+	enforcement() string
+	// note explains why configured limits are enforced weaker than requested ("" if not).
+	note() string
+	// release frees any resources held by the limiter.
+	release()
+}
+
+type nopLimiter struct{}
+
+func (nopLimiter) started(*exec.Cmd)              {}
+func (nopLimiter) breaches() <-chan string        { return nil }
+func (nopLimiter) exited(*os.ProcessState) string { return "" }
+func (nopLimiter) enforcement() string            { return EnforcementNone }
+func (nopLimiter) note() string                   { return "" }
+func (nopLimiter) release()                       {}
@@ -0,0 +1,332 @@
+//go:build linux
+
+package codex
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	cgroupPollInterval = 250 * time.Millisecond
+
+	// RLIMIT_NPROC is not exported by package syscall.
+	rlimitNproc = 6
+)
+
+var cgroupSeq atomic.Uint64
+
+func newLimiter(limits Limits) limiter {
+	if limits.MaxMemoryMB <= 0 && limits.MaxCPUSeconds <= 0 && limits.MaxOpenFiles <= 0 && limits.MaxProcesses <= 0 {
+		return nopLimiter{}
+	}
+	l := &linuxLimiter{
+		limits:   limits,
+		breachCh: make(chan string, 1),
+		stop:     make(chan struct{}),
+	}
+	if limits.MaxMemoryMB > 0 || limits.MaxProcesses > 0 || limits.MaxCPUSeconds > 0 {
+		// Best-effort: without a usable cgroup, processes fall back to an rlimit, CPU time
+		// is only limited per process and memory is not limited.
+		switch {
+		case strings.TrimSpace(limits.CgroupParent) == "":
+			l.cgErr = "no cgroup_parent configured"
+		default:
+			if cg, err := newCgroup(limits); err == nil {
+				l.cg = cg
+			} else {
+				l.cgErr = err.Error()
+			}
+		}
+	}
+	return l
+}
+
+// linuxLimiter applies Limits through a cgroup v2 subtree of the delegated
+// CgroupParent (memory, processes, and CPU time of the whole tree) and prlimit(2)
+// (CPU time of each process, open files, and processes without a cgroup). Memory is
+// deliberately not mapped to RLIMIT_AS: runtimes such as V8 reserve far more address
+// space than they ever use.
+//
+// Limits are applied right after the process starts, so anything codex spawns
+// in the first instant may escape them; this is acceptable for a guard rail.
+type linuxLimiter struct {
+	limits   Limits
+	cg       *cgroup
+	cgErr    string
+	breachCh chan string
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *linuxLimiter) started(cmd *exec.Cmd) {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+	pid := cmd.Process.Pid
+
+	if l.cg != nil {
+		if err := l.cg.add(pid); err != nil {
+			l.cg.remove()
+			l.cg = nil
+			l.cgErr = err.Error()
+		}
+	}
+
+	if l.limits.MaxCPUSeconds > 0 {
+		// SIGXCPU at the soft limit, SIGKILL shortly after. This only bounds each process
+		// on its own; the cgroup, when there is one, bounds the tree.
+		soft := uint64(l.limits.MaxCPUSeconds)
+		_ = prlimit(pid, syscall.RLIMIT_CPU, soft, soft+5)
+	}
+	if l.limits.MaxOpenFiles > 0 {
+		n := uint64(l.limits.MaxOpenFiles)
+		_ = prlimit(pid, syscall.RLIMIT_NOFILE, n, n)
+	}
+	if l.cg == nil {
+		if l.limits.MaxProcesses > 0 {
+			// Note: RLIMIT_NPROC counts all processes of the user and is ignored for root.
+			n := uint64(l.limits.MaxProcesses)
+			_ = prlimit(pid, rlimitNproc, n, n)
+		}
+		return
+	}
+
+	go l.watch()
+}
+
+func (l *linuxLimiter) watch() {
+	t := time.NewTicker(cgroupPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if name := l.cg.breached(); name != "" {
+				select {
+				case l.breachCh <- name:
+				default:
+				}
+				return
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (l *linuxLimiter) breaches() <-chan string {
+	return l.breachCh
+}
+
+func (l *linuxLimiter) exited(state *os.ProcessState) string {
+	if l.cg != nil {
+		if name := l.cg.breached(); name != "" {
+			return name
+		}
+	}
+	if state == nil || l.limits.MaxCPUSeconds <= 0 {
+		return ""
+	}
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return ""
+	}
+	switch ws.Signal() {
+	case syscall.SIGXCPU:
+		return LimitMaxCPUSeconds
+	case syscall.SIGKILL:
+		// The hard RLIMIT_CPU limit kills without SIGXCPU when codex ignores or handles
+		// the soft one.
+		if state.UserTime()+state.SystemTime() >= time.Duration(l.limits.MaxCPUSeconds)*time.Second {
+			return LimitMaxCPUSeconds
+		}
+	}
+	return ""
+}
+
+func (l *linuxLimiter) enforcement() string {
+	switch {
+	case l.cg != nil:
+		return EnforcementCgroup
+	case l.limits.MaxCPUSeconds > 0 || l.limits.MaxOpenFiles > 0 || l.limits.MaxProcesses > 0:
+		return EnforcementRlimit
+	default:
+		return EnforcementNone
+	}
+}
+
+func (l *linuxLimiter) note() string {
+	if l.cg != nil || l.cgErr == "" {
+		return ""
+	}
+	var weaker []string
+	if l.limits.MaxMemoryMB > 0 {
+		weaker = append(weaker, "max_memory_mb is not enforced")
+	}
+	if l.limits.MaxProcesses > 0 {
+		weaker = append(weaker, "max_processes falls back to RLIMIT_NPROC")
+	}
+	if l.limits.MaxCPUSeconds > 0 {
+		weaker = append(weaker, "max_cpu_seconds applies to each process separately")
+	}
+	return "cgroup unavailable (" + l.cgErr + "): " + strings.Join(weaker, "; ")
+}
+
+func (l *linuxLimiter) release() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	if l.cg != nil {
+		l.cg.remove()
+	}
+}
+
+func prlimit(pid int, resource int, soft uint64, hard uint64) error {
+	lim := syscall.Rlimit{Cur: soft, Max: hard}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&lim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// cgroup is a per-run cgroup v2 subtree.
+type cgroup struct {
+	dir string
+	// maxCPUUsec bounds the CPU time of the subtree (0 = unlimited). CPU usage is
+	// accounted in cpu.stat without the cpu controller, so it is checked by polling.
+	maxCPUUsec int64
+}
+
+// newCgroup creates a per-run subtree of limits.CgroupParent. The parent must be
+// delegated to the server and hold no processes itself: cgroup v2 refuses to enable
+// controllers for the children of a cgroup that has member processes.
+func newCgroup(limits Limits) (*cgroup, error) {
+	parent := strings.TrimSpace(limits.CgroupParent)
+
+	controllers := make([]string, 0, 2)
+	if limits.MaxMemoryMB > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.MaxProcesses > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if err := enableControllers(parent, controllers); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("codex-mcp-%d-%d", os.Getpid(), cgroupSeq.Add(1)))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return nil, err
+	}
+	cg := &cgroup{dir: dir, maxCPUUsec: int64(limits.MaxCPUSeconds) * 1e6}
+
+	if limits.MaxMemoryMB > 0 {
+		if err := cg.write("memory.max", strconv.FormatInt(int64(limits.MaxMemoryMB)<<20, 10)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+		// Best-effort: without swap the OOM killer fires at the limit instead of swapping.
+		_ = cg.write("memory.swap.max", "0")
+	}
+	if limits.MaxProcesses > 0 {
+		if err := cg.write("pids.max", strconv.Itoa(limits.MaxProcesses)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	return cg, nil
+}
+
+func enableControllers(parent string, controllers []string) error {
+	current, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(current))
+	for _, c := range controllers {
+		if containsField(enabled, c) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+c), 0o644); err != nil {
+			return fmt.Errorf("enable %s controller: %w", c, err)
+		}
+	}
+	return nil
+}
+
+func (c *cgroup) add(pid int) error {
+	return c.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+func (c *cgroup) write(file string, value string) error {
+	return os.WriteFile(filepath.Join(c.dir, file), []byte(value), 0o644)
+}
+
+// breached returns the name of the limit the cgroup has hit, if any.
+func (c *cgroup) breached() string {
+	if readEventCount(filepath.Join(c.dir, "memory.events"), "oom_kill") > 0 {
+		return LimitMaxMemory
+	}
+	if readEventCount(filepath.Join(c.dir, "pids.events"), "max") > 0 {
+		return LimitMaxProcesses
+	}
+	if c.maxCPUUsec > 0 && readEventCount(filepath.Join(c.dir, "cpu.stat"), "usage_usec") >= c.maxCPUUsec {
+		return LimitMaxCPUSeconds
+	}
+	return ""
+}
+
+func (c *cgroup) remove() {
+	// Make sure nothing is left behind before removing the subtree.
+	if err := c.write("cgroup.kill", "1"); err != nil {
+		if data, readErr := os.ReadFile(filepath.Join(c.dir, "cgroup.procs")); readErr == nil {
+			for _, f := range strings.Fields(string(data)) {
+				if pid, convErr := strconv.Atoi(f); convErr == nil && pid > 0 {
+					_ = syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if err := os.Remove(c.dir); err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func readEventCount(path string, key string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+func containsField(fields []string, needle string) bool {
+	for _, f := range fields {
+		if f == needle {
+			return true
+		}
+	}
+	return false
+}
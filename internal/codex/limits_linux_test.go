@@ -0,0 +1,86 @@
+package codex
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_Limits_AppliesOpenFilesRlimit(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "report_nofile")
+
+	res, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+		Limits:         Limits{MaxOpenFiles: 123},
+	})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if !strings.Contains(res.AgentMessages, "Max open files 123 123") {
+		t.Fatalf("AgentMessages=%q, want open files limit 123", res.AgentMessages)
+	}
+}
+
+func TestRun_Limits_MemoryNeedsCgroupParent(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "report_nofile")
+
+	res, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+		Limits:         Limits{MaxMemoryMB: 64},
+	})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if res.LimitEnforcement != EnforcementNone || !strings.Contains(res.LimitNote, "max_memory_mb is not enforced") {
+		t.Fatalf("enforcement=%q note=%q, want none with a note", res.LimitEnforcement, res.LimitNote)
+	}
+}
+
+func TestCgroup_BreachedByTreeCPUTime(t *testing.T) {
+	dir := t.TempDir()
+	cg := &cgroup{dir: dir, maxCPUUsec: 2e6}
+	if err := os.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 1999999\nuser_usec 1500000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cg.breached(); got != "" {
+		t.Fatalf("breached()=%q below the CPU limit", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 2000000\nuser_usec 1500000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cg.breached(); got != LimitMaxCPUSeconds {
+		t.Fatalf("breached()=%q, want %q", got, LimitMaxCPUSeconds)
+	}
+}
+
+func TestLimiter_AttributesHardCPUKill(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	// soft == hard, so the kernel kills with SIGKILL and never sends SIGXCPU, like the
+	// hard limit above MaxCPUSeconds does when codex ignores SIGXCPU.
+	cmd := exec.Command("sh", "-c", "ulimit -t 2 && while :; do :; done")
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected the CPU-bound process to be killed")
+	}
+	l := &linuxLimiter{limits: Limits{MaxCPUSeconds: 1}}
+	if got := l.exited(cmd.ProcessState); got != LimitMaxCPUSeconds {
+		t.Fatalf("exited()=%q for %v, want %q", got, cmd.ProcessState, LimitMaxCPUSeconds)
+	}
+	l = &linuxLimiter{limits: Limits{MaxCPUSeconds: 60}}
+	if got := l.exited(cmd.ProcessState); got != "" {
+		t.Fatalf("exited()=%q with CPU time below the limit, want none", got)
+	}
+}
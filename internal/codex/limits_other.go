@@ -0,0 +1,9 @@
+//go:build !linux
+
+package codex
+
+// newLimiter returns a no-op limiter: only MaxOutputBytes (enforced by Run itself)
+// is supported outside Linux.
+func newLimiter(limits Limits) limiter {
+	return nopLimiter{}
+}
@@ -150,3 +150,114 @@ func TestRun_InvalidJSONL(t *testing.T) {
 		t.Fatalf("expected data.line to be present")
 	}
 }
+
+func TestRun_MaxOutputBytesExceeded(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "flood")
+
+	_, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+		Limits:         Limits{MaxOutputBytes: 2048},
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	if cerr.Code != cerrors.ResourceLimitExceeded {
+		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.ResourceLimitExceeded)
+	}
+	if cerr.Data["limit"] != LimitMaxOutputBytes {
+		t.Fatalf("data.limit=%v, want %v", cerr.Data["limit"], LimitMaxOutputBytes)
+	}
+}
+
+func TestRun_ParsesTurnUsage(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "usage")
+
+	var reported []Usage
+	res, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+		OnUsage:        func(u Usage) { reported = append(reported, u) },
+	})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if res.SessionID != "t-usage" {
+		t.Fatalf("SessionID=%q, want %q", res.SessionID, "t-usage")
+	}
+	want := Usage{InputTokens: 120, CachedInputTokens: 20, OutputTokens: 30, ReasoningOutputTokens: 7}
+	if res.Usage == nil || *res.Usage != want {
+		t.Fatalf("Usage=%+v, want %+v", res.Usage, want)
+	}
+	if len(reported) != 1 || reported[0] != want || reported[0].Total() != 150 {
+		t.Fatalf("OnUsage reported %+v, want [%+v]", reported, want)
+	}
+}
+
+func TestRun_SuspendPausesTimeouts(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "thread_then_reply")
+
+	control := NewControl()
+	if _, err := control.Suspend(); !stderrors.Is(err, ErrNotRunning) {
+		t.Fatalf("Suspend() before start err=%v, want ErrNotRunning", err)
+	}
+
+	// The timeouts leave seconds of margin over the fake's 200ms of silence, which a
+	// race-instrumented child can stretch well past that.
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := Run(context.Background(), Options{
+			Prompt:          "hi",
+			WorkingDir:      ".",
+			Sandbox:         SandboxReadOnly,
+			ExecutablePath:  os.Args[0],
+			Timeout:         4 * time.Second,
+			NoOutputTimeout: 3 * time.Second,
+			Control:         control,
+			OnThreadID:      func(string) { close(started) },
+		})
+		done <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("codex did not start")
+	}
+	if ok, err := control.Suspend(); !ok || err != nil || !control.Suspended() {
+		t.Fatalf("Suspend() = %v, %v", ok, err)
+	}
+	// Longer than both the timeout and the no-output watchdog.
+	time.Sleep(4500 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("run ended while suspended: %v", err)
+	default:
+	}
+	if ok, err := control.Resume(); !ok || err != nil || control.Suspended() {
+		t.Fatalf("Resume() = %v, %v", ok, err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run failed after resume: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("run did not finish after resume")
+	}
+	if _, err := control.Resume(); !stderrors.Is(err, ErrNotRunning) {
+		t.Fatalf("Resume() after the run err=%v, want ErrNotRunning", err)
+	}
+}
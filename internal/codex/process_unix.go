@@ -30,3 +30,22 @@ func killProcessTree(cmd *exec.Cmd) {
 	}
 	_ = cmd.Process.Kill()
 }
+
+func suspendProcessTree(cmd *exec.Cmd) error {
+	return signalProcessTree(cmd, syscall.SIGSTOP)
+}
+
+func resumeProcessTree(cmd *exec.Cmd) error {
+	return signalProcessTree(cmd, syscall.SIGCONT)
+}
+
+func signalProcessTree(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd == nil || cmd.Process == nil {
+		return ErrNotRunning
+	}
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err != nil || pgid <= 0 {
+		return cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-pgid, sig)
+}
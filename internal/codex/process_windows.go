@@ -2,7 +2,10 @@
 
 package codex
 
-import "os/exec"
+import (
+	"errors"
+	"os/exec"
+)
 
 func configureProcess(cmd *exec.Cmd) {
 	// No-op for now.
@@ -14,3 +17,13 @@ func killProcessTree(cmd *exec.Cmd) {
 	}
 	_ = cmd.Process.Kill()
 }
+
+var errSuspendUnsupported = errors.New("suspending codex is not supported on windows")
+
+func suspendProcessTree(cmd *exec.Cmd) error {
+	return errSuspendUnsupported
+}
+
+func resumeProcessTree(cmd *exec.Cmd) error {
+	return errSuspendUnsupported
+}
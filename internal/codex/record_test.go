@@ -0,0 +1,61 @@
+package codex
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/replay"
+)
+
+func TestRun_RecordAndReplay(t *testing.T) {
+	resetVersionCache()
+	t.Cleanup(resetVersionCache)
+	t.Setenv(fakeCodexEnv, "echo_args")
+	dir := t.TempDir()
+
+	opts := Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Timeout:        5 * time.Second,
+		ExecutablePath: os.Args[0],
+		RecordDir:      dir,
+	}
+	recorded, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if recorded.RecordingPath == "" {
+		t.Fatalf("RecordingPath is empty")
+	}
+	rec, err := replay.Load(recorded.RecordingPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if rec.CodexVersion != "0.46.0" {
+		t.Fatalf("CodexVersion=%q, want %q", rec.CodexVersion, "0.46.0")
+	}
+	if len(rec.Argv) < 2 || rec.Argv[1] != "exec" {
+		t.Fatalf("Argv=%v, want exec invocation", rec.Argv)
+	}
+	if len(rec.Lines) != 1 || rec.Lines[0].Stream != replay.StreamStdout {
+		t.Fatalf("Lines=%+v, want 1 stdout line", rec.Lines)
+	}
+
+	// Play the recording back through the same binary acting as codex.
+	resetVersionCache()
+	t.Setenv(fakeCodexEnv, "")
+	t.Setenv(replay.EnvFile, recorded.RecordingPath)
+	opts.RecordDir = ""
+	replayed, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("replayed Run() failed: %v", err)
+	}
+	if replayed.SessionID != recorded.SessionID || replayed.AgentMessages != recorded.AgentMessages {
+		t.Fatalf("replayed result=(%q,%q), want (%q,%q)", replayed.SessionID, replayed.AgentMessages, recorded.SessionID, recorded.AgentMessages)
+	}
+	if replayed.CodexVersion != recorded.CodexVersion {
+		t.Fatalf("replayed CodexVersion=%q, want %q", replayed.CodexVersion, recorded.CodexVersion)
+	}
+}
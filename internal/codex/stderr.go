@@ -0,0 +1,122 @@
+package codex
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Severities assigned to codex stderr lines by ClassifyStderr.
+const (
+	StderrInfo    = "info"
+	StderrWarning = "warning"
+	StderrError   = "error"
+)
+
+// outputLine is a trimmed line read from one of the codex output streams.
+type outputLine struct {
+	stream string
+	text   []byte
+}
+
+// readLines forwards lines from r to lineCh until EOF or until done is closed,
+// so the reader never outlives Run when the drain loop exits early.
+func readLines(r io.Reader, stream string, lineCh chan<- outputLine, errCh chan<- error, done <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			select {
+			case lineCh <- outputLine{stream: stream, text: bytes.TrimSpace(line)}:
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+			return
+		}
+	}
+}
+
+var (
+	// Rust tracing/env_logger output, e.g. "2025-01-01T00:00:00Z ERROR codex_core: ..." or "[WARN codex] ...".
+	stderrLogLevelPattern = regexp.MustCompile(`(?:^|[\s\[])(ERROR|WARN|WARNING|INFO|DEBUG|TRACE)(?:[\s\]:]|$)`)
+
+	stderrErrorPrefixes = []string{
+		"error:",
+		"error ",
+		"fatal:",
+		"fatal error",
+		"thread 'main' panicked",
+	}
+	stderrErrorContains = []string{
+		"panicked at",
+		"permission denied",
+		"unauthorized",
+		"401 unauthorized",
+		"rate limit",
+		"quota exceeded",
+	}
+	stderrWarningPrefixes = []string{
+		"warning:",
+		"warn:",
+		"note:",
+	}
+	stderrWarningContains = []string{
+		"deprecated",
+		"deprecation",
+	}
+)
+
+// ClassifyStderr assigns a severity to a codex stderr line based on known log formats
+// and message patterns. Unrecognized lines are StderrInfo.
+func ClassifyStderr(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return StderrInfo
+	}
+	if m := stderrLogLevelPattern.FindStringSubmatch(line); m != nil {
+		switch m[1] {
+		case "ERROR":
+			return StderrError
+		case "WARN", "WARNING":
+			return StderrWarning
+		default:
+			return StderrInfo
+		}
+	}
+
+	lower := strings.ToLower(line)
+	for _, p := range stderrErrorPrefixes {
+		if strings.HasPrefix(lower, p) {
+			return StderrError
+		}
+	}
+	for _, c := range stderrErrorContains {
+		if strings.Contains(lower, c) {
+			return StderrError
+		}
+	}
+	for _, p := range stderrWarningPrefixes {
+		if strings.HasPrefix(lower, p) {
+			return StderrWarning
+		}
+	}
+	for _, c := range stderrWarningContains {
+		if strings.Contains(lower, c) {
+			return StderrWarning
+		}
+	}
+	return StderrInfo
+}
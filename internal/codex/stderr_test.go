@@ -0,0 +1,94 @@
+package codex
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "2025-01-01T00:00:00Z ERROR codex_core::client: stream disconnected", want: StderrError},
+		{line: "2025-01-01T00:00:00Z  WARN codex_core: retrying", want: StderrWarning},
+		{line: "[INFO codex] starting", want: StderrInfo},
+		{line: "error: unexpected argument '--bogus' found", want: StderrError},
+		{line: "thread 'main' panicked at src/main.rs:1:1", want: StderrError},
+		{line: "warning: `--foo` is deprecated", want: StderrWarning},
+		{line: "The --bar flag is deprecated and will be removed", want: StderrWarning},
+		{line: "Reading prompt from stdin...", want: StderrInfo},
+		{line: "", want: StderrInfo},
+	}
+	for _, tt := range tests {
+		if got := ClassifyStderr(tt.line); got != tt.want {
+			t.Fatalf("ClassifyStderr(%q)=%q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRun_StderrNoiseDoesNotFailRun(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "stderr_noise")
+
+	var mu sync.Mutex
+	severities := make(map[string]string)
+	res, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+		OnStderrLine: func(line []byte, severity string) {
+			mu.Lock()
+			severities[string(line)] = severity
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if !res.Success || res.AgentMessages != "ok" {
+		t.Fatalf("Run() success=%v agent_messages=%q, want success with %q", res.Success, res.AgentMessages, "ok")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(severities) != 2 {
+		t.Fatalf("stderr lines=%v, want 2", severities)
+	}
+	for line, severity := range severities {
+		if strings.HasPrefix(line, "warning:") && severity != StderrWarning {
+			t.Fatalf("severity(%q)=%q, want %q", line, severity, StderrWarning)
+		}
+	}
+}
+
+func TestRun_StderrIncludedInRecentOutputOnFailure(t *testing.T) {
+	t.Setenv(fakeCodexEnv, "stderr_error")
+
+	_, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+	})
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	recent, _ := cerr.Data["recent_output"].([]string)
+	if len(recent) != 1 || !strings.HasPrefix(recent[0], "[stderr] error:") {
+		t.Fatalf("recent_output=%v, want the stderr line", cerr.Data["recent_output"])
+	}
+	stderrErrs, _ := cerr.Data["stderr_errors"].([]string)
+	if len(stderrErrs) != 1 {
+		t.Fatalf("stderr_errors=%v, want 1 entry", cerr.Data["stderr_errors"])
+	}
+}
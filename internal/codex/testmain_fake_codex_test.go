@@ -1,20 +1,74 @@
 package codex
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/replay"
+)
+
+const (
+	fakeCodexEnv        = "CODEX_MCP_FAKE_CODEX"
+	fakeCodexVersionEnv = "CODEX_MCP_FAKE_CODEX_VERSION"
+	// fakeCodexHelpEnv overrides the `exec --help` output (default fakeExecHelp).
+	fakeCodexHelpEnv = "CODEX_MCP_FAKE_CODEX_HELP"
 )
 
-const fakeCodexEnv = "CODEX_MCP_FAKE_CODEX"
+// fakeExecHelp is a trimmed `codex exec --help` listing every negotiated feature.
+const fakeExecHelp = `Run Codex non-interactively
+
+Usage: codex exec [OPTIONS] [PROMPT]
+       codex exec [OPTIONS] <COMMAND>
+
+Commands:
+  resume  Resume a previous session by id or pick the most recent with --last
+  help    Print this message or the help of the given subcommand(s)
+
+Options:
+  -c, --config <key=value>
+  -i, --image <FILE>...
+  -m, --model <MODEL>
+      --oss
+  -s, --sandbox <SANDBOX_MODE>
+  -p, --profile <CONFIG_PROFILE>
+      --dangerously-bypass-approvals-and-sandbox  Skip all confirmation prompts [aliases: --yolo]
+  -C, --cd <DIR>
+      --skip-git-repo-check
+      --add-dir <DIR>
+      --json
+  -h, --help
+`
 
 func TestMain(m *testing.M) {
-	if mode := strings.TrimSpace(os.Getenv(fakeCodexEnv)); mode != "" && len(os.Args) > 1 && os.Args[1] == "exec" {
-		runFakeCodex(mode)
-		os.Exit(0)
+	if path := strings.TrimSpace(os.Getenv(replay.EnvFile)); path != "" && len(os.Args) > 1 {
+		os.Exit(replay.RunFake(context.Background(), path, os.Args[1:], os.Stdout, os.Stderr, 0))
+	}
+	if mode := strings.TrimSpace(os.Getenv(fakeCodexEnv)); mode != "" && len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "--version":
+			version := strings.TrimSpace(os.Getenv(fakeCodexVersionEnv))
+			if version == "" {
+				version = "0.46.0"
+			}
+			fmt.Fprintln(os.Stdout, "codex-cli "+version)
+			os.Exit(0)
+		case "exec":
+			if len(os.Args) > 2 && os.Args[2] == "--help" {
+				help, ok := os.LookupEnv(fakeCodexHelpEnv)
+				if !ok {
+					help = fakeExecHelp
+				}
+				fmt.Fprint(os.Stdout, help)
+				os.Exit(0)
+			}
+			runFakeCodex(mode)
+			os.Exit(0)
+		}
 	}
 	os.Exit(m.Run())
 }
@@ -34,6 +88,10 @@ func runFakeCodex(mode string) {
 		fmt.Fprintln(os.Stdout, string(b))
 	case "sleep_no_output":
 		time.Sleep(30 * time.Second)
+	case "thread_then_reply":
+		fmt.Fprintln(os.Stdout, `{"thread_id":"t-123"}`)
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprintln(os.Stdout, `{"item":{"type":"agent_message","text":"ok"}}`)
 	case "sleep":
 		out := map[string]any{
 			"thread_id": "t-123",
@@ -45,6 +103,60 @@ func runFakeCodex(mode string) {
 		b, _ := json.Marshal(out)
 		fmt.Fprintln(os.Stdout, string(b))
 		time.Sleep(30 * time.Second)
+	case "flood":
+		for i := 0; i < 1000; i++ {
+			out := map[string]any{
+				"thread_id": "t-123",
+				"item": map[string]any{
+					"type": "agent_message",
+					"text": strings.Repeat("x", 100),
+				},
+			}
+			b, _ := json.Marshal(out)
+			fmt.Fprintln(os.Stdout, string(b))
+		}
+	case "report_nofile":
+		// Give the parent a moment to apply limits after start.
+		time.Sleep(300 * time.Millisecond)
+		text := ""
+		if data, err := os.ReadFile("/proc/self/limits"); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "Max open files") {
+					text = strings.Join(strings.Fields(line), " ")
+				}
+			}
+		}
+		out := map[string]any{
+			"thread_id": "t-123",
+			"item": map[string]any{
+				"type": "agent_message",
+				"text": text,
+			},
+		}
+		b, _ := json.Marshal(out)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "legacy_events":
+		fmt.Fprintln(os.Stdout, `{"id":"0","msg":{"type":"session_configured","session_id":"t-legacy"}}`)
+		fmt.Fprintln(os.Stdout, `{"id":"1","msg":{"type":"agent_message","message":"ok"}}`)
+	case "stderr_noise":
+		fmt.Fprintln(os.Stderr, "warning: `--foo` is deprecated, use `--bar` instead")
+		fmt.Fprintln(os.Stderr, "2025-01-01T00:00:00.000000Z  INFO codex_core::config: loaded config")
+		out := map[string]any{
+			"thread_id": "t-123",
+			"item": map[string]any{
+				"type": "agent_message",
+				"text": "ok",
+			},
+		}
+		b, _ := json.Marshal(out)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "usage":
+		fmt.Fprintln(os.Stdout, `{"type":"thread.started","thread_id":"t-usage"}`)
+		fmt.Fprintln(os.Stdout, `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`)
+		fmt.Fprintln(os.Stdout, `{"type":"turn.completed","usage":{"input_tokens":120,"cached_input_tokens":20,"output_tokens":30,"reasoning_output_tokens":7}}`)
+	case "stderr_error":
+		fmt.Fprintln(os.Stderr, "error: unexpected argument '--bogus' found")
+		os.Exit(2)
 	case "invalid_json":
 		fmt.Fprintln(os.Stdout, "not-json")
 		out := map[string]any{
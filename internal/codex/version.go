@@ -0,0 +1,232 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const versionProbeTimeout = 5 * time.Second
+
+// Features negotiated against the installed codex CLI.
+const (
+	FeatureJSON             = "json"
+	FeatureSandbox          = "sandbox"
+	FeatureCd               = "cd"
+	FeatureImage            = "image"
+	FeatureModel            = "model"
+	FeatureProfile          = "profile"
+	FeatureYolo             = "yolo"
+	FeatureSkipGitRepoCheck = "skip_git_repo_check"
+	FeatureResume           = "resume"
+	FeatureAddDir           = "add_dir"
+	FeatureOSS              = "oss"
+	FeatureConfigOverride   = "config_override"
+)
+
+// featureFlags maps each flag-backed feature to the long option `codex exec --help` lists for it.
+// FeatureResume is the `resume` subcommand and is matched separately.
+var featureFlags = map[string]string{
+	FeatureJSON:             "--json",
+	FeatureSandbox:          "--sandbox",
+	FeatureCd:               "--cd",
+	FeatureImage:            "--image",
+	FeatureModel:            "--model",
+	FeatureProfile:          "--profile",
+	FeatureYolo:             "--yolo",
+	FeatureSkipGitRepoCheck: "--skip-git-repo-check",
+	FeatureAddDir:           "--add-dir",
+	FeatureOSS:              "--oss",
+	FeatureConfigOverride:   "--config",
+}
+
+var (
+	helpFlagPattern    = regexp.MustCompile(`--[a-z0-9][a-z0-9-]*`)
+	helpCommandPattern = regexp.MustCompile(`(?m)^\s+resume\b`)
+)
+
+// Version is a parsed codex CLI version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Raw is the trimmed `codex --version` output.
+	Raw string
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion extracts the first semantic version from `codex --version` output
+// (e.g. "codex-cli 0.46.0").
+func ParseVersion(s string) (Version, bool) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return Version{Major: major, Minor: minor, Patch: patch, Raw: strings.TrimSpace(s)}, true
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v >= o.
+func (v Version) AtLeast(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// Capabilities describes what the installed codex CLI supports.
+type Capabilities struct {
+	// Known is false when `codex exec --help` could not be read; all features are then assumed.
+	Known bool
+	// Version is the parsed `codex --version` output; zero when it could not be detected.
+	Version  Version
+	features map[string]bool
+}
+
+// ParseExecHelp derives capabilities from `codex exec --help` output: a feature is
+// supported when its flag (or, for resume, its subcommand) is listed. ok is false
+// when the output doesn't look like help text at all.
+func ParseExecHelp(help string) (caps Capabilities, ok bool) {
+	listed := make(map[string]bool)
+	for _, flag := range helpFlagPattern.FindAllString(help, -1) {
+		listed[flag] = true
+	}
+	if len(listed) == 0 {
+		return Capabilities{}, false
+	}
+	caps = Capabilities{Known: true, features: make(map[string]bool, len(featureFlags)+1)}
+	for name, flag := range featureFlags {
+		caps.features[name] = listed[flag]
+	}
+	caps.features[FeatureResume] = helpCommandPattern.MatchString(help)
+	return caps, true
+}
+
+// HasVersion reports whether the CLI version was detected.
+func (c Capabilities) HasVersion() bool {
+	return c.Version.Raw != ""
+}
+
+// Supports reports whether a feature is available.
+func (c Capabilities) Supports(feature string) bool {
+	if !c.Known {
+		return true
+	}
+	return c.features[feature]
+}
+
+// Features returns the full feature map (nil when the features are unknown).
+func (c Capabilities) Features() map[string]bool {
+	if !c.Known {
+		return nil
+	}
+	out := make(map[string]bool, len(c.features))
+	for k, v := range c.features {
+		out[k] = v
+	}
+	return out
+}
+
+type versionCacheEntry struct {
+	modTime time.Time
+	size    int64
+	caps    Capabilities
+	err     error
+}
+
+var versionCache = struct {
+	mu      sync.Mutex
+	entries map[string]versionCacheEntry
+}{entries: make(map[string]versionCacheEntry)}
+
+// DetectCapabilities probes `codex --version` and `codex exec --help` for the executable
+// at path and returns its capabilities. Results are cached per path and refreshed when the binary changes
+// (size or modification time). When the help can't be read the returned Capabilities
+// are unknown (everything assumed supported); err describes any probe failure.
+func DetectCapabilities(ctx context.Context, path string) (Capabilities, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		lookPath, err := exec.LookPath("codex")
+		if err != nil {
+			return Capabilities{}, err
+		}
+		path = lookPath
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	versionCache.mu.Lock()
+	entry, ok := versionCache.entries[path]
+	versionCache.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.caps, entry.err
+	}
+
+	caps, probeErr := probeCapabilities(ctx, path)
+	if ctx.Err() != nil {
+		// Don't cache failures caused by the caller going away.
+		return caps, probeErr
+	}
+
+	versionCache.mu.Lock()
+	versionCache.entries[path] = versionCacheEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		caps:    caps,
+		err:     probeErr,
+	}
+	versionCache.mu.Unlock()
+	return caps, probeErr
+}
+
+func probeCapabilities(ctx context.Context, path string) (Capabilities, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, versionProbeTimeout)
+	defer cancel()
+
+	var errs []error
+	help, err := exec.CommandContext(probeCtx, path, "exec", "--help").Output()
+	caps, ok := ParseExecHelp(string(help))
+	switch {
+	case err != nil:
+		caps = Capabilities{}
+		errs = append(errs, fmt.Errorf("codex exec --help failed: %w", err))
+	case !ok:
+		errs = append(errs, fmt.Errorf("unrecognized codex exec --help output"))
+	}
+
+	out, err := exec.CommandContext(probeCtx, path, "--version").Output()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("codex --version failed: %w", err))
+	} else if v, ok := ParseVersion(string(out)); ok {
+		caps.Version = v
+	} else {
+		errs = append(errs, fmt.Errorf("unrecognized codex --version output: %q", strings.TrimSpace(string(out))))
+	}
+	return caps, errors.Join(errs...)
+}
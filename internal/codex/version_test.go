@@ -0,0 +1,222 @@
+package codex
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// oldExecHelp is `codex exec --help` from a CLI without resume, --yolo,
+// --skip-git-repo-check or --add-dir.
+const oldExecHelp = `Usage: codex exec [OPTIONS] [PROMPT]
+
+Options:
+  -c, --config <key=value>
+  -i, --image <FILE>...
+  -m, --model <MODEL>
+      --oss
+  -s, --sandbox <SANDBOX_MODE>
+  -p, --profile <CONFIG_PROFILE>
+      --dangerously-bypass-approvals-and-sandbox
+  -C, --cd <DIR>
+      --json
+  -h, --help
+`
+
+func mustParseExecHelp(t *testing.T, help string) Capabilities {
+	t.Helper()
+	caps, ok := ParseExecHelp(help)
+	if !ok {
+		t.Fatalf("ParseExecHelp(%q) not recognized", help)
+	}
+	return caps
+}
+
+func resetVersionCache() {
+	versionCache.mu.Lock()
+	defer versionCache.mu.Unlock()
+	versionCache.entries = make(map[string]versionCacheEntry)
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"codex-cli 0.46.0\n", "0.46.0", true},
+		{"codex 1.2.3-beta", "1.2.3", true},
+		{"0.1.0", "0.1.0", true},
+		{"codex-cli dev", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, ok := ParseVersion(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ParseVersion(%q) ok=%v, want %v", tt.in, ok, tt.ok)
+			}
+			if ok && v.String() != tt.want {
+				t.Fatalf("ParseVersion(%q)=%q, want %q", tt.in, v.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilities_UnknownAssumesSupport(t *testing.T) {
+	var caps Capabilities
+	if !caps.Supports(FeatureResume) || !caps.Supports(FeatureAddDir) {
+		t.Fatalf("unknown capabilities should assume full support")
+	}
+}
+
+func TestParseExecHelp(t *testing.T) {
+	full := mustParseExecHelp(t, fakeExecHelp)
+	for name := range featureFlags {
+		if !full.Supports(name) {
+			t.Fatalf("full help: %s unsupported", name)
+		}
+	}
+	if !full.Supports(FeatureResume) {
+		t.Fatalf("full help: resume unsupported")
+	}
+
+	old := mustParseExecHelp(t, oldExecHelp)
+	for _, name := range []string{FeatureResume, FeatureYolo, FeatureSkipGitRepoCheck, FeatureAddDir} {
+		if old.Supports(name) {
+			t.Fatalf("old help: %s should be unsupported", name)
+		}
+	}
+	for _, name := range []string{FeatureJSON, FeatureSandbox, FeatureCd, FeatureConfigOverride} {
+		if !old.Supports(name) {
+			t.Fatalf("old help: %s should be supported", name)
+		}
+	}
+
+	if _, ok := ParseExecHelp(`{"thread_id":"t-1"}`); ok {
+		t.Fatalf("JSONL output should not be recognized as help")
+	}
+}
+
+func TestBuildArgs_DegradesForOldCLI(t *testing.T) {
+	caps := mustParseExecHelp(t, oldExecHelp)
+	args, err := buildArgs(Options{
+		WorkingDir:       "/repo",
+		Yolo:             true,
+		SkipGitRepoCheck: true,
+	}, SandboxReadOnly, "hi", caps)
+	if err != nil {
+		t.Fatalf("buildArgs() failed: %v", err)
+	}
+	want := []string{
+		"exec",
+		"--sandbox", "read-only",
+		"--cd", "/repo",
+		"--json",
+		"--dangerously-bypass-approvals-and-sandbox",
+		"--", "hi",
+	}
+	if len(args) != len(want) {
+		t.Fatalf("args=%v, want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("args[%d]=%q, want %q\nargs=%v", i, args[i], want[i], args)
+		}
+	}
+}
+
+func TestRun_ResumeUnsupported_ReturnsStructuredError(t *testing.T) {
+	resetVersionCache()
+	t.Cleanup(resetVersionCache)
+	t.Setenv(fakeCodexEnv, "echo_args")
+	t.Setenv(fakeCodexVersionEnv, "0.30.0")
+	t.Setenv(fakeCodexHelpEnv, oldExecHelp)
+
+	_, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		SessionID:      "sess-1",
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	if cerr.Code != cerrors.CodexVersionUnsupported {
+		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.CodexVersionUnsupported)
+	}
+	if cerr.Data["feature"] != FeatureResume || cerr.Data["version"] != "0.30.0" {
+		t.Fatalf("data=%v", cerr.Data)
+	}
+}
+
+func TestRun_LegacyEventFormat(t *testing.T) {
+	resetVersionCache()
+	t.Cleanup(resetVersionCache)
+	t.Setenv(fakeCodexEnv, "legacy_events")
+	t.Setenv(fakeCodexVersionEnv, "0.40.0")
+	t.Setenv(fakeCodexHelpEnv, oldExecHelp)
+
+	res, err := Run(context.Background(), Options{
+		Prompt:         "hi",
+		WorkingDir:     ".",
+		Sandbox:        SandboxReadOnly,
+		ExecutablePath: os.Args[0],
+		Timeout:        5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if res.SessionID != "t-legacy" || res.AgentMessages != "ok" {
+		t.Fatalf("SessionID=%q AgentMessages=%q", res.SessionID, res.AgentMessages)
+	}
+	if res.CodexVersion != "0.40.0" {
+		t.Fatalf("CodexVersion=%q, want %q", res.CodexVersion, "0.40.0")
+	}
+}
+
+func TestDetectCapabilities_RefreshesWhenBinaryChanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts not supported")
+	}
+	resetVersionCache()
+	t.Cleanup(resetVersionCache)
+
+	path := filepath.Join(t.TempDir(), "codex")
+	write := func(version string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("#!/bin/sh\nif [ \"$1\" = exec ]; then echo '  --json'; else echo codex-cli "+version+"; fi\n"), 0o755); err != nil {
+			t.Fatalf("write script: %v", err)
+		}
+	}
+
+	write("0.1.0")
+	caps, err := DetectCapabilities(context.Background(), path)
+	if err != nil {
+		t.Fatalf("DetectCapabilities() failed: %v", err)
+	}
+	if caps.Version.String() != "0.1.0" {
+		t.Fatalf("version=%q, want %q", caps.Version.String(), "0.1.0")
+	}
+
+	write("0.50.10")
+	caps, err = DetectCapabilities(context.Background(), path)
+	if err != nil {
+		t.Fatalf("DetectCapabilities() failed: %v", err)
+	}
+	if caps.Version.String() != "0.50.10" {
+		t.Fatalf("version=%q, want %q after binary changed", caps.Version.String(), "0.50.10")
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -10,10 +12,13 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `toml:"server"`
-	Codex    CodexConfig    `toml:"codex"`
-	Security SecurityConfig `toml:"security"`
-	Logging  logging.Config `toml:"logging"`
+	Server   ServerConfig    `toml:"server"`
+	Codex    CodexConfig     `toml:"codex"`
+	Security SecurityConfig  `toml:"security"`
+	Sessions SessionsConfig  `toml:"sessions"`
+	Commit   CommitConfig    `toml:"commit"`
+	Logging  logging.Config  `toml:"logging"`
+	Webhooks []WebhookConfig `toml:"webhooks"`
 }
 
 type ServerConfig struct {
@@ -35,8 +40,120 @@ type CodexConfig struct {
 	WorkdirLockMode string `toml:"workdir_lock_mode"`
 	// WorkdirLockTimeoutSeconds bounds waiting in queue mode (0 = wait until ctx cancel/timeout).
 	WorkdirLockTimeoutSeconds int `toml:"workdir_lock_timeout_seconds"`
+
+	// ReceiptTimeoutSeconds bounds the Git commands that snapshot the work tree before a
+	// run (`git add -A` into a scratch index) and collect its change receipt afterwards.
+	// Large repositories may need more than the default.
+	ReceiptTimeoutSeconds int `toml:"receipt_timeout_seconds"`
+
+	// RecordDir enables replay recordings of every codex run under this directory (empty = disabled).
+	// Recordings contain prompts and model output; treat them as sensitive.
+	RecordDir string `toml:"record_dir"`
+
+	// SessionsDir is where the codex CLI stores thread rollouts (used by fork_session,
+	// list_codex_threads and import_codex_thread).
+	// Empty uses $CODEX_HOME/sessions or ~/.codex/sessions.
+	SessionsDir string `toml:"sessions_dir"`
+
+	// WorktreeDir is where isolation=worktree runs create their worktrees.
+	// Empty uses codex-mcp/worktrees in the repository's Git directory.
+	WorktreeDir string `toml:"worktree_dir"`
+
+	Limits LimitsConfig `toml:"limits"`
+}
+
+// LimitsConfig bounds the resources of each codex process tree. Zero disables a limit.
+// See codex.Limits for how each limit is enforced.
+type LimitsConfig struct {
+	MaxMemoryMB    int `toml:"max_memory_mb"`
+	MaxCPUSeconds  int `toml:"max_cpu_seconds"`
+	MaxOpenFiles   int `toml:"max_open_files"`
+	MaxProcesses   int `toml:"max_processes"`
+	MaxOutputBytes int `toml:"max_output_bytes"`
+
+	// CgroupParent is a delegated cgroup v2 directory (Linux only), required for memory
+	// and process limits to use a cgroup, and for max_cpu_seconds to cover the whole
+	// process tree rather than each process.
+	CgroupParent string `toml:"cgroup_parent"`
+}
+
+// SessionsConfig controls session tracking.
+type SessionsConfig struct {
+	// StateDir persists sessions (state, change receipts, diagnostics) across server
+	// restarts. Empty keeps sessions in memory only.
+	StateDir string `toml:"state_dir"`
+
+	// MaxRunning caps concurrently running codex sessions; further runs wait in the queue.
+	MaxRunning int `toml:"max_running"`
+	// QueueSize bounds the number of waiting runs (0 = reject when MaxRunning is reached).
+	QueueSize int `toml:"queue_size"`
+	// TTLSeconds is how long finished sessions stay visible.
+	TTLSeconds int `toml:"ttl_seconds"`
+	// MaxQueueWaitSeconds bounds the time a run waits for a slot (0 = until cancelled).
+	MaxQueueWaitSeconds int `toml:"max_queue_wait_seconds"`
+	// FairShare groups queued runs so that one group cannot starve the others.
+	// Valid values: "client" (per MCP client session), "workdir" (per repository/workdir), "none".
+	FairShare string `toml:"fair_share"`
+
+	// Budget caps apply to every thread across all of its turns (0 = no cap). A request
+	// may ask for a lower budget but never a higher one.
+	BudgetMaxTokens           int64 `toml:"budget_max_tokens"`
+	BudgetMaxExecutionSeconds int   `toml:"budget_max_execution_seconds"`
+	BudgetMaxTurns            int   `toml:"budget_max_turns"`
+
+	// DiagnosticsDir spills every diagnostic entry, untruncated, to per-session log
+	// files (empty = memory only). Files rotate at DiagnosticsFileMaxMB; each session
+	// keeps at most DiagnosticsMaxFiles of them and all logs together at most
+	// DiagnosticsMaxTotalMB (oldest files are deleted first).
+	DiagnosticsDir        string `toml:"diagnostics_dir"`
+	DiagnosticsFileMaxMB  int    `toml:"diagnostics_file_max_mb"`
+	DiagnosticsMaxFiles   int    `toml:"diagnostics_max_files"`
+	DiagnosticsMaxTotalMB int    `toml:"diagnostics_max_total_mb"`
+
+	// MaxCheckpoints caps the turn checkpoints kept per session; older ones are
+	// deleted after each run (0 = keep all). Every checkpoint of a session is deleted
+	// when it expires.
+	MaxCheckpoints int `toml:"max_checkpoints"`
+}
+
+// ValidFairShareModes lists the accepted sessions.fair_share values.
+var ValidFairShareModes = []string{"client", "workdir", "none"}
+
+// CommitConfig configures the commits made for commit_mode runs.
+type CommitConfig struct {
+	// AuthorName and AuthorEmail are the author and committer of the commits.
+	AuthorName  string `toml:"author_name"`
+	AuthorEmail string `toml:"author_email"`
+	// BranchPrefix names the branches of commit_mode=commit-to-branch:
+	// <prefix><SESSION_ID>.
+	BranchPrefix string `toml:"branch_prefix"`
+	// Trailers are extra "Key: value" lines added to every commit message, after the
+	// Codex-Session-Id and Codex-Turn trailers.
+	Trailers []string `toml:"trailers"`
+}
+
+// WebhookConfig is one [[webhooks]] entry: session lifecycle events are POSTed to URL
+// as JSON, signed with HMAC-SHA256 when a secret is set.
+type WebhookConfig struct {
+	URL string `toml:"url"`
+	// Events filters the delivered event types (empty = all). See ValidWebhookEvents.
+	Events []string `toml:"events"`
+	// Secret signs deliveries (X-Codex-MCP-Signature). SecretEnv names an environment
+	// variable holding the secret instead, keeping it out of the config file; the
+	// config is rejected when that variable is unset.
+	Secret    string `toml:"secret"`
+	SecretEnv string `toml:"secret_env"`
+	// MaxAttempts bounds deliveries of one event (0 = 5). Failed attempts (network
+	// errors, 429 and 5xx) are retried after BackoffSeconds (0 = 1), doubling up to 60s.
+	MaxAttempts    int `toml:"max_attempts"`
+	BackoffSeconds int `toml:"backoff_seconds"`
+	// TimeoutSeconds bounds a single delivery attempt (0 = 10).
+	TimeoutSeconds int `toml:"timeout_seconds"`
 }
 
+// ValidWebhookEvents lists the accepted webhooks[].events values.
+var ValidWebhookEvents = []string{"started", "completed", "failed", "cancelled", "receipt-ready"}
+
 type SecurityConfig struct {
 	AllowedModels       []string `toml:"allowed_models"`
 	AllowedProfiles     []string `toml:"allowed_profiles"`
@@ -44,6 +161,20 @@ type SecurityConfig struct {
 	AllowedSandboxModes []string `toml:"allowed_sandbox_modes"`
 	AllowedWorkDirs     []string `toml:"allowed_work_dirs"`
 	DisableYolo         bool     `toml:"disable_yolo"`
+
+	// AllowedAddDirs are directory prefixes that may be passed as add_dir (empty = deny all).
+	AllowedAddDirs []string `toml:"allowed_add_dirs"`
+	// AllowWebSearch permits enabling the web search tool per call.
+	AllowWebSearch bool `toml:"allow_web_search"`
+	// AllowedReasoningEfforts lists permitted reasoning_effort values ("*" allows any valid value).
+	AllowedReasoningEfforts []string `toml:"allowed_reasoning_efforts"`
+	// AllowedApprovalPolicies lists permitted approval_policy values (empty = deny all).
+	AllowedApprovalPolicies []string `toml:"allowed_approval_policies"`
+	// AllowOSS permits the local open-source provider (--oss).
+	AllowOSS bool `toml:"allow_oss"`
+	// AllowSandboxEscalation permits resuming a thread with a more permissive sandbox
+	// than it was started with.
+	AllowSandboxEscalation bool `toml:"allow_sandbox_escalation"`
 }
 
 func Default() *Config {
@@ -60,6 +191,7 @@ func Default() *Config {
 			ExecutablePath:                "",
 			WorkdirLockMode:               "reject",
 			WorkdirLockTimeoutSeconds:     0,
+			ReceiptTimeoutSeconds:         30,
 		},
 		Security: SecurityConfig{
 			AllowedModels:       nil, // deny all by default
@@ -68,6 +200,31 @@ func Default() *Config {
 			AllowedSandboxModes: []string{codex.SandboxReadOnly, codex.SandboxWorkspaceWrite, codex.SandboxDangerFullAccess},
 			AllowedWorkDirs:     nil, // allow all by default
 			DisableYolo:         false,
+
+			AllowedAddDirs:          nil, // deny all by default
+			AllowWebSearch:          false,
+			AllowedReasoningEfforts: append([]string(nil), codex.ValidReasoningEfforts...),
+			AllowedApprovalPolicies: nil, // deny all by default
+			AllowOSS:                false,
+			AllowSandboxEscalation:  false,
+		},
+		Sessions: SessionsConfig{
+			MaxRunning:          4,
+			QueueSize:           16,
+			TTLSeconds:          3600,
+			MaxQueueWaitSeconds: 600,
+			FairShare:           "client",
+
+			DiagnosticsFileMaxMB:  4,
+			DiagnosticsMaxFiles:   8,
+			DiagnosticsMaxTotalMB: 256,
+
+			MaxCheckpoints: 20,
+		},
+		Commit: CommitConfig{
+			AuthorName:   "codex-mcp",
+			AuthorEmail:  "codex-mcp@localhost",
+			BranchPrefix: "codex-mcp/",
 		},
 		Logging: logging.DefaultConfig(),
 	}
@@ -109,6 +266,65 @@ func (c *Config) Validate() error {
 	if c.Codex.WorkdirLockTimeoutSeconds < 0 {
 		return fmt.Errorf("codex.workdir_lock_timeout_seconds must be >= 0")
 	}
+	if c.Codex.ReceiptTimeoutSeconds <= 0 {
+		return fmt.Errorf("codex.receipt_timeout_seconds must be > 0")
+	}
+	if c.Codex.Limits.MaxMemoryMB < 0 {
+		return fmt.Errorf("codex.limits.max_memory_mb must be >= 0")
+	}
+	if c.Codex.Limits.MaxCPUSeconds < 0 {
+		return fmt.Errorf("codex.limits.max_cpu_seconds must be >= 0")
+	}
+	if c.Codex.Limits.MaxOpenFiles < 0 {
+		return fmt.Errorf("codex.limits.max_open_files must be >= 0")
+	}
+	if c.Codex.Limits.MaxProcesses < 0 {
+		return fmt.Errorf("codex.limits.max_processes must be >= 0")
+	}
+	if c.Codex.Limits.MaxOutputBytes < 0 {
+		return fmt.Errorf("codex.limits.max_output_bytes must be >= 0")
+	}
+
+	if c.Sessions.MaxRunning <= 0 {
+		return fmt.Errorf("sessions.max_running must be > 0")
+	}
+	if c.Sessions.QueueSize < 0 {
+		return fmt.Errorf("sessions.queue_size must be >= 0")
+	}
+	if c.Sessions.TTLSeconds <= 0 {
+		return fmt.Errorf("sessions.ttl_seconds must be > 0")
+	}
+	if c.Sessions.MaxQueueWaitSeconds < 0 {
+		return fmt.Errorf("sessions.max_queue_wait_seconds must be >= 0")
+	}
+	if strings.TrimSpace(c.Sessions.FairShare) == "" {
+		c.Sessions.FairShare = "client"
+	}
+	c.Sessions.FairShare = strings.ToLower(strings.TrimSpace(c.Sessions.FairShare))
+	if c.Sessions.BudgetMaxTokens < 0 {
+		return fmt.Errorf("sessions.budget_max_tokens must be >= 0")
+	}
+	if c.Sessions.BudgetMaxExecutionSeconds < 0 {
+		return fmt.Errorf("sessions.budget_max_execution_seconds must be >= 0")
+	}
+	if c.Sessions.BudgetMaxTurns < 0 {
+		return fmt.Errorf("sessions.budget_max_turns must be >= 0")
+	}
+	if c.Sessions.DiagnosticsFileMaxMB <= 0 {
+		return fmt.Errorf("sessions.diagnostics_file_max_mb must be > 0")
+	}
+	if c.Sessions.DiagnosticsMaxFiles <= 0 {
+		return fmt.Errorf("sessions.diagnostics_max_files must be > 0")
+	}
+	if c.Sessions.DiagnosticsMaxTotalMB < c.Sessions.DiagnosticsFileMaxMB {
+		return fmt.Errorf("sessions.diagnostics_max_total_mb must be >= sessions.diagnostics_file_max_mb")
+	}
+	if c.Sessions.MaxCheckpoints < 0 {
+		return fmt.Errorf("sessions.max_checkpoints must be >= 0")
+	}
+	if !containsString(ValidFairShareModes, c.Sessions.FairShare) {
+		return fmt.Errorf("sessions.fair_share must be one of %v", ValidFairShareModes)
+	}
 
 	if c.Security.DefaultSandbox == "" {
 		return fmt.Errorf("security.default_sandbox is required")
@@ -133,6 +349,55 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("security.allowed_work_dirs contains an empty entry")
 		}
 	}
+	for _, dir := range c.Security.AllowedAddDirs {
+		if strings.TrimSpace(dir) == "" {
+			return fmt.Errorf("security.allowed_add_dirs contains an empty entry")
+		}
+	}
+	for _, effort := range c.Security.AllowedReasoningEfforts {
+		if effort != "*" && !containsString(codex.ValidReasoningEfforts, effort) {
+			return fmt.Errorf("security.allowed_reasoning_efforts contains invalid value %q (valid: %v)", effort, codex.ValidReasoningEfforts)
+		}
+	}
+	for _, policy := range c.Security.AllowedApprovalPolicies {
+		if policy != "*" && !containsString(codex.ValidApprovalPolicies, policy) {
+			return fmt.Errorf("security.allowed_approval_policies contains invalid value %q (valid: %v)", policy, codex.ValidApprovalPolicies)
+		}
+	}
+
+	if strings.TrimSpace(c.Commit.AuthorName) == "" || strings.TrimSpace(c.Commit.AuthorEmail) == "" {
+		return fmt.Errorf("commit.author_name and commit.author_email are required")
+	}
+	for i, trailer := range c.Commit.Trailers {
+		if key, value, ok := strings.Cut(trailer, ":"); !ok || strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") || strings.TrimSpace(value) == "" || strings.Contains(trailer, "\n") {
+			return fmt.Errorf("commit.trailers[%d] must be a \"Key: value\" line", i)
+		}
+	}
+
+	for i, hook := range c.Webhooks {
+		u, err := url.Parse(strings.TrimSpace(hook.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhooks[%d].url must be an http(s) URL", i)
+		}
+		for _, ev := range hook.Events {
+			if !containsString(ValidWebhookEvents, ev) {
+				return fmt.Errorf("webhooks[%d].events contains invalid value %q (valid: %v)", i, ev, ValidWebhookEvents)
+			}
+		}
+		// A missing secret must not silently downgrade deliveries to unsigned ones.
+		if env := strings.TrimSpace(hook.SecretEnv); env != "" && os.Getenv(env) == "" {
+			return fmt.Errorf("webhooks[%d].secret_env: environment variable %s is not set", i, env)
+		}
+		if hook.MaxAttempts < 0 {
+			return fmt.Errorf("webhooks[%d].max_attempts must be >= 0", i)
+		}
+		if hook.BackoffSeconds < 0 {
+			return fmt.Errorf("webhooks[%d].backoff_seconds must be >= 0", i)
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("webhooks[%d].timeout_seconds must be >= 0", i)
+		}
+	}
 
 	if strings.EqualFold(strings.TrimSpace(c.Logging.Output), "file") && strings.TrimSpace(c.Logging.FilePath) == "" {
 		return fmt.Errorf("logging.file_path is required when logging.output=file")
@@ -141,6 +406,18 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// CodexLimits converts the config section into codex.Limits.
+func (l LimitsConfig) CodexLimits() codex.Limits {
+	return codex.Limits{
+		MaxMemoryMB:    l.MaxMemoryMB,
+		MaxCPUSeconds:  l.MaxCPUSeconds,
+		MaxOpenFiles:   l.MaxOpenFiles,
+		MaxProcesses:   l.MaxProcesses,
+		MaxOutputBytes: int64(l.MaxOutputBytes),
+		CgroupParent:   strings.TrimSpace(l.CgroupParent),
+	}
+}
+
 func (s SecurityConfig) IsModelAllowed(model string) bool {
 	return isAllowlisted(s.AllowedModels, model)
 }
@@ -157,9 +434,26 @@ func (s SecurityConfig) IsWorkDirAllowed(workDir string) bool {
 	if len(s.AllowedWorkDirs) == 0 {
 		return true
 	}
+	return hasPathPrefix(s.AllowedWorkDirs, workDir)
+}
+
+// IsAddDirAllowed reports whether dir may be passed as an extra writable directory.
+// Unlike work dirs, an empty allowlist denies everything.
+func (s SecurityConfig) IsAddDirAllowed(dir string) bool {
+	return hasPathPrefix(s.AllowedAddDirs, dir)
+}
+
+func (s SecurityConfig) IsReasoningEffortAllowed(effort string) bool {
+	return isAllowlisted(s.AllowedReasoningEfforts, effort)
+}
+
+func (s SecurityConfig) IsApprovalPolicyAllowed(policy string) bool {
+	return isAllowlisted(s.AllowedApprovalPolicies, policy)
+}
 
-	path := filepath.Clean(workDir)
-	for _, prefix := range s.AllowedWorkDirs {
+func hasPathPrefix(prefixes []string, p string) bool {
+	path := filepath.Clean(p)
+	for _, prefix := range prefixes {
 		prefix = filepath.Clean(prefix)
 		if prefix == "." || prefix == string(filepath.Separator) {
 			return true
@@ -50,6 +50,7 @@ func TestLoadFromEnv(t *testing.T) {
 	t.Setenv(envServerName, "My Server")
 	t.Setenv(envDefaultTimeout, "10")
 	t.Setenv(envAllowedModels, "a,b")
+	t.Setenv(envReceiptTimeout, "120")
 
 	cfg.LoadFromEnv()
 
@@ -62,6 +63,9 @@ func TestLoadFromEnv(t *testing.T) {
 	if len(cfg.Security.AllowedModels) != 2 || cfg.Security.AllowedModels[0] != "a" || cfg.Security.AllowedModels[1] != "b" {
 		t.Fatalf("allowed_models=%v, want [a b]", cfg.Security.AllowedModels)
 	}
+	if cfg.Codex.ReceiptTimeoutSeconds != 120 {
+		t.Fatalf("codex.receipt_timeout_seconds=%d, want 120", cfg.Codex.ReceiptTimeoutSeconds)
+	}
 }
 
 func TestLoad_ConfigFile(t *testing.T) {
@@ -76,6 +80,10 @@ version = "1.2.3"
 default_timeout_seconds = 12
 max_timeout_seconds = 34
 
+[codex.limits]
+max_memory_mb = 2048
+max_output_bytes = 1048576
+
 [security]
 allowed_models = ["*"]
 default_sandbox = "read-only"
@@ -102,4 +110,177 @@ output = "stderr"
 	if !cfg.Security.IsModelAllowed("anything") {
 		t.Fatalf("expected wildcard models to allow any")
 	}
+	if cfg.Codex.Limits.MaxMemoryMB != 2048 || cfg.Codex.Limits.MaxOutputBytes != 1048576 {
+		t.Fatalf("codex.limits=%+v", cfg.Codex.Limits)
+	}
+}
+
+func TestValidate_RejectsNegativeLimits(t *testing.T) {
+	cfg := Default()
+	cfg.Codex.Limits.MaxProcesses = -1
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected negative codex.limits.max_processes to be rejected")
+	}
+}
+
+func TestSecurityConfig_ExecOptionAllowlists(t *testing.T) {
+	sec := Default().Security
+
+	if sec.IsAddDirAllowed(filepath.Join(string(filepath.Separator), "tmp")) {
+		t.Fatalf("expected add_dir to be denied when allowlist is empty")
+	}
+	sec.AllowedAddDirs = []string{filepath.Join(string(filepath.Separator), "tmp", "extra")}
+	if !sec.IsAddDirAllowed(filepath.Join(string(filepath.Separator), "tmp", "extra", "a")) {
+		t.Fatalf("expected child of allowlisted add_dir to be allowed")
+	}
+
+	if !sec.IsReasoningEffortAllowed("high") {
+		t.Fatalf("expected default allowlist to allow every reasoning effort")
+	}
+	if sec.IsApprovalPolicyAllowed("never") {
+		t.Fatalf("expected approval_policy to be denied by default")
+	}
+	sec.AllowedApprovalPolicies = []string{"on-request"}
+	if !sec.IsApprovalPolicyAllowed("on-request") || sec.IsApprovalPolicyAllowed("never") {
+		t.Fatalf("expected only allowlisted approval policy to be allowed")
+	}
+}
+
+func TestValidate_RejectsInvalidExecOptionAllowlists(t *testing.T) {
+	cfg := Default()
+	cfg.Security.AllowedReasoningEfforts = []string{"extreme"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected invalid reasoning effort to be rejected")
+	}
+
+	cfg = Default()
+	cfg.Security.AllowedApprovalPolicies = []string{"sometimes"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected invalid approval policy to be rejected")
+	}
+}
+
+func TestLoadFromEnv_ExecOptions(t *testing.T) {
+	cfg := Default()
+	t.Setenv(envAllowedAddDirs, "/a,/b")
+	t.Setenv(envAllowWebSearch, "true")
+	t.Setenv(envAllowedApprovalPolicies, "never")
+	t.Setenv(envAllowOSS, "1")
+
+	cfg.LoadFromEnv()
+
+	if len(cfg.Security.AllowedAddDirs) != 2 {
+		t.Fatalf("allowed_add_dirs=%v, want [/a /b]", cfg.Security.AllowedAddDirs)
+	}
+	if !cfg.Security.AllowWebSearch || !cfg.Security.AllowOSS {
+		t.Fatalf("allow_web_search=%v allow_oss=%v, want true", cfg.Security.AllowWebSearch, cfg.Security.AllowOSS)
+	}
+	if len(cfg.Security.AllowedApprovalPolicies) != 1 || cfg.Security.AllowedApprovalPolicies[0] != "never" {
+		t.Fatalf("allowed_approval_policies=%v, want [never]", cfg.Security.AllowedApprovalPolicies)
+	}
+}
+
+func TestLoadFromEnv_Sessions(t *testing.T) {
+	cfg := Default()
+	t.Setenv(envMaxRunning, "8")
+	t.Setenv(envQueueSize, "0")
+	t.Setenv(envSessionTTL, "120")
+	t.Setenv(envMaxQueueWait, "30")
+	t.Setenv(envFairShare, "WorkDir")
+	t.Setenv(envBudgetMaxTokens, "500000")
+	t.Setenv(envBudgetMaxExecution, "3600")
+	t.Setenv(envBudgetMaxTurns, "20")
+	t.Setenv(envDiagnosticsDir, "/var/lib/codex-mcp/diag")
+	t.Setenv(envDiagnosticsFileMaxMB, "1")
+	t.Setenv(envDiagnosticsMaxFiles, "3")
+	t.Setenv(envDiagnosticsMaxTotalMB, "64")
+	t.Setenv(envMaxCheckpoints, "5")
+
+	cfg.LoadFromEnv()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+
+	s := cfg.Sessions
+	if s.MaxRunning != 8 || s.QueueSize != 0 || s.TTLSeconds != 120 || s.MaxQueueWaitSeconds != 30 {
+		t.Fatalf("sessions=%+v", s)
+	}
+	if s.FairShare != "workdir" {
+		t.Fatalf("fair_share=%q, want workdir", s.FairShare)
+	}
+	if s.BudgetMaxTokens != 500000 || s.BudgetMaxExecutionSeconds != 3600 || s.BudgetMaxTurns != 20 {
+		t.Fatalf("budget caps=%+v", s)
+	}
+	if s.DiagnosticsDir != "/var/lib/codex-mcp/diag" || s.DiagnosticsFileMaxMB != 1 || s.DiagnosticsMaxFiles != 3 || s.DiagnosticsMaxTotalMB != 64 {
+		t.Fatalf("diagnostics log=%+v", s)
+	}
+	if s.MaxCheckpoints != 5 {
+		t.Fatalf("max_checkpoints=%d, want 5", s.MaxCheckpoints)
+	}
+}
+
+func TestValidate_RejectsInvalidSessions(t *testing.T) {
+	for name, mutate := range map[string]func(*SessionsConfig){
+		"max_running":            func(s *SessionsConfig) { s.MaxRunning = 0 },
+		"queue_size":             func(s *SessionsConfig) { s.QueueSize = -1 },
+		"ttl_seconds":            func(s *SessionsConfig) { s.TTLSeconds = 0 },
+		"max_queue_wait_seconds": func(s *SessionsConfig) { s.MaxQueueWaitSeconds = -1 },
+		"fair_share":             func(s *SessionsConfig) { s.FairShare = "round-robin" },
+		"budget_max_tokens":      func(s *SessionsConfig) { s.BudgetMaxTokens = -1 },
+		"budget_max_turns":       func(s *SessionsConfig) { s.BudgetMaxTurns = -1 },
+		"diagnostics_max_files":  func(s *SessionsConfig) { s.DiagnosticsMaxFiles = 0 },
+		"diagnostics_total":      func(s *SessionsConfig) { s.DiagnosticsMaxTotalMB = 1; s.DiagnosticsFileMaxMB = 2 },
+		"max_checkpoints":        func(s *SessionsConfig) { s.MaxCheckpoints = -1 },
+	} {
+		cfg := Default()
+		mutate(&cfg.Sessions)
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected invalid sessions.%s to be rejected", name)
+		}
+	}
+}
+
+func TestLoad_Webhooks(t *testing.T) {
+	t.Setenv("CODEX_HOOK_SECRET", "s3cret")
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := `
+[[webhooks]]
+url = "https://hooks.example.com/codex"
+events = ["completed", "receipt-ready"]
+secret_env = "CODEX_HOOK_SECRET"
+max_attempts = 3
+
+[[webhooks]]
+url = "http://127.0.0.1:9000/all"
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(cfg.Webhooks) != 2 {
+		t.Fatalf("webhooks=%+v, want 2 entries", cfg.Webhooks)
+	}
+	if h := cfg.Webhooks[0]; h.URL != "https://hooks.example.com/codex" || len(h.Events) != 2 || h.SecretEnv != "CODEX_HOOK_SECRET" || h.MaxAttempts != 3 {
+		t.Fatalf("webhooks[0]=%+v", h)
+	}
+}
+
+func TestValidate_RejectsInvalidWebhooks(t *testing.T) {
+	for name, hook := range map[string]WebhookConfig{
+		"url":          {URL: "ftp://example.com"},
+		"missing_host": {URL: "https://"},
+		"events":       {URL: "https://example.com", Events: []string{"finished"}},
+		"max_attempts": {URL: "https://example.com", MaxAttempts: -1},
+		"timeout":      {URL: "https://example.com", TimeoutSeconds: -1},
+		"secret_env":   {URL: "https://example.com", SecretEnv: "CODEX_MCP_TEST_UNSET_HOOK_SECRET"},
+	} {
+		cfg := Default()
+		cfg.Webhooks = []WebhookConfig{hook}
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected invalid webhook %s to be rejected", name)
+		}
+	}
 }
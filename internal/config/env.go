@@ -17,6 +17,19 @@ const (
 	envExecutablePath   = "CODEX_EXECUTABLE_PATH"
 	envWorkdirLockMode  = "CODEX_WORKDIR_LOCK_MODE"
 	envWorkdirLockWait  = "CODEX_WORKDIR_LOCK_TIMEOUT"
+	envReceiptTimeout   = "CODEX_RECEIPT_TIMEOUT"
+	envRecordDir        = "CODEX_RECORD_DIR"
+	envSessionsDir      = "CODEX_SESSIONS_DIR"
+	envWorktreeDir      = "CODEX_WORKTREE_DIR"
+
+	envCommitAuthorName  = "CODEX_COMMIT_AUTHOR_NAME"
+	envCommitAuthorEmail = "CODEX_COMMIT_AUTHOR_EMAIL"
+
+	envMaxMemoryMB    = "CODEX_MAX_MEMORY_MB"
+	envMaxCPUSeconds  = "CODEX_MAX_CPU_SECONDS"
+	envMaxOpenFiles   = "CODEX_MAX_OPEN_FILES"
+	envMaxProcesses   = "CODEX_MAX_PROCESSES"
+	envMaxOutputBytes = "CODEX_MAX_OUTPUT_BYTES"
 
 	envAllowedModels       = "CODEX_ALLOWED_MODELS"
 	envAllowedProfiles     = "CODEX_ALLOWED_PROFILES"
@@ -25,6 +38,31 @@ const (
 	envAllowedWorkDirs     = "CODEX_ALLOWED_WORK_DIRS"
 	envDisableYolo         = "CODEX_DISABLE_YOLO"
 
+	envAllowedAddDirs          = "CODEX_ALLOWED_ADD_DIRS"
+	envAllowWebSearch          = "CODEX_ALLOW_WEB_SEARCH"
+	envAllowedReasoningEfforts = "CODEX_ALLOWED_REASONING_EFFORTS"
+	envAllowedApprovalPolicies = "CODEX_ALLOWED_APPROVAL_POLICIES"
+	envAllowOSS                = "CODEX_ALLOW_OSS"
+	envAllowSandboxEscalation  = "CODEX_ALLOW_SANDBOX_ESCALATION"
+
+	envStateDir     = "CODEX_MCP_STATE_DIR"
+	envMaxRunning   = "CODEX_MCP_MAX_RUNNING"
+	envQueueSize    = "CODEX_MCP_QUEUE_SIZE"
+	envSessionTTL   = "CODEX_MCP_SESSION_TTL"
+	envMaxQueueWait = "CODEX_MCP_MAX_QUEUE_WAIT"
+	envFairShare    = "CODEX_MCP_FAIR_SHARE"
+
+	envBudgetMaxTokens    = "CODEX_MCP_BUDGET_MAX_TOKENS"
+	envBudgetMaxExecution = "CODEX_MCP_BUDGET_MAX_EXECUTION_SECONDS"
+	envBudgetMaxTurns     = "CODEX_MCP_BUDGET_MAX_TURNS"
+
+	envDiagnosticsDir        = "CODEX_MCP_DIAGNOSTICS_DIR"
+	envDiagnosticsFileMaxMB  = "CODEX_MCP_DIAGNOSTICS_FILE_MAX_MB"
+	envDiagnosticsMaxFiles   = "CODEX_MCP_DIAGNOSTICS_MAX_FILES"
+	envDiagnosticsMaxTotalMB = "CODEX_MCP_DIAGNOSTICS_MAX_TOTAL_MB"
+
+	envMaxCheckpoints = "CODEX_MCP_MAX_CHECKPOINTS"
+
 	envLogLevel  = "CODEX_LOG_LEVEL"
 	envLogFormat = "CODEX_LOG_FORMAT"
 	envLogOutput = "CODEX_LOG_OUTPUT"
@@ -64,6 +102,39 @@ func (c *Config) LoadFromEnv() {
 	if v, ok := readIntEnv(envWorkdirLockWait); ok {
 		c.Codex.WorkdirLockTimeoutSeconds = v
 	}
+	if v, ok := readIntEnv(envReceiptTimeout); ok {
+		c.Codex.ReceiptTimeoutSeconds = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envRecordDir)); v != "" {
+		c.Codex.RecordDir = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envSessionsDir)); v != "" {
+		c.Codex.SessionsDir = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envWorktreeDir)); v != "" {
+		c.Codex.WorktreeDir = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envCommitAuthorName)); v != "" {
+		c.Commit.AuthorName = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envCommitAuthorEmail)); v != "" {
+		c.Commit.AuthorEmail = v
+	}
+	if v, ok := readIntEnv(envMaxMemoryMB); ok {
+		c.Codex.Limits.MaxMemoryMB = v
+	}
+	if v, ok := readIntEnv(envMaxCPUSeconds); ok {
+		c.Codex.Limits.MaxCPUSeconds = v
+	}
+	if v, ok := readIntEnv(envMaxOpenFiles); ok {
+		c.Codex.Limits.MaxOpenFiles = v
+	}
+	if v, ok := readIntEnv(envMaxProcesses); ok {
+		c.Codex.Limits.MaxProcesses = v
+	}
+	if v, ok := readIntEnv(envMaxOutputBytes); ok {
+		c.Codex.Limits.MaxOutputBytes = v
+	}
 
 	if v, ok := readCSVEnv(envAllowedModels); ok {
 		c.Security.AllowedModels = v
@@ -85,6 +156,73 @@ func (c *Config) LoadFromEnv() {
 			c.Security.DisableYolo = b
 		}
 	}
+	if v, ok := readCSVEnv(envAllowedAddDirs); ok {
+		c.Security.AllowedAddDirs = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envAllowWebSearch)); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.Security.AllowWebSearch = b
+		}
+	}
+	if v, ok := readCSVEnv(envAllowedReasoningEfforts); ok {
+		c.Security.AllowedReasoningEfforts = v
+	}
+	if v, ok := readCSVEnv(envAllowedApprovalPolicies); ok {
+		c.Security.AllowedApprovalPolicies = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envAllowOSS)); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.Security.AllowOSS = b
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv(envAllowSandboxEscalation)); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.Security.AllowSandboxEscalation = b
+		}
+	}
+
+	if v := strings.TrimSpace(os.Getenv(envStateDir)); v != "" {
+		c.Sessions.StateDir = v
+	}
+	if v, ok := readIntEnv(envMaxRunning); ok {
+		c.Sessions.MaxRunning = v
+	}
+	if v, ok := readIntEnv(envQueueSize); ok {
+		c.Sessions.QueueSize = v
+	}
+	if v, ok := readIntEnv(envSessionTTL); ok {
+		c.Sessions.TTLSeconds = v
+	}
+	if v, ok := readIntEnv(envMaxQueueWait); ok {
+		c.Sessions.MaxQueueWaitSeconds = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envFairShare)); v != "" {
+		c.Sessions.FairShare = v
+	}
+	if v, ok := readIntEnv(envBudgetMaxTokens); ok {
+		c.Sessions.BudgetMaxTokens = int64(v)
+	}
+	if v, ok := readIntEnv(envBudgetMaxExecution); ok {
+		c.Sessions.BudgetMaxExecutionSeconds = v
+	}
+	if v, ok := readIntEnv(envBudgetMaxTurns); ok {
+		c.Sessions.BudgetMaxTurns = v
+	}
+	if v := strings.TrimSpace(os.Getenv(envDiagnosticsDir)); v != "" {
+		c.Sessions.DiagnosticsDir = v
+	}
+	if v, ok := readIntEnv(envDiagnosticsFileMaxMB); ok {
+		c.Sessions.DiagnosticsFileMaxMB = v
+	}
+	if v, ok := readIntEnv(envDiagnosticsMaxFiles); ok {
+		c.Sessions.DiagnosticsMaxFiles = v
+	}
+	if v, ok := readIntEnv(envDiagnosticsMaxTotalMB); ok {
+		c.Sessions.DiagnosticsMaxTotalMB = v
+	}
+	if v, ok := readIntEnv(envMaxCheckpoints); ok {
+		c.Sessions.MaxCheckpoints = v
+	}
 
 	if v := strings.TrimSpace(os.Getenv(envLogLevel)); v != "" {
 		c.Logging.Level = v
@@ -16,18 +16,22 @@ const (
 	InternalError  Code = -32603
 
 	// Server-defined error codes for codex-mcp-go.
-	CodexNotFound        Code = -32001
-	CodexTimeout         Code = -32002
-	CodexExecutionFailed Code = -32003
-	WorkdirNotFound      Code = -32004
-	WorkdirNotDirectory  Code = -32005
-	ImageNotFound        Code = -32006
-	InvalidSandboxMode   Code = -32007
-	ParameterProhibited  Code = -32008
-	SessionNotFound      Code = -32009
-	NoOutputTimeout      Code = -32010
-	SessionLimitExceeded Code = -32011
-	WorkdirBusy          Code = -32012
+	CodexNotFound           Code = -32001
+	CodexTimeout            Code = -32002
+	CodexExecutionFailed    Code = -32003
+	WorkdirNotFound         Code = -32004
+	WorkdirNotDirectory     Code = -32005
+	ImageNotFound           Code = -32006
+	InvalidSandboxMode      Code = -32007
+	ParameterProhibited     Code = -32008
+	SessionNotFound         Code = -32009
+	NoOutputTimeout         Code = -32010
+	SessionLimitExceeded    Code = -32011
+	WorkdirBusy             Code = -32012
+	ResourceLimitExceeded   Code = -32013
+	CodexVersionUnsupported Code = -32014
+	BudgetExceeded          Code = -32015
+	WorkdirMismatch         Code = -32016
 )
 
 // Name returns a stable string identifier for the code.
@@ -67,6 +71,14 @@ func (c Code) Name() string {
 		return "SessionLimitExceeded"
 	case WorkdirBusy:
 		return "WorkdirBusy"
+	case ResourceLimitExceeded:
+		return "ResourceLimitExceeded"
+	case CodexVersionUnsupported:
+		return "CodexVersionUnsupported"
+	case BudgetExceeded:
+		return "BudgetExceeded"
+	case WorkdirMismatch:
+		return "WorkdirMismatch"
 	default:
 		return "UnknownError"
 	}
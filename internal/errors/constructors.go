@@ -92,3 +92,29 @@ func ErrWorkdirBusy(cd string, workdirKey string, mode string) *Error {
 		WithData("workdir_key", workdirKey).
 		WithData("mode", mode)
 }
+
+func ErrResourceLimitExceeded(limit string, value int64) *Error {
+	return New(ResourceLimitExceeded, "resource limit exceeded").
+		WithData("limit", limit).
+		WithData("value", value)
+}
+
+func ErrCodexVersionUnsupported(version string, feature string) *Error {
+	return New(CodexVersionUnsupported, "installed codex CLI does not support a required feature").
+		WithData("version", version).
+		WithData("feature", feature)
+}
+
+func ErrBudgetExceeded(limit string, used int64, max int64) *Error {
+	return New(BudgetExceeded, "session budget exceeded").
+		WithData("limit", limit).
+		WithData("used", used).
+		WithData("max", max)
+}
+
+func ErrWorkdirMismatch(sessionID string, originCd string, cd string) *Error {
+	return New(WorkdirMismatch, "cd does not match the session's original workdir").
+		WithData("SESSION_ID", sessionID).
+		WithData("origin_cd", originCd).
+		WithData("cd", cd)
+}
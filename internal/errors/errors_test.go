@@ -28,6 +28,10 @@ func TestCodeName(t *testing.T) {
 		{NoOutputTimeout, "NoOutputTimeout"},
 		{SessionLimitExceeded, "SessionLimitExceeded"},
 		{WorkdirBusy, "WorkdirBusy"},
+		{ResourceLimitExceeded, "ResourceLimitExceeded"},
+		{CodexVersionUnsupported, "CodexVersionUnsupported"},
+		{BudgetExceeded, "BudgetExceeded"},
+		{WorkdirMismatch, "WorkdirMismatch"},
 		{Code(0), "UnknownError"},
 		{Code(-999999), "UnknownError"},
 	}
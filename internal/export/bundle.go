@@ -0,0 +1,78 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Bundle file names.
+const (
+	BundleJSON     = "transcript.json"
+	BundleMarkdown = "transcript.md"
+	BundlePatch    = "changes.patch"
+)
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// WriteBundle writes a tar or zip archive holding the transcript as JSON and
+// Markdown, plus the change receipt diff as a patch when one was captured.
+func WriteBundle(w io.Writer, t Transcript, format Format) error {
+	files, err := bundleFiles(t)
+	if err != nil {
+		return err
+	}
+	modTime, err := time.Parse(time.RFC3339, t.ExportedAt)
+	if err != nil {
+		modTime = time.Now()
+	}
+
+	switch format {
+	case FormatTar:
+		tw := tar.NewWriter(w)
+		for _, f := range files {
+			hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), ModTime: modTime, Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(f.data); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	case FormatZip:
+		zw := zip.NewWriter(w)
+		for _, f := range files {
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: modTime})
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(f.data); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}
+	return fmt.Errorf("format %q is not an archive", format)
+}
+
+func bundleFiles(t Transcript) ([]bundleFile, error) {
+	var js, md bytes.Buffer
+	if err := WriteJSON(&js, t); err != nil {
+		return nil, err
+	}
+	if err := WriteMarkdown(&md, t); err != nil {
+		return nil, err
+	}
+	files := []bundleFile{{BundleJSON, js.Bytes()}, {BundleMarkdown, md.Bytes()}}
+	if patch := t.Patch(); patch != "" {
+		files = append(files, bundleFile{BundlePatch, []byte(patch)})
+	}
+	return files, nil
+}
@@ -0,0 +1,366 @@
+// Package export renders a session transcript (turns, agent messages, commands,
+// reasoning and the change receipt) as Markdown, HTML, JSON or a tar/zip bundle.
+// Every string is passed through redact before it is rendered.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/redact"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+// Format is an export format.
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatJSON     Format = "json"
+	FormatTar      Format = "tar"
+	FormatZip      Format = "zip"
+)
+
+// ValidFormats contains all valid export formats.
+var ValidFormats = []Format{FormatMarkdown, FormatHTML, FormatJSON, FormatTar, FormatZip}
+
+// ParseFormat parses a format name ("" defaults to markdown; "md" is accepted).
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", "md":
+		return FormatMarkdown, nil
+	case FormatMarkdown, FormatHTML, FormatJSON, FormatTar, FormatZip:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q (expected markdown, html, json, tar or zip)", s)
+}
+
+// Binary reports whether the format is an archive rather than text.
+func (f Format) Binary() bool {
+	return f == FormatTar || f == FormatZip
+}
+
+// MIMEType returns the media type of the rendered export.
+func (f Format) MIMEType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	case FormatTar:
+		return "application/x-tar"
+	case FormatZip:
+		return "application/zip"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// Extension returns the file extension for the format, without the dot.
+func (f Format) Extension() string {
+	if f == FormatMarkdown {
+		return "md"
+	}
+	return string(f)
+}
+
+// EventType classifies a transcript event.
+type EventType string
+
+const (
+	EventAgentMessage EventType = "agent_message"
+	EventReasoning    EventType = "reasoning"
+	EventCommand      EventType = "command"
+	EventError        EventType = "error"
+	// EventTruncated stands in for an output line that was cut to the diagnostics
+	// size limit and no longer parses.
+	EventTruncated EventType = "truncated"
+)
+
+// Event is one item of codex output, recovered from the session diagnostics.
+type Event struct {
+	At       string    `json:"ts"`
+	Type     EventType `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Output   string    `json:"output,omitempty"`
+}
+
+// Turn is one turn of the transcript with the events it produced.
+type Turn struct {
+	session.TurnView
+	Events []Event `json:"events,omitempty"`
+}
+
+// Transcript is the redacted, renderable form of a session.
+type Transcript struct {
+	SessionID       string            `json:"SESSION_ID"`
+	Title           string            `json:"title,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	State           session.State     `json:"state"`
+	WorkDir         string            `json:"cd"`
+	GitRoot         string            `json:"git_root,omitempty"`
+	Sandbox         string            `json:"sandbox,omitempty"`
+	ParentID        string            `json:"parent_session_id,omitempty"`
+	StartedAt       string            `json:"started_at"`
+	EndedAt         string            `json:"ended_at,omitempty"`
+	ExecutionTimeMs int64             `json:"execution_time_ms,omitempty"`
+	ToolCallCount   int               `json:"tool_call_count,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	ExportedAt      string            `json:"exported_at"`
+
+	Turns []Turn `json:"turns,omitempty"`
+	// Events are those recorded before the first turn.
+	Events []Event `json:"events,omitempty"`
+	// EventsTruncated reports that older output had already been dropped from the
+	// session's bounded diagnostics, so early events are missing.
+	EventsTruncated bool `json:"events_truncated,omitempty"`
+	// TruncatedEvents counts output lines that were cut to the diagnostics size limit
+	// and could not be recovered; each is listed as a "truncated" event.
+	TruncatedEvents int `json:"truncated_events,omitempty"`
+
+	ChangeReceipt *receipt.ChangeReceipt `json:"change_receipt,omitempty"`
+}
+
+// Patch returns the diff of the session's change receipt ("" when none was captured).
+func (t Transcript) Patch() string {
+	if t.ChangeReceipt == nil {
+		return ""
+	}
+	return t.ChangeReceipt.Diff
+}
+
+// WithLoggedDiagnostics replaces the bounded, truncated in-memory diagnostics of snap
+// with the untruncated entries from the session's diagnostics log. In-memory entries
+// older than the log (rotated away) or newer than it are kept.
+func WithLoggedDiagnostics(snap session.Snapshot, logged []session.DiagnosticEntry) session.Snapshot {
+	if len(logged) == 0 {
+		return snap
+	}
+	first, last := logged[0].Seq, logged[len(logged)-1].Seq
+	merged := make([]session.DiagnosticEntry, 0, len(logged)+len(snap.Diagnostics))
+	for _, d := range snap.Diagnostics {
+		if d.Seq < first {
+			merged = append(merged, d)
+		}
+	}
+	merged = append(merged, logged...)
+	for _, d := range snap.Diagnostics {
+		if d.Seq > last {
+			merged = append(merged, d)
+		}
+	}
+	snap.Diagnostics = merged
+	return snap
+}
+
+// Build converts a session snapshot into a redacted transcript. Events come from
+// snap.Diagnostics; pass the snapshot through WithLoggedDiagnostics first to recover
+// output lines that were truncated in memory.
+func Build(snap session.Snapshot, now time.Time) Transcript {
+	t := Transcript{
+		SessionID:       snap.ID,
+		Title:           redact.String(snap.Title),
+		Labels:          redact.Map(snap.Labels),
+		State:           snap.State,
+		WorkDir:         snap.WorkDir,
+		GitRoot:         snap.GitRoot,
+		Sandbox:         snap.Sandbox,
+		ParentID:        snap.ParentID,
+		StartedAt:       snap.StartedAt.UTC().Format(time.RFC3339),
+		ExecutionTimeMs: snap.ExecutionTimeMs,
+		ToolCallCount:   snap.ToolCallCount,
+		Error:           redact.String(snap.Error),
+		ExportedAt:      now.UTC().Format(time.RFC3339),
+		ChangeReceipt:   redactReceipt(snap.ChangeReceipt),
+	}
+	if snap.EndedAt != nil {
+		t.EndedAt = snap.EndedAt.UTC().Format(time.RFC3339)
+	}
+	if len(snap.Diagnostics) > 0 && snap.Diagnostics[0].Seq > 1 {
+		t.EventsTruncated = true
+	}
+
+	turns := append([]session.Turn(nil), snap.Turns...)
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].Index < turns[j].Index })
+	for _, turn := range turns {
+		v := turn.View()
+		v.PromptPreview = redact.String(v.PromptPreview)
+		v.AgentMessage = redact.String(v.AgentMessage)
+		v.Error = redact.String(v.Error)
+		v.ChangeReceipt = redactReceipt(v.ChangeReceipt)
+		t.Turns = append(t.Turns, Turn{TurnView: v})
+	}
+
+	for _, d := range snap.Diagnostics {
+		ev, ok := parseEvent(d)
+		if !ok {
+			if d.Kind != session.DiagnosticOutput || !d.Truncated {
+				continue
+			}
+			ev = Event{
+				At:   d.At.UTC().Format(time.RFC3339),
+				Type: EventTruncated,
+				Text: fmt.Sprintf("Output line #%d was truncated in the session diagnostics and could not be parsed.", d.Seq),
+			}
+			t.TruncatedEvents++
+		}
+		// Attribute the event to the latest turn started before it.
+		idx := -1
+		for i, turn := range turns {
+			if !turn.StartedAt.After(d.At) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			t.Events = append(t.Events, ev)
+		} else {
+			t.Turns[idx].Events = append(t.Turns[idx].Events, ev)
+		}
+	}
+	return t
+}
+
+// parseEvent extracts a transcript event from a codex JSONL output line. Lines that
+// were truncated by the diagnostics size limit no longer parse and are rejected.
+func parseEvent(d session.DiagnosticEntry) (Event, bool) {
+	if d.Kind != session.DiagnosticOutput {
+		return Event{}, false
+	}
+	var line struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Item    *struct {
+			Type             string          `json:"type"`
+			Text             string          `json:"text"`
+			Command          json.RawMessage `json:"command"`
+			AggregatedOutput string          `json:"aggregated_output"`
+			ExitCode         *int            `json:"exit_code"`
+		} `json:"item"`
+		Msg *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Text    string `json:"text"`
+		} `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(d.Message), &line); err != nil {
+		return Event{}, false
+	}
+	ev := Event{At: d.At.UTC().Format(time.RFC3339)}
+
+	switch {
+	case line.Item != nil:
+		// Started/updated items are repeated in full by item.completed.
+		if line.Type == "item.started" || line.Type == "item.updated" {
+			return Event{}, false
+		}
+		switch line.Item.Type {
+		case "agent_message":
+			ev.Type, ev.Text = EventAgentMessage, line.Item.Text
+		case "reasoning":
+			ev.Type, ev.Text = EventReasoning, line.Item.Text
+		case "command_execution":
+			ev.Type = EventCommand
+			ev.Command = commandString(line.Item.Command)
+			ev.Output = line.Item.AggregatedOutput
+			ev.ExitCode = line.Item.ExitCode
+		default:
+			return Event{}, false
+		}
+	case line.Msg != nil:
+		// Legacy {"id":..,"msg":{..}} events.
+		switch line.Msg.Type {
+		case "agent_message":
+			ev.Type, ev.Text = EventAgentMessage, line.Msg.Message
+		case "agent_reasoning":
+			ev.Type, ev.Text = EventReasoning, line.Msg.Text
+		case "error", "stream_error":
+			ev.Type, ev.Text = EventError, line.Msg.Message
+		default:
+			return Event{}, false
+		}
+	case line.Type == "error":
+		ev.Type, ev.Text = EventError, line.Message
+	default:
+		return Event{}, false
+	}
+
+	ev.Text = redact.String(ev.Text)
+	ev.Command = redact.String(ev.Command)
+	ev.Output = redact.String(ev.Output)
+	return ev, true
+}
+
+// commandString renders a command given either as a string or as an argv array.
+func commandString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var argv []string
+	if err := json.Unmarshal(raw, &argv); err == nil {
+		return strings.Join(argv, " ")
+	}
+	return string(raw)
+}
+
+func redactReceipt(r *receipt.ChangeReceipt) *receipt.ChangeReceipt {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	out.GitStatus = redact.String(r.GitStatus)
+	out.DiffStat = redact.String(r.DiffStat)
+	out.Diff = redact.String(r.Diff)
+	out.ReceiptError = redact.String(r.ReceiptError)
+	out.ChangedFiles = append([]receipt.FileChange(nil), r.ChangedFiles...)
+	out.PreexistingChanges = append([]receipt.FileChange(nil), r.PreexistingChanges...)
+	out.Staged = redactSection(r.Staged)
+	out.Unstaged = redactSection(r.Unstaged)
+	out.Untracked = append([]receipt.UntrackedFile(nil), r.Untracked...)
+	for i := range out.Untracked {
+		out.Untracked[i].Content = redact.String(out.Untracked[i].Content)
+	}
+	return &out
+}
+
+func redactSection(s *receipt.DiffSection) *receipt.DiffSection {
+	if s == nil {
+		return nil
+	}
+	out := *s
+	out.Diff = redact.String(s.Diff)
+	out.Files = append([]receipt.FileStat(nil), s.Files...)
+	return &out
+}
+
+// Write renders t in the given format.
+func Write(w io.Writer, t Transcript, format Format) error {
+	switch format {
+	case FormatMarkdown:
+		return WriteMarkdown(w, t)
+	case FormatHTML:
+		return WriteHTML(w, t)
+	case FormatJSON:
+		return WriteJSON(w, t)
+	case FormatTar, FormatZip:
+		return WriteBundle(w, t, format)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// WriteJSON renders t as indented JSON.
+func WriteJSON(w io.Writer, t Transcript) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
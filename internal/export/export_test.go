@@ -0,0 +1,190 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+const testSecret = "sk-proj-abcdefghijklmnopqrstuvwxyz012345"
+
+func testSnapshot() session.Snapshot {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(5 * time.Second)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+	return session.Snapshot{
+		ID:        "t-1",
+		State:     session.StateCompleted,
+		WorkDir:   "/repo",
+		Sandbox:   "workspace-write",
+		Title:     "fix <login>",
+		StartedAt: start,
+		EndedAt:   &end,
+		Turns: []session.Turn{{
+			Index:         0,
+			State:         session.StateCompleted,
+			PromptPreview: "fix the login bug; key is " + testSecret,
+			PromptChars:   40,
+			StartedAt:     start,
+			EndedAt:       &end,
+			AgentMessage:  "Fixed it.",
+		}},
+		Diagnostics: []session.DiagnosticEntry{
+			{Seq: 1, At: at(0), Kind: session.DiagnosticSystem, Message: "session started"},
+			{Seq: 2, At: at(1), Kind: session.DiagnosticOutput, Message: `{"type":"item.completed","item":{"type":"reasoning","text":"Look at auth.go"}}`},
+			{Seq: 3, At: at(2), Kind: session.DiagnosticOutput, Message: `{"type":"item.started","item":{"type":"command_execution","command":"go test ./..."}}`},
+			{Seq: 4, At: at(3), Kind: session.DiagnosticOutput, Message: `{"type":"item.completed","item":{"type":"command_execution","command":["bash","-lc","OPENAI_API_KEY=` + testSecret + ` go test ./..."],"aggregated_output":"ok\n","exit_code":0}}`},
+			{Seq: 5, At: at(4), Kind: session.DiagnosticOutput, Message: `{"type":"item.completed","item":{"type":"agent_message","text":"Fixed it."}}`},
+			{Seq: 6, At: at(4), Kind: session.DiagnosticOutput, Message: `{"type":"item.completed","item":{"type":"agent_mess`},
+		},
+		ChangeReceipt: &receipt.ChangeReceipt{
+			ReceiptAvailable:   true,
+			ChangedFiles:       []receipt.FileChange{{Path: "auth.go", WorktreeStatus: "M", Change: "M", Added: &one, Removed: &zero}},
+			PreexistingChanges: []receipt.FileChange{{Path: "notes.md", WorktreeStatus: "M"}},
+			Diff:               "diff --git a/auth.go b/auth.go\n+const key = \"" + testSecret + "\"\n",
+			Unstaged:           &receipt.DiffSection{Diff: "+const key = \"" + testSecret + "\"\n"},
+			Untracked:          []receipt.UntrackedFile{{Path: ".env", Content: "OPENAI_API_KEY=" + testSecret + "\n"}},
+		},
+	}
+}
+
+var one, zero = 1, 0
+
+func TestBuild_ParsesEventsAndRedacts(t *testing.T) {
+	snap := testSnapshot()
+	tr := Build(snap, time.Now())
+
+	if len(tr.Turns) != 1 || len(tr.Events) != 0 {
+		t.Fatalf("turns=%d events=%d", len(tr.Turns), len(tr.Events))
+	}
+	events := tr.Turns[0].Events
+	if len(events) != 3 || events[0].Type != EventReasoning || events[1].Type != EventCommand || events[2].Type != EventAgentMessage {
+		t.Fatalf("events=%+v", events)
+	}
+	if cmd := events[1]; cmd.ExitCode == nil || *cmd.ExitCode != 0 || !strings.HasPrefix(cmd.Command, "bash -lc OPENAI_API_KEY=[REDACTED]") {
+		t.Fatalf("command event=%+v", cmd)
+	}
+	if strings.Contains(tr.Turns[0].PromptPreview, testSecret) || strings.Contains(tr.Patch(), testSecret) {
+		t.Fatalf("secret leaked into transcript: %+v", tr)
+	}
+	if !strings.Contains(snap.ChangeReceipt.Diff, testSecret) || !strings.Contains(snap.ChangeReceipt.Untracked[0].Content, testSecret) {
+		t.Fatalf("Build() modified the snapshot's receipt")
+	}
+}
+
+func TestBuild_PrefersLoggedDiagnosticsAndReportsTruncation(t *testing.T) {
+	snap := testSnapshot()
+	full := `{"type":"item.completed","item":{"type":"command_execution","command":"cat big.log","aggregated_output":"` + strings.Repeat("x", 4096) + `","exit_code":0}}`
+	at := snap.Turns[0].StartedAt.Add(3 * time.Second)
+	snap.Diagnostics = append(snap.Diagnostics, session.DiagnosticEntry{
+		Seq: 7, At: at, Kind: session.DiagnosticOutput, Message: full[:2048], Truncated: true,
+	})
+
+	tr := Build(snap, time.Now())
+	last := tr.Turns[0].Events[len(tr.Turns[0].Events)-1]
+	if tr.TruncatedEvents != 1 || last.Type != EventTruncated {
+		t.Fatalf("truncated=%d last event=%+v", tr.TruncatedEvents, last)
+	}
+
+	logged := []session.DiagnosticEntry{{Seq: 7, At: at, Kind: session.DiagnosticOutput, Message: full}}
+	tr = Build(WithLoggedDiagnostics(snap, logged), time.Now())
+	last = tr.Turns[0].Events[len(tr.Turns[0].Events)-1]
+	if tr.TruncatedEvents != 0 || last.Type != EventCommand || len(last.Output) != 4096 {
+		t.Fatalf("truncated=%d last event=%+v", tr.TruncatedEvents, last.Type)
+	}
+	if n := len(tr.Turns[0].Events); n != 4 {
+		t.Fatalf("in-memory entries older than the log were not kept: %d events", n)
+	}
+}
+
+func TestWrite_TextFormats(t *testing.T) {
+	tr := Build(testSnapshot(), time.Now())
+
+	for _, format := range []Format{FormatMarkdown, FormatHTML, FormatJSON} {
+		var buf bytes.Buffer
+		if err := Write(&buf, tr, format); err != nil {
+			t.Fatalf("Write(%s) failed: %v", format, err)
+		}
+		out := buf.String()
+		if strings.Contains(out, testSecret) {
+			t.Fatalf("%s export leaked a secret", format)
+		}
+		if !strings.Contains(out, "Look at auth.go") || !strings.Contains(out, "go test ./...") {
+			t.Fatalf("%s export is missing events:\n%s", format, out)
+		}
+		if !strings.Contains(out, "notes.md") {
+			t.Fatalf("%s export is missing the pre-existing changes:\n%s", format, out)
+		}
+	}
+
+	var md bytes.Buffer
+	if err := WriteMarkdown(&md, tr); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	if !strings.Contains(md.String(), "- `auth.go` M +1 -0\n") {
+		t.Fatalf("markdown is missing the line counts:\n%s", md.String())
+	}
+
+	var html bytes.Buffer
+	if err := WriteHTML(&html, tr); err != nil {
+		t.Fatalf("WriteHTML() failed: %v", err)
+	}
+	if !strings.Contains(html.String(), "fix &lt;login&gt;") || strings.Contains(html.String(), "<link") {
+		t.Fatalf("html is not escaped or not self-contained:\n%s", html.String())
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	tr := Build(testSnapshot(), time.Now())
+
+	var tarBuf bytes.Buffer
+	if err := WriteBundle(&tarBuf, tr, FormatTar); err != nil {
+		t.Fatalf("WriteBundle(tar) failed: %v", err)
+	}
+	var names []string
+	tarReader := tar.NewReader(&tarBuf)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, ",") != BundleJSON+","+BundleMarkdown+","+BundlePatch {
+		t.Fatalf("tar entries=%v", names)
+	}
+
+	var zipBuf bytes.Buffer
+	if err := WriteBundle(&zipBuf, tr, FormatZip); err != nil {
+		t.Fatalf("WriteBundle(zip) failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	if len(zr.File) != 3 || zr.File[2].Name != BundlePatch {
+		t.Fatalf("zip entries=%v", zr.File)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatMarkdown {
+		t.Fatalf("ParseFormat(\"\")=%q, %v", f, err)
+	}
+	if f, err := ParseFormat("ZIP"); err != nil || f != FormatZip {
+		t.Fatalf("ParseFormat(ZIP)=%q, %v", f, err)
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Fatalf("expected pdf to be rejected")
+	}
+}
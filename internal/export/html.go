@@ -0,0 +1,115 @@
+package export
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteHTML renders t as a self-contained HTML page (inline CSS, no external assets).
+func WriteHTML(w io.Writer, t Transcript) error {
+	return htmlTemplate.Execute(w, htmlView{Transcript: t, Heading: heading(t), Summary: summaryRows(t)})
+}
+
+type htmlView struct {
+	Transcript
+	Heading string
+	Summary [][2]string
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"inc":        func(i int) int { return i + 1 },
+	"trim":       func(s string) string { return strings.TrimRight(s, "\n") },
+	"fileStatus": fileStatus,
+	"hasMessage": func(events []Event) bool {
+		for _, ev := range events {
+			if ev.Type == EventAgentMessage {
+				return true
+			}
+		}
+		return false
+	},
+	"diffClass": func(line string) string {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+			return "meta"
+		case strings.HasPrefix(line, "+"):
+			return "add"
+		case strings.HasPrefix(line, "-"):
+			return "del"
+		case strings.HasPrefix(line, "@@"):
+			return "hunk"
+		}
+		return ""
+	},
+	"lines": func(s string) []string { return strings.Split(strings.TrimRight(s, "\n"), "\n") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Heading}}</title>
+<style>
+body{font:14px/1.5 -apple-system,BlinkMacSystemFont,"Segoe UI",Helvetica,Arial,sans-serif;max-width:960px;margin:2em auto;padding:0 1em;color:#1f2328}
+h1{font-size:1.6em;border-bottom:1px solid #d0d7de;padding-bottom:.3em}
+h2{font-size:1.25em;margin-top:2em;border-bottom:1px solid #d0d7de;padding-bottom:.2em}
+h3{font-size:1em;margin:1.2em 0 .4em}
+table{border-collapse:collapse}
+td,th{border:1px solid #d0d7de;padding:4px 10px;text-align:left;vertical-align:top}
+pre{background:#f6f8fa;padding:10px;overflow:auto;border-radius:6px;white-space:pre-wrap;word-break:break-word}
+blockquote{margin:0;padding:0 1em;color:#59636e;border-left:.25em solid #d0d7de;white-space:pre-wrap}
+.meta{color:#59636e;font-size:.9em}
+.error{color:#d1242f}
+.add{color:#116329;background:#dafbe1;display:block}
+.del{color:#82071e;background:#ffebe9;display:block}
+.hunk{color:#0550ae;display:block}
+pre .meta{font-weight:bold;display:block}
+</style>
+</head>
+<body>
+<h1>{{.Heading}}</h1>
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{if .Error}}<p class="error"><strong>Error:</strong> {{.Error}}</p>{{end}}
+{{if .EventsTruncated}}<p class="meta">Older output was dropped from the session diagnostics; early events are missing.</p>{{end}}
+{{range .Events}}{{template "event" .}}{{end}}
+{{range .Turns}}
+<h2>Turn {{inc .Index}} ({{.State}})</h2>
+<p class="meta">started {{.StartedAt}}{{if .DurationMs}} · {{.DurationMs}} ms{{end}}{{if .Model}} · model {{.Model}}{{end}}{{if .Sandbox}} · sandbox {{.Sandbox}}{{end}}{{with .Usage}} · {{.InputTokens}} input / {{.OutputTokens}} output tokens{{end}}</p>
+<h3>Prompt <span class="meta">({{.PromptChars}} chars{{with .PromptSHA256}}, sha256 <code>{{.}}</code>{{end}})</span></h3>
+<blockquote>{{.PromptPreview}}</blockquote>
+{{range .Events}}{{template "event" .}}{{end}}
+{{if and .AgentMessage (not (hasMessage .Events))}}<h3>Agent message</h3>
+<pre>{{trim .AgentMessage}}</pre>{{end}}
+{{if .Error}}<p class="error"><strong>Error:</strong> {{.Error}}</p>{{end}}
+{{end}}
+{{with .ChangeReceipt}}
+<h2>Change receipt</h2>
+{{if .ReceiptAvailable}}
+{{if .ChangedFiles}}<ul>{{range .ChangedFiles}}<li><code>{{.Path}}</code> {{fileStatus .}}</li>{{end}}</ul>{{end}}
+{{if .PreexistingChanges}}<p class="meta">Already changed before the run:{{range .PreexistingChanges}} <code>{{.Path}}</code>{{end}}</p>{{end}}
+{{if .Checkpoint}}<p class="meta">Checkpoint: <code>{{.Checkpoint}}</code> (restore with rollback_session)</p>{{end}}
+{{if .Commit}}<p class="meta">Committed as <code>{{.Commit}}</code>{{if .CommitBranch}} on <code>{{.CommitBranch}}</code>{{end}}</p>{{end}}
+{{if .DiffStat}}<pre>{{trim .DiffStat}}</pre>{{end}}
+{{if .Diff}}<pre>{{range lines .Diff}}<span class="{{diffClass .}}">{{.}}</span>
+{{end}}</pre>{{if .DiffTruncated}}<p class="meta">Diff truncated.</p>{{end}}
+{{else if .ChangedFiles}}<p class="meta">No diff was captured (run codex with return_diff=true).</p>
+{{else}}<p class="meta">No changes.</p>{{end}}
+{{else}}<p class="meta">Receipt unavailable{{if .ReceiptError}}: {{.ReceiptError}}{{end}}</p>{{end}}
+{{end}}
+<p class="meta">Exported {{.ExportedAt}}. Secrets are redacted on a best-effort basis.</p>
+</body>
+</html>
+{{define "event"}}
+{{- if eq .Type "agent_message"}}<h3>Agent message</h3>
+<pre>{{trim .Text}}</pre>
+{{- else if eq .Type "reasoning"}}<h3>Reasoning</h3>
+<blockquote>{{trim .Text}}</blockquote>
+{{- else if eq .Type "command"}}<h3>Command{{with .ExitCode}} <span class="meta">(exit {{.}})</span>{{end}}</h3>
+<pre>$ {{.Command}}{{if .Output}}
+{{trim .Output}}{{end}}</pre>
+{{- else if eq .Type "error"}}<h3 class="error">Error</h3>
+<blockquote>{{trim .Text}}</blockquote>
+{{- else if eq .Type "truncated"}}<p class="meta">{{.Text}}</p>
+{{- end}}
+{{end}}`))
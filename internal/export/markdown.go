@@ -0,0 +1,230 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+// WriteMarkdown renders t as a Markdown document.
+func WriteMarkdown(w io.Writer, t Transcript) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) { fmt.Fprintf(bw, format, args...) }
+
+	p("# %s\n\n", heading(t))
+	p("| Field | Value |\n|---|---|\n")
+	for _, row := range summaryRows(t) {
+		p("| %s | %s |\n", row[0], escapeCell(row[1]))
+	}
+	if t.Error != "" {
+		p("\n**Error:** %s\n", t.Error)
+	}
+	if t.EventsTruncated {
+		p("\n_Older output was dropped from the session diagnostics; early events are missing._\n")
+	}
+	for _, ev := range t.Events {
+		writeMarkdownEvent(p, ev)
+	}
+
+	for _, turn := range t.Turns {
+		p("\n## Turn %d (%s)\n\n", turn.Index+1, turn.State)
+		meta := []string{"started " + turn.StartedAt}
+		if turn.DurationMs > 0 {
+			meta = append(meta, fmt.Sprintf("%d ms", turn.DurationMs))
+		}
+		if turn.Model != "" {
+			meta = append(meta, "model "+turn.Model)
+		}
+		if turn.Sandbox != "" {
+			meta = append(meta, "sandbox "+turn.Sandbox)
+		}
+		if turn.Usage != nil {
+			meta = append(meta, fmt.Sprintf("%d input / %d output tokens", turn.Usage.InputTokens, turn.Usage.OutputTokens))
+		}
+		p("_%s_\n\n", strings.Join(meta, " · "))
+		if turn.PromptSHA256 != "" {
+			p("**Prompt** (%d chars, sha256 `%s`):\n\n", turn.PromptChars, turn.PromptSHA256)
+		} else {
+			p("**Prompt** (%d chars):\n\n", turn.PromptChars)
+		}
+		p("%s\n", quote(turn.PromptPreview))
+
+		hasMessage := false
+		for _, ev := range turn.Events {
+			hasMessage = hasMessage || ev.Type == EventAgentMessage
+			writeMarkdownEvent(p, ev)
+		}
+		if !hasMessage && turn.AgentMessage != "" {
+			writeMarkdownEvent(p, Event{Type: EventAgentMessage, Text: turn.AgentMessage})
+		}
+		if turn.Error != "" {
+			p("\n**Error:** %s\n", turn.Error)
+		}
+	}
+
+	if r := t.ChangeReceipt; r != nil {
+		p("\n## Change receipt\n\n")
+		writeMarkdownReceipt(p, r)
+	}
+	return bw.Flush()
+}
+
+func writeMarkdownEvent(p func(string, ...any), ev Event) {
+	switch ev.Type {
+	case EventAgentMessage:
+		p("\n### Agent message\n\n%s\n", strings.TrimSpace(ev.Text))
+	case EventReasoning:
+		p("\n### Reasoning\n\n%s\n", quote(ev.Text))
+	case EventCommand:
+		status := ""
+		if ev.ExitCode != nil {
+			status = fmt.Sprintf(" (exit %d)", *ev.ExitCode)
+		}
+		p("\n### Command%s\n\n%s", status, codeBlock("sh", "$ "+ev.Command))
+		if out := strings.TrimRight(ev.Output, "\n"); out != "" {
+			p("\n%s", codeBlock("text", out))
+		}
+	case EventError:
+		p("\n### Error\n\n%s\n", quote(ev.Text))
+	case EventTruncated:
+		p("\n_%s_\n", ev.Text)
+	}
+}
+
+func writeMarkdownReceipt(p func(string, ...any), r *receipt.ChangeReceipt) {
+	if !r.ReceiptAvailable {
+		p("_Receipt unavailable")
+		if r.ReceiptError != "" {
+			p(": %s", r.ReceiptError)
+		}
+		p("_\n")
+		return
+	}
+	if len(r.ChangedFiles) > 0 {
+		for _, f := range r.ChangedFiles {
+			p("- `%s` %s\n", f.Path, fileStatus(f))
+		}
+		p("\n")
+	}
+	if len(r.PreexistingChanges) > 0 {
+		paths := make([]string, 0, len(r.PreexistingChanges))
+		for _, f := range r.PreexistingChanges {
+			paths = append(paths, "`"+f.Path+"`")
+		}
+		p("_Already changed before the run: %s._\n\n", strings.Join(paths, ", "))
+	}
+	if r.Checkpoint != "" {
+		p("_Checkpoint: `%s` (restore with rollback_session)._\n\n", r.Checkpoint)
+	}
+	if r.Commit != "" {
+		p("_Committed as `%s`", r.Commit)
+		if r.CommitBranch != "" {
+			p(" on `%s`", r.CommitBranch)
+		}
+		p("._\n\n")
+	}
+	if r.DiffStat != "" {
+		p("%s\n", codeBlock("text", strings.TrimRight(r.DiffStat, "\n")))
+	}
+	switch {
+	case r.Diff != "":
+		p("%s\n", codeBlock("diff", strings.TrimRight(r.Diff, "\n")))
+		if r.DiffTruncated {
+			p("_Diff truncated._\n")
+		}
+	case len(r.ChangedFiles) > 0:
+		p("_No diff was captured (run codex with return_diff=true)._\n")
+	default:
+		p("_No changes._\n")
+	}
+}
+
+// fileStatus is the change since the pre-run baseline, or the porcelain status, with
+// the line counts when known.
+func fileStatus(f receipt.FileChange) string {
+	status := f.Change
+	if status == "" {
+		status = strings.TrimSpace(f.IndexStatus + f.WorktreeStatus)
+	}
+	switch {
+	case f.Binary:
+		status += " (binary)"
+	case f.Added != nil && f.Removed != nil:
+		status += fmt.Sprintf(" +%d -%d", *f.Added, *f.Removed)
+	}
+	return status
+}
+
+func heading(t Transcript) string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return "Codex session " + t.SessionID
+}
+
+func summaryRows(t Transcript) [][2]string {
+	rows := [][2]string{
+		{"SESSION_ID", t.SessionID},
+		{"State", string(t.State)},
+		{"Workdir", t.WorkDir},
+	}
+	add := func(k, v string) {
+		if v != "" {
+			rows = append(rows, [2]string{k, v})
+		}
+	}
+	add("Git root", t.GitRoot)
+	add("Sandbox", t.Sandbox)
+	add("Forked from", t.ParentID)
+	add("Started", t.StartedAt)
+	add("Ended", t.EndedAt)
+	if t.ExecutionTimeMs > 0 {
+		add("Execution time", fmt.Sprintf("%d ms", t.ExecutionTimeMs))
+	}
+	if len(t.Labels) > 0 {
+		keys := make([]string, 0, len(t.Labels))
+		for k := range t.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+"="+t.Labels[k])
+		}
+		add("Labels", strings.Join(pairs, ", "))
+	}
+	add("Exported", t.ExportedAt)
+	return rows
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func quote(s string) string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return ">"
+	}
+	return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+}
+
+// codeBlock fences s with more backticks than any run inside it.
+func codeBlock(lang, s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + s + "\n" + fence + "\n"
+}
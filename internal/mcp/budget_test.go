@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func callToolError(t *testing.T, cs *mcpsdk.ClientSession, args map[string]any) map[string]any {
+	t.Helper()
+	return callNamedToolError(t, cs, "codex", args)
+}
+
+func callNamedToolError(t *testing.T, cs *mcpsdk.ClientSession, name string, args map[string]any) map[string]any {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcpsdk.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s call failed: %v", name, err)
+	}
+	if !res.IsError || len(res.Content) == 0 {
+		t.Fatalf("expected %s call to fail, got %+v", name, res)
+	}
+	tc, ok := res.Content[0].(*mcpsdk.TextContent)
+	if !ok {
+		t.Fatalf("error content type=%T, want *TextContent", res.Content[0])
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(tc.Text), &payload); err != nil {
+		t.Fatalf("error payload is not JSON: %v (%q)", err, tc.Text)
+	}
+	return payload
+}
+
+func assertBudgetExceeded(t *testing.T, payload map[string]any, limit string) {
+	t.Helper()
+	if payload["code"] != float64(cerrors.BudgetExceeded) {
+		t.Fatalf("error=%v, want %s", payload, cerrors.BudgetExceeded.Name())
+	}
+	data, _ := payload["data"].(map[string]any)
+	if data["limit"] != limit {
+		t.Fatalf("error data=%v, want limit %q", data, limit)
+	}
+}
+
+func TestCodexTool_TurnBudgetRejectsResume(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+	cd := t.TempDir()
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	out := callStructured(t, cs, "codex", map[string]any{
+		"PROMPT":           "hi",
+		"cd":               cd,
+		"budget_max_turns": 1,
+	})
+	budget, _ := out["budget"].(map[string]any)
+	remaining, _ := budget["remaining"].(map[string]any)
+	if remaining["turns"] != float64(0) {
+		t.Fatalf("budget=%v, want 0 remaining turns", out["budget"])
+	}
+
+	// The stored budget applies to later resumes even when not repeated.
+	payload := callToolError(t, cs, map[string]any{"PROMPT": "again", "cd": cd, "SESSION_ID": "t-123"})
+	assertBudgetExceeded(t, payload, "turns")
+}
+
+func TestCodexTool_BudgetCancelsRunMidStream(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Sessions.BudgetMaxExecutionSeconds = 1
+	cs := connectTestClient(t, cfg)
+
+	t.Run("tokens", func(t *testing.T) {
+		t.Setenv(fakeCodexEnv, "usage_then_sleep")
+		start := time.Now()
+		payload := callToolError(t, cs, map[string]any{"PROMPT": "hi", "cd": t.TempDir(), "budget_max_tokens": 100})
+		assertBudgetExceeded(t, payload, "tokens")
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("run was not cancelled when the token budget ran out")
+		}
+	})
+
+	t.Run("execution time cap", func(t *testing.T) {
+		// Requests cannot raise the server-wide cap.
+		t.Setenv(fakeCodexEnv, "sleep")
+		payload := callToolError(t, cs, map[string]any{"PROMPT": "hi", "cd": t.TempDir(), "budget_max_execution_seconds": 60})
+		assertBudgetExceeded(t, payload, "execution_ms")
+	})
+}
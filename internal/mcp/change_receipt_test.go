@@ -41,7 +41,8 @@ func TestCodexTool_ChangeReceipt_GitRepo(t *testing.T) {
 	runGit("add", "file.txt")
 	runGit("commit", "-m", "init")
 
-	// Create an unstaged modification that should appear in the receipt.
+	// An unstaged modification made before the run is reported as pre-existing, not as
+	// a change made by codex.
 	if err := os.WriteFile(path, []byte("hello world\n"), 0o644); err != nil {
 		t.Fatalf("modify file: %v", err)
 	}
@@ -66,7 +67,8 @@ func TestCodexTool_ChangeReceipt_GitRepo(t *testing.T) {
 	}
 	defer cs.Close()
 
-	t.Setenv(fakeCodexEnv, "success_tool_call")
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, filepath.Join(repo, "new.txt"))
 
 	out, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{
 		Name: "codex",
@@ -95,6 +97,9 @@ func TestCodexTool_ChangeReceipt_GitRepo(t *testing.T) {
 	if cr["receipt_available"] != true {
 		t.Fatalf("change_receipt.receipt_available=%v, want true", cr["receipt_available"])
 	}
+	if cr["baseline_tree"] == nil || cr["result_tree"] == nil || cr["baseline_tree"] == cr["result_tree"] {
+		t.Fatalf("change_receipt trees=%v..%v, want a baseline and a different result", cr["baseline_tree"], cr["result_tree"])
+	}
 	if cr["git_status"] == "" {
 		t.Fatalf("change_receipt.git_status is empty")
 	}
@@ -104,25 +109,134 @@ func TestCodexTool_ChangeReceipt_GitRepo(t *testing.T) {
 	if cr["diff"] == "" {
 		t.Fatalf("change_receipt.diff is empty")
 	}
+	if cr["codex_version"] != "0.46.0" {
+		t.Fatalf("change_receipt.codex_version=%v, want %q", cr["codex_version"], "0.46.0")
+	}
+
+	if diff, _ := cr["diff"].(string); !strings.Contains(diff, "codex was here") || strings.Contains(diff, "hello world") {
+		t.Fatalf("change_receipt.diff must cover only the run's changes:\n%s", diff)
+	}
 
 	changed, ok := cr["changed_files"].([]any)
 	if !ok {
 		t.Fatalf("change_receipt.changed_files type=%T, want array", cr["changed_files"])
 	}
-	found := false
-	for _, item := range changed {
-		m, ok := item.(map[string]any)
-		if !ok {
-			continue
-		}
-		if m["path"] == "file.txt" {
-			found = true
-			break
-		}
+	if len(changed) != 1 {
+		t.Fatalf("expected changed_files to hold only new.txt, got=%v", changed)
+	}
+	if m, _ := changed[0].(map[string]any); m["path"] != "new.txt" || m["change"] != "A" || m["worktree_status"] != "?" || m["added"] != float64(1) || m["removed"] != float64(0) {
+		t.Fatalf("changed_files[0]=%v, want untracked new.txt added with one line", m)
+	}
+	pre, _ := cr["preexisting_changes"].([]any)
+	if len(pre) != 1 || pre[0].(map[string]any)["path"] != "file.txt" {
+		t.Fatalf("expected preexisting_changes to hold file.txt, got=%v", cr["preexisting_changes"])
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain=v1"); !strings.Contains(status, " M file.txt") || !strings.Contains(status, "?? new.txt") {
+		t.Fatalf("snapshot must not touch the index:\n%s", status)
+	}
+}
+
+func TestCodexTool_ChangeReceipt_Sections(t *testing.T) {
+	repo := initTestRepo(t)
+	// Before the run: a staged new file and a binary untracked file.
+	if err := os.WriteFile(filepath.Join(repo, "staged.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	gitOutput(t, repo, "add", "staged.txt")
+	if err := os.WriteFile(filepath.Join(repo, "blob.bin"), []byte("a\x00b"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	sectionFiles := func(cr map[string]any, name string) []any {
+		section, _ := cr[name].(map[string]any)
+		files, _ := section["files"].([]any)
+		return files
+	}
+
+	// The run edits and stages the already staged file: only its own edit is staged.
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "staged.txt")
+	t.Setenv(fakeWriteContentEnv, "one\nthree\n")
+	t.Setenv(fakeStageEnv, "1")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "return_diff": true})
+	cr, _ := out["change_receipt"].(map[string]any)
+
+	files := sectionFiles(cr, "staged")
+	if len(files) != 1 || files[0].(map[string]any)["path"] != "staged.txt" || files[0].(map[string]any)["added"] != float64(1) || files[0].(map[string]any)["removed"] != float64(1) {
+		t.Fatalf("change_receipt.staged=%v, want the run's one-line edit of staged.txt", cr["staged"])
 	}
-	if !found {
-		t.Fatalf("expected changed_files to include file.txt, got=%v", changed)
+	staged, _ := cr["staged"].(map[string]any)
+	if diff, _ := staged["diff"].(string); !strings.Contains(diff, "-two") || !strings.Contains(diff, "+three") || strings.Contains(diff, "+one") {
+		t.Fatalf("change_receipt.staged.diff=%q", diff)
 	}
+	if files := sectionFiles(cr, "unstaged"); len(files) != 0 {
+		t.Fatalf("change_receipt.unstaged=%v, want nothing", cr["unstaged"])
+	}
+	if untracked, _ := cr["untracked"].([]any); len(untracked) != 0 {
+		t.Fatalf("change_receipt.untracked=%v, want the pre-existing blob.bin left out", untracked)
+	}
+
+	// The next turn edits file.txt without staging it.
+	t.Setenv(fakeWriteFileEnv, "file.txt")
+	t.Setenv(fakeWriteContentEnv, "")
+	t.Setenv(fakeStageEnv, "")
+	out = callStructured(t, cs, "codex", map[string]any{"PROMPT": "again", "SESSION_ID": "t-123", "return_diff": true})
+	cr, _ = out["change_receipt"].(map[string]any)
+
+	if files := sectionFiles(cr, "staged"); len(files) != 0 {
+		t.Fatalf("change_receipt.staged=%v, want nothing from this turn", cr["staged"])
+	}
+	files = sectionFiles(cr, "unstaged")
+	if len(files) != 1 || files[0].(map[string]any)["path"] != "file.txt" || files[0].(map[string]any)["removed"] != float64(1) {
+		t.Fatalf("change_receipt.unstaged=%v, want the run's edit of file.txt", cr["unstaged"])
+	}
+	unstaged, _ := cr["unstaged"].(map[string]any)
+	if numstat, _ := unstaged["numstat"].(string); numstat != "1\t1\tfile.txt" {
+		t.Fatalf("change_receipt.unstaged.numstat=%q", numstat)
+	}
+}
+
+func TestCodexTool_ChangeReceipt_UntrackedContent(t *testing.T) {
+	repo := initTestRepo(t)
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "return_diff": true})
+	cr, _ := out["change_receipt"].(map[string]any)
+	untracked, _ := cr["untracked"].([]any)
+	if len(untracked) != 1 {
+		t.Fatalf("change_receipt.untracked=%v, want new.txt", untracked)
+	}
+	if f := untracked[0].(map[string]any); f["path"] != "new.txt" || f["content"] != "codex was here\n" || f["truncated"] != nil {
+		t.Fatalf("change_receipt.untracked[0]=%v", f)
+	}
+
+	// Without return_diff, untracked files are listed without their contents. The
+	// untracked new.txt from before this run is left out.
+	t.Setenv(fakeWriteFileEnv, "other.txt")
+	out = callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo})
+	cr, _ = out["change_receipt"].(map[string]any)
+	untracked, _ = cr["untracked"].([]any)
+	if len(untracked) != 1 || untracked[0].(map[string]any)["path"] != "other.txt" || untracked[0].(map[string]any)["content"] != nil {
+		t.Fatalf("change_receipt.untracked=%v, want other.txt without content", untracked)
+	}
+}
+
+func gitOutput(t *testing.T, repo string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
 }
 
 func TestCodexTool_ChangeReceipt_NonGitDir(t *testing.T) {
@@ -263,11 +377,9 @@ func TestCodexTool_ChangeReceipt_TruncatesDiff(t *testing.T) {
 	runGit("add", "big.txt")
 	runGit("commit", "-m", "init")
 
-	// Create a large diff (>64KiB).
-	blob := strings.Repeat("a", 100000) + "\n"
-	if err := os.WriteFile(path, []byte(blob), 0o644); err != nil {
-		t.Fatalf("modify file: %v", err)
-	}
+	// Codex makes a large diff (>64KiB).
+	t.Setenv(fakeWriteFileEnv, path)
+	t.Setenv(fakeWriteContentEnv, strings.Repeat("a", 100000)+"\n")
 
 	ctx := context.Background()
 	cfg := config.Default()
@@ -289,7 +401,7 @@ func TestCodexTool_ChangeReceipt_TruncatesDiff(t *testing.T) {
 	}
 	defer cs.Close()
 
-	t.Setenv(fakeCodexEnv, "success_tool_call")
+	t.Setenv(fakeCodexEnv, "write_file")
 
 	out, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{
 		Name: "codex",
@@ -0,0 +1,241 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/w31r4/codex-mcp-go/internal/codex"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/rollout"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type ListCodexThreadsInput struct {
+	Cd     string `json:"cd,omitempty"`
+	Offset *int   `json:"offset,omitempty"`
+	Limit  *int   `json:"limit,omitempty"`
+}
+
+// CodexThread is a thread found in the codex rollout directory.
+type CodexThread struct {
+	SessionID        string `json:"SESSION_ID"`
+	CreatedAt        string `json:"created_at,omitempty"`
+	Cwd              string `json:"cwd,omitempty"`
+	FirstUserMessage string `json:"first_user_message,omitempty"`
+	Model            string `json:"model,omitempty"`
+	Sandbox          string `json:"sandbox,omitempty"`
+	CLIVersion       string `json:"cli_version,omitempty"`
+	Originator       string `json:"originator,omitempty"`
+	// Tracked reports whether the server already tracks the thread as a session.
+	Tracked bool `json:"tracked"`
+}
+
+type ListCodexThreadsOutput struct {
+	SessionsDir string        `json:"sessions_dir"`
+	Threads     []CodexThread `json:"threads"`
+	Total       int           `json:"total"`
+	NextOffset  int           `json:"next_offset"`
+	HasMore     bool          `json:"has_more"`
+}
+
+type ImportCodexThreadInput struct {
+	SessionID string            `json:"SESSION_ID"`
+	Title     string            `json:"title,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type ImportCodexThreadOutput struct {
+	Imported bool         `json:"imported"`
+	Thread   CodexThread  `json:"thread"`
+	Session  session.View `json:"session"`
+}
+
+func buildListCodexThreadsInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"cd":     {Type: "string", Description: "Only return threads started in this directory or below it."},
+			"offset": {Type: "number", Description: "Skip this many threads (newest first). Start with 0."},
+			"limit":  {Type: "number", Description: "Maximum number of threads to return (default 20, max 100)."},
+		},
+	}
+}
+
+func buildImportCodexThreadInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Codex thread ID to import (see list_codex_threads)."},
+			"title":      {Type: "string", Description: "Optional human-readable title for the session."},
+			"labels": {
+				Type:                 "object",
+				Description:          "Optional string labels stored on the session.",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func codexThreadView(t rollout.Thread) CodexThread {
+	v := CodexThread{
+		SessionID:        t.ID,
+		Cwd:              t.Cwd,
+		FirstUserMessage: t.FirstUserMessage,
+		Model:            t.Model,
+		Sandbox:          t.Sandbox,
+		CLIVersion:       t.CLIVersion,
+		Originator:       t.Originator,
+	}
+	if !t.CreatedAt.IsZero() {
+		v.CreatedAt = t.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	_, v.Tracked = globalSessions.Get(t.ID)
+	return v
+}
+
+func handleListCodexThreads(ctx context.Context, req *mcp.CallToolRequest, input ListCodexThreadsInput) (result *mcp.CallToolResult, output ListCodexThreadsOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "list_codex_threads")
+	logging.LogRequest(ctx, map[string]any{"cd": input.Cd})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("list_codex_threads", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "threads": len(output.Threads), "total": output.Total}, err)
+	}()
+
+	offset := 0
+	if input.Offset != nil && *input.Offset > 0 {
+		offset = *input.Offset
+	}
+	limit := 20
+	if input.Limit != nil && *input.Limit > 0 {
+		limit = *input.Limit
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	dir := codexSessionsDir()
+	if dir == "" {
+		return nil, ListCodexThreadsOutput{}, cerrors.New(cerrors.InternalError, "codex sessions directory is unknown; set codex.sessions_dir")
+	}
+	cd := strings.TrimSpace(input.Cd)
+	if cd != "" {
+		cd = normalizeWorkdir(cd)
+	}
+	threads, total, listErr := rollout.List(dir, rollout.ListOptions{Cwd: cd, Offset: offset, Limit: limit})
+	if listErr != nil {
+		return nil, ListCodexThreadsOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to scan codex sessions directory", listErr).
+			WithData("sessions_dir", dir)
+	}
+
+	output.SessionsDir = dir
+	output.Threads = make([]CodexThread, 0, len(threads))
+	for _, t := range threads {
+		output.Threads = append(output.Threads, codexThreadView(t))
+	}
+	output.Total = total
+	output.NextOffset = offset + limit
+	if output.NextOffset > total {
+		output.NextOffset = total
+	}
+	output.HasMore = output.NextOffset < total
+	return nil, output, nil
+}
+
+func handleImportCodexThread(ctx context.Context, req *mcp.CallToolRequest, input ImportCodexThreadInput) (result *mcp.CallToolResult, output ImportCodexThreadOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "import_codex_thread")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id": strings.TrimSpace(input.SessionID),
+		"title":      input.Title,
+		"labels":     input.Labels,
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("import_codex_thread", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "imported": output.Imported}, err)
+	}()
+
+	threadID := strings.ToLower(strings.TrimSpace(input.SessionID))
+	if threadID == "" {
+		return nil, ImportCodexThreadOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	if !rollout.IsThreadID(threadID) {
+		return nil, ImportCodexThreadOutput{}, cerrors.ErrInvalidParams("SESSION_ID is not a codex thread ID").WithData("SESSION_ID", threadID)
+	}
+
+	dir := codexSessionsDir()
+	if dir == "" {
+		return nil, ImportCodexThreadOutput{}, cerrors.New(cerrors.InternalError, "codex sessions directory is unknown; set codex.sessions_dir")
+	}
+	path, findErr := rollout.Find(dir, threadID)
+	if findErr != nil {
+		if stderrors.Is(findErr, rollout.ErrNotFound) {
+			return nil, ImportCodexThreadOutput{}, cerrors.ErrSessionNotFound(threadID).WithData("sessions_dir", dir)
+		}
+		return nil, ImportCodexThreadOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to locate codex rollout", findErr).
+			WithData("SESSION_ID", threadID)
+	}
+	thread, readErr := rollout.ReadThread(path)
+	if readErr != nil {
+		return nil, ImportCodexThreadOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to read codex rollout", readErr).
+			WithData("SESSION_ID", threadID).
+			WithData("path", path)
+	}
+
+	// A thread without a known sandbox is pinned to the most restrictive one, so a
+	// resume cannot escalate it unless the server allows that.
+	sandbox := thread.Sandbox
+	if !codex.IsValidSandbox(sandbox) {
+		sandbox = codex.SandboxReadOnly
+	}
+	workDir := thread.Cwd
+	if workDir != "" {
+		workDir = normalizeWorkdir(workDir)
+	}
+	view, imported, importErr := globalSessions.Import(session.ImportSpec{
+		ID:        thread.ID,
+		WorkDir:   workDir,
+		Sandbox:   sandbox,
+		CreatedAt: thread.CreatedAt,
+		Title:     strings.TrimSpace(input.Title),
+		Labels:    input.Labels,
+	})
+	if importErr != nil {
+		return nil, ImportCodexThreadOutput{}, importErr
+	}
+	if imported {
+		if info, statErr := os.Stat(workDir); statErr == nil && info.IsDir() {
+			if root, ok := workdirGitRoot(ctx, workDir); ok {
+				globalSessions.SetGitRoot(thread.ID, root)
+				view, _ = globalSessions.Get(thread.ID)
+			}
+		}
+		globalSessions.AppendDiagnostic(thread.ID, session.DiagnosticSystem, "imported from "+path)
+		rc.Logger.Info("imported codex thread", "session_id", thread.ID, "path", path)
+	}
+
+	output.Imported = imported
+	output.Thread = codexThreadView(thread)
+	output.Session = view
+	return nil, output, nil
+}
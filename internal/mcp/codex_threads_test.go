@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+const importThreadID = "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a6c"
+
+func TestCodexThreads_ListAndImport(t *testing.T) {
+	dir, origin := t.TempDir(), t.TempDir()
+	day := filepath.Join(dir, "2025", "01", "02")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rollout := `{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"` + importThreadID + `","cwd":"` + origin + `","originator":"codex_cli_rs"}}` + "\n" +
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"turn_context","payload":{"cwd":"` + origin + `","model":"gpt-5-codex","sandbox_policy":{"mode":"workspace-write"}}}` + "\n" +
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"refactor the parser"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(day, "rollout-2025-01-02T10-00-00-"+importThreadID+".jsonl"), []byte(rollout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Codex.SessionsDir = dir
+	cs := connectTestClient(t, cfg)
+
+	out := callStructured(t, cs, "list_codex_threads", map[string]any{"cd": origin})
+	threads, _ := out["threads"].([]any)
+	if out["total"] != float64(1) || len(threads) != 1 {
+		t.Fatalf("list=%v, want one thread", out)
+	}
+	th, _ := threads[0].(map[string]any)
+	if th["SESSION_ID"] != importThreadID || th["model"] != "gpt-5-codex" || th["first_user_message"] != "refactor the parser" ||
+		th["created_at"] != "2025-01-02T10:00:00Z" || th["tracked"] != false {
+		t.Fatalf("thread=%v", th)
+	}
+	if other := callStructured(t, cs, "list_codex_threads", map[string]any{"cd": t.TempDir()}); other["total"] != float64(0) {
+		t.Fatalf("cd filter=%v, want no threads", other)
+	}
+
+	imp := callStructured(t, cs, "import_codex_thread", map[string]any{"SESSION_ID": importThreadID, "title": "from the TUI"})
+	sess, _ := imp["session"].(map[string]any)
+	if imp["imported"] != true || sess["cd"] != origin || sess["sandbox"] != "workspace-write" || sess["title"] != "from the TUI" {
+		t.Fatalf("import=%v", imp)
+	}
+	if again := callStructured(t, cs, "import_codex_thread", map[string]any{"SESSION_ID": importThreadID}); again["imported"] != false {
+		t.Fatalf("re-import=%v, want imported=false", again)
+	}
+	listed := callStructured(t, cs, "list_codex_threads", map[string]any{})
+	if threads, _ := listed["threads"].([]any); len(threads) != 1 || threads[0].(map[string]any)["tracked"] != true {
+		t.Fatalf("list after import=%v, want tracked", listed)
+	}
+
+	// The imported thread is pinned to its recorded workdir and sandbox.
+	payload := callToolError(t, cs, map[string]any{"PROMPT": "continue", "cd": t.TempDir(), "SESSION_ID": importThreadID})
+	if payload["code"] != float64(cerrors.WorkdirMismatch) {
+		t.Fatalf("error=%v, want WorkdirMismatch", payload)
+	}
+	payload = callToolError(t, cs, map[string]any{"PROMPT": "continue", "SESSION_ID": importThreadID, "sandbox": "danger-full-access"})
+	if payload["code"] != float64(cerrors.ParameterProhibited) {
+		t.Fatalf("error=%v, want ParameterProhibited", payload)
+	}
+
+	missing := callNamedToolError(t, cs, "import_codex_thread", map[string]any{"SESSION_ID": "0199a1b2-c3d4-7e5f-8a9b-ffffffffffff"})
+	if missing["code"] != float64(cerrors.SessionNotFound) {
+		t.Fatalf("error=%v, want SessionNotFound", missing)
+	}
+}
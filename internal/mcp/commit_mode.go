@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+// Values of CodexInput.CommitMode.
+const (
+	commitModeNone     = "none"
+	commitModeCommit   = "commit"
+	commitModeToBranch = "commit-to-branch"
+)
+
+const (
+	commitSubjectRunes = 72
+	commitBodyRunes    = 4000
+)
+
+// commitChanges commits the changes of a successful run as recorded in rec, per the
+// run's commit_mode, and records the outcome on rec.
+func (r *codexRun) commitChanges(rec *receipt.ChangeReceipt, agentMessages string) {
+	mode := r.input.CommitMode
+	if mode == "" || mode == commitModeNone || !rec.ReceiptAvailable {
+		return
+	}
+	cfg := config.Default().Commit
+	if globalConfig != nil {
+		cfg = globalConfig.Commit
+	}
+	opts := receipt.CommitOptions{
+		Message:     commitMessage(r.input.PROMPT, agentMessages, r.trackingID, r.turn, cfg.Trailers),
+		AuthorName:  cfg.AuthorName,
+		AuthorEmail: cfg.AuthorEmail,
+		Timeout:     worktreeGitTimeout,
+	}
+	if mode == commitModeToBranch {
+		opts.Branch = cfg.BranchPrefix + r.trackingID
+		rec.CommitBranch = opts.Branch
+	}
+	commit, err := receipt.CommitChanges(context.Background(), rec.GitRoot, rec.BaselineTree, rec.ResultTree, opts)
+	rec.Commit = commit
+	if err != nil {
+		rec.CommitError = err.Error()
+		r.logger.Warn("failed to commit changes", "error", err.Error())
+		globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "commit failed: "+err.Error())
+		return
+	}
+	if commit != "" {
+		globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "committed changes as "+commit)
+	}
+}
+
+// commitMessage builds the message of a run's commit: the first line of the prompt as
+// the subject, the agent's final message as the body, and trailers naming the thread.
+func commitMessage(prompt, agentMessages, sessionID string, turn int, trailers []string) string {
+	subject := ""
+	for _, line := range strings.Split(prompt, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subject = truncateRunes(line, commitSubjectRunes)
+			break
+		}
+	}
+	if subject == "" {
+		subject = "Apply codex changes"
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n\n")
+	if body := strings.TrimSpace(agentMessages); body != "" {
+		b.WriteString(truncateRunes(body, commitBodyRunes) + "\n\n")
+	}
+	fmt.Fprintf(&b, "Codex-Session-Id: %s\nCodex-Turn: %d\n", sessionID, turn)
+	for _, t := range trailers {
+		b.WriteString(strings.TrimSpace(t) + "\n")
+	}
+	return b.String()
+}
+
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+)
+
+func TestCodexTool_CommitMode_Commit(t *testing.T) {
+	repo := initTestRepo(t)
+	// Pre-existing work stays uncommitted.
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("dirty\n"), 0o644); err != nil {
+		t.Fatalf("modify file: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Commit.Trailers = []string{"Reviewed-by: pending"}
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	out := callStructured(t, cs, "codex", map[string]any{
+		"PROMPT":      "Add new.txt\n\nwith some details",
+		"cd":          repo,
+		"commit_mode": "commit",
+	})
+	cr, _ := out["change_receipt"].(map[string]any)
+	commit, _ := cr["commit"].(string)
+	if commit == "" || cr["commit_error"] != nil {
+		t.Fatalf("change_receipt commit=%v error=%v", cr["commit"], cr["commit_error"])
+	}
+	if head := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD")); head != commit {
+		t.Fatalf("HEAD=%s, want the commit %s", head, commit)
+	}
+
+	msg := gitOutput(t, repo, "log", "-1", "--format=%an <%ae>%n%B")
+	for _, want := range []string{
+		"codex-mcp <codex-mcp@localhost>\nAdd new.txt\n\nedited a file\n",
+		"Codex-Session-Id: t-123\nCodex-Turn: 0\nReviewed-by: pending\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("commit message=%q, want it to contain %q", msg, want)
+		}
+	}
+	if files := strings.TrimSpace(gitOutput(t, repo, "show", "--format=", "--name-only", "HEAD")); files != "new.txt" {
+		t.Fatalf("committed files=%q, want only new.txt", files)
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != " M file.txt\n" {
+		t.Fatalf("status after commit=%q, want only the pre-existing change", status)
+	}
+}
+
+func TestCodexTool_CommitMode_CommitToBranch(t *testing.T) {
+	repo := initTestRepo(t)
+	head := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD"))
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "commit_mode": "commit-to-branch"})
+	cr, _ := out["change_receipt"].(map[string]any)
+	if cr["commit_branch"] != "codex-mcp/t-123" || cr["commit"] == nil {
+		t.Fatalf("change_receipt commit=%v branch=%v error=%v", cr["commit"], cr["commit_branch"], cr["commit_error"])
+	}
+	if tip := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "codex-mcp/t-123")); tip != cr["commit"] {
+		t.Fatalf("branch tip=%s, want %v", tip, cr["commit"])
+	}
+	if parent := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "codex-mcp/t-123^")); parent != head {
+		t.Fatalf("branch parent=%s, want HEAD %s", parent, head)
+	}
+	if got := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD")); got != head {
+		t.Fatalf("HEAD moved to %s", got)
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != "?? new.txt\n" {
+		t.Fatalf("status=%q, want the worktree untouched", status)
+	}
+}
+
+func TestCodexTool_CommitMode_FailedRunNotCommitted(t *testing.T) {
+	repo := initTestRepo(t)
+	head := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD"))
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "write_file_fail")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	callNamedToolError(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "commit_mode": "commit"})
+
+	if got := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD")); got != head {
+		t.Fatalf("failed run was committed: HEAD=%s", got)
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != "?? new.txt\n" {
+		t.Fatalf("status=%q, want the change left uncommitted", status)
+	}
+}
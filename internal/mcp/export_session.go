@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/export"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type ExportSessionInput struct {
+	SessionID string `json:"SESSION_ID"`
+	Format    string `json:"format,omitempty"`
+}
+
+type ExportSessionOutput struct {
+	SessionID string `json:"SESSION_ID"`
+	Format    string `json:"format"`
+	MIMEType  string `json:"mime_type"`
+	Filename  string `json:"filename"`
+	SizeBytes int    `json:"size_bytes"`
+	// Content holds text formats; ContentBase64 holds tar/zip bundles.
+	Content       string `json:"content,omitempty"`
+	ContentBase64 string `json:"content_base64,omitempty"`
+}
+
+func buildExportSessionInputSchema() *jsonschema.Schema {
+	formats := make([]any, 0, len(export.ValidFormats))
+	for _, f := range export.ValidFormats {
+		formats = append(formats, string(f))
+	}
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Session identifier to export."},
+			"format": {
+				Type:        "string",
+				Enum:        formats,
+				Description: "markdown (default), html (self-contained page), json, or a tar/zip bundle of transcript.json, transcript.md and changes.patch (base64-encoded).",
+			},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleExportSession(ctx context.Context, req *mcp.CallToolRequest, input ExportSessionInput) (result *mcp.CallToolResult, output ExportSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "export_session")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id": strings.TrimSpace(input.SessionID),
+		"format":     input.Format,
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("export_session", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "size_bytes": output.SizeBytes}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, ExportSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	format, parseErr := export.ParseFormat(input.Format)
+	if parseErr != nil {
+		return nil, ExportSessionOutput{}, cerrors.ErrInvalidParams(parseErr.Error())
+	}
+	snap, ok := globalSessions.Snapshot(input.SessionID)
+	if !ok {
+		return nil, ExportSessionOutput{}, cerrors.ErrSessionNotFound(input.SessionID)
+	}
+	// The in-memory diagnostics are bounded and truncated; prefer the full log.
+	if logged, err := globalSessions.LoggedDiagnostics(snap.ID); err != nil {
+		rc.Logger.Warn("failed to read diagnostics log; exporting in-memory diagnostics", "error", err.Error())
+	} else {
+		snap = export.WithLoggedDiagnostics(snap, logged)
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, export.Build(snap, time.Now()), format); err != nil {
+		return nil, ExportSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to render export", err).
+			WithData("SESSION_ID", snap.ID)
+	}
+
+	output = ExportSessionOutput{
+		SessionID: snap.ID,
+		Format:    string(format),
+		MIMEType:  format.MIMEType(),
+		Filename:  "codex-session-" + snap.ID + "." + format.Extension(),
+		SizeBytes: buf.Len(),
+	}
+	if format.Binary() {
+		output.ContentBase64 = base64.StdEncoding.EncodeToString(buf.Bytes())
+	} else {
+		output.Content = buf.String()
+	}
+	return nil, output, nil
+}
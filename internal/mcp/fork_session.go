@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/rollout"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type ForkSessionInput struct {
+	SessionID string            `json:"SESSION_ID"`
+	Title     string            `json:"title,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type ForkSessionOutput struct {
+	Success         bool         `json:"success"`
+	SessionID       string       `json:"SESSION_ID"`
+	ParentSessionID string       `json:"parent_session_id"`
+	Session         session.View `json:"session"`
+}
+
+func buildForkSessionInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Codex thread to fork. Must not be running."},
+			"title":      {Type: "string", Description: "Title for the fork (defaults to the parent's title)."},
+			"labels": {
+				Type:                 "object",
+				Description:          "Labels for the fork, merged over the parent's labels.",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+// codexSessionsDir returns the directory holding codex rollouts.
+func codexSessionsDir() string {
+	if cfg := globalConfig; cfg != nil {
+		if dir := strings.TrimSpace(cfg.Codex.SessionsDir); dir != "" {
+			return dir
+		}
+	}
+	return rollout.DefaultDir()
+}
+
+func handleForkSession(ctx context.Context, req *mcp.CallToolRequest, input ForkSessionInput) (result *mcp.CallToolResult, output ForkSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "fork_session")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id": strings.TrimSpace(input.SessionID),
+		"title":      input.Title,
+		"labels":     input.Labels,
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("fork_session", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "fork_id": output.SessionID}, err)
+	}()
+
+	parentID := strings.TrimSpace(input.SessionID)
+	if parentID == "" {
+		return nil, ForkSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	if resolved, ok := globalSessions.Resolve(parentID); ok {
+		parentID = resolved
+	}
+	if view, ok := globalSessions.Get(parentID); ok && (view.State.Active() || view.State == session.StateQueued) {
+		return nil, ForkSessionOutput{}, cerrors.ErrInvalidParams("cannot fork a running session").WithData("SESSION_ID", parentID)
+	}
+	if !rollout.IsThreadID(parentID) {
+		return nil, ForkSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is not a codex thread ID").WithData("SESSION_ID", parentID)
+	}
+	title := strings.TrimSpace(input.Title)
+	if err := session.ValidateTitle(title); err != nil {
+		return nil, ForkSessionOutput{}, err
+	}
+	if err := session.ValidateLabels(input.Labels); err != nil {
+		return nil, ForkSessionOutput{}, err
+	}
+
+	dir := codexSessionsDir()
+	if dir == "" {
+		return nil, ForkSessionOutput{}, cerrors.New(cerrors.InternalError, "codex sessions directory is unknown; set codex.sessions_dir")
+	}
+	fork, copyErr := rollout.Copy(dir, parentID, time.Now())
+	if copyErr != nil {
+		if stderrors.Is(copyErr, rollout.ErrNotFound) {
+			return nil, ForkSessionOutput{}, cerrors.ErrSessionNotFound(parentID).WithData("sessions_dir", dir)
+		}
+		return nil, ForkSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to copy codex rollout", copyErr).
+			WithData("SESSION_ID", parentID)
+	}
+	rc.Logger.Info("forked codex thread", "parent", parentID, "fork", fork.ThreadID, "path", fork.Path)
+
+	spec := session.ForkSpec{
+		ParentID: parentID,
+		ChildID:  fork.ThreadID,
+		Title:    title,
+		Labels:   input.Labels,
+	}
+	if _, tracked := globalSessions.Get(parentID); !tracked {
+		// The manager has no record of the parent (e.g. a thread started from the
+		// Codex TUI), so take its workdir and sandbox from the rollout itself.
+		thread, readErr := rollout.ReadThread(fork.SourcePath)
+		if readErr != nil {
+			rc.Logger.Warn("failed to read parent rollout metadata", "path", fork.SourcePath, "error", readErr.Error())
+		}
+		spec.WorkDir = thread.Cwd
+		spec.Sandbox = thread.Sandbox
+	}
+	view, regErr := globalSessions.RegisterFork(spec)
+	if regErr != nil {
+		// Without a session the copied rollout would be an orphan thread.
+		if rmErr := os.Remove(fork.Path); rmErr != nil {
+			rc.Logger.Warn("failed to remove fork rollout", "path", fork.Path, "error", rmErr.Error())
+		}
+		return nil, ForkSessionOutput{}, regErr
+	}
+	globalSessions.AppendDiagnostic(fork.ThreadID, session.DiagnosticSystem, "forked from "+parentID)
+
+	return nil, ForkSessionOutput{
+		Success:         true,
+		SessionID:       fork.ThreadID,
+		ParentSessionID: parentID,
+		Session:         view,
+	}, nil
+}
@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+const forkParentID = "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b"
+
+func TestForkSession_CopiesRolloutAndRecordsLineage(t *testing.T) {
+	dir := t.TempDir()
+	day := filepath.Join(dir, "2025", "01", "02")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"` + forkParentID + `","cwd":"/repo"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(day, "rollout-2025-01-02T10-00-00-"+forkParentID+".jsonl"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.SessionsDir = dir
+	cs := connectTestClient(t, cfg)
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := globalSessions.Start(forkParentID, "/repo", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	// A running thread cannot be forked.
+	res, err := cs.CallTool(context.Background(), &mcpsdk.CallToolParams{
+		Name:      "fork_session",
+		Arguments: map[string]any{"SESSION_ID": forkParentID},
+	})
+	if err == nil && !res.IsError {
+		t.Fatalf("expected fork of a running session to fail")
+	}
+	globalSessions.MarkCompleted(forkParentID, 1, 0)
+
+	out := callStructured(t, cs, "fork_session", map[string]any{
+		"SESSION_ID": forkParentID,
+		"title":      "try approach B",
+	})
+	forkID, _ := out["SESSION_ID"].(string)
+	if out["success"] != true || forkID == "" || forkID == forkParentID || out["parent_session_id"] != forkParentID {
+		t.Fatalf("unexpected fork output: %+v", out)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", "rollout-*-"+forkID+".jsonl"))
+	if len(matches) != 1 {
+		t.Fatalf("fork rollout not written: %v", matches)
+	}
+
+	child := callStructured(t, cs, "get_session", map[string]any{"SESSION_ID": forkID})
+	cv, _ := child["session"].(map[string]any)
+	if cv["parent_session_id"] != forkParentID || cv["title"] != "try approach B" || cv["cd"] != "/repo" {
+		t.Fatalf("unexpected fork session: %+v", child)
+	}
+	parent := callStructured(t, cs, "get_session", map[string]any{"SESSION_ID": forkParentID})
+	pv, _ := parent["session"].(map[string]any)
+	children, _ := pv["child_session_ids"].([]any)
+	if len(children) != 1 || children[0] != forkID {
+		t.Fatalf("parent child_session_ids=%v, want [%s]", pv["child_session_ids"], forkID)
+	}
+
+	// Unknown threads are reported as not found.
+	res, err = cs.CallTool(context.Background(), &mcpsdk.CallToolParams{
+		Name:      "fork_session",
+		Arguments: map[string]any{"SESSION_ID": "0199a1b2-0000-7000-8000-000000000000"},
+	})
+	if err == nil && !res.IsError {
+		t.Fatalf("expected fork of an unknown thread to fail")
+	}
+}
+
+func TestForkSession_UntrackedParentTakesOriginFromRollout(t *testing.T) {
+	const parentID = "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a6c"
+	dir := t.TempDir()
+	day := filepath.Join(dir, "2025", "01", "02")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lines := `{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"` + parentID + `","cwd":"/tui/repo"}}` + "\n" +
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"turn_context","payload":{"cwd":"/tui/repo","model":"gpt-5-codex","sandbox_policy":{"mode":"workspace-write"}}}` + "\n"
+	if err := os.WriteFile(filepath.Join(day, "rollout-2025-01-02T10-00-00-"+parentID+".jsonl"), []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.SessionsDir = dir
+	cs := connectTestClient(t, cfg)
+
+	out := callStructured(t, cs, "fork_session", map[string]any{"SESSION_ID": parentID})
+	forkID, _ := out["SESSION_ID"].(string)
+	child := callStructured(t, cs, "get_session", map[string]any{"SESSION_ID": forkID})
+	cv, _ := child["session"].(map[string]any)
+	if cv["cd"] != "/tui/repo" || cv["sandbox"] != "workspace-write" || cv["parent_session_id"] != parentID {
+		t.Fatalf("unexpected fork session: %+v", child)
+	}
+}
+
+func TestForkSession_RemovesRolloutCopyWhenRegistrationFails(t *testing.T) {
+	const parentID = "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a7d"
+	dir := t.TempDir()
+	day := filepath.Join(dir, "2025", "01", "02")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"` + parentID + `","cwd":"/repo"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(day, "rollout-2025-01-02T10-00-00-"+parentID+".jsonl"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.SessionsDir = dir
+	cs := connectTestClient(t, cfg)
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := globalSessions.Start(parentID, "/repo", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	globalSessions.MarkCompleted(parentID, 0, 0)
+	full := make(map[string]string, session.MaxLabels)
+	for i := 0; i < session.MaxLabels; i++ {
+		full[fmt.Sprintf("k%d", i)] = "v"
+	}
+	if _, _, err := globalSessions.UpdateMetadata(parentID, session.MetadataUpdate{Set: full}); err != nil {
+		t.Fatalf("UpdateMetadata() failed: %v", err)
+	}
+
+	// The merged labels exceed the limit, so the fork is not registered.
+	res, err := cs.CallTool(context.Background(), &mcpsdk.CallToolParams{
+		Name:      "fork_session",
+		Arguments: map[string]any{"SESSION_ID": parentID, "labels": map[string]any{"extra": "x"}},
+	})
+	if err == nil && !res.IsError {
+		t.Fatalf("expected fork with too many labels to fail")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", "rollout-*.jsonl"))
+	if len(matches) != 1 {
+		t.Fatalf("rollout files = %v, want only the parent", matches)
+	}
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type LabelSessionInput struct {
+	SessionID    string            `json:"SESSION_ID"`
+	Title        *string           `json:"title,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	RemoveLabels []string          `json:"remove_labels,omitempty"`
+}
+
+type LabelSessionOutput struct {
+	Found   bool         `json:"found"`
+	Session session.View `json:"session,omitempty"`
+}
+
+func buildLabelSessionInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Session identifier to update."},
+			"title":      {Type: "string", Description: "New session title (empty string clears it)."},
+			"labels": {
+				Type:                 "object",
+				Description:          "Labels to add or overwrite.",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+			"remove_labels": {
+				Type:        "array",
+				Description: "Label keys to remove.",
+				Items:       &jsonschema.Schema{Type: "string"},
+			},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleLabelSession(ctx context.Context, req *mcp.CallToolRequest, input LabelSessionInput) (result *mcp.CallToolResult, output LabelSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "label_session")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id":    strings.TrimSpace(input.SessionID),
+		"title":         input.Title,
+		"labels":        input.Labels,
+		"remove_labels": input.RemoveLabels,
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("label_session", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "found": output.Found}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, LabelSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+
+	view, found, updateErr := globalSessions.UpdateMetadata(input.SessionID, session.MetadataUpdate{
+		Title:  input.Title,
+		Set:    input.Labels,
+		Remove: input.RemoveLabels,
+	})
+	if updateErr != nil {
+		return nil, LabelSessionOutput{}, updateErr
+	}
+	output.Found = found
+	if found {
+		output.Session = view
+	}
+	return nil, output, nil
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/config"
+)
+
+func TestLabelSession_LabelsAreStoredAndFilterable(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+
+	s := NewServer(cfg)
+	c := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "client", Version: "test"}, nil)
+
+	t1, t2 := mcpsdk.NewInMemoryTransports()
+	ss, err := s.Connect(ctx, t1, nil)
+	if err != nil {
+		t.Fatalf("server Connect() failed: %v", err)
+	}
+	defer ss.Close()
+
+	cs, err := c.Connect(ctx, t2, nil)
+	if err != nil {
+		t.Fatalf("client Connect() failed: %v", err)
+	}
+	defer cs.Close()
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	res, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name: "codex",
+		Arguments: map[string]any{
+			"PROMPT": "hi",
+			"cd":     t.TempDir(),
+			"title":  "Fix login",
+			"labels": map[string]any{"ticket": "ABC-1"},
+		},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("codex call failed: err=%v res=%+v", err, res)
+	}
+
+	listSessions := func(labels map[string]any) []any {
+		t.Helper()
+		res, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{
+			Name:      "list_sessions",
+			Arguments: map[string]any{"labels": labels},
+		})
+		if err != nil {
+			t.Fatalf("list_sessions failed: %v", err)
+		}
+		sc, _ := res.StructuredContent.(map[string]any)
+		sessions, _ := sc["sessions"].([]any)
+		return sessions
+	}
+
+	sessions := listSessions(map[string]any{"ticket": "ABC-1"})
+	if len(sessions) != 1 {
+		t.Fatalf("list_sessions(ticket=ABC-1) len=%d, want 1", len(sessions))
+	}
+	got, _ := sessions[0].(map[string]any)
+	if got["SESSION_ID"] != "t-123" || got["title"] != "Fix login" {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+
+	res, err = cs.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name: "label_session",
+		Arguments: map[string]any{
+			"SESSION_ID":    "t-123",
+			"labels":        map[string]any{"owner": "alice"},
+			"remove_labels": []any{"ticket"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("label_session failed: %v", err)
+	}
+	sc, _ := res.StructuredContent.(map[string]any)
+	if sc["found"] != true {
+		t.Fatalf("label_session.found=%v, want true", sc["found"])
+	}
+
+	if sessions := listSessions(map[string]any{"ticket": "*"}); len(sessions) != 0 {
+		t.Fatalf("list_sessions(ticket=*) len=%d, want 0", len(sessions))
+	}
+	if sessions := listSessions(map[string]any{"owner": "alice"}); len(sessions) != 1 {
+		t.Fatalf("list_sessions(owner=alice) len=%d, want 1", len(sessions))
+	}
+}
@@ -3,12 +3,38 @@ package mcp
 import (
 	"context"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/w31r4/codex-mcp-go/internal/config"
 )
 
+func TestCodexInput_MatchesInputSchema(t *testing.T) {
+	props := buildInputSchema().Properties
+	typ := reflect.TypeOf(CodexInput{})
+	fields := make(map[string]bool, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields[name] = true
+		if props[name] == nil {
+			t.Errorf("CodexInput.%s (%q) is missing from buildInputSchema", typ.Field(i).Name, name)
+		}
+		if _, ok := typ.Field(i).Tag.Lookup("jsonschema"); ok {
+			t.Errorf("CodexInput.%s has a jsonschema tag; document it in buildInputSchema only", typ.Field(i).Name)
+		}
+	}
+	for name, prop := range props {
+		if !fields[name] {
+			t.Errorf("buildInputSchema property %q has no CodexInput field", name)
+		}
+		if prop.Description == "" {
+			t.Errorf("buildInputSchema property %q has no description", name)
+		}
+	}
+}
+
 func TestCodexTool_OutputSchemaShape(t *testing.T) {
 	ctx := context.Background()
 
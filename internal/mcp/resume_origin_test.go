@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func TestCodexTool_ResumeEnforcesOriginalWorkdirAndSandbox(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+
+	origin, other := t.TempDir(), t.TempDir()
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": origin})
+
+	// cd and sandbox come from the record when omitted.
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "again", "SESSION_ID": "t-123"})
+	if v, _ := globalSessions.Get("t-123"); v.WorkDir != origin || v.Sandbox != "read-only" {
+		t.Fatalf("resumed session=%+v, want cd %s", v, origin)
+	}
+
+	payload := callToolError(t, cs, map[string]any{"PROMPT": "elsewhere", "cd": other, "SESSION_ID": "t-123"})
+	if payload["code"] != float64(cerrors.WorkdirMismatch) {
+		t.Fatalf("error=%v, want WorkdirMismatch", payload)
+	}
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "elsewhere", "cd": other, "SESSION_ID": "t-123", "allow_workdir_change": true})
+	// Allowing one change does not move the thread's origin.
+	if o, _ := globalSessions.Origin("t-123"); o.WorkDir != origin {
+		t.Fatalf("origin=%+v, want %s", o, origin)
+	}
+
+	payload = callToolError(t, cs, map[string]any{"PROMPT": "write", "SESSION_ID": "t-123", "sandbox": "workspace-write"})
+	if payload["code"] != float64(cerrors.ParameterProhibited) {
+		t.Fatalf("error=%v, want ParameterProhibited", payload)
+	}
+
+	cfg.Security.AllowSandboxEscalation = true
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "write", "SESSION_ID": "t-123", "sandbox": "workspace-write"})
+}
+
+func TestCodexTool_CdRequiredForNewSessions(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	payload := callToolError(t, cs, map[string]any{"PROMPT": "hi", "SESSION_ID": "unknown-thread"})
+	if payload["code"] != float64(cerrors.InvalidParams) {
+		t.Fatalf("error=%v, want InvalidParams", payload)
+	}
+}
@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type RollbackSessionInput struct {
+	SessionID  string `json:"SESSION_ID"`
+	Checkpoint string `json:"checkpoint,omitempty"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+}
+
+type RollbackSessionOutput struct {
+	SessionID  string             `json:"SESSION_ID"`
+	GitRoot    string             `json:"git_root"`
+	Checkpoint receipt.Checkpoint `json:"checkpoint"`
+	DryRun     bool               `json:"dry_run"`
+	// Files are the files restored (or, for a dry run, that would be restored).
+	Files []receipt.FileChange `json:"files"`
+	// UndoCheckpoint is the checkpoint of the worktree as it was before the rollback.
+	UndoCheckpoint *receipt.Checkpoint `json:"undo_checkpoint,omitempty"`
+	// Checkpoints are all checkpoints of the session.
+	Checkpoints []receipt.Checkpoint `json:"checkpoints"`
+}
+
+func buildRollbackSessionInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Session identifier whose checkpoint to restore."},
+			"checkpoint": {
+				Type:        "string",
+				Description: "Checkpoint to restore: a turn index (the worktree as it was before that turn), \"undo\" (the worktree before the last rollback), or a checkpoint ref from a change receipt. Defaults to the latest turn.",
+			},
+			"dry_run": {Type: "boolean", Description: "Only list the files that would change. Default false."},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleRollbackSession(ctx context.Context, req *mcp.CallToolRequest, input RollbackSessionInput) (result *mcp.CallToolResult, output RollbackSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "rollback_session")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id": strings.TrimSpace(input.SessionID),
+		"checkpoint": input.Checkpoint,
+		"dry_run":    input.DryRun,
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("rollback_session", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "files": len(output.Files), "dry_run": output.DryRun}, err)
+	}()
+
+	sessionID := strings.TrimSpace(input.SessionID)
+	if sessionID == "" {
+		return nil, RollbackSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	view, ok := globalSessions.Get(sessionID)
+	if !ok {
+		return nil, RollbackSessionOutput{}, cerrors.ErrSessionNotFound(sessionID)
+	}
+	sessionID = view.SessionID
+	switch view.State {
+	case session.StateRunning, session.StateQueued, session.StateSuspended:
+		return nil, RollbackSessionOutput{}, cerrors.ErrInvalidParams("session is still active; wait for it or cancel it before rolling back").
+			WithData("SESSION_ID", sessionID).
+			WithData("state", string(view.State))
+	}
+	root := view.GitRoot
+	if root == "" {
+		return nil, RollbackSessionOutput{}, cerrors.ErrInvalidParams("session has no checkpoints: its workdir is not in a Git repository").
+			WithData("SESSION_ID", sessionID)
+	}
+
+	checkpoints, listErr := receipt.ListCheckpoints(ctx, root, sessionID, 0)
+	if listErr != nil {
+		return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to list checkpoints", listErr).
+			WithData("git_root", root)
+	}
+	target, found := findCheckpoint(checkpoints, strings.TrimSpace(input.Checkpoint))
+	if !found {
+		names := make([]string, 0, len(checkpoints))
+		for _, cp := range checkpoints {
+			names = append(names, cp.Name)
+		}
+		return nil, RollbackSessionOutput{}, cerrors.ErrInvalidParams("checkpoint not found").
+			WithData("SESSION_ID", sessionID).
+			WithData("checkpoint", input.Checkpoint).
+			WithData("checkpoints", names)
+	}
+
+	output = RollbackSessionOutput{
+		SessionID:   sessionID,
+		GitRoot:     root,
+		Checkpoint:  target,
+		DryRun:      input.DryRun,
+		Checkpoints: checkpoints,
+	}
+	if input.DryRun {
+		files, restoreErr := receipt.Restore(ctx, root, target.Commit, receipt.RestoreOptions{DryRun: true})
+		if restoreErr != nil {
+			return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to compare the worktree with the checkpoint", restoreErr).
+				WithData("checkpoint", target.Ref)
+		}
+		output.Files = nonNilFiles(files)
+		return nil, output, nil
+	}
+
+	// Codex runs in this repository hold the workdir lock; never restore under them.
+	acquired, lockErr := globalWorkLocks.acquire(ctx, root, workdirLockReject, 0)
+	if lockErr != nil {
+		return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to acquire workdir lock", lockErr).
+			WithData("workdir_key", root)
+	}
+	if !acquired {
+		return nil, RollbackSessionOutput{}, cerrors.ErrWorkdirBusy(root, root, string(workdirLockReject))
+	}
+	defer globalWorkLocks.release(root)
+
+	// Keep the current worktree, so the rollback can be undone with checkpoint "undo".
+	current, _, snapErr := receipt.Snapshot(ctx, root, receipt.SnapshotOptions{Timeout: receiptTimeout()})
+	if snapErr != nil {
+		return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to snapshot the worktree", snapErr).
+			WithData("git_root", root)
+	}
+	undoRef := receipt.CheckpointRef(sessionID, receipt.UndoCheckpoint)
+	undo, undoErr := receipt.CreateCheckpoint(ctx, current, undoRef, fmt.Sprintf("codex-mcp checkpoint before rollback to %s", target.Name), 0)
+	if undoErr != nil {
+		return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to create the undo checkpoint", undoErr).
+			WithData("git_root", root)
+	}
+	output.UndoCheckpoint = &undo
+
+	files, restoreErr := receipt.Restore(ctx, root, target.Commit, receipt.RestoreOptions{})
+	if restoreErr != nil {
+		return nil, RollbackSessionOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to restore the checkpoint", restoreErr).
+			WithData("checkpoint", target.Ref).
+			WithData("undo_checkpoint", undo.Ref)
+	}
+	output.Files = nonNilFiles(files)
+	if list, err := receipt.ListCheckpoints(ctx, root, sessionID, 0); err == nil {
+		output.Checkpoints = list
+	}
+	globalSessions.AppendDiagnostic(sessionID, session.DiagnosticSystem,
+		fmt.Sprintf("rolled back %d file(s) to checkpoint %s", len(files), target.Ref))
+	rc.Logger.Info("rolled back session", "session_id", sessionID, "checkpoint", target.Ref, "files", len(files))
+	return nil, output, nil
+}
+
+// findCheckpoint returns the checkpoint named by name (a turn index, "undo" or a ref),
+// or the latest turn checkpoint when name is empty.
+func findCheckpoint(checkpoints []receipt.Checkpoint, name string) (receipt.Checkpoint, bool) {
+	if name == "" {
+		for i := len(checkpoints) - 1; i >= 0; i-- {
+			if _, err := strconv.Atoi(checkpoints[i].Name); err == nil {
+				return checkpoints[i], true
+			}
+		}
+		return receipt.Checkpoint{}, false
+	}
+	for _, cp := range checkpoints {
+		if cp.Name == name || cp.Ref == name {
+			return cp, true
+		}
+	}
+	return receipt.Checkpoint{}, false
+}
+
+func nonNilFiles(files []receipt.FileChange) []receipt.FileChange {
+	if files == nil {
+		return []receipt.FileChange{}
+	}
+	return files
+}
@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+)
+
+func TestRollbackSession_RestoresCheckpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	gitOutput(t, repo, "init")
+	gitOutput(t, repo, "config", "user.email", "test@example.com")
+	gitOutput(t, repo, "config", "user.name", "test")
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	gitOutput(t, repo, "add", "file.txt")
+	gitOutput(t, repo, "commit", "-m", "init")
+	head := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD"))
+
+	// Uncommitted work from before the run must survive the rollback.
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("dirty\n"), 0o644); err != nil {
+		t.Fatalf("modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "keep.txt"), []byte("untracked\n"), 0o644); err != nil {
+		t.Fatalf("write untracked file: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	created := filepath.Join(repo, "sub", "new.txt")
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, created)
+	if err := os.MkdirAll(filepath.Dir(created), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo})
+	cr, _ := out["change_receipt"].(map[string]any)
+	if cr["checkpoint"] != "refs/codex-mcp/t-123/0" || cr["checkpoint_commit"] == nil {
+		t.Fatalf("change_receipt checkpoint=%v commit=%v, want refs/codex-mcp/t-123/0", cr["checkpoint"], cr["checkpoint_commit"])
+	}
+	if refs := strings.TrimSpace(gitOutput(t, repo, "for-each-ref", "--format=%(refname)", "refs/codex-mcp/")); refs != "refs/codex-mcp/t-123/0" {
+		t.Fatalf("checkpoint refs=%q, want only the thread's checkpoint", refs)
+	}
+
+	dry := callStructured(t, cs, "rollback_session", map[string]any{"SESSION_ID": "t-123", "dry_run": true})
+	files, _ := dry["files"].([]any)
+	if dry["dry_run"] != true || len(files) != 1 {
+		t.Fatalf("dry run=%+v, want one file", dry)
+	}
+	if f, _ := files[0].(map[string]any); f["path"] != "sub/new.txt" || f["change"] != "D" {
+		t.Fatalf("dry run file=%+v, want sub/new.txt deleted", files[0])
+	}
+	if _, err := os.Stat(created); err != nil {
+		t.Fatalf("dry run changed the worktree: %v", err)
+	}
+
+	res := callStructured(t, cs, "rollback_session", map[string]any{"SESSION_ID": "t-123", "checkpoint": "0"})
+	if undo, _ := res["undo_checkpoint"].(map[string]any); undo["ref"] != "refs/codex-mcp/t-123/undo" {
+		t.Fatalf("undo_checkpoint=%+v", res["undo_checkpoint"])
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("sub/new.txt still exists after rollback (err=%v)", err)
+	}
+	if _, err := os.Stat(filepath.Dir(created)); !os.IsNotExist(err) {
+		t.Fatalf("empty directory sub/ was not removed (err=%v)", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo, "file.txt")); string(b) != "dirty\n" {
+		t.Fatalf("file.txt=%q, want the pre-run content", b)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "keep.txt")); err != nil {
+		t.Fatalf("untracked keep.txt lost: %v", err)
+	}
+	if got := strings.TrimSpace(gitOutput(t, repo, "rev-parse", "HEAD")); got != head {
+		t.Fatalf("HEAD moved: %s, want %s", got, head)
+	}
+	if staged := gitOutput(t, repo, "diff", "--cached", "--name-only"); staged != "" {
+		t.Fatalf("index changed: %q", staged)
+	}
+	if stash := gitOutput(t, repo, "stash", "list"); stash != "" {
+		t.Fatalf("stash changed: %q", stash)
+	}
+
+	// The rollback itself can be undone.
+	callStructured(t, cs, "rollback_session", map[string]any{"SESSION_ID": "t-123", "checkpoint": "undo"})
+	if b, err := os.ReadFile(created); err != nil || !strings.Contains(string(b), "codex was here") {
+		t.Fatalf("undo did not restore sub/new.txt: %q, %v", b, err)
+	}
+}
+
+func TestRollbackSession_UnknownCheckpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	gitOutput(t, repo, "init")
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo})
+
+	payload := callNamedToolError(t, cs, "rollback_session", map[string]any{"SESSION_ID": "t-123", "checkpoint": "7"})
+	if payload["code"] != float64(cerrors.InvalidParams) || payload["message"] != "checkpoint not found" {
+		t.Fatalf("error=%v, want checkpoint not found", payload)
+	}
+	data, _ := payload["data"].(map[string]any)
+	if names, _ := data["checkpoints"].([]any); len(names) != 1 || names[0] != "0" {
+		t.Fatalf("error data=%v, want the available checkpoints", data)
+	}
+}
+
+func TestCheckpoints_PrunedPerSessionAndDeletedOnExpiry(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	gitOutput(t, repo, "init")
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Sessions.MaxCheckpoints = 2
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo})
+	for i := 0; i < 2; i++ {
+		callStructured(t, cs, "codex", map[string]any{"PROMPT": "again", "SESSION_ID": "t-123"})
+	}
+	refs := func() string {
+		return strings.TrimSpace(gitOutput(t, repo, "for-each-ref", "--format=%(refname)", "refs/codex-mcp/"))
+	}
+	if got := refs(); got != "refs/codex-mcp/t-123/1\nrefs/codex-mcp/t-123/2" {
+		t.Fatalf("checkpoint refs=%q, want only the 2 newest turns", got)
+	}
+
+	snap, ok := globalSessions.Snapshot("t-123")
+	if !ok {
+		t.Fatalf("session t-123 not found")
+	}
+	deleteExpiredCheckpoints(logging.GetLogger())(snap)
+	deadline := time.Now().Add(5 * time.Second)
+	for refs() != "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("checkpoint refs=%q after expiry, want none", refs())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
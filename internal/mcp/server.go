@@ -2,8 +2,14 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,23 +31,43 @@ var (
 	globalMetrics   = metrics.New()
 	globalConfig    = config.Default()
 	globalWorkLocks = newWorkdirLockManager()
+	// globalRunCtx bounds background (async) runs; Run replaces it with the server context.
+	globalRunCtx = context.Background()
 )
 
-// CodexInput represents the input parameters for the codex tool
+// CodexInput represents the input parameters for the codex tool. The parameters are
+// documented once, in buildInputSchema.
 type CodexInput struct {
-	PROMPT            string   `json:"PROMPT" jsonschema:"Instruction for the task to send to codex."`
-	Cd                string   `json:"cd" jsonschema:"Set the workspace root for codex before executing the task."`
-	Sandbox           string   `json:"sandbox,omitempty" jsonschema:"enum=read-only,enum=workspace-write,enum=danger-full-access,description=Sandbox policy for model-generated commands. Valid values: read-only (default) workspace-write danger-full-access."`
-	SessionID         string   `json:"SESSION_ID,omitempty" jsonschema:"Resume the specified session of the codex. Defaults to None, start a new session."`
-	SkipGitRepoCheck  *bool    `json:"skip_git_repo_check,omitempty" jsonschema:"Allow codex running outside a Git repository (useful for one-off directories)."`
-	ReturnAllMessages bool     `json:"return_all_messages,omitempty" jsonschema:"Return all messages (e.g. reasoning, tool calls, etc.) from the codex session. Set to False by default, only the agent's final reply message is returned."`
-	ReturnDiff        bool     `json:"return_diff,omitempty" jsonschema:"Include a truncated 'git diff' in the change receipt (best-effort). Defaults to false."`
-	Image             []string `json:"image,omitempty" jsonschema:"Attach one or more image files to the initial prompt. Separate multiple paths with commas or repeat the flag."`
-	Model             string   `json:"model,omitempty" jsonschema:"The model to use for the codex session. This parameter is restricted by server allowlist (disabled by default)."`
-	Yolo              *bool    `json:"yolo,omitempty" jsonschema:"Run every command without approvals or sandboxing. Defaults to false to avoid unsafe execution."`
-	Profile           string   `json:"profile,omitempty" jsonschema:"Configuration profile name to load from '~/.codex/config.toml'. This parameter is restricted by server allowlist (disabled by default)."`
-	TimeoutSeconds    *int     `json:"timeout_seconds,omitempty" jsonschema:"Total timeout (seconds) for the codex invocation. Defaults to 1800 (30 minutes) if not set; capped at 1800 (30 minutes)."`
-	NoOutputSeconds   *int     `json:"no_output_seconds,omitempty" jsonschema:"No-output watchdog (seconds). Kill the run if no output for this duration. Defaults to 0 (disabled) if not set."`
+	PROMPT             string            `json:"PROMPT"`
+	Cd                 string            `json:"cd,omitempty"`
+	Sandbox            string            `json:"sandbox,omitempty"`
+	SessionID          string            `json:"SESSION_ID,omitempty"`
+	AllowWorkdirChange bool              `json:"allow_workdir_change,omitempty"`
+	SkipGitRepoCheck   *bool             `json:"skip_git_repo_check,omitempty"`
+	ReturnAllMessages  bool              `json:"return_all_messages,omitempty"`
+	ReturnDiff         bool              `json:"return_diff,omitempty"`
+	Image              []string          `json:"image,omitempty"`
+	Model              string            `json:"model,omitempty"`
+	Yolo               *bool             `json:"yolo,omitempty"`
+	Profile            string            `json:"profile,omitempty"`
+	TimeoutSeconds     *int              `json:"timeout_seconds,omitempty"`
+	NoOutputSeconds    *int              `json:"no_output_seconds,omitempty"`
+	AddDir             []string          `json:"add_dir,omitempty"`
+	WebSearch          *bool             `json:"web_search,omitempty"`
+	ReasoningEffort    string            `json:"reasoning_effort,omitempty"`
+	ApprovalPolicy     string            `json:"approval_policy,omitempty"`
+	OSS                *bool             `json:"oss,omitempty"`
+	Title              string            `json:"title,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Async              bool              `json:"async,omitempty"`
+	Priority           int               `json:"priority,omitempty"`
+	Isolation          string            `json:"isolation,omitempty"`
+	CleanupWorktree    bool              `json:"cleanup_worktree,omitempty"`
+	CommitMode         string            `json:"commit_mode,omitempty"`
+
+	BudgetMaxTokens           int64 `json:"budget_max_tokens,omitempty"`
+	BudgetMaxExecutionSeconds int   `json:"budget_max_execution_seconds,omitempty"`
+	BudgetMaxTurns            int   `json:"budget_max_turns,omitempty"`
 }
 
 // CodexOutput represents the output from the codex tool
@@ -53,6 +79,9 @@ type CodexOutput struct {
 	ExecutionTimeMs int64                    `json:"execution_time_ms"`
 	ToolCallCount   int                      `json:"tool_call_count"`
 	ChangeReceipt   receipt.ChangeReceipt    `json:"change_receipt"`
+	Async           bool                     `json:"async,omitempty"`
+	Budget          *session.BudgetStatus    `json:"budget,omitempty"`
+	Timings         *session.TimingsView     `json:"timings,omitempty"`
 }
 
 type StatsInput struct{}
@@ -60,6 +89,15 @@ type StatsInput struct{}
 type StatsOutput struct {
 	Uptime  string           `json:"uptime"`
 	Metrics metrics.Snapshot `json:"metrics"`
+	Codex   CodexInfo        `json:"codex"`
+}
+
+// CodexInfo describes the detected codex CLI.
+type CodexInfo struct {
+	Path         string          `json:"path,omitempty"`
+	Version      string          `json:"version,omitempty"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	Error        string          `json:"error,omitempty"`
 }
 
 // buildInputSchema creates an explicit JSON Schema for CodexInput.
@@ -75,17 +113,21 @@ func buildInputSchema() *jsonschema.Schema {
 			},
 			"cd": {
 				Type:        "string",
-				Description: "Set the workspace root for codex before executing the task.",
+				Description: "Set the workspace root for codex before executing the task. Required for new sessions; defaults to the session's original workdir on resume.",
 			},
 			"sandbox": {
 				Type:        "string",
-				Description: "Sandbox policy for model-generated commands. Valid values: read-only (default), workspace-write, danger-full-access.",
+				Description: "Sandbox policy for model-generated commands. Valid values: read-only (default), workspace-write, danger-full-access. Defaults to the session's original sandbox on resume.",
 				Enum:        []any{"read-only", "workspace-write", "danger-full-access"},
 			},
 			"SESSION_ID": {
 				Type:        "string",
 				Description: "Resume the specified session of the codex. Defaults to None, start a new session.",
 			},
+			"allow_workdir_change": {
+				Type:        "boolean",
+				Description: "Allow resuming a session in a different cd than it was started in (outside its original git repository). Defaults to false.",
+			},
 			"skip_git_repo_check": {
 				Type:        "boolean",
 				Description: "Allow codex running outside a Git repository (useful for one-off directories).",
@@ -123,8 +165,74 @@ func buildInputSchema() *jsonschema.Schema {
 				Type:        "number",
 				Description: "No-output watchdog (seconds). Kill the run if no output for this duration. Defaults to 0 (disabled) if not set.",
 			},
+			"add_dir": {
+				Type:        "array",
+				Description: "Additional directories codex may write to (--add-dir). Relative paths are resolved against cd. This parameter is restricted by server allowlist (disabled by default).",
+				Items:       &jsonschema.Schema{Type: "string"},
+			},
+			"web_search": {
+				Type:        "boolean",
+				Description: "Enable or disable the web search tool. Enabling it is restricted by server policy (disabled by default).",
+			},
+			"reasoning_effort": {
+				Type:        "string",
+				Description: "Model reasoning effort. Valid values: minimal, low, medium, high. This parameter is restricted by server allowlist.",
+				Enum:        []any{"minimal", "low", "medium", "high"},
+			},
+			"approval_policy": {
+				Type:        "string",
+				Description: "When codex asks for approval before running commands. Valid values: untrusted, on-failure, on-request, never. This parameter is restricted by server allowlist (disabled by default).",
+				Enum:        []any{"untrusted", "on-failure", "on-request", "never"},
+			},
+			"oss": {
+				Type:        "boolean",
+				Description: "Use the local open-source model provider (--oss). This parameter is restricted by server policy (disabled by default).",
+			},
+			"title": {
+				Type:        "string",
+				Description: "Optional human-readable title for the session (e.g. the task name).",
+			},
+			"labels": {
+				Type:                 "object",
+				Description:          "Optional string labels stored on the session (e.g. ticket, owner). Merged into existing labels when resuming.",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+			"async": {
+				Type:        "boolean",
+				Description: "Return immediately with a tracking SESSION_ID while the run continues in the background. Use wait_session and get_session_result to collect the outcome. Defaults to false.",
+			},
+			"priority": {
+				Type:        "integer",
+				Description: "Scheduling priority when the server is at its concurrent session limit; higher runs first. Clamped to [-100, 100]. Defaults to 0.",
+			},
+			"isolation": {
+				Type:        "string",
+				Description: "Isolation of a new session. worktree runs codex in a dedicated git worktree on a new branch off HEAD (reported in change_receipt.worktree) and does not take the repository lock, so several sessions can write to one repository in parallel. Resumes run in the session's worktree. Defaults to none.",
+				Enum:        []any{isolationNone, isolationWorktree},
+			},
+			"cleanup_worktree": {
+				Type:        "boolean",
+				Description: "With isolation=worktree, remove the worktree after the run unless it has uncommitted changes; its branch is kept when it has new commits. Defaults to false.",
+			},
+			"commit_mode": {
+				Type:        "string",
+				Description: "Commit codex's changes (only the files it changed, as they are after the run) when the run succeeds. commit commits on top of HEAD and stages the committed files; commit-to-branch commits to a dedicated branch (see change_receipt.commit_branch) and leaves HEAD, the index and the worktree alone. Messages carry Codex-Session-Id and Codex-Turn trailers. Failed runs are never committed. Defaults to none.",
+				Enum:        []any{commitModeNone, commitModeCommit, commitModeToBranch},
+			},
+			"budget_max_tokens": {
+				Type:        "integer",
+				Description: "Maximum total tokens (input + output) across all turns of the thread. Kept for later resumes; capped by server configuration.",
+			},
+			"budget_max_execution_seconds": {
+				Type:        "integer",
+				Description: "Maximum total codex execution time (seconds) across all turns of the thread. Kept for later resumes; capped by server configuration.",
+			},
+			"budget_max_turns": {
+				Type:        "integer",
+				Description: "Maximum number of turns on the thread. Kept for later resumes; capped by server configuration.",
+			},
 		},
-		Required: []string{"PROMPT", "cd"},
+		Required: []string{"PROMPT"},
 	}
 }
 
@@ -159,7 +267,7 @@ func buildOutputSchema() *jsonschema.Schema {
 			},
 			"change_receipt": {
 				Type:        "object",
-				Description: "Best-effort Git change receipt for the working directory. Available only when cd is inside a Git repository and git is installed.",
+				Description: "Best-effort Git change receipt for the working directory. Available only when cd is inside a Git repository and git is installed. The work tree is snapshotted before the run, so changed_files, diff_stat and diff cover only this run's changes; files that were already dirty are listed in preexisting_changes.",
 				Properties: map[string]*jsonschema.Schema{
 					"receipt_available": {
 						Type:        "boolean",
@@ -177,9 +285,13 @@ func buildOutputSchema() *jsonschema.Schema {
 						Type:        "string",
 						Description: "Raw output of `git diff --stat` (best-effort).",
 					},
+					"numstat": {
+						Type:        "string",
+						Description: "Raw output of `git diff --numstat` for changed_files (best-effort).",
+					},
 					"changed_files": {
 						Type:        "array",
-						Description: "Parsed file changes from porcelain output.",
+						Description: "Files changed by the run (porcelain output when no pre-run snapshot could be taken).",
 						Items: &jsonschema.Schema{
 							Type: "object",
 							Properties: map[string]*jsonschema.Schema{
@@ -195,10 +307,63 @@ func buildOutputSchema() *jsonschema.Schema {
 									Type:        "string",
 									Description: "Worktree status (porcelain column Y).",
 								},
+								"change": {
+									Type:        "string",
+									Description: "Change since the pre-run snapshot: A (added), M (modified), D (deleted) or T (type changed).",
+								},
+								"added": {
+									Type:        "integer",
+									Description: "Lines added (numstat); absent for binary files.",
+								},
+								"removed": {
+									Type:        "integer",
+									Description: "Lines removed (numstat); absent for binary files.",
+								},
+								"binary": {
+									Type:        "boolean",
+									Description: "Whether Git treats the file as binary.",
+								},
 							},
 							Required: []string{"path"},
 						},
 					},
+					"preexisting_changes": {
+						Type:        "array",
+						Description: "Files that were already dirty (per `git status`) before the run; not attributed to codex.",
+						Items:       &jsonschema.Schema{Type: "object"},
+					},
+					"baseline_tree": {
+						Type:        "string",
+						Description: "Git tree object of the work tree before the run (index and worktree, untracked files included).",
+					},
+					"result_tree": {
+						Type:        "string",
+						Description: "Git tree object of the work tree after the run; `git diff <baseline_tree> <result_tree>` reproduces the diff.",
+					},
+					"checkpoint": {
+						Type:        "string",
+						Description: "Ref of the pre-run checkpoint (refs/codex-mcp/<SESSION_ID>/<turn>); rollback_session restores the worktree to it.",
+					},
+					"checkpoint_commit": {
+						Type:        "string",
+						Description: "Commit the checkpoint ref points at.",
+					},
+					"commit": {
+						Type:        "string",
+						Description: "SHA of the commit of this run's changes (commit_mode); absent when nothing was committed.",
+					},
+					"commit_branch": {
+						Type:        "string",
+						Description: "Branch that received the commit (commit_mode=commit-to-branch).",
+					},
+					"commit_error": {
+						Type:        "string",
+						Description: "Why the changes were not committed; does not fail the parent tool call.",
+					},
+					"worktree": {
+						Type:        "object",
+						Description: "Isolated worktree of the run (isolation=worktree): repo, path, branch, base_commit, and removed/branch_deleted/kept_reason after cleanup_worktree.",
+					},
 					"diff": {
 						Type:        "string",
 						Description: "Truncated `git diff` output; present only when return_diff=true.",
@@ -207,6 +372,27 @@ func buildOutputSchema() *jsonschema.Schema {
 						Type:        "boolean",
 						Description: "Whether the diff output was truncated due to size limits.",
 					},
+					"staged": {
+						Type:        "object",
+						Description: "The run's changes that it staged in the index: files (path, added, removed, binary), raw numstat, and diff/diff_truncated when return_diff=true. Changes made before the run are excluded (without a pre-run snapshot: everything staged, `git diff --cached`).",
+					},
+					"unstaged": {
+						Type:        "object",
+						Description: "The run's changes left unstaged in the worktree, in the same shape as staged (without a pre-run snapshot: `git diff`).",
+					},
+					"untracked": {
+						Type:        "array",
+						Description: "Untracked files the run created or changed (ignored files excluded; without a pre-run snapshot: every untracked file): path, size, binary, and with return_diff=true the content of text files, size-capped (truncated marks cut files).",
+						Items:       &jsonschema.Schema{Type: "object"},
+					},
+					"untracked_omitted": {
+						Type:        "integer",
+						Description: "Number of untracked files left out of untracked.",
+					},
+					"codex_version": {
+						Type:        "string",
+						Description: "Detected codex CLI version that produced the changes (best-effort).",
+					},
 					"receipt_error": {
 						Type:        "string",
 						Description: "Best-effort diagnostic string when receipt collection fails; does not fail the parent tool call.",
@@ -214,6 +400,18 @@ func buildOutputSchema() *jsonschema.Schema {
 				},
 				Required: []string{"receipt_available"},
 			},
+			"async": {
+				Type:        "boolean",
+				Description: "True when the run was started in the background (async=true); success then only means the run was accepted, and SESSION_ID is a tracking ID for wait_session/get_session_result.",
+			},
+			"budget": {
+				Type:        "object",
+				Description: "Thread budget after this run: limits, used (tokens, execution_ms, turns) and remaining. Omitted when no budget applies.",
+			},
+			"timings": {
+				Type:        "object",
+				Description: "Milliseconds spent in each phase of the run, in order: lock_wait_ms, queue_wait_ms, baseline_ms, spawn_ms, first_output_ms, thread_id_ms, first_agent_message_ms, process_exit_ms, receipt_ms; plus started_at and total_ms. Phases that were not reached are omitted.",
+			},
 		},
 		Required: []string{"success", "SESSION_ID", "agent_messages"},
 	}
@@ -232,7 +430,16 @@ func NewServer(cfg *config.Config) *mcp.Server {
 		cfg = globalConfig
 	}
 	globalConfig = cfg
-	globalSessions = session.NewManager(session.DefaultOptions())
+	if globalSessions != nil {
+		_ = globalSessions.Close()
+	}
+	closeWebhooks(webhookDrainTimeout)
+	globalWebhooks = newWebhookDispatcher(cfg)
+	var onEvent func(session.Event)
+	if globalWebhooks != nil {
+		onEvent = globalWebhooks.Notify
+	}
+	globalSessions = newSessionManager(cfg, onEvent)
 
 	s := mcp.NewServer(&mcp.Implementation{
 		Name:    cfg.Server.Name,
@@ -309,16 +516,138 @@ Edge Cases & Best Practices:
 		},
 	}, handleGetSession)
 
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "get_session_history",
+		Title:       "Get Session History",
+		Description: "Returns the ordered turn history (prompt hash/preview, sandbox, model, timings, final agent message, usage, change receipt) for the given SESSION_ID, with pagination.",
+		InputSchema: buildGetSessionHistoryInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			ReadOnlyHint: true,
+		},
+	}, handleGetSessionHistory)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "wait_session",
+		Title:       "Wait Session",
+		Description: "Blocks up to timeout_seconds until the run of the given SESSION_ID finishes and its result is available.",
+		InputSchema: buildWaitSessionInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			ReadOnlyHint: true,
+		},
+	}, handleWaitSession)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "get_session_result",
+		Title:       "Get Session Result",
+		Description: "Returns the full codex tool output (or error) of the last finished run of the given SESSION_ID. Results are kept until the session is evicted; a result restored after a server restart has its diffs capped and no all_messages.",
+		InputSchema: buildGetSessionResultInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			ReadOnlyHint: true,
+		},
+	}, handleGetSessionResult)
+
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "tail_session",
 		Title:       "Tail Session",
-		Description: "Returns recent session diagnostic entries for the given SESSION_ID.",
+		Description: "Returns recent session diagnostic entries for the given SESSION_ID. With sessions.diagnostics_dir set, older cursors and untruncated entries (full=true) are read from disk.",
 		InputSchema: buildTailSessionInputSchema(),
 		Annotations: &mcp.ToolAnnotations{
 			ReadOnlyHint: true,
 		},
 	}, handleTailSession)
 
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "label_session",
+		Title:       "Label Session",
+		Description: "Sets the title and adds, overwrites or removes labels on the session identified by SESSION_ID.",
+		InputSchema: buildLabelSessionInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			IdempotentHint: true,
+		},
+	}, handleLabelSession)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "export_session",
+		Title:       "Export Session",
+		Description: "Exports the transcript of the given SESSION_ID (turns, agent messages, commands, reasoning and the change receipt with diff) as Markdown, self-contained HTML, JSON, or a tar/zip bundle. Secrets are redacted before export.",
+		InputSchema: buildExportSessionInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			ReadOnlyHint: true,
+		},
+	}, handleExportSession)
+
+	forkDestructive, forkOpenWorld := false, false
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "fork_session",
+		Title:       "Fork Session",
+		Description: "Copies a finished Codex thread into a new thread (a new SESSION_ID) so it can be resumed independently with the codex tool. The fork records its parent; get_session shows the lineage.",
+		InputSchema: buildForkSessionInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			DestructiveHint: &forkDestructive,
+			OpenWorldHint:   &forkOpenWorld,
+		},
+	}, handleForkSession)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "list_codex_threads",
+		Title:       "List Codex Threads",
+		Description: "Lists Codex threads found in the codex sessions directory (including threads started in the interactive codex TUI), newest first, with their creation time, cwd, first user message preview and model. Use import_codex_thread to resume one with the codex tool.",
+		InputSchema: buildListCodexThreadsInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			ReadOnlyHint: true,
+		},
+	}, handleListCodexThreads)
+
+	importDestructive, importOpenWorld := false, false
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "import_codex_thread",
+		Title:       "Import Codex Thread",
+		Description: "Tracks an existing Codex thread (see list_codex_threads) as a session so it can be resumed with the codex tool. The session is pinned to the thread's recorded cwd and sandbox, so resumes get the same workdir and sandbox checks as threads started by this server. Importing a tracked thread is a no-op.",
+		InputSchema: buildImportCodexThreadInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			DestructiveHint: &importDestructive,
+			IdempotentHint:  true,
+			OpenWorldHint:   &importOpenWorld,
+		},
+	}, handleImportCodexThread)
+
+	suspendDestructive, suspendOpenWorld := false, false
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "suspend_session",
+		Title:       "Suspend Session",
+		Description: "Freezes the codex process of a running session (SIGSTOP to its process group) without ending the thread. The session's timeout and no-output watchdog pause until resume_session.",
+		InputSchema: buildSuspendSessionInputSchema("Running session identifier to suspend."),
+		Annotations: &mcp.ToolAnnotations{
+			DestructiveHint: &suspendDestructive,
+			IdempotentHint:  true,
+			OpenWorldHint:   &suspendOpenWorld,
+		},
+	}, handleSuspendSession)
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "resume_session",
+		Title:       "Resume Session",
+		Description: "Continues the codex process of a session suspended with suspend_session (SIGCONT); its timeout and no-output watchdog continue where they stopped.",
+		InputSchema: buildSuspendSessionInputSchema("Suspended session identifier to resume."),
+		Annotations: &mcp.ToolAnnotations{
+			DestructiveHint: &suspendDestructive,
+			IdempotentHint:  true,
+			OpenWorldHint:   &suspendOpenWorld,
+		},
+	}, handleResumeSession)
+
+	rollbackDestructive, rollbackOpenWorld := true, false
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "rollback_session",
+		Title:       "Rollback Session",
+		Description: "Restores the worktree of a session's Git repository to a checkpoint taken before one of its turns (tracked and untracked files; ignored files, HEAD, the index and the stash are left alone). Checkpoints are stored under refs/codex-mcp/<SESSION_ID>/<turn> and reported in change receipts. Use dry_run=true to list the files that would change; the worktree before a rollback is kept as checkpoint \"undo\".",
+		InputSchema: buildRollbackSessionInputSchema(),
+		Annotations: &mcp.ToolAnnotations{
+			DestructiveHint: &rollbackDestructive,
+			OpenWorldHint:   &rollbackOpenWorld,
+		},
+	}, handleRollbackSession)
+
 	destructive := true
 	openWorld := false
 	mcp.AddTool(s, &mcp.Tool{
@@ -349,12 +678,24 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		}
 	}
 	logging.LogRequest(ctx, map[string]any{
-		"cd":                  input.Cd,
-		"sandbox":             input.Sandbox,
-		"session_id":          strings.TrimSpace(input.SessionID),
-		"prompt_chars":        len(input.PROMPT),
-		"image_count":         len(input.Image),
-		"return_all_messages": input.ReturnAllMessages,
+		"cd":                           input.Cd,
+		"sandbox":                      input.Sandbox,
+		"session_id":                   strings.TrimSpace(input.SessionID),
+		"prompt_chars":                 len(input.PROMPT),
+		"image_count":                  len(input.Image),
+		"return_all_messages":          input.ReturnAllMessages,
+		"add_dir_count":                len(input.AddDir),
+		"reasoning_effort":             input.ReasoningEffort,
+		"approval_policy":              input.ApprovalPolicy,
+		"title":                        input.Title,
+		"labels":                       input.Labels,
+		"async":                        input.Async,
+		"priority":                     input.Priority,
+		"isolation":                    input.Isolation,
+		"commit_mode":                  input.CommitMode,
+		"budget_max_tokens":            input.BudgetMaxTokens,
+		"budget_max_execution_seconds": input.BudgetMaxExecutionSeconds,
+		"budget_max_turns":             input.BudgetMaxTurns,
 	})
 	defer func() {
 		success := err == nil && out.Success
@@ -376,10 +717,67 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		return nil, CodexOutput{}, cerrors.ErrInvalidParams("PROMPT is required and must be a non-empty string")
 	}
 
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	// Resumes default to where the thread was started.
+	origin, resuming := globalSessions.Origin(input.SessionID)
+	if resuming {
+		if input.Cd == "" {
+			input.Cd = origin.WorkDir
+		}
+		if input.Sandbox == "" {
+			input.Sandbox = origin.Sandbox
+		}
+	}
+
 	if input.Cd == "" {
 		return nil, CodexOutput{}, cerrors.ErrInvalidParams("cd is required and must be a non-empty string")
 	}
 
+	input.Isolation = strings.ToLower(strings.TrimSpace(input.Isolation))
+	switch input.Isolation {
+	case "", isolationNone:
+		if input.CleanupWorktree {
+			return nil, CodexOutput{}, cerrors.ErrInvalidParams("cleanup_worktree requires isolation=worktree")
+		}
+	case isolationWorktree:
+		if resuming {
+			// The thread already has a workdir (its worktree, if it was isolated).
+			return nil, CodexOutput{}, cerrors.ErrInvalidParams("isolation applies to new sessions only").
+				WithData("SESSION_ID", input.SessionID)
+		}
+	default:
+		return nil, CodexOutput{}, cerrors.ErrInvalidParams("isolation must be one of: none, worktree").
+			WithData("isolation", input.Isolation)
+	}
+	isolated := input.Isolation == isolationWorktree
+
+	input.CommitMode = strings.ToLower(strings.TrimSpace(input.CommitMode))
+	switch input.CommitMode {
+	case "", commitModeNone, commitModeCommit, commitModeToBranch:
+	default:
+		return nil, CodexOutput{}, cerrors.ErrInvalidParams("commit_mode must be one of: none, commit, commit-to-branch").
+			WithData("commit_mode", input.CommitMode)
+	}
+
+	input.Title = strings.TrimSpace(input.Title)
+	if err := session.ValidateTitle(input.Title); err != nil {
+		return nil, CodexOutput{}, err
+	}
+	if err := session.ValidateLabels(input.Labels); err != nil {
+		return nil, CodexOutput{}, err
+	}
+	if input.BudgetMaxTokens < 0 || input.BudgetMaxExecutionSeconds < 0 || input.BudgetMaxTurns < 0 {
+		return nil, CodexOutput{}, cerrors.ErrInvalidParams("budget limits must be >= 0")
+	}
+	budget, budgetErr := globalSessions.ResolveBudget(input.SessionID, session.Budget{
+		MaxTokens:      input.BudgetMaxTokens,
+		MaxExecutionMs: int64(input.BudgetMaxExecutionSeconds) * 1000,
+		MaxTurns:       input.BudgetMaxTurns,
+	}, budgetCaps(cfg))
+	if budgetErr != nil {
+		return nil, CodexOutput{}, budgetErr
+	}
+
 	if cfg != nil && !cfg.Security.IsWorkDirAllowed(input.Cd) {
 		return nil, CodexOutput{}, cerrors.New(cerrors.InvalidParams, "working directory is not allowed").
 			WithData("path", input.Cd)
@@ -399,7 +797,29 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	}
 
 	// Enforce per-workdir exclusivity to avoid concurrent writes in the same repo/workspace.
-	lockKey := workdirKey(ctx, input.Cd)
+	// Prefer git root when available so concurrent runs in the same repo
+	// (even from different subdirs) are mutually excluded.
+	gitRoot, inRepo := workdirGitRoot(ctx, input.Cd)
+	if resuming && !input.AllowWorkdirChange && !sameWorkdir(origin, input.Cd, gitRoot) {
+		return nil, CodexOutput{}, cerrors.ErrWorkdirMismatch(input.SessionID, origin.WorkDir, input.Cd)
+	}
+	if isolated && !inRepo {
+		return nil, CodexOutput{}, cerrors.ErrInvalidParams("isolation=worktree requires cd inside a Git repository").
+			WithData("cd", input.Cd)
+	}
+	if input.CommitMode != "" && input.CommitMode != commitModeNone && !inRepo {
+		return nil, CodexOutput{}, cerrors.ErrInvalidParams("commit_mode requires cd inside a Git repository").
+			WithData("cd", input.Cd)
+	}
+	lockKey := gitRoot
+	if !inRepo {
+		lockKey = normalizeWorkdir(input.Cd)
+	}
+	shareKey := lockKey
+	if isolated {
+		// The run takes the lock of its own worktree once that exists.
+		lockKey = ""
+	}
 	lockMode := workdirLockMode("reject")
 	lockTimeout := time.Duration(0)
 	if cfg != nil {
@@ -418,7 +838,14 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	if !acquired {
 		return nil, CodexOutput{}, cerrors.ErrWorkdirBusy(input.Cd, lockKey, string(lockMode))
 	}
-	defer globalWorkLocks.release(lockKey)
+	timings := session.Timings{Start: rc.StartTime, LockAcquired: time.Now()}
+	// Async runs hand the lock and run context over to the background goroutine.
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			globalWorkLocks.release(lockKey)
+		}
+	}()
 
 	// Set defaults
 	if input.Sandbox == "" {
@@ -428,7 +855,6 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 			input.Sandbox = "read-only"
 		}
 	}
-	input.SessionID = strings.TrimSpace(input.SessionID)
 
 	if cfg != nil && !cfg.Security.IsSandboxAllowed(input.Sandbox) {
 		return nil, CodexOutput{}, cerrors.ErrInvalidSandboxMode(input.Sandbox, cfg.Security.AllowedSandboxModes)
@@ -447,6 +873,11 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	if cfg != nil && cfg.Security.DisableYolo && yolo {
 		return nil, CodexOutput{}, cerrors.ErrParameterProhibited("yolo", "yolo is disabled by server policy")
 	}
+	if resuming && cfg != nil && !cfg.Security.AllowSandboxEscalation &&
+		codex.SandboxRank(input.Sandbox) > codex.SandboxRank(origin.Sandbox) {
+		return nil, CodexOutput{}, cerrors.ErrParameterProhibited("sandbox", "escalating the sandbox on resume is disabled by server policy").
+			WithData("origin_sandbox", origin.Sandbox)
+	}
 
 	if input.Model != "" {
 		if cfg == nil || !cfg.Security.IsModelAllowed(input.Model) {
@@ -460,6 +891,68 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		}
 	}
 
+	input.ReasoningEffort = strings.TrimSpace(input.ReasoningEffort)
+	if input.ReasoningEffort != "" {
+		if !slices.Contains(codex.ValidReasoningEfforts, input.ReasoningEffort) {
+			return nil, CodexOutput{}, cerrors.ErrInvalidParams("invalid reasoning_effort: "+input.ReasoningEffort).
+				WithData("valid", codex.ValidReasoningEfforts)
+		}
+		if cfg == nil || !cfg.Security.IsReasoningEffortAllowed(input.ReasoningEffort) {
+			return nil, CodexOutput{}, cerrors.ErrParameterProhibited("reasoning_effort", "reasoning_effort is not allowlisted by server configuration")
+		}
+	}
+
+	input.ApprovalPolicy = strings.TrimSpace(input.ApprovalPolicy)
+	if input.ApprovalPolicy != "" {
+		if !slices.Contains(codex.ValidApprovalPolicies, input.ApprovalPolicy) {
+			return nil, CodexOutput{}, cerrors.ErrInvalidParams("invalid approval_policy: "+input.ApprovalPolicy).
+				WithData("valid", codex.ValidApprovalPolicies)
+		}
+		if cfg == nil || !cfg.Security.IsApprovalPolicyAllowed(input.ApprovalPolicy) {
+			return nil, CodexOutput{}, cerrors.ErrParameterProhibited("approval_policy", "approval_policy is not allowlisted by server configuration")
+		}
+	}
+
+	if input.WebSearch != nil && *input.WebSearch && (cfg == nil || !cfg.Security.AllowWebSearch) {
+		return nil, CodexOutput{}, cerrors.ErrParameterProhibited("web_search", "web search is disabled by server policy")
+	}
+
+	oss := false
+	if input.OSS != nil {
+		oss = *input.OSS
+	}
+	if oss && (cfg == nil || !cfg.Security.AllowOSS) {
+		return nil, CodexOutput{}, cerrors.ErrParameterProhibited("oss", "oss is disabled by server policy")
+	}
+
+	addDirs := make([]string, 0, len(input.AddDir))
+	for _, dir := range input.AddDir {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(input.Cd, dir)
+		}
+		dir = filepath.Clean(dir)
+		if cfg == nil || !cfg.Security.IsAddDirAllowed(dir) {
+			return nil, CodexOutput{}, cerrors.ErrParameterProhibited("add_dir", "add_dir is not allowlisted by server configuration").
+				WithData("path", dir)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, CodexOutput{}, cerrors.ErrWorkdirNotFound(dir).WithData("param", "add_dir")
+			}
+			return nil, CodexOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to stat add_dir", err).
+				WithData("path", dir)
+		}
+		if !info.IsDir() {
+			return nil, CodexOutput{}, cerrors.ErrWorkdirNotDirectory(dir).WithData("param", "add_dir")
+		}
+		addDirs = append(addDirs, dir)
+	}
+
 	var timeout time.Duration
 	timeoutSeconds := 0
 	if cfg != nil {
@@ -498,6 +991,22 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		}
 	}
 
+	var worktree *receipt.Worktree
+	if isolated {
+		wt, runCd, wtErr := addRunWorktree(ctx, cfg, gitRoot, input.Cd)
+		if wtErr != nil {
+			return nil, CodexOutput{}, wtErr
+		}
+		// Nothing else can hold a new worktree's lock; take it so rollback_session and
+		// resumes are excluded while the run is active.
+		if ok, _ := globalWorkLocks.acquire(ctx, wt.Path, workdirLockReject, 0); !ok {
+			discardWorktree(&wt)
+			return nil, CodexOutput{}, cerrors.ErrWorkdirBusy(wt.Path, wt.Path, string(workdirLockReject))
+		}
+		worktree, lockKey, gitRoot, input.Cd = &wt, wt.Path, wt.Path, runCd
+		rc.Logger.Info("created worktree", "path", wt.Path, "branch", wt.Branch, "base", wt.BaseCommit)
+	}
+
 	// Create options for codex client
 	opts := codex.Options{
 		Prompt:            input.PROMPT,
@@ -515,6 +1024,13 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		ExecutablePath:    cfg.Codex.ExecutablePath,
 		MaxBufferedLines:  cfg.Codex.MaxBufferedLines,
 		Reporter:          reporter,
+		AddDirs:           addDirs,
+		WebSearch:         input.WebSearch,
+		ReasoningEffort:   input.ReasoningEffort,
+		ApprovalPolicy:    input.ApprovalPolicy,
+		OSS:               oss,
+		Limits:            cfg.Codex.Limits.CodexLimits(),
+		RecordDir:         strings.TrimSpace(cfg.Codex.RecordDir),
 	}
 
 	// Track this execution as a session.
@@ -522,18 +1038,174 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	if trackingID == "" {
 		trackingID = session.NewTemporaryID()
 	}
-	runCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	if _, startErr := globalSessions.Start(trackingID, input.Cd, input.Sandbox, cancel); startErr != nil {
+	runParent := ctx
+	if input.Async {
+		// Detach from the request: the run must outlive the tool call.
+		runParent = context.WithoutCancel(ctx)
+		reporter = progress.Nop
+	}
+	runCtx, cancel := context.WithCancel(runParent)
+	defer func() {
+		if !handedOff {
+			cancel()
+		}
+	}()
+	ticket, startErr := globalSessions.Enqueue(trackingID, input.Cd, input.Sandbox, cancel, session.QueueOptions{
+		Priority: clampPriority(input.Priority),
+		ShareKey: fairShareKey(cfg, req, shareKey),
+	})
+	if startErr != nil {
+		if worktree != nil {
+			discardWorktree(worktree)
+		}
 		return nil, CodexOutput{}, startErr
 	}
+	if inRepo {
+		globalSessions.SetGitRoot(trackingID, gitRoot)
+	}
+	globalSessions.SetBudget(trackingID, budget)
+	if input.Title != "" || len(input.Labels) > 0 {
+		meta := session.MetadataUpdate{Set: input.Labels}
+		if input.Title != "" {
+			meta.Title = &input.Title
+		}
+		if _, _, metaErr := globalSessions.UpdateMetadata(trackingID, meta); metaErr != nil {
+			rc.Logger.Warn("failed to store session metadata", "error", metaErr.Error())
+		}
+	}
+	turn, _ := globalSessions.BeginTurn(trackingID, session.TurnStart{
+		Prompt:  input.PROMPT,
+		Sandbox: input.Sandbox,
+		Model:   input.Model,
+	})
+
+	run := &codexRun{
+		input:      input,
+		opts:       opts,
+		reporter:   reporter,
+		trackingID: trackingID,
+		logger:     rc.Logger,
+		ticket:     ticket,
+		timings:    timings,
+		turn:       turn,
+		worktree:   worktree,
+	}
+	if input.Async {
+		handedOff = true
+		stop := context.AfterFunc(globalRunCtx, cancel)
+		go func() {
+			defer globalWorkLocks.release(lockKey)
+			defer cancel()
+			defer stop()
+			_, _, _ = run.execute(context.WithoutCancel(ctx), runCtx)
+		}()
+		out = CodexOutput{
+			Success:   true,
+			SessionID: trackingID,
+			Async:     true,
+		}
+		callResult = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: "codex run started in the background; SESSION_ID=" + trackingID},
+			},
+		}
+		return callResult, out, nil
+	}
+	return run.execute(ctx, runCtx)
+}
+
+// codexRun is a validated codex invocation whose session has already been started.
+type codexRun struct {
+	input    CodexInput
+	opts     codex.Options
+	reporter progress.Reporter
+	logger   logging.Logger
+	// trackingID switches from the temporary ID to the thread ID once it is known.
+	trackingID string
+	// ticket holds the session's place in the run queue.
+	ticket *session.Ticket
+	// timings collects the phase timestamps of the run.
+	timings session.Timings
+	// turn is the index of the run's turn; checkpoint is the pre-run checkpoint (in
+	// the repository at checkpointRoot), if one was taken.
+	turn           int
+	checkpoint     *receipt.Checkpoint
+	checkpointRoot string
+	// worktree is the isolated worktree the run uses (isolation=worktree).
+	worktree *receipt.Worktree
+}
+
+// execute runs codex and records the outcome on the session. ctx bounds post-run work
+// (change receipts); runCtx bounds the codex process itself.
+func (r *codexRun) execute(ctx, runCtx context.Context) (callResult *mcp.CallToolResult, out CodexOutput, err error) {
+	if err = r.awaitSlot(runCtx); err == nil {
+		r.timings.SlotGranted = time.Now()
+		callResult, out, err = r.run(ctx, runCtx)
+	}
+	globalSessions.SetTimings(r.trackingID, r.timings)
+	for _, d := range r.timings.Durations() {
+		globalMetrics.RecordPhase(d.Phase, d.Duration)
+	}
+
+	result := session.Result{}
+	if err != nil {
+		var cerr *cerrors.Error
+		if !errors.As(err, &cerr) {
+			cerr = cerrors.ErrCodexExecutionFailed("failed to execute codex", err)
+		}
+		result.Error = cerr
+	} else if b, marshalErr := json.Marshal(out); marshalErr == nil {
+		result.Output = b
+		if stored, storedErr := json.Marshal(storedOutput(out)); storedErr == nil {
+			result.StoredOutput = stored
+		}
+	} else {
+		result.Error = cerrors.Wrap(cerrors.InternalError, "failed to encode result", marshalErr)
+	}
+	globalSessions.SetResult(r.trackingID, result)
+	return callResult, out, err
+}
 
+// receiptTimeout bounds the Git work of the pre-run snapshot and the change receipt.
+func receiptTimeout() time.Duration {
+	if cfg := globalConfig; cfg != nil && cfg.Codex.ReceiptTimeoutSeconds > 0 {
+		return time.Duration(cfg.Codex.ReceiptTimeoutSeconds) * time.Second
+	}
+	return time.Duration(config.Default().Codex.ReceiptTimeoutSeconds) * time.Second
+}
+
+// storedOutput is the form of out kept in the session store: the receipt texts are
+// capped like the session's own receipt, and all_messages are left out.
+func storedOutput(out CodexOutput) CodexOutput {
+	out.AllMessages = nil
+	if capped := out.ChangeReceipt.Capped(session.PersistedReceiptTextBytes); capped != nil {
+		out.ChangeReceipt = *capped
+	}
+	return out
+}
+
+// awaitSlot blocks while the session is queued behind the MaxRunning limit, reporting
+// its queue position as progress.
+func (r *codexRun) awaitSlot(ctx context.Context) error {
+	if !r.ticket.Queued() {
+		return nil
+	}
+	globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "waiting for a free session slot")
+	return r.ticket.Wait(ctx, func(pos session.QueuePosition) {
+		msg := fmt.Sprintf("queued for a session slot (position %d of %d)", pos.Position, pos.Queued)
+		r.reporter.Report(ctx, msg)
+		globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, msg)
+	})
+}
+
+func (r *codexRun) run(ctx, runCtx context.Context) (callResult *mcp.CallToolResult, out CodexOutput, err error) {
+	input, opts := r.input, r.opts
 	// Record best-effort diagnostics for local debugging and post-timeout inspection.
-	getSessionID := func() string { return trackingID }
-	globalSessions.AppendDiagnostic(trackingID, session.DiagnosticSystem, "session started")
-	globalSessions.AppendDiagnostic(trackingID, session.DiagnosticSystem, "workdir lock acquired")
+	getSessionID := func() string { return r.trackingID }
+	globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "session started")
+	globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "workdir lock acquired")
 	opts.Reporter = diagnosticsReporter{
-		next:         reporter,
+		next:         r.reporter,
 		getSessionID: getSessionID,
 	}
 	opts.OnRawLine = func(line []byte) {
@@ -543,41 +1215,127 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		}
 		globalSessions.AppendDiagnostic(id, session.DiagnosticOutput, string(line))
 	}
+	opts.OnStderrLine = func(line []byte, severity string) {
+		id := strings.TrimSpace(getSessionID())
+		if id == "" {
+			return
+		}
+		globalSessions.AppendDiagnosticLevel(id, session.DiagnosticStderr, severity, string(line))
+	}
 	opts.OnThreadID = func(threadID string) {
 		threadID = strings.TrimSpace(threadID)
 		if threadID == "" {
 			return
 		}
 		// For new sessions, switch from temporary id to the real thread id as soon as we observe it.
-		if input.SessionID != "" || threadID == trackingID {
+		if input.SessionID != "" || threadID == r.trackingID {
 			return
 		}
-		if ok, updateErr := globalSessions.UpdateID(trackingID, threadID); updateErr == nil && ok {
-			trackingID = threadID
-			globalSessions.AppendDiagnostic(trackingID, session.DiagnosticSystem, "received SESSION_ID")
+		if ok, updateErr := globalSessions.UpdateID(r.trackingID, threadID); updateErr == nil && ok {
+			r.trackingID = threadID
+			globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "received SESSION_ID")
+		}
+	}
+
+	// suspend_session and resume_session signal the process through the session.
+	opts.Control = codex.NewControl()
+	globalSessions.SetProcessControl(r.trackingID, opts.Control)
+
+	// Enforce the thread budget mid-run: cancel once the remaining time or tokens run out.
+	// The time budget is paused with the process while it is suspended.
+	budgetCtx, stopBudget := context.WithCancelCause(runCtx)
+	defer stopBudget(nil)
+	if status, ok := globalSessions.BudgetStatus(r.trackingID); ok {
+		if remaining := status.Remaining.ExecutionMs; remaining != nil {
+			limit := status.Limits.MaxExecutionMs
+			stopTimer := opts.Control.AfterFunc(time.Duration(*remaining)*time.Millisecond, func() {
+				stopBudget(cerrors.ErrBudgetExceeded("execution_ms", limit, limit))
+			})
+			defer stopTimer()
+		}
+		if limit := status.Limits.MaxTokens; limit > 0 {
+			used := status.Used.Tokens
+			opts.OnUsage = func(u codex.Usage) {
+				if total := used + u.Total(); total > limit {
+					stopBudget(cerrors.ErrBudgetExceeded("tokens", total, limit))
+				}
+			}
+		}
+	}
+
+	// Snapshot the work tree so the receipt reports only what this run changed.
+	var baseline *receipt.Baseline
+	if b, ok, snapErr := receipt.Snapshot(runCtx, input.Cd, receipt.SnapshotOptions{Timeout: receiptTimeout()}); snapErr != nil {
+		r.logger.Warn("failed to snapshot work tree; receipt will include pre-existing changes", "error", snapErr.Error())
+		globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "baseline snapshot failed: "+snapErr.Error())
+	} else if ok {
+		baseline = &b
+		// Keep the snapshot as a checkpoint that rollback_session can restore.
+		ref := receipt.CheckpointRef(r.trackingID, strconv.Itoa(r.turn))
+		msg := fmt.Sprintf("codex-mcp checkpoint before turn %d", r.turn)
+		if cp, cpErr := receipt.CreateCheckpoint(runCtx, b, ref, msg, 0); cpErr != nil {
+			r.logger.Warn("failed to create checkpoint", "error", cpErr.Error())
+			globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "checkpoint failed: "+cpErr.Error())
+		} else {
+			r.checkpoint, r.checkpointRoot = &cp, b.GitRoot
 		}
 	}
+	r.timings.BaselineTaken = time.Now()
 
 	// Execute codex
 	runStart := time.Now()
-	codexResult, runErr := codex.Run(runCtx, opts)
+	codexResult, runErr := codex.Run(budgetCtx, opts)
 	runDuration := time.Since(runStart)
+	if codexResult != nil {
+		r.timings.Spawned = codexResult.Timings.Spawned
+		r.timings.FirstOutput = codexResult.Timings.FirstOutput
+		r.timings.ThreadID = codexResult.Timings.ThreadID
+		r.timings.FirstAgentMessage = codexResult.Timings.FirstAgentMessage
+		r.timings.ProcessExited = codexResult.Timings.Exited
+	}
+	if codexResult != nil && !opts.Limits.IsZero() {
+		if codexResult.LimitNote != "" {
+			r.logger.Warn("resource limits partially enforced", "enforcement", codexResult.LimitEnforcement, "note", codexResult.LimitNote)
+		} else {
+			r.logger.Info("resource limits applied", "enforcement", codexResult.LimitEnforcement)
+		}
+	}
+	if codexResult != nil && codexResult.RecordingPath != "" {
+		r.logger.Info("codex run recorded", "session_id", r.trackingID, "recording", codexResult.RecordingPath)
+	}
 	if runErr != nil {
 		// Best-effort: collect a change receipt even on failure/cancellation so users can inspect what changed.
 		failureReceipt := receipt.Collect(context.Background(), input.Cd, receipt.CollectOptions{
 			ReturnDiff: input.ReturnDiff,
+			Baseline:   baseline,
+			Timeout:    receiptTimeout(),
 		})
+		r.timings.ReceiptCollected = time.Now()
+		if codexResult != nil {
+			failureReceipt.CodexVersion = codexResult.CodexVersion
+		}
 		// Best-effort: if this was a new session, update the temporary tracking ID to the real thread_id when known.
-		if input.SessionID == "" && codexResult != nil && strings.TrimSpace(codexResult.SessionID) != "" && codexResult.SessionID != trackingID {
-			if ok, updateErr := globalSessions.UpdateID(trackingID, codexResult.SessionID); updateErr == nil && ok {
-				trackingID = codexResult.SessionID
+		if input.SessionID == "" && codexResult != nil && strings.TrimSpace(codexResult.SessionID) != "" && codexResult.SessionID != r.trackingID {
+			if ok, updateErr := globalSessions.UpdateID(r.trackingID, codexResult.SessionID); updateErr == nil && ok {
+				r.trackingID = codexResult.SessionID
 			}
 		}
-		_ = globalSessions.SetChangeReceipt(trackingID, failureReceipt)
+		if codexResult != nil {
+			globalSessions.SetTurnResult(r.trackingID, codexResult.AgentMessages, sessionUsage(codexResult.Usage))
+		}
+		r.recordCheckpoint(&failureReceipt)
+		r.recordWorktree(&failureReceipt)
+		_ = globalSessions.SetChangeReceipt(r.trackingID, failureReceipt)
+		var budgetErr *cerrors.Error
+		if errors.As(context.Cause(budgetCtx), &budgetErr) {
+			budgetErr = budgetErr.WithData("SESSION_ID", r.trackingID)
+			globalSessions.MarkFailed(r.trackingID, budgetErr)
+			return nil, CodexOutput{}, budgetErr
+		}
 		if errors.Is(runCtx.Err(), context.Canceled) {
-			globalSessions.MarkCancelled(trackingID, "cancelled")
+			globalSessions.MarkCancelled(r.trackingID, "cancelled")
 		} else {
-			globalSessions.MarkFailed(trackingID, runErr)
+			globalSessions.MarkFailed(r.trackingID, runErr)
 		}
 		var cerr *cerrors.Error
 		if errors.As(runErr, &cerr) {
@@ -587,12 +1345,14 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	}
 
 	// Best-effort: if this was a new session, update the temporary tracking ID to the real thread_id when known.
-	if input.SessionID == "" && codexResult != nil && strings.TrimSpace(codexResult.SessionID) != "" && codexResult.SessionID != trackingID {
-		if ok, updateErr := globalSessions.UpdateID(trackingID, codexResult.SessionID); updateErr == nil && ok {
-			trackingID = codexResult.SessionID
+	if input.SessionID == "" && codexResult != nil && strings.TrimSpace(codexResult.SessionID) != "" && codexResult.SessionID != r.trackingID {
+		if ok, updateErr := globalSessions.UpdateID(r.trackingID, codexResult.SessionID); updateErr == nil && ok {
+			r.trackingID = codexResult.SessionID
 		}
 	}
 
+	globalSessions.SetTurnResult(r.trackingID, codexResult.AgentMessages, sessionUsage(codexResult.Usage))
+
 	// Check if execution was successful
 	if !codexResult.Success {
 		msg := strings.TrimSpace(codexResult.Error)
@@ -601,20 +1361,34 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		}
 		failureReceipt := receipt.Collect(context.Background(), input.Cd, receipt.CollectOptions{
 			ReturnDiff: input.ReturnDiff,
+			Baseline:   baseline,
+			Timeout:    receiptTimeout(),
 		})
-		_ = globalSessions.SetChangeReceipt(trackingID, failureReceipt)
+		r.timings.ReceiptCollected = time.Now()
+		failureReceipt.CodexVersion = codexResult.CodexVersion
+		r.recordCheckpoint(&failureReceipt)
+		r.recordWorktree(&failureReceipt)
+		_ = globalSessions.SetChangeReceipt(r.trackingID, failureReceipt)
 		errOut := cerrors.New(cerrors.CodexExecutionFailed, msg)
-		globalSessions.MarkFailed(trackingID, errOut)
+		globalSessions.MarkFailed(r.trackingID, errOut)
 		return nil, CodexOutput{}, errOut
 	}
 
-	globalSessions.MarkCompleted(trackingID, runDuration.Milliseconds(), codexResult.ToolCallCount)
-
 	// Best-effort: collect a post-run change receipt for local review.
 	changeReceipt := receipt.Collect(ctx, input.Cd, receipt.CollectOptions{
 		ReturnDiff: input.ReturnDiff,
+		Baseline:   baseline,
+		Timeout:    receiptTimeout(),
 	})
-	_ = globalSessions.SetChangeReceipt(trackingID, changeReceipt)
+	r.timings.ReceiptCollected = time.Now()
+	changeReceipt.CodexVersion = codexResult.CodexVersion
+	r.recordCheckpoint(&changeReceipt)
+	r.commitChanges(&changeReceipt, codexResult.AgentMessages)
+	r.recordWorktree(&changeReceipt)
+	_ = globalSessions.SetChangeReceipt(r.trackingID, changeReceipt)
+	// Complete only once the receipt is attached, so the completed event carries it
+	// (as failed and cancelled already do).
+	globalSessions.MarkCompleted(r.trackingID, runDuration.Milliseconds(), codexResult.ToolCallCount)
 
 	// Prepare the response
 	out = CodexOutput{
@@ -624,11 +1398,15 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 		ExecutionTimeMs: runDuration.Milliseconds(),
 		ToolCallCount:   codexResult.ToolCallCount,
 		ChangeReceipt:   changeReceipt,
+		Timings:         r.timings.View(),
 	}
 
 	if input.ReturnAllMessages {
 		out.AllMessages = codexResult.AllMessages
 	}
+	if status, ok := globalSessions.BudgetStatus(r.trackingID); ok && !status.Limits.IsZero() {
+		out.Budget = &status
+	}
 
 	callResult = &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -638,6 +1416,58 @@ func handleCodexTool(ctx context.Context, req *mcp.CallToolRequest, input CodexI
 	return callResult, out, nil
 }
 
+// recordCheckpoint adds the run's checkpoint to rec. A new session's checkpoint is
+// first moved from its temporary ID to the thread ID; then the session's oldest turn
+// checkpoints beyond sessions.max_checkpoints are deleted.
+func (r *codexRun) recordCheckpoint(rec *receipt.ChangeReceipt) {
+	if r.checkpoint == nil {
+		return
+	}
+	if ref := receipt.CheckpointRef(r.trackingID, r.checkpoint.Name); ref != r.checkpoint.Ref {
+		if err := receipt.MoveCheckpoint(context.Background(), r.checkpointRoot, r.checkpoint.Ref, ref, 0); err != nil {
+			r.logger.Warn("failed to rename checkpoint", "ref", r.checkpoint.Ref, "error", err.Error())
+		} else {
+			r.checkpoint.Ref = ref
+		}
+	}
+	rec.Checkpoint = r.checkpoint.Ref
+	rec.CheckpointCommit = r.checkpoint.Commit
+
+	keep := config.Default().Sessions.MaxCheckpoints
+	if globalConfig != nil {
+		keep = globalConfig.Sessions.MaxCheckpoints
+	}
+	if n, err := receipt.PruneCheckpoints(context.Background(), r.checkpointRoot, r.trackingID, keep, 0); err != nil {
+		r.logger.Warn("failed to prune checkpoints", "error", err.Error())
+	} else if n > 0 {
+		globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, fmt.Sprintf("deleted %d old checkpoint(s) (max_checkpoints=%d)", n, keep))
+	}
+}
+
+// budgetCaps returns the server-wide budget caps from cfg.
+func budgetCaps(cfg *config.Config) session.Budget {
+	if cfg == nil {
+		return session.Budget{}
+	}
+	return session.Budget{
+		MaxTokens:      cfg.Sessions.BudgetMaxTokens,
+		MaxExecutionMs: int64(cfg.Sessions.BudgetMaxExecutionSeconds) * 1000,
+		MaxTurns:       cfg.Sessions.BudgetMaxTurns,
+	}
+}
+
+func sessionUsage(u *codex.Usage) *session.Usage {
+	if u == nil {
+		return nil
+	}
+	return &session.Usage{
+		InputTokens:           u.InputTokens,
+		CachedInputTokens:     u.CachedInputTokens,
+		OutputTokens:          u.OutputTokens,
+		ReasoningOutputTokens: u.ReasoningOutputTokens,
+	}
+}
+
 func handleStats(ctx context.Context, req *mcp.CallToolRequest, input StatsInput) (result *mcp.CallToolResult, output StatsOutput, err error) {
 	ctx, rc := logging.NewRequestContext(ctx, "stats")
 	logging.LogRequest(ctx, map[string]any{})
@@ -656,13 +1486,38 @@ func handleStats(ctx context.Context, req *mcp.CallToolRequest, input StatsInput
 	output = StatsOutput{
 		Uptime:  time.Since(serverStartTime).String(),
 		Metrics: globalMetrics.Snapshot(),
+		Codex:   detectCodexInfo(ctx),
 	}
 	return nil, output, nil
 }
 
+func detectCodexInfo(ctx context.Context) CodexInfo {
+	info := CodexInfo{}
+	if globalConfig != nil {
+		info.Path = strings.TrimSpace(globalConfig.Codex.ExecutablePath)
+	}
+	if info.Path == "" {
+		if p, err := exec.LookPath("codex"); err == nil {
+			info.Path = p
+		}
+	}
+	caps, err := codex.DetectCapabilities(ctx, info.Path)
+	if err != nil {
+		info.Error = err.Error()
+	}
+	if caps.HasVersion() {
+		info.Version = caps.Version.String()
+	}
+	info.Capabilities = caps.Features()
+	return info
+}
+
 // Run starts the MCP server over stdio transport.
 func Run(ctx context.Context, cfg *config.Config) error {
 	server := NewServer(cfg)
+	globalRunCtx = ctx
+	defer closeWebhooks(webhookDrainTimeout)
+	defer globalSessions.Close()
 	globalSessions.StartCleanup(ctx, time.Minute)
 	return server.Run(ctx, &mcp.StdioTransport{})
 }
@@ -131,3 +131,75 @@ func TestHandleCodexTool_ImageNotFound(t *testing.T) {
 		t.Fatalf("data.path=%v, want %v", cerr.Data["path"], missing)
 	}
 }
+
+func TestHandleCodexTool_ExecOptionsProhibitedByDefault(t *testing.T) {
+	dir := t.TempDir()
+	enabled := true
+	tests := []struct {
+		name  string
+		input CodexInput
+		param string
+	}{
+		{name: "add_dir", input: CodexInput{AddDir: []string{dir}}, param: "add_dir"},
+		{name: "web_search", input: CodexInput{WebSearch: &enabled}, param: "web_search"},
+		{name: "approval_policy", input: CodexInput{ApprovalPolicy: "never"}, param: "approval_policy"},
+		{name: "oss", input: CodexInput{OSS: &enabled}, param: "oss"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.input
+			in.PROMPT = "hi"
+			in.Cd = dir
+			_, _, err := handleCodexTool(context.Background(), nil, in)
+			var cerr *cerrors.Error
+			if !stderrors.As(err, &cerr) {
+				t.Fatalf("expected structured error, got %T: %v", err, err)
+			}
+			if cerr.Code != cerrors.ParameterProhibited {
+				t.Fatalf("code=%v, want %v", cerr.Code, cerrors.ParameterProhibited)
+			}
+			if cerr.Data["parameter"] != tt.param {
+				t.Fatalf("data.parameter=%v, want %v", cerr.Data["parameter"], tt.param)
+			}
+		})
+	}
+}
+
+func TestHandleCodexTool_InvalidReasoningEffort(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := handleCodexTool(context.Background(), nil, CodexInput{
+		PROMPT:          "hi",
+		Cd:              dir,
+		ReasoningEffort: "extreme",
+	})
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) {
+		t.Fatalf("expected structured error, got %T: %v", err, err)
+	}
+	if cerr.Code != cerrors.InvalidParams {
+		t.Fatalf("code=%v, want %v", cerr.Code, cerrors.InvalidParams)
+	}
+}
+
+func TestHandleListSessions_RejectsInvalidFilters(t *testing.T) {
+	for _, input := range []ListSessionsInput{
+		{State: []string{"sleeping"}},
+		{StartedAfter: "yesterday"},
+		{Order: "newest"},
+		{Cursor: "bogus"},
+	} {
+		_, _, err := handleListSessions(context.Background(), nil, input)
+		var cerr *cerrors.Error
+		if !stderrors.As(err, &cerr) || cerr.Code != cerrors.InvalidParams {
+			t.Fatalf("input %+v: expected InvalidParams, got %v", input, err)
+		}
+	}
+
+	_, out, err := handleListSessions(context.Background(), nil, ListSessionsInput{State: []string{"Running"}})
+	if err != nil {
+		t.Fatalf("handleListSessions() failed: %v", err)
+	}
+	if out.Total != len(out.Sessions) {
+		t.Fatalf("total=%d, sessions=%d", out.Total, len(out.Sessions))
+	}
+}
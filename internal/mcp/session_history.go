@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type GetSessionHistoryInput struct {
+	SessionID       string `json:"SESSION_ID"`
+	Offset          *int   `json:"offset,omitempty"`
+	Limit           *int   `json:"limit,omitempty"`
+	IncludeReceipts *bool  `json:"include_receipts,omitempty"`
+}
+
+type GetSessionHistoryOutput struct {
+	Found      bool               `json:"found"`
+	SessionID  string             `json:"SESSION_ID"`
+	Total      int                `json:"total"`
+	Turns      []session.TurnView `json:"turns,omitempty"`
+	NextOffset int                `json:"next_offset"`
+	HasMore    bool               `json:"has_more"`
+}
+
+func buildGetSessionHistoryInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID":       {Type: "string", Description: "Session identifier to read the turn history of."},
+			"offset":           {Type: "number", Description: "Return turns with index >= offset (oldest first). Start with 0."},
+			"limit":            {Type: "number", Description: "Maximum number of turns to return (default 20, max 100)."},
+			"include_receipts": {Type: "boolean", Description: "Include each turn's change receipt (default true)."},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleGetSessionHistory(ctx context.Context, req *mcp.CallToolRequest, input GetSessionHistoryInput) (result *mcp.CallToolResult, output GetSessionHistoryOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "get_session_history")
+	logging.LogRequest(ctx, map[string]any{
+		"session_id": strings.TrimSpace(input.SessionID),
+	})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("get_session_history", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "found": output.Found, "turns": len(output.Turns)}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, GetSessionHistoryOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+
+	offset := 0
+	if input.Offset != nil && *input.Offset > 0 {
+		offset = *input.Offset
+	}
+	limit := 20
+	if input.Limit != nil && *input.Limit > 0 {
+		limit = *input.Limit
+	}
+
+	turns, total, next, found := globalSessions.History(input.SessionID, offset, limit)
+	if input.IncludeReceipts != nil && !*input.IncludeReceipts {
+		for i := range turns {
+			turns[i].ChangeReceipt = nil
+		}
+	}
+
+	output.Found = found
+	output.SessionID = input.SessionID
+	output.Total = total
+	output.Turns = turns
+	output.NextOffset = next
+	output.HasMore = next < total
+	return nil, output, nil
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/config"
+)
+
+func TestGetSessionHistory_RecordsTurnsAcrossResume(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+
+	s := NewServer(cfg)
+	c := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "client", Version: "test"}, nil)
+
+	t1, t2 := mcpsdk.NewInMemoryTransports()
+	ss, err := s.Connect(ctx, t1, nil)
+	if err != nil {
+		t.Fatalf("server Connect() failed: %v", err)
+	}
+	defer ss.Close()
+
+	cs, err := c.Connect(ctx, t2, nil)
+	if err != nil {
+		t.Fatalf("client Connect() failed: %v", err)
+	}
+	defer cs.Close()
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	workdir := t.TempDir()
+	for _, args := range []map[string]any{
+		{"PROMPT": "first", "cd": workdir},
+		{"PROMPT": "second", "cd": workdir, "SESSION_ID": "t-123"},
+	} {
+		res, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{Name: "codex", Arguments: args})
+		if err != nil {
+			t.Fatalf("codex call failed: %v", err)
+		}
+		if res.IsError {
+			t.Fatalf("codex call returned error: %+v", res.Content)
+		}
+	}
+
+	res, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "get_session_history",
+		Arguments: map[string]any{"SESSION_ID": "t-123", "limit": 1},
+	})
+	if err != nil {
+		t.Fatalf("get_session_history failed: %v", err)
+	}
+	sc, ok := res.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("structuredContent type=%T, want map", res.StructuredContent)
+	}
+	if sc["found"] != true || sc["total"] != float64(2) || sc["has_more"] != true {
+		t.Fatalf("unexpected history page: %+v", sc)
+	}
+	turns, _ := sc["turns"].([]any)
+	if len(turns) != 1 {
+		t.Fatalf("turns len=%d, want 1", len(turns))
+	}
+	first, _ := turns[0].(map[string]any)
+	if first["prompt_preview"] != "first" || first["state"] != "completed" || first["agent_message"] != "hello from codex" {
+		t.Fatalf("unexpected first turn: %+v", first)
+	}
+
+	res, err = cs.CallTool(ctx, &mcpsdk.CallToolParams{
+		Name:      "get_session_history",
+		Arguments: map[string]any{"SESSION_ID": "t-123", "offset": sc["next_offset"]},
+	})
+	if err != nil {
+		t.Fatalf("get_session_history failed: %v", err)
+	}
+	sc, _ = res.StructuredContent.(map[string]any)
+	turns, _ = sc["turns"].([]any)
+	if len(turns) != 1 || sc["has_more"] != false {
+		t.Fatalf("unexpected second page: %+v", sc)
+	}
+	second, _ := turns[0].(map[string]any)
+	if second["prompt_preview"] != "second" || second["index"] != float64(1) {
+		t.Fatalf("unexpected second turn: %+v", second)
+	}
+}
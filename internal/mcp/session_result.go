@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultWaitSeconds = 30
+	maxWaitSeconds     = 600
+)
+
+type WaitSessionInput struct {
+	SessionID      string `json:"SESSION_ID"`
+	TimeoutSeconds *int   `json:"timeout_seconds,omitempty"`
+}
+
+type WaitSessionOutput struct {
+	Found   bool         `json:"found"`
+	Done    bool         `json:"done"`
+	Session session.View `json:"session,omitempty"`
+}
+
+type GetSessionResultInput struct {
+	SessionID string `json:"SESSION_ID"`
+}
+
+type GetSessionResultOutput struct {
+	Found     bool           `json:"found"`
+	SessionID string         `json:"SESSION_ID"`
+	State     session.State  `json:"state,omitempty"`
+	Ready     bool           `json:"ready"`
+	Result    *CodexOutput   `json:"result,omitempty"`
+	Error     *cerrors.Error `json:"error,omitempty"`
+}
+
+func buildWaitSessionInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID":      {Type: "string", Description: "Session identifier (the tracking SESSION_ID returned by an async codex call, or the thread ID)."},
+			"timeout_seconds": {Type: "number", Description: "Maximum time to wait for the run to finish (default 30, max 600)."},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func buildGetSessionResultInputSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: "Session identifier (the tracking SESSION_ID returned by an async codex call, or the thread ID)."},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleWaitSession(ctx context.Context, req *mcp.CallToolRequest, input WaitSessionInput) (result *mcp.CallToolResult, output WaitSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "wait_session")
+	logFields := map[string]any{"session_id": strings.TrimSpace(input.SessionID)}
+	if input.TimeoutSeconds != nil {
+		logFields["timeout_seconds"] = *input.TimeoutSeconds
+	}
+	logging.LogRequest(ctx, logFields)
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("wait_session", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "found": output.Found, "done": output.Done}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, WaitSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	waitSeconds := defaultWaitSeconds
+	if input.TimeoutSeconds != nil && *input.TimeoutSeconds >= 0 {
+		waitSeconds = *input.TimeoutSeconds
+	}
+	if waitSeconds > maxWaitSeconds {
+		waitSeconds = maxWaitSeconds
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(waitSeconds)*time.Second)
+	defer cancel()
+	view, done, found := globalSessions.Wait(waitCtx, input.SessionID)
+	output.Found = found
+	output.Done = done
+	if found {
+		output.Session = view
+	}
+	return nil, output, nil
+}
+
+func handleGetSessionResult(ctx context.Context, req *mcp.CallToolRequest, input GetSessionResultInput) (result *mcp.CallToolResult, output GetSessionResultOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, "get_session_result")
+	logging.LogRequest(ctx, map[string]any{"session_id": strings.TrimSpace(input.SessionID)})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest("get_session_result", success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "found": output.Found, "ready": output.Ready}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, GetSessionResultOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+
+	res, view, found := globalSessions.Result(input.SessionID)
+	output.Found = found
+	output.SessionID = input.SessionID
+	if !found {
+		return nil, output, nil
+	}
+	output.SessionID = view.SessionID
+	output.State = view.State
+	if res == nil {
+		return nil, output, nil
+	}
+	output.Ready = true
+	output.Error = res.Error
+	if len(res.Output) > 0 {
+		var out CodexOutput
+		if err := json.Unmarshal(res.Output, &out); err != nil {
+			return nil, GetSessionResultOutput{}, cerrors.Wrap(cerrors.InternalError, "failed to decode stored result", err)
+		}
+		output.Result = &out
+	}
+	return nil, output, nil
+}
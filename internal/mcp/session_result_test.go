@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/w31r4/codex-mcp-go/internal/config"
+)
+
+func connectTestClient(t *testing.T, cfg *config.Config) *mcpsdk.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+
+	s := NewServer(cfg)
+	c := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "client", Version: "test"}, nil)
+
+	t1, t2 := mcpsdk.NewInMemoryTransports()
+	ss, err := s.Connect(ctx, t1, nil)
+	if err != nil {
+		t.Fatalf("server Connect() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = ss.Close() })
+
+	cs, err := c.Connect(ctx, t2, nil)
+	if err != nil {
+		t.Fatalf("client Connect() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = cs.Close() })
+	return cs
+}
+
+func callStructured(t *testing.T, cs *mcpsdk.ClientSession, name string, args map[string]any) map[string]any {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcpsdk.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if res.IsError {
+		t.Fatalf("%s returned error: %+v", name, res.Content)
+	}
+	sc, ok := res.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("%s structuredContent type=%T, want map", name, res.StructuredContent)
+	}
+	return sc
+}
+
+func TestCodexTool_AsyncRunWaitAndResult(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	out := callStructured(t, cs, "codex", map[string]any{
+		"PROMPT": "hi",
+		"cd":     t.TempDir(),
+		"async":  true,
+	})
+	trackingID, _ := out["SESSION_ID"].(string)
+	if out["async"] != true || out["success"] != true || !strings.HasPrefix(trackingID, "tmp_") {
+		t.Fatalf("unexpected async output: %+v", out)
+	}
+
+	wait := callStructured(t, cs, "wait_session", map[string]any{"SESSION_ID": trackingID, "timeout_seconds": 10})
+	if wait["found"] != true || wait["done"] != true {
+		t.Fatalf("unexpected wait_session output: %+v", wait)
+	}
+	sess, _ := wait["session"].(map[string]any)
+	if sess["SESSION_ID"] != "t-123" || sess["state"] != "completed" {
+		t.Fatalf("unexpected session after wait: %+v", sess)
+	}
+
+	// The temporary tracking ID keeps resolving after the switch to the thread ID.
+	res := callStructured(t, cs, "get_session_result", map[string]any{"SESSION_ID": trackingID})
+	if res["ready"] != true || res["SESSION_ID"] != "t-123" {
+		t.Fatalf("unexpected get_session_result output: %+v", res)
+	}
+	result, _ := res["result"].(map[string]any)
+	if result["success"] != true || result["agent_messages"] != "hello from codex" || result["SESSION_ID"] != "t-123" {
+		t.Fatalf("unexpected stored result: %+v", result)
+	}
+}
+
+func TestCodexTool_AsyncRunCancelledStoresError(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "sleep")
+	out := callStructured(t, cs, "codex", map[string]any{
+		"PROMPT": "hi",
+		"cd":     t.TempDir(),
+		"async":  true,
+	})
+	trackingID, _ := out["SESSION_ID"].(string)
+
+	// Wait until the thread ID is known so cancel_session can address it.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		wait := callStructured(t, cs, "wait_session", map[string]any{"SESSION_ID": trackingID, "timeout_seconds": 0})
+		if wait["done"] == true {
+			t.Fatalf("run finished early: %+v", wait)
+		}
+		if sess, _ := wait["session"].(map[string]any); sess["SESSION_ID"] == "t-123" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("thread id not observed: %+v", wait)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	res := callStructured(t, cs, "get_session_result", map[string]any{"SESSION_ID": "t-123"})
+	if res["ready"] != false || res["state"] != "running" {
+		t.Fatalf("unexpected result while running: %+v", res)
+	}
+
+	callStructured(t, cs, "cancel_session", map[string]any{"SESSION_ID": "t-123"})
+	wait := callStructured(t, cs, "wait_session", map[string]any{"SESSION_ID": "t-123", "timeout_seconds": 10})
+	if wait["done"] != true {
+		t.Fatalf("wait_session did not observe completion: %+v", wait)
+	}
+	res = callStructured(t, cs, "get_session_result", map[string]any{"SESSION_ID": "t-123"})
+	if res["ready"] != true || res["state"] != "cancelled" || res["error"] == nil || res["result"] != nil {
+		t.Fatalf("unexpected result after cancel: %+v", res)
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"time"
 
 	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/w31r4/codex-mcp-go/internal/codex"
+	"github.com/w31r4/codex-mcp-go/internal/config"
 	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
 	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
 	"github.com/w31r4/codex-mcp-go/internal/session"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -16,10 +19,163 @@ import (
 
 var globalSessions = session.NewManager(session.DefaultOptions())
 
-type ListSessionsInput struct{}
+const maxPriority = 100
+
+func clampPriority(p int) int {
+	if p > maxPriority {
+		return maxPriority
+	}
+	if p < -maxPriority {
+		return -maxPriority
+	}
+	return p
+}
+
+// fairShareKey groups a run for fair scheduling according to sessions.fair_share.
+func fairShareKey(cfg *config.Config, req *mcp.CallToolRequest, workdirKey string) string {
+	mode := "client"
+	if cfg != nil && cfg.Sessions.FairShare != "" {
+		mode = cfg.Sessions.FairShare
+	}
+	switch mode {
+	case "workdir":
+		return workdirKey
+	case "client":
+		if req == nil || req.Session == nil {
+			return ""
+		}
+		if id := req.Session.ID(); id != "" {
+			return id
+		}
+		// stdio sessions have no ID; fall back to the client's self-reported name.
+		if p := req.Session.InitializeParams(); p != nil && p.ClientInfo != nil {
+			return p.ClientInfo.Name
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// newSessionManager builds the session manager for cfg, restoring persisted sessions
+// when sessions.state_dir is set. Store failures fall back to in-memory tracking.
+// onEvent (optional) receives lifecycle events (see session.Options.OnEvent).
+func newSessionManager(cfg *config.Config, onEvent func(session.Event)) *session.Manager {
+	opts := session.DefaultOptions()
+	opts.OnEvent = onEvent
+	dir := ""
+	if cfg != nil {
+		dir = strings.TrimSpace(cfg.Sessions.StateDir)
+		// A zero MaxRunning means the section was not configured (e.g. a hand-built Config).
+		if sc := cfg.Sessions; sc.MaxRunning > 0 {
+			opts.MaxRunning = sc.MaxRunning
+			opts.QueueSize = sc.QueueSize
+			opts.MaxQueueWait = time.Duration(sc.MaxQueueWaitSeconds) * time.Second
+			if sc.TTLSeconds > 0 {
+				opts.TTL = time.Duration(sc.TTLSeconds) * time.Second
+			}
+		}
+	}
+
+	logger := logging.GetLogger()
+	opts.OnExpire = deleteExpiredCheckpoints(logger)
+	if cfg != nil {
+		if diagDir := strings.TrimSpace(cfg.Sessions.DiagnosticsDir); diagDir != "" {
+			const mb = int64(1) << 20
+			diagLog, err := session.OpenDiagnosticsLog(diagDir, session.DiagnosticsLogOptions{
+				MaxFileBytes:  int64(cfg.Sessions.DiagnosticsFileMaxMB) * mb,
+				MaxFiles:      cfg.Sessions.DiagnosticsMaxFiles,
+				MaxTotalBytes: int64(cfg.Sessions.DiagnosticsMaxTotalMB) * mb,
+			})
+			if err != nil {
+				logger.Warn("diagnostics log unavailable; diagnostics are kept in memory only", "diagnostics_dir", diagDir, "error", err.Error())
+			} else {
+				opts.DiagnosticsLog = diagLog
+			}
+		}
+	}
+	if dir == "" {
+		return session.NewManager(opts)
+	}
+
+	store, err := session.OpenJSONLStore(dir)
+	if err != nil {
+		logger.Warn("session store unavailable; sessions will not survive restarts", "state_dir", dir, "error", err.Error())
+		return session.NewManager(opts)
+	}
+	if skipped := store.SkippedLines(); len(skipped) > 0 {
+		logger.Warn("session store has unreadable lines; the sessions they held were not restored", "state_dir", dir, "lines", skipped)
+	}
+	opts.Store = store
+	m := session.NewManager(opts)
+	restored, err := m.Restore(time.Now())
+	if err != nil {
+		logger.Warn("failed to restore sessions", "state_dir", dir, "error", err.Error())
+	} else if restored > 0 {
+		logger.Info("restored sessions", "state_dir", dir, "count", restored)
+	}
+	return m
+}
+
+// deleteExpiredCheckpoints returns a session.Options.OnExpire hook that deletes the
+// checkpoint refs of an expired session in the background.
+func deleteExpiredCheckpoints(logger logging.Logger) func(session.Snapshot) {
+	return func(snap session.Snapshot) {
+		root := checkpointRepo(snap)
+		if root == "" {
+			return
+		}
+		go func() {
+			n, err := receipt.DeleteCheckpoints(context.Background(), root, snap.ID, worktreeGitTimeout)
+			if err != nil {
+				logger.Warn("failed to delete checkpoints of expired session", "session_id", snap.ID, "git_root", root, "error", err.Error())
+			} else if n > 0 {
+				logger.Info("deleted checkpoints of expired session", "session_id", snap.ID, "git_root", root, "count", n)
+			}
+		}()
+	}
+}
+
+// checkpointRepo returns the repository holding the checkpoints of snap ("" when it
+// has none). The refs of a worktree run live in its main repository, which outlives
+// the worktree.
+func checkpointRepo(snap session.Snapshot) string {
+	receipts := []*receipt.ChangeReceipt{snap.ChangeReceipt}
+	for _, t := range snap.Turns {
+		receipts = append(receipts, t.ChangeReceipt)
+	}
+	for _, r := range receipts {
+		if r == nil || r.Checkpoint == "" {
+			continue
+		}
+		if r.Worktree != nil && r.Worktree.Repo != "" {
+			return r.Worktree.Repo
+		}
+		return r.GitRoot
+	}
+	return ""
+}
+
+type ListSessionsInput struct {
+	State         []string          `json:"state,omitempty"`
+	Cd            string            `json:"cd,omitempty"`
+	GitRoot       string            `json:"git_root,omitempty"`
+	Sandbox       string            `json:"sandbox,omitempty"`
+	Model         string            `json:"model,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	StartedAfter  string            `json:"started_after,omitempty"`
+	StartedBefore string            `json:"started_before,omitempty"`
+	Query         string            `json:"query,omitempty"`
+	Order         string            `json:"order,omitempty"`
+	Cursor        string            `json:"cursor,omitempty"`
+	Limit         int               `json:"limit,omitempty"`
+}
 
 type ListSessionsOutput struct {
-	Sessions []session.View `json:"sessions"`
+	Sessions   []session.View `json:"sessions"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 type GetSessionInput struct {
@@ -41,9 +197,47 @@ type CancelSessionOutput struct {
 }
 
 func buildListSessionsInputSchema() *jsonschema.Schema {
+	states := make([]any, 0, len(session.ValidStates))
+	for _, s := range session.ValidStates {
+		states = append(states, string(s))
+	}
+	orders := make([]any, 0, len(session.ValidListOrders))
+	for _, o := range session.ValidListOrders {
+		orders = append(orders, string(o))
+	}
 	return &jsonschema.Schema{
-		Type:       "object",
-		Properties: map[string]*jsonschema.Schema{},
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"state": {
+				Type:        "array",
+				Items:       &jsonschema.Schema{Type: "string", Enum: states},
+				Description: "Only return sessions in one of these states.",
+			},
+			"cd":       {Type: "string", Description: "Only return sessions whose working directory is this directory or below it."},
+			"git_root": {Type: "string", Description: "Only return sessions in the git repository with this root."},
+			"sandbox": {
+				Type:        "string",
+				Enum:        []any{codex.SandboxReadOnly, codex.SandboxWorkspaceWrite, codex.SandboxDangerFullAccess},
+				Description: "Only return sessions started with this sandbox policy.",
+			},
+			"model": {Type: "string", Description: "Only return sessions with a turn that used this model."},
+			"labels": {
+				Type:                 "object",
+				Description:          "Only return sessions carrying all of these labels (use \"*\" to match any value).",
+				AdditionalProperties: &jsonschema.Schema{Type: "string"},
+			},
+			"tags": {
+				Type:        "array",
+				Items:       &jsonschema.Schema{Type: "string"},
+				Description: "Only return sessions carrying all of these label keys, with any value.",
+			},
+			"started_after":  {Type: "string", Description: "Only return sessions started at or after this RFC3339 time."},
+			"started_before": {Type: "string", Description: "Only return sessions started before this RFC3339 time."},
+			"query":          {Type: "string", Description: "Case-insensitive text matched against session titles, prompt previews and session errors."},
+			"order":          {Type: "string", Enum: orders, Description: "Sort order by start time (default started_desc)."},
+			"cursor":         {Type: "string", Description: "Opaque next_cursor from a previous call."},
+			"limit":          {Type: "number", Description: "Maximum number of sessions to return (default 50, max 500)."},
+		},
 	}
 }
 
@@ -69,7 +263,16 @@ func buildCancelSessionInputSchema() *jsonschema.Schema {
 
 func handleListSessions(ctx context.Context, req *mcp.CallToolRequest, input ListSessionsInput) (result *mcp.CallToolResult, output ListSessionsOutput, err error) {
 	ctx, rc := logging.NewRequestContext(ctx, "list_sessions")
-	logging.LogRequest(ctx, map[string]any{})
+	logging.LogRequest(ctx, map[string]any{
+		"state":  input.State,
+		"cd":     input.Cd,
+		"labels": input.Labels,
+		"tags":   input.Tags,
+		"query":  input.Query,
+		"order":  input.Order,
+		"cursor": input.Cursor != "",
+		"limit":  input.Limit,
+	})
 	defer func() {
 		success := err == nil
 		globalMetrics.RecordRequest("list_sessions", success, time.Since(rc.StartTime))
@@ -79,13 +282,83 @@ func handleListSessions(ctx context.Context, req *mcp.CallToolRequest, input Lis
 				globalMetrics.RecordError(cerr.Code.Name())
 			}
 		}
-		logging.LogResponse(ctx, map[string]any{"success": success}, err)
+		logging.LogResponse(ctx, map[string]any{"success": success, "total": output.Total}, err)
 	}()
 
-	output.Sessions = globalSessions.List()
+	filter, filterErr := listFilterFromInput(input)
+	if filterErr != nil {
+		return nil, ListSessionsOutput{}, filterErr
+	}
+	views, total, next, queryErr := globalSessions.Query(filter, session.ListPage{
+		Order:  session.ListOrder(strings.TrimSpace(input.Order)),
+		Cursor: strings.TrimSpace(input.Cursor),
+		Limit:  input.Limit,
+	})
+	if queryErr != nil {
+		return nil, ListSessionsOutput{}, queryErr
+	}
+	output.Sessions = views
+	output.Total = total
+	output.NextCursor = next
 	return nil, output, nil
 }
 
+func listFilterFromInput(input ListSessionsInput) (session.ListFilter, error) {
+	filter := session.ListFilter{
+		Sandbox: strings.TrimSpace(input.Sandbox),
+		Model:   strings.TrimSpace(input.Model),
+		Labels:  input.Labels,
+		Query:   input.Query,
+	}
+	for _, tag := range input.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	for _, raw := range input.State {
+		state := session.State(strings.ToLower(strings.TrimSpace(raw)))
+		valid := false
+		for _, s := range session.ValidStates {
+			if s == state {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return session.ListFilter{}, cerrors.ErrInvalidParams("invalid state").
+				WithData("state", raw).
+				WithData("valid", session.ValidStates)
+		}
+		filter.States = append(filter.States, state)
+	}
+	if cd := strings.TrimSpace(input.Cd); cd != "" {
+		filter.WorkDir = normalizeWorkdir(cd)
+	}
+	if root := strings.TrimSpace(input.GitRoot); root != "" {
+		filter.GitRoot = normalizeWorkdir(root)
+	}
+	for _, tr := range []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{
+		{"started_after", input.StartedAfter, &filter.StartedAfter},
+		{"started_before", input.StartedBefore, &filter.StartedBefore},
+	} {
+		v := strings.TrimSpace(tr.value)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return session.ListFilter{}, cerrors.ErrInvalidParams(tr.name+" must be an RFC3339 timestamp").
+				WithData(tr.name, tr.value)
+		}
+		*tr.dst = t
+	}
+	return filter, nil
+}
+
 func handleGetSession(ctx context.Context, req *mcp.CallToolRequest, input GetSessionInput) (result *mcp.CallToolResult, output GetSessionOutput, err error) {
 	ctx, rc := logging.NewRequestContext(ctx, "get_session")
 	logging.LogRequest(ctx, map[string]any{"session_id": strings.TrimSpace(input.SessionID)})
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
@@ -46,3 +47,43 @@ func TestStatsTool_AppearsInToolsList(t *testing.T) {
 		t.Fatalf("stats tool not found in tools/list")
 	}
 }
+
+func TestStatsTool_ReportsCodexVersion(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+
+	s := NewServer(cfg)
+	c := mcpsdk.NewClient(&mcpsdk.Implementation{Name: "client", Version: "test"}, nil)
+
+	t1, t2 := mcpsdk.NewInMemoryTransports()
+	ss, err := s.Connect(ctx, t1, nil)
+	if err != nil {
+		t.Fatalf("server Connect() failed: %v", err)
+	}
+	defer ss.Close()
+
+	cs, err := c.Connect(ctx, t2, nil)
+	if err != nil {
+		t.Fatalf("client Connect() failed: %v", err)
+	}
+	defer cs.Close()
+
+	res, err := cs.CallTool(ctx, &mcpsdk.CallToolParams{Name: "stats"})
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	sc, ok := res.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("stats structuredContent type=%T, want map", res.StructuredContent)
+	}
+	info, ok := sc["codex"].(map[string]any)
+	if !ok {
+		t.Fatalf("stats.codex type=%T, want map", sc["codex"])
+	}
+	if info["version"] != "0.46.0" {
+		t.Fatalf("stats.codex.version=%v, want %q", info["version"], "0.46.0")
+	}
+}
@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/w31r4/codex-mcp-go/internal/codex"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type SuspendSessionInput struct {
+	SessionID string `json:"SESSION_ID"`
+}
+
+type SuspendSessionOutput struct {
+	// Changed is false when the session was already in the requested state.
+	Changed bool         `json:"changed"`
+	Session session.View `json:"session"`
+}
+
+func buildSuspendSessionInputSchema(description string) *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "object",
+		Properties: map[string]*jsonschema.Schema{
+			"SESSION_ID": {Type: "string", Description: description},
+		},
+		Required: []string{"SESSION_ID"},
+	}
+}
+
+func handleSuspendSession(ctx context.Context, req *mcp.CallToolRequest, input SuspendSessionInput) (*mcp.CallToolResult, SuspendSessionOutput, error) {
+	return setSessionSuspended(ctx, "suspend_session", input, true)
+}
+
+func handleResumeSession(ctx context.Context, req *mcp.CallToolRequest, input SuspendSessionInput) (*mcp.CallToolResult, SuspendSessionOutput, error) {
+	return setSessionSuspended(ctx, "resume_session", input, false)
+}
+
+func setSessionSuspended(ctx context.Context, tool string, input SuspendSessionInput, suspend bool) (result *mcp.CallToolResult, output SuspendSessionOutput, err error) {
+	ctx, rc := logging.NewRequestContext(ctx, tool)
+	logging.LogRequest(ctx, map[string]any{"session_id": strings.TrimSpace(input.SessionID)})
+	defer func() {
+		success := err == nil
+		globalMetrics.RecordRequest(tool, success, time.Since(rc.StartTime))
+		if err != nil {
+			var cerr *cerrors.Error
+			if stderrors.As(err, &cerr) {
+				globalMetrics.RecordError(cerr.Code.Name())
+			}
+		}
+		logging.LogResponse(ctx, map[string]any{"success": success, "changed": output.Changed}, err)
+	}()
+
+	input.SessionID = strings.TrimSpace(input.SessionID)
+	if input.SessionID == "" {
+		return nil, SuspendSessionOutput{}, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+
+	var view session.View
+	var changed bool
+	if suspend {
+		view, changed, err = globalSessions.Suspend(input.SessionID)
+	} else {
+		view, changed, err = globalSessions.Resume(input.SessionID)
+	}
+	if stderrors.Is(err, codex.ErrNotRunning) {
+		// The session holds a slot but codex has not been started (or has just exited).
+		return nil, SuspendSessionOutput{}, cerrors.ErrInvalidParams("session has no running codex process").
+			WithData("SESSION_ID", input.SessionID)
+	}
+	if err != nil {
+		return nil, SuspendSessionOutput{}, err
+	}
+	if changed {
+		msg := "session resumed"
+		if suspend {
+			msg = "session suspended"
+		}
+		globalSessions.AppendDiagnostic(view.SessionID, session.DiagnosticSystem, msg)
+	}
+	return nil, SuspendSessionOutput{Changed: changed, Session: view}, nil
+}
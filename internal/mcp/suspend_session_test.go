@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func TestSuspendSession_PausesAndResumesRun(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "sleep")
+	callStructured(t, cs, "codex", map[string]any{
+		"PROMPT":            "hi",
+		"cd":                t.TempDir(),
+		"async":             true,
+		"no_output_seconds": 1,
+	})
+
+	// Wait until codex reported its thread, so the process is running.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, ok := globalSessions.Get("t-123"); ok && v.State == "running" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("codex did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	out := callStructured(t, cs, "suspend_session", map[string]any{"SESSION_ID": "t-123"})
+	sess, _ := out["session"].(map[string]any)
+	if out["changed"] != true || sess["state"] != "suspended" || sess["suspended_at"] == nil {
+		t.Fatalf("suspend output=%+v", out)
+	}
+	if again := callStructured(t, cs, "suspend_session", map[string]any{"SESSION_ID": "t-123"}); again["changed"] != false {
+		t.Fatalf("second suspend=%+v, want unchanged", again)
+	}
+
+	// The no-output watchdog (1s) must not fire while suspended.
+	time.Sleep(1500 * time.Millisecond)
+	wait := callStructured(t, cs, "wait_session", map[string]any{"SESSION_ID": "t-123", "timeout_seconds": 0})
+	if wait["done"] == true {
+		t.Fatalf("suspended run finished: %+v", wait)
+	}
+
+	out = callStructured(t, cs, "resume_session", map[string]any{"SESSION_ID": "t-123"})
+	if sess, _ := out["session"].(map[string]any); out["changed"] != true || sess["state"] != "running" {
+		t.Fatalf("resume output=%+v", out)
+	}
+	// After resuming, the watchdog continues and ends the silent run.
+	wait = callStructured(t, cs, "wait_session", map[string]any{"SESSION_ID": "t-123", "timeout_seconds": 10})
+	if sess, _ := wait["session"].(map[string]any); wait["done"] != true || sess["state"] != "failed" {
+		t.Fatalf("wait after resume=%+v", wait)
+	}
+
+	payload := callNamedToolError(t, cs, "resume_session", map[string]any{"SESSION_ID": "t-123"})
+	if payload["code"] != float64(cerrors.InvalidParams) {
+		t.Fatalf("resume of a finished session error=%v", payload)
+	}
+}
@@ -18,6 +18,7 @@ type TailSessionInput struct {
 	SessionID string `json:"SESSION_ID"`
 	Cursor    *int64 `json:"cursor,omitempty"`
 	Limit     *int   `json:"limit,omitempty"`
+	Full      bool   `json:"full,omitempty"`
 }
 
 type TailSessionOutput struct {
@@ -28,6 +29,7 @@ type TailSessionOutput struct {
 	NextCursor    uint64                        `json:"next_cursor"`
 	Dropped       bool                          `json:"dropped,omitempty"`
 	DroppedBefore uint64                        `json:"dropped_before,omitempty"`
+	FromDisk      bool                          `json:"from_disk,omitempty"`
 }
 
 func buildTailSessionInputSchema() *jsonschema.Schema {
@@ -37,6 +39,10 @@ func buildTailSessionInputSchema() *jsonschema.Schema {
 			"SESSION_ID": {Type: "string", Description: "Session identifier to tail."},
 			"cursor":     {Type: "number", Description: "Return entries with seq > cursor. Start with 0."},
 			"limit":      {Type: "number", Description: "Maximum number of entries to return (default 50, max 200)."},
+			"full": {
+				Type:        "boolean",
+				Description: "Return entries untruncated. Requires sessions.diagnostics_dir; older cursors are read from disk automatically.",
+			},
 		},
 		Required: []string{"SESSION_ID"},
 	}
@@ -46,6 +52,7 @@ func handleTailSession(ctx context.Context, req *mcp.CallToolRequest, input Tail
 	ctx, rc := logging.NewRequestContext(ctx, "tail_session")
 	logging.LogRequest(ctx, map[string]any{
 		"session_id": strings.TrimSpace(input.SessionID),
+		"full":       input.Full,
 	})
 	defer func() {
 		success := err == nil
@@ -73,15 +80,16 @@ func handleTailSession(ctx context.Context, req *mcp.CallToolRequest, input Tail
 		limit = *input.Limit
 	}
 
-	entries, next, dropped, droppedBefore, state, found := globalSessions.TailDiagnostics(input.SessionID, cursor, limit)
+	tail := globalSessions.TailDiagnostics(input.SessionID, cursor, limit, input.Full)
 
-	output.Found = found
+	output.Found = tail.Found
 	output.SessionID = input.SessionID
-	output.State = state
-	output.Entries = entries
-	output.NextCursor = next
-	output.Dropped = dropped
-	output.DroppedBefore = droppedBefore
+	output.State = tail.State
+	output.Entries = tail.Entries
+	output.NextCursor = tail.NextCursor
+	output.Dropped = tail.Dropped
+	output.DroppedBefore = tail.DroppedBefore
+	output.FromDisk = tail.FromDisk
 
 	return nil, output, nil
 }
@@ -3,11 +3,13 @@ package mcp
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/session"
 )
 
 func TestTailSession_ReturnsEntriesForRunningSession(t *testing.T) {
@@ -134,3 +136,29 @@ func TestTailSession_ReturnsEntriesForRunningSession(t *testing.T) {
 		t.Fatalf("codex tool call did not return after cancellation")
 	}
 }
+
+func TestTailSession_FullEntriesFromDisk(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Sessions.DiagnosticsDir = t.TempDir()
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": t.TempDir()})
+	long := strings.Repeat("y", 5000)
+	globalSessions.AppendDiagnostic("t-123", session.DiagnosticOutput, long)
+
+	out := callStructured(t, cs, "tail_session", map[string]any{"SESSION_ID": "t-123", "cursor": 0, "limit": 200})
+	entries, _ := out["entries"].([]any)
+	last, _ := entries[len(entries)-1].(map[string]any)
+	if msg, _ := last["message"].(string); len(msg) >= len(long) || last["truncated"] != true {
+		t.Fatalf("in-memory entry should be truncated: %v", last)
+	}
+
+	out = callStructured(t, cs, "tail_session", map[string]any{"SESSION_ID": "t-123", "cursor": 0, "limit": 200, "full": true})
+	entries, _ = out["entries"].([]any)
+	last, _ = entries[len(entries)-1].(map[string]any)
+	if out["from_disk"] != true || last["message"] != long {
+		t.Fatalf("full tail did not return the untruncated entry: from_disk=%v", out["from_disk"])
+	}
+}
@@ -3,6 +3,8 @@ package mcp
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -10,10 +12,29 @@ import (
 
 const fakeCodexEnv = "CODEX_MCP_FAKE_CODEX"
 
+// fakeWriteFileEnv and fakeWriteContentEnv configure the write_file mode, which edits a
+// file the way codex would before replying; with fakeStageEnv set it also stages it.
+const (
+	fakeWriteFileEnv    = "CODEX_MCP_FAKE_WRITE_FILE"
+	fakeWriteContentEnv = "CODEX_MCP_FAKE_WRITE_CONTENT"
+	fakeStageEnv        = "CODEX_MCP_FAKE_STAGE"
+)
+
 func TestMain(m *testing.M) {
-	if mode := os.Getenv(fakeCodexEnv); mode != "" && len(os.Args) > 1 && os.Args[1] == "exec" {
-		runFakeCodex(mode)
-		os.Exit(0)
+	if mode := os.Getenv(fakeCodexEnv); mode != "" && len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "--version":
+			fmt.Fprintln(os.Stdout, "codex-cli 0.46.0")
+			os.Exit(0)
+		case "exec":
+			if len(os.Args) > 2 && os.Args[2] == "--help" {
+				// Listing no flags leaves the capabilities unknown, i.e. all assumed.
+				fmt.Fprintln(os.Stdout, "Usage: codex exec [OPTIONS] [PROMPT]")
+				os.Exit(0)
+			}
+			runFakeCodex(mode)
+			os.Exit(0)
+		}
 	}
 	os.Exit(m.Run())
 }
@@ -23,6 +44,35 @@ func runFakeCodex(mode string) {
 	case "success_tool_call":
 		fmt.Fprintln(os.Stdout, `{"thread_id":"t-123","item":{"type":"tool_call","name":"x"}}`)
 		fmt.Fprintln(os.Stdout, `{"thread_id":"t-123","item":{"type":"agent_message","text":"hello from codex"}}`)
+	case "write_file", "write_file_fail":
+		content := os.Getenv(fakeWriteContentEnv)
+		if content == "" {
+			content = "codex was here\n"
+		}
+		// Relative paths are inside the workdir, like codex's own edits.
+		path := os.Getenv(fakeWriteFileEnv)
+		for i := 0; i < len(os.Args)-1 && !filepath.IsAbs(path); i++ {
+			if os.Args[i] == "--cd" {
+				path = filepath.Join(os.Args[i+1], path)
+			}
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if os.Getenv(fakeStageEnv) != "" {
+			add := exec.Command("git", "add", "--", filepath.Base(path))
+			add.Dir = filepath.Dir(path)
+			if out, err := add.CombinedOutput(); err != nil {
+				fmt.Fprintln(os.Stderr, string(out))
+				os.Exit(1)
+			}
+		}
+		fmt.Fprintln(os.Stdout, `{"thread_id":"t-123","item":{"type":"agent_message","text":"edited a file"}}`)
+		if mode == "write_file_fail" {
+			fmt.Fprintln(os.Stderr, "fake codex failure")
+			os.Exit(1)
+		}
 	case "sleep":
 		threadID := "t-123"
 		for i := 0; i < len(os.Args)-1; i++ {
@@ -33,6 +83,10 @@ func runFakeCodex(mode string) {
 		}
 		fmt.Fprintf(os.Stdout, `{"thread_id":%q,"item":{"type":"agent_message","text":"hello from codex"}}`+"\n", threadID)
 		time.Sleep(30 * time.Second)
+	case "usage_then_sleep":
+		fmt.Fprintln(os.Stdout, `{"type":"thread.started","thread_id":"t-123"}`)
+		fmt.Fprintln(os.Stdout, `{"type":"turn.completed","usage":{"input_tokens":120,"output_tokens":30}}`)
+		time.Sleep(30 * time.Second)
 	default:
 		fmt.Fprintln(os.Stdout, `{"thread_id":"t-123","item":{"type":"agent_message","text":"hello from codex"}}`)
 	}
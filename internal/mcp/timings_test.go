@@ -0,0 +1,44 @@
+package mcp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+func TestCodexTool_ReportsPhaseTimings(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": t.TempDir()})
+	timings, ok := out["timings"].(map[string]any)
+	if !ok {
+		t.Fatalf("timings=%v, want an object", out["timings"])
+	}
+	for _, phase := range session.Phases {
+		if _, ok := timings[phase+"_ms"].(float64); !ok {
+			t.Fatalf("timings=%v, missing %s_ms", timings, phase)
+		}
+	}
+	if timings["started_at"] == "" {
+		t.Fatalf("timings=%v, missing started_at", timings)
+	}
+
+	got := callStructured(t, cs, "get_session", map[string]any{"SESSION_ID": "t-123"})
+	sess, _ := got["session"].(map[string]any)
+	if _, ok := sess["timings"].(map[string]any); !ok {
+		t.Fatalf("get_session=%v, want timings", got)
+	}
+
+	stats := callStructured(t, cs, "stats", map[string]any{})
+	m, _ := stats["metrics"].(map[string]any)
+	phases, _ := m["phase_timings"].(map[string]any)
+	spawn, _ := phases[session.PhaseSpawn].(map[string]any)
+	if count, _ := spawn["count"].(float64); count < 1 {
+		t.Fatalf("phase_timings=%v, want a spawn histogram", m["phase_timings"])
+	}
+}
@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/webhook"
+)
+
+// globalWebhooks delivers session lifecycle events to [[webhooks]] (nil when none are configured).
+var globalWebhooks *webhook.Dispatcher
+
+// webhookDrainTimeout bounds how long shutdown waits for queued webhook deliveries.
+const webhookDrainTimeout = 5 * time.Second
+
+func newWebhookDispatcher(cfg *config.Config) *webhook.Dispatcher {
+	if cfg == nil || len(cfg.Webhooks) == 0 {
+		return nil
+	}
+	hooks := make([]webhook.Hook, 0, len(cfg.Webhooks))
+	for _, c := range cfg.Webhooks {
+		hooks = append(hooks, webhook.FromConfig(c))
+	}
+	return webhook.New(hooks, logging.GetLogger())
+}
+
+// closeWebhooks stops globalWebhooks, waiting up to timeout for queued deliveries.
+func closeWebhooks(timeout time.Duration) {
+	if globalWebhooks == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_ = globalWebhooks.Close(ctx)
+	globalWebhooks = nil
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/webhook"
+)
+
+func TestWebhooks_DeliverSessionLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []webhook.Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.HeaderSignature) != webhook.Sign("hook-secret", r.Header.Get(webhook.HeaderTimestamp), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p webhook.Payload
+		_ = json.Unmarshal(body, &p)
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	t.Setenv("CODEX_MCP_TEST_HOOK_SECRET", "hook-secret")
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Webhooks = []config.WebhookConfig{{URL: srv.URL, SecretEnv: "CODEX_MCP_TEST_HOOK_SECRET"}}
+	cs := connectTestClient(t, cfg)
+
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": t.TempDir()})
+	closeWebhooks(webhookDrainTimeout)
+
+	mu.Lock()
+	defer mu.Unlock()
+	events := map[string]webhook.Payload{}
+	for _, p := range payloads {
+		events[string(p.Event)] = p
+	}
+	if _, ok := events["started"]; !ok {
+		t.Fatalf("no started event delivered: %+v", payloads)
+	}
+	completed, ok := events["completed"]
+	if !ok || completed.Session.SessionID != "t-123" || completed.Session.State != "completed" {
+		t.Fatalf("completed event=%+v (all: %+v)", completed, payloads)
+	}
+	if completed.ChangeReceipt == nil {
+		t.Fatalf("completed event has no receipt summary: %+v", completed)
+	}
+}
@@ -6,19 +6,18 @@ import (
 	"time"
 
 	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
 )
 
-func workdirKey(ctx context.Context, cd string) string {
+// workdirGitRoot returns the normalized git root containing cd, if any.
+func workdirGitRoot(ctx context.Context, cd string) (string, bool) {
 	cd = normalizeWorkdir(cd)
-
-	// Prefer git root when available so concurrent runs in the same repo
-	// (even from different subdirs) are mutually excluded.
 	if root, ok, err := receipt.GitRoot(ctx, cd, 2*time.Second); err == nil && ok {
 		if normalized := normalizeWorkdir(root); normalized != "" {
-			return normalized
+			return normalized, true
 		}
 	}
-	return cd
+	return "", false
 }
 
 func normalizeWorkdir(path string) string {
@@ -35,3 +34,12 @@ func normalizeWorkdir(path string) string {
 	}
 	return filepath.Clean(path)
 }
+
+// sameWorkdir reports whether cd (with git root gitRoot) is where the thread of origin
+// was started: the same directory, or anywhere in the same git repository.
+func sameWorkdir(origin session.Origin, cd string, gitRoot string) bool {
+	if normalizeWorkdir(origin.WorkDir) == normalizeWorkdir(cd) {
+		return true
+	}
+	return origin.GitRoot != "" && origin.GitRoot == gitRoot
+}
@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+// Values of CodexInput.Isolation.
+const (
+	isolationNone     = "none"
+	isolationWorktree = "worktree"
+)
+
+const worktreeGitTimeout = 30 * time.Second
+
+// addRunWorktree creates an isolated worktree for a new run in the repository at
+// gitRoot and returns it with the directory matching cd inside it.
+func addRunWorktree(ctx context.Context, cfg *config.Config, gitRoot string, cd string) (receipt.Worktree, string, error) {
+	dir := ""
+	if cfg != nil {
+		dir = strings.TrimSpace(cfg.Codex.WorktreeDir)
+	}
+	name := worktreeName()
+	path := ""
+	if dir != "" {
+		// The directory may be shared by several repositories.
+		path = filepath.Join(dir, filepath.Base(gitRoot)+"-"+name)
+	} else {
+		defaultDir, err := receipt.DefaultWorktreeDir(ctx, gitRoot, worktreeGitTimeout)
+		if err != nil {
+			return receipt.Worktree{}, "", cerrors.Wrap(cerrors.InternalError, "failed to locate the worktree directory", err).
+				WithData("git_root", gitRoot)
+		}
+		path = filepath.Join(defaultDir, name)
+	}
+
+	wt, err := receipt.AddWorktree(ctx, gitRoot, path, receipt.WorktreeBranchPrefix+name, worktreeGitTimeout)
+	if stderrors.Is(err, receipt.ErrNoCommits) {
+		return receipt.Worktree{}, "", cerrors.ErrInvalidParams("isolation=worktree needs a commit to branch from").
+			WithData("git_root", gitRoot)
+	}
+	if err != nil {
+		return receipt.Worktree{}, "", cerrors.Wrap(cerrors.InternalError, "failed to create worktree", err).
+			WithData("git_root", gitRoot).
+			WithData("path", path)
+	}
+	wt.Path = normalizeWorkdir(wt.Path)
+
+	// Run in the same subdirectory of the worktree as cd is of the repository.
+	runCd := wt.Path
+	if rel, relErr := filepath.Rel(gitRoot, normalizeWorkdir(cd)); relErr == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		runCd = filepath.Join(wt.Path, rel)
+	}
+	return wt, runCd, nil
+}
+
+// worktreeName returns a unique, sortable name for a run worktree and its branch.
+func worktreeName() string {
+	name := time.Now().Format("20060102-150405")
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err == nil {
+		name += "-" + hex.EncodeToString(buf)
+	}
+	return name
+}
+
+// discardWorktree removes a worktree whose run never started.
+func discardWorktree(wt *receipt.Worktree) {
+	_ = receipt.RemoveWorktree(context.Background(), wt, worktreeGitTimeout)
+}
+
+// recordWorktree adds the run's worktree to rec, removing it first when the caller
+// asked for cleanup.
+func (r *codexRun) recordWorktree(rec *receipt.ChangeReceipt) {
+	if r.worktree == nil {
+		return
+	}
+	if r.input.CleanupWorktree && !r.worktree.Removed {
+		if err := receipt.RemoveWorktree(context.Background(), r.worktree, worktreeGitTimeout); err != nil {
+			r.worktree.KeptReason = err.Error()
+			r.logger.Warn("failed to remove worktree", "path", r.worktree.Path, "error", err.Error())
+		}
+		switch {
+		case r.worktree.Removed:
+			globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "removed worktree "+r.worktree.Path)
+		case r.worktree.KeptReason != "":
+			globalSessions.AppendDiagnostic(r.trackingID, session.DiagnosticSystem, "kept worktree "+r.worktree.Path+": "+r.worktree.KeptReason)
+		}
+	}
+	wt := *r.worktree
+	rec.Worktree = &wt
+}
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	gitOutput(t, repo, "init")
+	gitOutput(t, repo, "config", "user.email", "test@example.com")
+	gitOutput(t, repo, "config", "user.name", "test")
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	gitOutput(t, repo, "add", "file.txt")
+	gitOutput(t, repo, "commit", "-m", "init")
+	return repo
+}
+
+func TestCodexTool_WorktreeIsolation(t *testing.T) {
+	repo := initTestRepo(t)
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+
+	// Another run holds the repository lock; an isolated run must not need it.
+	repoKey := normalizeWorkdir(repo)
+	if ok, _ := globalWorkLocks.acquire(context.Background(), repoKey, workdirLockReject, 0); !ok {
+		t.Fatalf("failed to take the repository lock")
+	}
+	defer globalWorkLocks.release(repoKey)
+
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "isolation": "worktree"})
+
+	cr, _ := out["change_receipt"].(map[string]any)
+	wt, _ := cr["worktree"].(map[string]any)
+	path, _ := wt["path"].(string)
+	branch, _ := wt["branch"].(string)
+	if path == "" || !strings.HasPrefix(branch, "codex-mcp/") || wt["repo"] != repoKey || wt["removed"] != nil {
+		t.Fatalf("change_receipt.worktree=%+v", wt)
+	}
+	if cr["git_root"] != path {
+		t.Fatalf("change_receipt.git_root=%v, want the worktree %s", cr["git_root"], path)
+	}
+	if files, _ := cr["changed_files"].([]any); len(files) != 1 || files[0].(map[string]any)["path"] != "new.txt" {
+		t.Fatalf("change_receipt.changed_files=%v, want new.txt", cr["changed_files"])
+	}
+	if b, err := os.ReadFile(filepath.Join(path, "new.txt")); err != nil || string(b) != "codex was here\n" {
+		t.Fatalf("worktree new.txt=%q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the run wrote to the main work tree (err=%v)", err)
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+		t.Fatalf("main work tree is dirty: %q", status)
+	}
+	if got := strings.TrimSpace(gitOutput(t, path, "rev-parse", "--abbrev-ref", "HEAD")); got != branch {
+		t.Fatalf("worktree branch=%q, want %q", got, branch)
+	}
+	if v, ok := globalSessions.Get("t-123"); !ok || v.GitRoot != path {
+		t.Fatalf("session git_root=%q, want the worktree", v.GitRoot)
+	}
+
+	// Resumes run in the worktree, and isolation cannot be requested again.
+	payload := callNamedToolError(t, cs, "codex", map[string]any{"PROMPT": "again", "SESSION_ID": "t-123", "isolation": "worktree"})
+	if payload["code"] != float64(cerrors.InvalidParams) {
+		t.Fatalf("resume with isolation error=%v, want InvalidParams", payload)
+	}
+}
+
+func TestCodexTool_WorktreeCleanup(t *testing.T) {
+	repo := initTestRepo(t)
+
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cfg.Codex.WorktreeDir = t.TempDir()
+	cs := connectTestClient(t, cfg)
+
+	// A run without changes leaves nothing behind.
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+	out := callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "isolation": "worktree", "cleanup_worktree": true})
+	cr, _ := out["change_receipt"].(map[string]any)
+	wt, _ := cr["worktree"].(map[string]any)
+	if wt["removed"] != true || wt["branch_deleted"] != true {
+		t.Fatalf("change_receipt.worktree=%+v, want removed with its branch", wt)
+	}
+	if path, _ := wt["path"].(string); !strings.HasPrefix(path, normalizeWorkdir(cfg.Codex.WorktreeDir)) {
+		t.Fatalf("worktree path=%q, want it under worktree_dir", path)
+	} else if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("worktree still exists (err=%v)", err)
+	}
+	if branches := gitOutput(t, repo, "branch", "--list", "codex-mcp/*"); branches != "" {
+		t.Fatalf("branches left behind: %q", branches)
+	}
+
+	// Uncommitted changes are never discarded.
+	t.Setenv(fakeCodexEnv, "write_file")
+	t.Setenv(fakeWriteFileEnv, "new.txt")
+	out = callStructured(t, cs, "codex", map[string]any{"PROMPT": "hi", "cd": repo, "isolation": "worktree", "cleanup_worktree": true})
+	cr, _ = out["change_receipt"].(map[string]any)
+	wt, _ = cr["worktree"].(map[string]any)
+	path, _ := wt["path"].(string)
+	if wt["removed"] != nil || wt["kept_reason"] == nil {
+		t.Fatalf("change_receipt.worktree=%+v, want it kept", wt)
+	}
+	if _, err := os.Stat(filepath.Join(path, "new.txt")); err != nil {
+		t.Fatalf("kept worktree lost new.txt: %v", err)
+	}
+}
+
+func TestCodexTool_IsolationValidation(t *testing.T) {
+	cfg := config.Default()
+	cfg.Codex.ExecutablePath = os.Args[0]
+	cs := connectTestClient(t, cfg)
+	t.Setenv(fakeCodexEnv, "success_tool_call")
+
+	for _, args := range []map[string]any{
+		{"PROMPT": "hi", "cd": t.TempDir(), "cleanup_worktree": true},
+		{"PROMPT": "hi", "cd": t.TempDir(), "isolation": "worktree"},
+	} {
+		if payload := callNamedToolError(t, cs, "codex", args); payload["code"] != float64(cerrors.InvalidParams) {
+			t.Fatalf("codex %v error=%v, want InvalidParams", args, payload)
+		}
+	}
+}
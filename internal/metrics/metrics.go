@@ -18,6 +18,7 @@ type Metrics struct {
 	mu          sync.RWMutex
 	toolCalls   map[string]*atomic.Int64
 	errorCounts map[string]*atomic.Int64
+	phases      map[string]*histogram
 }
 
 type Snapshot struct {
@@ -29,12 +30,65 @@ type Snapshot struct {
 	MinLatencyMs    int64            `json:"min_latency_ms"`
 	ToolCalls       map[string]int64 `json:"tool_calls"`
 	ErrorCounts     map[string]int64 `json:"error_counts"`
+	// PhaseTimings are histograms of the time codex runs spent in each phase.
+	PhaseTimings map[string]HistogramSnapshot `json:"phase_timings,omitempty"`
+}
+
+// PhaseBucketsMs are the upper bounds (inclusive, in milliseconds) of the phase
+// timing histogram buckets.
+var PhaseBucketsMs = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 300000, 900000, 1800000}
+
+// HistogramSnapshot is a point-in-time copy of a histogram. Counts has one entry per
+// bound in BoundsMs plus a final overflow bucket; they are not cumulative.
+type HistogramSnapshot struct {
+	Count    int64   `json:"count"`
+	SumMs    int64   `json:"sum_ms"`
+	MaxMs    int64   `json:"max_ms"`
+	BoundsMs []int64 `json:"bounds_ms"`
+	Counts   []int64 `json:"counts"`
+}
+
+type histogram struct {
+	count  atomic.Int64
+	sumMs  atomic.Int64
+	maxMs  atomic.Int64
+	counts []atomic.Int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]atomic.Int64, len(PhaseBucketsMs)+1)}
+}
+
+func (h *histogram) observe(ms int64) {
+	i := 0
+	for i < len(PhaseBucketsMs) && ms > PhaseBucketsMs[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sumMs.Add(ms)
+	updateMax(&h.maxMs, ms)
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Count:    h.count.Load(),
+		SumMs:    h.sumMs.Load(),
+		MaxMs:    h.maxMs.Load(),
+		BoundsMs: append([]int64(nil), PhaseBucketsMs...),
+		Counts:   make([]int64, len(h.counts)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+	}
+	return s
 }
 
 func New() *Metrics {
 	return &Metrics{
 		toolCalls:   make(map[string]*atomic.Int64),
 		errorCounts: make(map[string]*atomic.Int64),
+		phases:      make(map[string]*histogram),
 	}
 }
 
@@ -72,6 +126,18 @@ func (m *Metrics) RecordError(codeName string) {
 	c.Add(1)
 }
 
+// RecordPhase adds the time a codex run spent in phase to its histogram.
+func (m *Metrics) RecordPhase(phase string, d time.Duration) {
+	m.mu.Lock()
+	h, ok := m.phases[phase]
+	if !ok {
+		h = newHistogram()
+		m.phases[phase] = h
+	}
+	m.mu.Unlock()
+	h.observe(d.Milliseconds())
+}
+
 func (m *Metrics) Snapshot() Snapshot {
 	total := m.totalRequests.Load()
 	avg := int64(0)
@@ -92,6 +158,14 @@ func (m *Metrics) Snapshot() Snapshot {
 		errorCounts[k] = v.Load()
 	}
 
+	var phases map[string]HistogramSnapshot
+	if len(m.phases) > 0 {
+		phases = make(map[string]HistogramSnapshot, len(m.phases))
+		for k, h := range m.phases {
+			phases[k] = h.snapshot()
+		}
+	}
+
 	return Snapshot{
 		TotalRequests:   total,
 		SuccessRequests: m.successRequests.Load(),
@@ -101,16 +175,21 @@ func (m *Metrics) Snapshot() Snapshot {
 		MinLatencyMs:    m.minLatencyMs.Load(),
 		ToolCalls:       toolCalls,
 		ErrorCounts:     errorCounts,
+		PhaseTimings:    phases,
 	}
 }
 
 func (m *Metrics) updateMaxLatency(latencyMs int64) {
+	updateMax(&m.maxLatencyMs, latencyMs)
+}
+
+func updateMax(v *atomic.Int64, n int64) {
 	for {
-		max := m.maxLatencyMs.Load()
-		if latencyMs <= max {
+		max := v.Load()
+		if n <= max {
 			return
 		}
-		if m.maxLatencyMs.CompareAndSwap(max, latencyMs) {
+		if v.CompareAndSwap(max, n) {
 			return
 		}
 	}
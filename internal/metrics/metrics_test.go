@@ -54,3 +54,28 @@ func TestMetrics_RecordError(t *testing.T) {
 		t.Fatalf("ErrorCounts[CodexNotFound]=%d, want %d", s.ErrorCounts["CodexNotFound"], 1)
 	}
 }
+
+func TestMetrics_RecordPhase(t *testing.T) {
+	m := New()
+	if s := m.Snapshot(); s.PhaseTimings != nil {
+		t.Fatalf("PhaseTimings=%v, want nil before any run", s.PhaseTimings)
+	}
+
+	m.RecordPhase("spawn", 5*time.Millisecond)
+	m.RecordPhase("spawn", 10*time.Millisecond)
+	m.RecordPhase("spawn", 2*time.Hour)
+
+	h, ok := m.Snapshot().PhaseTimings["spawn"]
+	if !ok {
+		t.Fatalf("missing spawn histogram")
+	}
+	if h.Count != 3 || h.MaxMs != (2*time.Hour).Milliseconds() {
+		t.Fatalf("histogram=%+v", h)
+	}
+	if len(h.Counts) != len(h.BoundsMs)+1 {
+		t.Fatalf("counts=%d, bounds=%d: want an overflow bucket", len(h.Counts), len(h.BoundsMs))
+	}
+	if h.Counts[0] != 2 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Fatalf("counts=%v, want 2 in the first bucket (bounds are inclusive) and 1 overflow", h.Counts)
+	}
+}
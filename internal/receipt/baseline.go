@@ -0,0 +1,186 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Baseline is a snapshot of a work tree taken before a run. Tree is a Git tree object
+// holding the index and worktree (untracked files included, ignored files excluded) as
+// they were; it is written through a temporary index, so HEAD and the user's index are
+// left alone.
+type Baseline struct {
+	GitRoot string
+	Tree    string
+	// Index is a tree of the index alone, used to tell what a run staged ("" when the
+	// index could not be written, e.g. during a merge conflict).
+	Index string
+	// Dirty are the files that differed from HEAD before the run.
+	Dirty []FileChange
+}
+
+// SnapshotOptions configures Snapshot.
+type SnapshotOptions struct {
+	// Timeout bounds each Git command.
+	Timeout time.Duration
+}
+
+// Snapshot records the state of the work tree containing cd. ok is false when cd is
+// not inside a Git work tree.
+func Snapshot(ctx context.Context, cd string, opts SnapshotOptions) (b Baseline, ok bool, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultCollectTimeout
+	}
+	root, ok, err := GitRoot(ctx, cd, opts.Timeout)
+	if err != nil || !ok {
+		return Baseline{}, false, err
+	}
+	status, err := runGit(ctx, root, opts.Timeout, "status", "--porcelain=v1")
+	if err != nil {
+		return Baseline{}, true, fmt.Errorf("git status failed: %w", err)
+	}
+	index, _ := writeIndexTree(ctx, root, false, opts.Timeout)
+	tree, err := writeWorktreeTree(ctx, root, opts.Timeout)
+	if err != nil {
+		return Baseline{}, true, err
+	}
+	return Baseline{GitRoot: root, Tree: tree, Index: index, Dirty: parsePorcelainV1(status)}, true, nil
+}
+
+// writeWorktreeTree writes the index and worktree of the repository at root to a tree
+// object. It stages everything into a copy of the index, so the user's index is never
+// modified.
+func writeWorktreeTree(ctx context.Context, root string, timeout time.Duration) (string, error) {
+	return writeIndexTree(ctx, root, true, timeout)
+}
+
+// writeIndexTree writes a copy of the index of the repository at root to a tree
+// object, with the whole worktree staged into it first when withWorktree is set.
+func writeIndexTree(ctx context.Context, root string, withWorktree bool, timeout time.Duration) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "codex-mcp-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpIndex := filepath.Join(tmpDir, "index")
+
+	indexPath, err := runGit(ctx, root, timeout, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	if indexPath = strings.TrimSpace(indexPath); !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(root, indexPath)
+	}
+	// Starting from the user's index keeps its stat cache, so only changed files are
+	// rehashed. A repository without an index starts empty.
+	if err := copyFile(tmpIndex, indexPath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmpIndex}
+	if withWorktree {
+		if _, err := runGitEnv(ctx, root, timeout, env, "add", "-A", "--", "."); err != nil {
+			return "", fmt.Errorf("git add failed: %w", err)
+		}
+	}
+	tree, err := runGitEnv(ctx, root, timeout, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("git write-tree failed: %w", err)
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// collectFromBaseline fills receipt with the changes made since b: the delta between
+// the baseline tree and the current work tree, with files that were dirty before the
+// run reported separately in PreexistingChanges.
+func collectFromBaseline(ctx context.Context, receipt *ChangeReceipt, b *Baseline, status string, opts CollectOptions) {
+	receipt.BaselineTree = b.Tree
+	receipt.PreexistingChanges = b.Dirty
+
+	tree, err := writeWorktreeTree(ctx, b.GitRoot, opts.Timeout)
+	if err != nil {
+		receipt.ReceiptError = err.Error()
+		return
+	}
+	receipt.ResultTree = tree
+
+	nameStatus, err := runGit(ctx, b.GitRoot, opts.Timeout, "diff", "--no-renames", "--name-status", b.Tree, tree)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff --name-status failed: %v", err)
+		return
+	}
+	porcelain := make(map[string]FileChange)
+	for _, f := range parsePorcelainV1(status) {
+		porcelain[f.Path] = f
+	}
+	receipt.ChangedFiles = parseNameStatus(nameStatus, porcelain)
+
+	diffStat, err := runGit(ctx, b.GitRoot, opts.Timeout, "diff", "--stat", b.Tree, tree)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff --stat failed: %v", err)
+		return
+	}
+	receipt.DiffStat = diffStat
+
+	numstat, err := runGit(ctx, b.GitRoot, opts.Timeout, "diff", "--no-renames", "--numstat", b.Tree, tree)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff --numstat failed: %v", err)
+		return
+	}
+	receipt.NumStat = numstat
+	applyNumStat(receipt.ChangedFiles, parseNumStat(numstat))
+
+	if opts.ReturnDiff {
+		diff, truncated, err := runGitTruncated(ctx, b.GitRoot, opts.Timeout, opts.MaxDiffBytes, "diff", b.Tree, tree)
+		if err != nil {
+			receipt.ReceiptError = fmt.Sprintf("git diff failed: %v", err)
+			return
+		}
+		receipt.Diff = diff
+		receipt.DiffTruncated = truncated
+	}
+}
+
+// parseNameStatus parses `git diff --name-status` output. Porcelain status columns are
+// copied from porcelain for files that are still uncommitted.
+func parseNameStatus(out string, porcelain map[string]FileChange) []FileChange {
+	lines := strings.Split(out, "\n")
+	files := make([]FileChange, 0, len(lines))
+	for _, line := range lines {
+		change, path, found := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+		if !found || change == "" || path == "" {
+			continue
+		}
+		path = unquotePath(path)
+		f := porcelain[path]
+		f.Path = path
+		f.Change = change[:1]
+		files = append(files, f)
+	}
+	return files
+}
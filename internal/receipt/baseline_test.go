@@ -0,0 +1,78 @@
+package receipt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_RecordsWorktreeWithoutTouchingIndex(t *testing.T) {
+	repo := initRepo(t)
+	writeFile(t, repo, "file.txt", "changed\n")
+	writeFile(t, repo, "new.txt", "new\n")
+	indexBefore := git(t, repo, "write-tree")
+
+	b, ok, err := Snapshot(context.Background(), repo, SnapshotOptions{})
+	if err != nil || !ok {
+		t.Fatalf("Snapshot() ok=%v err=%v", ok, err)
+	}
+	if b.GitRoot == "" || b.Tree == "" || b.Index == "" {
+		t.Fatalf("incomplete baseline: %+v", b)
+	}
+	if got := strings.TrimSpace(indexBefore); b.Index != got {
+		t.Fatalf("baseline index=%s, want %s", b.Index, got)
+	}
+	if got := git(t, repo, "show", b.Tree+":new.txt"); got != "new\n" {
+		t.Fatalf("snapshot new.txt=%q", got)
+	}
+	if got := git(t, repo, "show", b.Tree+":file.txt"); got != "changed\n" {
+		t.Fatalf("snapshot file.txt=%q", got)
+	}
+	if status := git(t, repo, "status", "--porcelain=v1"); !strings.Contains(status, "?? new.txt") {
+		t.Fatalf("Snapshot() staged the work tree: %q", status)
+	}
+	dirty := map[string]FileChange{}
+	for _, f := range b.Dirty {
+		dirty[f.Path] = f
+	}
+	if dirty["file.txt"].WorktreeStatus != "M" || dirty["new.txt"].WorktreeStatus != "?" || len(dirty) != 2 {
+		t.Fatalf("baseline dirty=%+v", b.Dirty)
+	}
+}
+
+func TestSnapshot_OutsideRepository(t *testing.T) {
+	initRepo(t) // skips without git
+	if _, ok, err := Snapshot(context.Background(), t.TempDir(), SnapshotOptions{}); ok || err != nil {
+		t.Fatalf("Snapshot() outside a repository ok=%v err=%v", ok, err)
+	}
+}
+
+func TestSnapshot_HonorsTimeout(t *testing.T) {
+	repo := initRepo(t)
+	if _, _, err := Snapshot(context.Background(), repo, SnapshotOptions{Timeout: time.Nanosecond}); err == nil {
+		t.Fatalf("Snapshot() with an expired timeout succeeded")
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	out := "M\tfile.txt\nA\t\"caf\\303\\251.txt\"\nD\tgone.txt\r\nT\tlink\nbogus\n\n"
+	porcelain := map[string]FileChange{
+		"file.txt": {Path: "file.txt", IndexStatus: " ", WorktreeStatus: "M"},
+	}
+	got := parseNameStatus(out, porcelain)
+	want := []FileChange{
+		{Path: "file.txt", IndexStatus: " ", WorktreeStatus: "M", Change: "M"},
+		{Path: "café.txt", Change: "A"},
+		{Path: "gone.txt", Change: "D"},
+		{Path: "link", Change: "T"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseNameStatus()=%+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("parseNameStatus()[%d]=%+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
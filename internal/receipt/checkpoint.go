@@ -0,0 +1,280 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckpointNamespace is the ref namespace checkpoints are stored under. Refs outside
+// refs/heads, refs/tags and refs/stash are not shown by `git branch`, `git tag` or
+// `git stash list`, and are never pushed by default.
+const CheckpointNamespace = "refs/codex-mcp/"
+
+// UndoCheckpoint is the name of the checkpoint Restore callers take before rolling
+// back, so the rollback itself can be undone.
+const UndoCheckpoint = "undo"
+
+const defaultRestoreTimeout = 30 * time.Second
+
+// checkpointIdentity is the author and committer of checkpoint commits, so they can be
+// written in repositories without a configured user.
+var checkpointIdentity = []string{
+	"GIT_AUTHOR_NAME=codex-mcp",
+	"GIT_AUTHOR_EMAIL=codex-mcp@localhost",
+	"GIT_COMMITTER_NAME=codex-mcp",
+	"GIT_COMMITTER_EMAIL=codex-mcp@localhost",
+}
+
+// Checkpoint is a commit of a work tree snapshot, stored at
+// refs/codex-mcp/<session>/<name>. Name is the index of the turn that started from
+// the snapshot, or UndoCheckpoint.
+type Checkpoint struct {
+	Name      string    `json:"name"`
+	Ref       string    `json:"ref"`
+	Commit    string    `json:"commit"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// CheckpointRef returns the ref of the checkpoint name of sessionID.
+func CheckpointRef(sessionID string, name string) string {
+	return CheckpointNamespace + sessionID + "/" + name
+}
+
+// CreateCheckpoint commits the snapshot b (with HEAD as its parent, when there is one)
+// and points ref at it. Only the new ref is written: branches, the index and the stash
+// are left alone.
+func CreateCheckpoint(ctx context.Context, b Baseline, ref string, message string, timeout time.Duration) (Checkpoint, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if b.GitRoot == "" || b.Tree == "" {
+		return Checkpoint{}, errors.New("snapshot has no tree")
+	}
+	if !strings.HasPrefix(ref, CheckpointNamespace) {
+		return Checkpoint{}, fmt.Errorf("checkpoint ref %q is outside %s", ref, CheckpointNamespace)
+	}
+	if _, err := runGit(ctx, b.GitRoot, timeout, "check-ref-format", ref); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint ref %q", ref)
+	}
+
+	args := []string{"commit-tree", b.Tree, "-m", message}
+	if head, err := runGit(ctx, b.GitRoot, timeout, "rev-parse", "--verify", "-q", "HEAD^{commit}"); err == nil && strings.TrimSpace(head) != "" {
+		args = append(args, "-p", strings.TrimSpace(head))
+	}
+	commit, err := runGitEnv(ctx, b.GitRoot, timeout, checkpointIdentity, args...)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("git commit-tree failed: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+	if _, err := runGit(ctx, b.GitRoot, timeout, "update-ref", "-m", "codex-mcp: checkpoint", ref, commit); err != nil {
+		return Checkpoint{}, fmt.Errorf("git update-ref failed: %w", err)
+	}
+	return Checkpoint{
+		Name:      ref[strings.LastIndex(ref, "/")+1:],
+		Ref:       ref,
+		Commit:    commit,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// MoveCheckpoint renames the checkpoint ref from to to (e.g. once a new session's
+// thread ID is known).
+func MoveCheckpoint(ctx context.Context, gitRoot string, from, to string, timeout time.Duration) error {
+	if from == to {
+		return nil
+	}
+	if _, err := runGit(ctx, gitRoot, timeout, "update-ref", "-m", "codex-mcp: checkpoint", to, from); err != nil {
+		return fmt.Errorf("git update-ref failed: %w", err)
+	}
+	if _, err := runGit(ctx, gitRoot, timeout, "update-ref", "-d", from); err != nil {
+		return fmt.Errorf("git update-ref -d failed: %w", err)
+	}
+	return nil
+}
+
+// ListCheckpoints returns the checkpoints of sessionID in the repository at gitRoot:
+// turn checkpoints in turn order, then any others by name.
+func ListCheckpoints(ctx context.Context, gitRoot string, sessionID string, timeout time.Duration) ([]Checkpoint, error) {
+	prefix := CheckpointNamespace + sessionID + "/"
+	out, err := runGit(ctx, gitRoot, timeout, "for-each-ref",
+		"--format=%(refname)%09%(objectname)%09%(creatordate:unix)", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+	var checkpoints []Checkpoint
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		name, ok := strings.CutPrefix(fields[0], prefix)
+		if !ok || name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		cp := Checkpoint{Name: name, Ref: fields[0], Commit: fields[1]}
+		if sec, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			cp.CreatedAt = time.Unix(sec, 0)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		ti, errI := strconv.Atoi(checkpoints[i].Name)
+		tj, errJ := strconv.Atoi(checkpoints[j].Name)
+		switch {
+		case errI == nil && errJ == nil:
+			return ti < tj
+		case errI == nil || errJ == nil:
+			return errI == nil
+		default:
+			return checkpoints[i].Name < checkpoints[j].Name
+		}
+	})
+	return checkpoints, nil
+}
+
+// DeleteCheckpoints deletes every checkpoint of sessionID in the repository at gitRoot
+// (e.g. once the session has expired) and returns how many it deleted.
+func DeleteCheckpoints(ctx context.Context, gitRoot string, sessionID string, timeout time.Duration) (int, error) {
+	checkpoints, err := ListCheckpoints(ctx, gitRoot, sessionID, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return deleteCheckpoints(ctx, gitRoot, checkpoints, timeout)
+}
+
+// PruneCheckpoints deletes all but the keep newest turn checkpoints of sessionID in the
+// repository at gitRoot and returns how many it deleted. The undo checkpoint is kept,
+// and keep <= 0 keeps everything.
+func PruneCheckpoints(ctx context.Context, gitRoot string, sessionID string, keep int, timeout time.Duration) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	checkpoints, err := ListCheckpoints(ctx, gitRoot, sessionID, timeout)
+	if err != nil {
+		return 0, err
+	}
+	// Turn checkpoints come first, oldest turn first.
+	turns := 0
+	for _, cp := range checkpoints {
+		if _, err := strconv.Atoi(cp.Name); err == nil {
+			turns++
+		}
+	}
+	if turns <= keep {
+		return 0, nil
+	}
+	return deleteCheckpoints(ctx, gitRoot, checkpoints[:turns-keep], timeout)
+}
+
+// deleteCheckpoints deletes the refs of checkpoints in a single `git update-ref`.
+func deleteCheckpoints(ctx context.Context, gitRoot string, checkpoints []Checkpoint, timeout time.Duration) (int, error) {
+	if len(checkpoints) == 0 {
+		return 0, nil
+	}
+	var stdin strings.Builder
+	for _, cp := range checkpoints {
+		fmt.Fprintf(&stdin, "delete %s %s\n", cp.Ref, cp.Commit)
+	}
+	if _, err := runGitInput(ctx, gitRoot, timeout, nil, stdin.String(), "update-ref", "--stdin"); err != nil {
+		return 0, fmt.Errorf("git update-ref --stdin failed: %w", err)
+	}
+	return len(checkpoints), nil
+}
+
+// RestoreOptions configures Restore.
+type RestoreOptions struct {
+	// DryRun only reports the files Restore would change.
+	DryRun bool
+	// Timeout bounds each Git command.
+	Timeout time.Duration
+}
+
+// Restore makes the worktree of the repository at gitRoot match the checkpoint commit
+// and returns the files it changed (or would change, for a dry run). Change is the
+// action taken: A re-creates, M (or T) overwrites and D deletes a file. HEAD and the
+// index are left alone, as are ignored files.
+func Restore(ctx context.Context, gitRoot string, commit string, opts RestoreOptions) ([]FileChange, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRestoreTimeout
+	}
+	current, err := writeWorktreeTree(ctx, gitRoot, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(ctx, gitRoot, opts.Timeout, "diff", "-z", "--no-renames", "--name-status", current, commit+"^{tree}")
+	if err != nil {
+		return nil, fmt.Errorf("git diff --name-status failed: %w", err)
+	}
+	files := parseNameStatusZ(out)
+	if opts.DryRun || len(files) == 0 {
+		return files, nil
+	}
+
+	var checkout []string
+	for _, f := range files {
+		if f.Change != "D" {
+			checkout = append(checkout, f.Path)
+			continue
+		}
+		path := filepath.Join(gitRoot, filepath.FromSlash(f.Path))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		removeEmptyParents(gitRoot, filepath.Dir(path))
+	}
+	if len(checkout) == 0 {
+		return files, nil
+	}
+
+	// Check the files out of a temporary index holding the checkpoint, so the user's
+	// index keeps its staged changes.
+	tmpDir, err := os.MkdirTemp("", "codex-mcp-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	if _, err := runGitEnv(ctx, gitRoot, opts.Timeout, env, "read-tree", commit); err != nil {
+		return nil, fmt.Errorf("git read-tree failed: %w", err)
+	}
+	stdin := strings.Join(checkout, "\x00") + "\x00"
+	if _, err := runGitInput(ctx, gitRoot, opts.Timeout, env, stdin, "checkout-index", "-f", "-z", "--stdin"); err != nil {
+		return nil, fmt.Errorf("git checkout-index failed: %w", err)
+	}
+	return files, nil
+}
+
+// parseNameStatusZ parses `git diff -z --name-status` output (without renames).
+func parseNameStatusZ(out string) []FileChange {
+	fields := strings.Split(out, "\x00")
+	var files []FileChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		change, path := fields[i], fields[i+1]
+		if change == "" || path == "" {
+			continue
+		}
+		files = append(files, FileChange{Path: path, Change: change[:1]})
+	}
+	return files
+}
+
+// removeEmptyParents removes dir and its parents up to (not including) root while they
+// are empty.
+func removeEmptyParents(root string, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestore_RevertsWorktreeAndKeepsIndex(t *testing.T) {
+	repo := initRepo(t)
+	writeFile(t, repo, "staged.txt", "staged\n")
+	git(t, repo, "add", "staged.txt")
+	b, ok, err := Snapshot(context.Background(), repo, SnapshotOptions{})
+	if err != nil || !ok {
+		t.Fatalf("Snapshot() ok=%v err=%v", ok, err)
+	}
+	cp, err := CreateCheckpoint(context.Background(), b, CheckpointRef("s1", "0"), "checkpoint", 0)
+	if err != nil {
+		t.Fatalf("CreateCheckpoint() failed: %v", err)
+	}
+
+	// Changes made after the checkpoint: an edit, a deletion and a new nested file.
+	writeFile(t, repo, "file.txt", "edited\n")
+	if err := os.Remove(filepath.Join(repo, "staged.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, repo, "dir/sub/added.txt", "added\n")
+
+	dry, err := Restore(context.Background(), repo, cp.Commit, RestoreOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Restore(dry run) failed: %v", err)
+	}
+	changes := map[string]string{}
+	for _, f := range dry {
+		changes[f.Path] = f.Change
+	}
+	if len(changes) != 3 || changes["file.txt"] != "M" || changes["staged.txt"] != "A" || changes["dir/sub/added.txt"] != "D" {
+		t.Fatalf("dry run changes=%+v", dry)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "file.txt")); string(got) != "edited\n" {
+		t.Fatalf("dry run modified file.txt")
+	}
+
+	files, err := Restore(context.Background(), repo, cp.Commit, RestoreOptions{})
+	if err != nil || len(files) != 3 {
+		t.Fatalf("Restore()=%+v, %v", files, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "file.txt")); string(got) != "hello\n" {
+		t.Fatalf("file.txt=%q after restore", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "staged.txt")); string(got) != "staged\n" {
+		t.Fatalf("staged.txt=%q after restore", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "dir")); !os.IsNotExist(err) {
+		t.Fatalf("empty directories of the removed file were kept: %v", err)
+	}
+	// The index still has staged.txt staged, and nothing else.
+	if status := git(t, repo, "status", "--porcelain=v1"); strings.TrimSpace(status) != "A  staged.txt" {
+		t.Fatalf("status after restore=%q", status)
+	}
+
+	if files, err := Restore(context.Background(), repo, cp.Commit, RestoreOptions{}); err != nil || len(files) != 0 {
+		t.Fatalf("second Restore()=%+v, %v, want no changes", files, err)
+	}
+}
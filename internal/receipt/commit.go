@@ -0,0 +1,155 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CommitOptions configures CommitChanges.
+type CommitOptions struct {
+	// Branch, when set, receives the commit (on top of its tip, or of HEAD for a new
+	// branch) and HEAD, the index and the worktree are left alone. Otherwise the commit
+	// goes on top of HEAD, and the index entries of the committed files are updated to
+	// match it.
+	Branch  string
+	Message string
+	// AuthorName and AuthorEmail are the author and committer of the commit.
+	AuthorName  string
+	AuthorEmail string
+	// Timeout bounds each Git command.
+	Timeout time.Duration
+}
+
+// CommitChanges commits the files that changed from baselineTree to resultTree (the
+// trees of a baseline receipt, see Collect) as they are in resultTree, and nothing
+// else: changes that were already in the work tree before the run stay uncommitted.
+// It returns "" when there is nothing to commit.
+func CommitChanges(ctx context.Context, gitRoot string, baselineTree, resultTree string, opts CommitOptions) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if baselineTree == "" || resultTree == "" {
+		return "", errors.New("receipt has no baseline")
+	}
+	out, err := runGit(ctx, gitRoot, opts.Timeout, "diff", "-z", "--no-renames", "--name-status", baselineTree, resultTree)
+	if err != nil {
+		return "", fmt.Errorf("git diff --name-status failed: %w", err)
+	}
+	files := parseNameStatusZ(out)
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	ref := "HEAD"
+	if opts.Branch != "" {
+		ref = "refs/heads/" + opts.Branch
+		if _, err := runGit(ctx, gitRoot, opts.Timeout, "check-ref-format", ref); err != nil {
+			return "", fmt.Errorf("invalid branch name %q", opts.Branch)
+		}
+	}
+	old := revParseCommit(ctx, gitRoot, opts.Timeout, ref)
+	parent := old
+	if parent == "" && opts.Branch != "" {
+		parent = revParseCommit(ctx, gitRoot, opts.Timeout, "HEAD")
+	}
+
+	indexInfo, err := resultIndexInfo(ctx, gitRoot, resultTree, files, opts.Timeout)
+	if err != nil {
+		return "", err
+	}
+
+	// Build the tree in a temporary index: the parent plus the committed files.
+	tmpDir, err := os.MkdirTemp("", "codex-mcp-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	if parent != "" {
+		if _, err := runGitEnv(ctx, gitRoot, opts.Timeout, env, "read-tree", parent); err != nil {
+			return "", fmt.Errorf("git read-tree failed: %w", err)
+		}
+	}
+	if _, err := runGitInput(ctx, gitRoot, opts.Timeout, env, indexInfo, "update-index", "-z", "--index-info"); err != nil {
+		return "", fmt.Errorf("git update-index failed: %w", err)
+	}
+	tree, err := runGitEnv(ctx, gitRoot, opts.Timeout, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("git write-tree failed: %w", err)
+	}
+	tree = strings.TrimSpace(tree)
+	if parent != "" {
+		if parentTree, err := runGit(ctx, gitRoot, opts.Timeout, "rev-parse", parent+"^{tree}"); err == nil && strings.TrimSpace(parentTree) == tree {
+			return "", nil
+		}
+	}
+
+	args := []string{"commit-tree", tree, "-m", opts.Message}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	identity := []string{
+		"GIT_AUTHOR_NAME=" + opts.AuthorName,
+		"GIT_AUTHOR_EMAIL=" + opts.AuthorEmail,
+		"GIT_COMMITTER_NAME=" + opts.AuthorName,
+		"GIT_COMMITTER_EMAIL=" + opts.AuthorEmail,
+	}
+	commit, err := runGitEnv(ctx, gitRoot, opts.Timeout, identity, args...)
+	if err != nil {
+		return "", fmt.Errorf("git commit-tree failed: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	// The old value makes the update fail if the ref moved in the meantime ("" means it
+	// must not exist yet).
+	if _, err := runGit(ctx, gitRoot, opts.Timeout, "update-ref", "-m", "codex-mcp: commit", ref, commit, old); err != nil {
+		return "", fmt.Errorf("git update-ref failed: %w", err)
+	}
+	if opts.Branch == "" {
+		// Stage the committed files, so they do not show up as reverted in the index.
+		if _, err := runGitInput(ctx, gitRoot, opts.Timeout, nil, indexInfo, "update-index", "-z", "--index-info"); err != nil {
+			return commit, fmt.Errorf("committed, but git update-index failed: %w", err)
+		}
+	}
+	return commit, nil
+}
+
+func revParseCommit(ctx context.Context, gitRoot string, timeout time.Duration, rev string) string {
+	out, err := runGit(ctx, gitRoot, timeout, "rev-parse", "--verify", "-q", rev+"^{commit}")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// resultIndexInfo returns `git update-index -z --index-info` input that sets files to
+// their entries in tree, removing the files that are not in it.
+func resultIndexInfo(ctx context.Context, gitRoot string, tree string, files []FileChange, timeout time.Duration) (string, error) {
+	out, err := runGit(ctx, gitRoot, timeout, "ls-tree", "-r", "-z", "--full-tree", tree)
+	if err != nil {
+		return "", fmt.Errorf("git ls-tree failed: %w", err)
+	}
+	entries := make(map[string]string)
+	for _, entry := range strings.Split(out, "\x00") {
+		// <mode> SP <type> SP <object> TAB <path>, which --index-info accepts as is.
+		if _, path, ok := strings.Cut(entry, "\t"); ok {
+			entries[path] = entry
+		}
+	}
+	var b strings.Builder
+	for _, f := range files {
+		if entry, ok := entries[f.Path]; ok {
+			b.WriteString(entry)
+		} else {
+			// Mode 0 removes the path; the object name only has to be well-formed.
+			b.WriteString("0 " + strings.Repeat("0", len(tree)) + "\t" + f.Path)
+		}
+		b.WriteByte(0)
+	}
+	return b.String(), nil
+}
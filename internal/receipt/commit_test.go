@@ -0,0 +1,94 @@
+package receipt
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// runTrees snapshots repo, applies change and returns the baseline and result trees.
+func runTrees(t *testing.T, repo string, change func()) (string, string) {
+	t.Helper()
+	before, ok, err := Snapshot(context.Background(), repo, SnapshotOptions{})
+	if err != nil || !ok {
+		t.Fatalf("Snapshot() ok=%v err=%v", ok, err)
+	}
+	change()
+	after, ok, err := Snapshot(context.Background(), repo, SnapshotOptions{})
+	if err != nil || !ok {
+		t.Fatalf("Snapshot() ok=%v err=%v", ok, err)
+	}
+	return before.Tree, after.Tree
+}
+
+func TestCommitChanges_CommitsOnlyTheRunsChanges(t *testing.T) {
+	repo := initRepo(t)
+	// A change that predates the run must stay uncommitted.
+	writeFile(t, repo, "mine.txt", "user work\n")
+	baseline, result := runTrees(t, repo, func() {
+		writeFile(t, repo, "file.txt", "by codex\n")
+		writeFile(t, repo, "codex.txt", "new\n")
+	})
+
+	commit, err := CommitChanges(context.Background(), repo, baseline, result, CommitOptions{
+		Message:     "codex run",
+		AuthorName:  "Codex",
+		AuthorEmail: "codex@example.com",
+	})
+	if err != nil || commit == "" {
+		t.Fatalf("CommitChanges()=%q, %v", commit, err)
+	}
+	if head := strings.TrimSpace(git(t, repo, "rev-parse", "HEAD")); head != commit {
+		t.Fatalf("HEAD=%s, want %s", head, commit)
+	}
+	files := strings.Fields(git(t, repo, "show", "--name-only", "--format=", commit))
+	if len(files) != 2 || files[0] != "codex.txt" || files[1] != "file.txt" {
+		t.Fatalf("committed files=%v", files)
+	}
+	if author := strings.TrimSpace(git(t, repo, "log", "-1", "--format=%an <%ae>")); author != "Codex <codex@example.com>" {
+		t.Fatalf("author=%q", author)
+	}
+	// The committed files are staged to match, so only the user's file is left over.
+	if status := git(t, repo, "status", "--porcelain=v1"); strings.TrimSpace(status) != "?? mine.txt" {
+		t.Fatalf("status after commit=%q", status)
+	}
+
+	// Nothing changed since: nothing to commit.
+	if again, err := CommitChanges(context.Background(), repo, result, result, CommitOptions{Message: "noop"}); err != nil || again != "" {
+		t.Fatalf("CommitChanges() without changes=%q, %v", again, err)
+	}
+}
+
+func TestCommitChanges_ToBranchLeavesHEADAlone(t *testing.T) {
+	repo := initRepo(t)
+	head := strings.TrimSpace(git(t, repo, "rev-parse", "HEAD"))
+	baseline, result := runTrees(t, repo, func() {
+		writeFile(t, repo, "file.txt", "by codex\n")
+	})
+
+	commit, err := CommitChanges(context.Background(), repo, baseline, result, CommitOptions{
+		Branch:      "codex/s1",
+		Message:     "codex run",
+		AuthorName:  "Codex",
+		AuthorEmail: "codex@example.com",
+	})
+	if err != nil || commit == "" {
+		t.Fatalf("CommitChanges()=%q, %v", commit, err)
+	}
+	if got := strings.TrimSpace(git(t, repo, "rev-parse", "HEAD")); got != head {
+		t.Fatalf("HEAD moved to %s", got)
+	}
+	if got := strings.TrimSpace(git(t, repo, "rev-parse", "codex/s1")); got != commit {
+		t.Fatalf("branch=%s, want %s", got, commit)
+	}
+	if parent := strings.TrimSpace(git(t, repo, "rev-parse", commit+"^")); parent != head {
+		t.Fatalf("branch commit parent=%s, want HEAD %s", parent, head)
+	}
+	if status := git(t, repo, "status", "--porcelain=v1"); strings.TrimSpace(status) != "M file.txt" {
+		t.Fatalf("status after branch commit=%q", status)
+	}
+
+	if _, err := CommitChanges(context.Background(), repo, baseline, result, CommitOptions{Branch: "bad..name"}); err == nil {
+		t.Fatalf("expected an invalid branch name to be rejected")
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,6 +16,13 @@ import (
 type CollectOptions struct {
 	ReturnDiff   bool
 	MaxDiffBytes int
+	// MaxUntrackedBytes caps the untracked file contents included with ReturnDiff,
+	// across all files.
+	MaxUntrackedBytes int
+
+	// Baseline, when set, limits the receipt to changes made since the snapshot (see
+	// Snapshot) instead of everything that differs from HEAD.
+	Baseline *Baseline
 
 	// Timeout bounds the total time spent in Git collection.
 	Timeout time.Duration
@@ -63,26 +72,44 @@ func Collect(ctx context.Context, cd string, opts CollectOptions) ChangeReceipt
 		return receipt
 	}
 	receipt.GitStatus = status
+	if b := opts.Baseline; b != nil && b.Tree != "" && b.GitRoot == gitRoot {
+		collectFromBaseline(ctx, &receipt, b, status, opts)
+	} else {
+		collectFromHEAD(ctx, &receipt, cd, status, opts)
+	}
+	if receipt.ReceiptError == "" {
+		collectSections(ctx, &receipt, gitRoot, opts)
+	}
+	return receipt
+}
+
+// collectFromHEAD fills receipt with everything that differs from HEAD, for when no
+// pre-run baseline is available. DiffStat and Diff cover the unstaged changes.
+func collectFromHEAD(ctx context.Context, receipt *ChangeReceipt, cd string, status string, opts CollectOptions) {
 	receipt.ChangedFiles = parsePorcelainV1(status)
 
 	diffStat, err := runGit(ctx, cd, opts.Timeout, "diff", "--stat")
 	if err != nil {
 		receipt.ReceiptError = fmt.Sprintf("git diff --stat failed: %v", err)
-		return receipt
+		return
 	}
 	receipt.DiffStat = diffStat
 
+	// Line counts against HEAD, staged and unstaged together; an unborn branch has none.
+	if numstat, err := runGit(ctx, cd, opts.Timeout, "diff", "--no-renames", "--numstat", "HEAD", "--"); err == nil {
+		receipt.NumStat = numstat
+		applyNumStat(receipt.ChangedFiles, parseNumStat(numstat))
+	}
+
 	if opts.ReturnDiff {
 		diff, truncated, err := runGitTruncated(ctx, cd, opts.Timeout, opts.MaxDiffBytes, "diff")
 		if err != nil {
 			receipt.ReceiptError = fmt.Sprintf("git diff failed: %v", err)
-			return receipt
+			return
 		}
 		receipt.Diff = diff
 		receipt.DiffTruncated = truncated
 	}
-
-	return receipt
 }
 
 // GitRoot returns the repository root for cd if it is inside a Git work tree.
@@ -103,6 +130,16 @@ func GitRoot(ctx context.Context, cd string, timeout time.Duration) (root string
 }
 
 func runGit(ctx context.Context, cd string, timeout time.Duration, args ...string) (string, error) {
+	return runGitEnv(ctx, cd, timeout, nil, args...)
+}
+
+// runGitEnv is runGit with extra environment variables (e.g. GIT_INDEX_FILE).
+func runGitEnv(ctx context.Context, cd string, timeout time.Duration, env []string, args ...string) (string, error) {
+	return runGitInput(ctx, cd, timeout, env, "", args...)
+}
+
+// runGitInput is runGitEnv with stdin (for --stdin commands).
+func runGitInput(ctx context.Context, cd string, timeout time.Duration, env []string, stdin string, args ...string) (string, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -113,6 +150,12 @@ func runGit(ctx context.Context, cd string, timeout time.Duration, args ...strin
 	defer cancel()
 
 	cmd := exec.CommandContext(runCtx, "git", append([]string{"-C", cd}, args...)...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
 	out, err := cmd.CombinedOutput()
 	if runCtx.Err() != nil {
 		return "", runCtx.Err()
@@ -210,7 +253,7 @@ func parsePorcelainV1(status string) []FileChange {
 			continue
 		}
 		if strings.HasPrefix(line, "?? ") {
-			path := strings.TrimSpace(strings.TrimPrefix(line, "?? "))
+			path := unquotePath(strings.TrimSpace(strings.TrimPrefix(line, "?? ")))
 			if path == "" {
 				continue
 			}
@@ -226,7 +269,7 @@ func parsePorcelainV1(status string) []FileChange {
 		}
 		indexStatus := string(line[0])
 		worktreeStatus := string(line[1])
-		path := strings.TrimSpace(line[3:])
+		path := unquotePath(strings.TrimSpace(line[3:]))
 		if path == "" {
 			continue
 		}
@@ -239,6 +282,19 @@ func parsePorcelainV1(status string) []FileChange {
 	return out
 }
 
+// unquotePath undoes the C-style quoting Git applies, outside of -z output, to paths
+// with special or non-ASCII bytes ("caf\303\251.txt"), so they match the raw paths
+// of -z output and the file system. Other paths are returned as they are.
+func unquotePath(path string) string {
+	if len(path) < 2 || path[0] != '"' || path[len(path)-1] != '"' {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
 func isNotGitRepo(err error) bool {
 	if err == nil {
 		return false
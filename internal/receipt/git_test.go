@@ -0,0 +1,60 @@
+package receipt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a repository with one committed file, file.txt.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git(t, repo, "init")
+	git(t, repo, "config", "user.email", "test@example.com")
+	git(t, repo, "config", "user.name", "test")
+	writeFile(t, repo, "file.txt", "hello\n")
+	git(t, repo, "add", "file.txt")
+	git(t, repo, "commit", "-m", "init")
+	return repo
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestUnquotePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain.txt":                  "plain.txt",
+		`"caf\303\251.txt"`:          "café.txt",
+		`"with \"quotes\" and\ttab"`: "with \"quotes\" and\ttab",
+		`"unterminated`:              `"unterminated`,
+	} {
+		if got := unquotePath(in); got != want {
+			t.Errorf("unquotePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
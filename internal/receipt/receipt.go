@@ -1,9 +1,19 @@
 package receipt
 
+import "unicode/utf8"
+
 type FileChange struct {
 	Path           string `json:"path"`
 	IndexStatus    string `json:"index_status,omitempty"`
 	WorktreeStatus string `json:"worktree_status,omitempty"`
+	// Change is the change since the pre-run baseline (A, M, D or T); set only for
+	// baseline receipts.
+	Change string `json:"change,omitempty"`
+	// Added and Removed are the line counts of the change (`git diff --numstat`); both
+	// are unset for binary files.
+	Added   *int `json:"added,omitempty"`
+	Removed *int `json:"removed,omitempty"`
+	Binary  bool `json:"binary,omitempty"`
 }
 
 type ChangeReceipt struct {
@@ -11,14 +21,113 @@ type ChangeReceipt struct {
 	GitRoot          string       `json:"git_root,omitempty"`
 	GitStatus        string       `json:"git_status,omitempty"`
 	DiffStat         string       `json:"diff_stat,omitempty"`
+	NumStat          string       `json:"numstat,omitempty"`
 	ChangedFiles     []FileChange `json:"changed_files,omitempty"`
 
+	// PreexistingChanges are the files that were already dirty before the run. When
+	// the receipt has a baseline, ChangedFiles, DiffStat and Diff cover only the changes
+	// made since BaselineTree (the pre-run tree) up to ResultTree (the post-run tree).
+	PreexistingChanges []FileChange `json:"preexisting_changes,omitempty"`
+	BaselineTree       string       `json:"baseline_tree,omitempty"`
+	ResultTree         string       `json:"result_tree,omitempty"`
+
+	// Checkpoint is the ref of the pre-run checkpoint (see CreateCheckpoint), and
+	// CheckpointCommit the commit it points at.
+	Checkpoint       string `json:"checkpoint,omitempty"`
+	CheckpointCommit string `json:"checkpoint_commit,omitempty"`
+
+	// Worktree is the isolated worktree the run used (isolation=worktree).
+	Worktree *Worktree `json:"worktree,omitempty"`
+
+	// Commit is the commit of the run's changes (commit_mode), on CommitBranch for
+	// commit-to-branch. CommitError explains why the changes were not committed.
+	Commit       string `json:"commit,omitempty"`
+	CommitBranch string `json:"commit_branch,omitempty"`
+	CommitError  string `json:"commit_error,omitempty"`
+
 	// Diff is included only when explicitly requested (e.g. return_diff=true),
 	// and is always size-limited.
 	Diff          string `json:"diff,omitempty"`
 	DiffTruncated bool   `json:"diff_truncated,omitempty"`
 
+	// Staged, Unstaged and Untracked split the run's changes by where they are after
+	// the run: added to the index, left in the worktree, and files Git does not track
+	// (UntrackedOmitted counts those left out of the list). Without a baseline they
+	// cover the whole uncommitted state instead: HEAD to index, index to worktree, and
+	// every untracked file.
+	Staged           *DiffSection    `json:"staged,omitempty"`
+	Unstaged         *DiffSection    `json:"unstaged,omitempty"`
+	Untracked        []UntrackedFile `json:"untracked,omitempty"`
+	UntrackedOmitted int             `json:"untracked_omitted,omitempty"`
+
+	// CodexVersion is the detected codex CLI version that produced the changes (best-effort).
+	CodexVersion string `json:"codex_version,omitempty"`
+
 	// ReceiptError contains best-effort diagnostics for why a receipt is unavailable.
 	// It MUST NOT cause the parent tool call to fail.
 	ReceiptError string `json:"receipt_error,omitempty"`
 }
+
+// Capped returns r with its diffs and untracked file contents cut to at most maxBytes
+// each (and the matching truncated flags set). r itself is returned when nothing
+// exceeds the cap; otherwise r is left unmodified.
+func (r *ChangeReceipt) Capped(maxBytes int) *ChangeReceipt {
+	if r == nil || maxBytes <= 0 || !r.exceeds(maxBytes) {
+		return r
+	}
+	out := *r
+	if cut, ok := capText(r.Diff, maxBytes); ok {
+		out.Diff, out.DiffTruncated = cut, true
+	}
+	out.Staged = r.Staged.capped(maxBytes)
+	out.Unstaged = r.Unstaged.capped(maxBytes)
+	if len(r.Untracked) > 0 {
+		out.Untracked = append([]UntrackedFile(nil), r.Untracked...)
+		for i := range out.Untracked {
+			if cut, ok := capText(out.Untracked[i].Content, maxBytes); ok {
+				out.Untracked[i].Content, out.Untracked[i].Truncated = cut, true
+			}
+		}
+	}
+	return &out
+}
+
+func (r *ChangeReceipt) exceeds(maxBytes int) bool {
+	if len(r.Diff) > maxBytes {
+		return true
+	}
+	if (r.Staged != nil && len(r.Staged.Diff) > maxBytes) || (r.Unstaged != nil && len(r.Unstaged.Diff) > maxBytes) {
+		return true
+	}
+	for _, f := range r.Untracked {
+		if len(f.Content) > maxBytes {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DiffSection) capped(maxBytes int) *DiffSection {
+	if s == nil {
+		return nil
+	}
+	cut, ok := capText(s.Diff, maxBytes)
+	if !ok {
+		return s
+	}
+	out := *s
+	out.Diff, out.DiffTruncated = cut, true
+	return &out
+}
+
+// capText cuts s to at most maxBytes on a rune boundary; ok reports whether it did.
+func capText(s string, maxBytes int) (string, bool) {
+	if len(s) <= maxBytes {
+		return s, false
+	}
+	n := maxBytes
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
@@ -0,0 +1,296 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxUntrackedBytes = 64 * 1024
+	maxUntrackedFiles        = 200
+	// binarySniffBytes is how much of a file is checked for NUL bytes, as Git does.
+	binarySniffBytes = 8000
+)
+
+// FileStat is one line of `git diff --numstat`. Binary files have no line counts.
+type FileStat struct {
+	Path    string `json:"path"`
+	Added   *int   `json:"added,omitempty"`
+	Removed *int   `json:"removed,omitempty"`
+	Binary  bool   `json:"binary,omitempty"`
+}
+
+// DiffSection is one side of the uncommitted changes: staged (HEAD to index) or
+// unstaged (index to worktree).
+type DiffSection struct {
+	Files []FileStat `json:"files"`
+	// NumStat is the raw `git diff --numstat` output.
+	NumStat string `json:"numstat,omitempty"`
+	// Diff is included only when requested, and is always size-limited.
+	Diff          string `json:"diff,omitempty"`
+	DiffTruncated bool   `json:"diff_truncated,omitempty"`
+}
+
+// UntrackedFile is a file Git does not track yet (ignored files excluded). Content is
+// included only when diffs are requested, for text files, within a shared size cap.
+type UntrackedFile struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Binary    bool   `json:"binary,omitempty"`
+	Content   string `json:"content,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// collectSections fills the staged, unstaged and untracked sections of receipt. With a
+// baseline they hold only the run's changes (see collectBaselineSections); otherwise
+// the whole uncommitted state of the work tree.
+func collectSections(ctx context.Context, receipt *ChangeReceipt, root string, opts CollectOptions) {
+	if b := opts.Baseline; b != nil && receipt.ResultTree != "" {
+		collectBaselineSections(ctx, receipt, b, opts)
+		return
+	}
+	staged, err := collectSection(ctx, root, opts, "--cached")
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff --cached failed: %v", err)
+		return
+	}
+	receipt.Staged = staged
+
+	unstaged, err := collectSection(ctx, root, opts)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff failed: %v", err)
+		return
+	}
+	receipt.Unstaged = unstaged
+
+	untracked, omitted, err := collectUntracked(ctx, root, opts, nil)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git ls-files failed: %v", err)
+		return
+	}
+	receipt.Untracked = untracked
+	receipt.UntrackedOmitted = omitted
+}
+
+// collectBaselineSections splits the run's changes (BaselineTree to ResultTree) by
+// where they are after the run: staged holds those the run added to the index,
+// unstaged the rest, and untracked the files the run created or changed that Git does
+// not track. Files that were dirty before the run and left alone appear in none.
+func collectBaselineSections(ctx context.Context, receipt *ChangeReceipt, b *Baseline, opts CollectOptions) {
+	mid, err := stagedTree(ctx, b, receipt.ChangedFiles, opts.Timeout)
+	if err != nil {
+		receipt.ReceiptError = err.Error()
+		return
+	}
+	staged, err := collectSection(ctx, b.GitRoot, opts, b.Tree, mid)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff failed: %v", err)
+		return
+	}
+	receipt.Staged = staged
+
+	unstaged, err := collectSection(ctx, b.GitRoot, opts, mid, receipt.ResultTree)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git diff failed: %v", err)
+		return
+	}
+	receipt.Unstaged = unstaged
+
+	changed := make(map[string]bool, len(receipt.ChangedFiles))
+	for _, f := range receipt.ChangedFiles {
+		if f.Change != "D" {
+			changed[f.Path] = true
+		}
+	}
+	untracked, omitted, err := collectUntracked(ctx, b.GitRoot, opts, changed)
+	if err != nil {
+		receipt.ReceiptError = fmt.Sprintf("git ls-files failed: %v", err)
+		return
+	}
+	receipt.Untracked = untracked
+	receipt.UntrackedOmitted = omitted
+}
+
+// stagedTree returns the baseline tree with the run's staged changes applied: changed
+// files whose index entry moved during the run take it from the current index. It is
+// written through a temporary index, like writeWorktreeTree.
+func stagedTree(ctx context.Context, b *Baseline, changed []FileChange, timeout time.Duration) (string, error) {
+	if b.Index == "" || len(changed) == 0 {
+		return b.Tree, nil
+	}
+	index, err := writeIndexTree(ctx, b.GitRoot, false, timeout)
+	if err != nil {
+		return "", err
+	}
+	raw, err := runGit(ctx, b.GitRoot, timeout, "diff", "-z", "--raw", "--no-abbrev", "--no-renames", b.Index, index)
+	if err != nil {
+		return "", fmt.Errorf("git diff --raw failed: %w", err)
+	}
+	paths := make(map[string]bool, len(changed))
+	for _, f := range changed {
+		paths[f.Path] = true
+	}
+	// Each entry is ":<old mode> <new mode> <old id> <new id> <status>\x00<path>\x00".
+	var info strings.Builder
+	fields := strings.Split(raw, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		meta, path := strings.Fields(strings.TrimPrefix(fields[i], ":")), fields[i+1]
+		if len(meta) != 5 || !paths[path] {
+			continue
+		}
+		fmt.Fprintf(&info, "%s %s\t%s\x00", meta[1], meta[3], path)
+	}
+	if info.Len() == 0 {
+		return b.Tree, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "codex-mcp-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	if _, err := runGitEnv(ctx, b.GitRoot, timeout, env, "read-tree", b.Tree); err != nil {
+		return "", fmt.Errorf("git read-tree failed: %w", err)
+	}
+	if _, err := runGitInput(ctx, b.GitRoot, timeout, env, info.String(), "update-index", "-z", "--index-info"); err != nil {
+		return "", fmt.Errorf("git update-index failed: %w", err)
+	}
+	tree, err := runGitEnv(ctx, b.GitRoot, timeout, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("git write-tree failed: %w", err)
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+func collectSection(ctx context.Context, root string, opts CollectOptions, args ...string) (*DiffSection, error) {
+	numstat, err := runGit(ctx, root, opts.Timeout, append([]string{"diff", "--no-renames", "--numstat"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	section := &DiffSection{Files: parseNumStat(numstat), NumStat: numstat}
+	if opts.ReturnDiff {
+		diff, truncated, err := runGitTruncated(ctx, root, opts.Timeout, opts.MaxDiffBytes, append([]string{"diff", "--no-renames"}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		section.Diff = diff
+		section.DiffTruncated = truncated
+	}
+	return section, nil
+}
+
+// parseNumStat parses `git diff --numstat` output ("<added>\t<removed>\t<path>", with
+// "-" counts for binary files).
+func parseNumStat(out string) []FileStat {
+	lines := strings.Split(out, "\n")
+	stats := make([]FileStat, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 3)
+		if len(fields) != 3 || fields[2] == "" {
+			continue
+		}
+		s := FileStat{Path: unquotePath(fields[2])}
+		if fields[0] == "-" && fields[1] == "-" {
+			s.Binary = true
+		} else {
+			added, _ := strconv.Atoi(fields[0])
+			removed, _ := strconv.Atoi(fields[1])
+			s.Added, s.Removed = &added, &removed
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// applyNumStat copies line counts from stats onto the matching files.
+func applyNumStat(files []FileChange, stats []FileStat) {
+	byPath := make(map[string]FileStat, len(stats))
+	for _, s := range stats {
+		byPath[s.Path] = s
+	}
+	for i := range files {
+		s, ok := byPath[files[i].Path]
+		if !ok {
+			continue
+		}
+		files[i].Binary = s.Binary
+		files[i].Added, files[i].Removed = s.Added, s.Removed
+	}
+}
+
+// collectUntracked lists the untracked files of the repository at root (only those in
+// only, when it is not nil), reading the contents of text files when diffs are
+// requested. At most maxUntrackedFiles are listed; omitted is the number left out.
+func collectUntracked(ctx context.Context, root string, opts CollectOptions, only map[string]bool) (files []UntrackedFile, omitted int, err error) {
+	out, err := runGit(ctx, root, opts.Timeout, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, 0, err
+	}
+	budget := opts.MaxUntrackedBytes
+	if budget <= 0 {
+		budget = defaultMaxUntrackedBytes
+	}
+	for _, path := range strings.Split(out, "\x00") {
+		if path == "" || (only != nil && !only[path]) {
+			continue
+		}
+		if len(files) == maxUntrackedFiles {
+			omitted++
+			continue
+		}
+		f := UntrackedFile{Path: path}
+		readUntracked(&f, filepath.Join(root, filepath.FromSlash(path)), opts.ReturnDiff, &budget)
+		files = append(files, f)
+	}
+	return files, omitted, nil
+}
+
+// readUntracked fills in the size and binary flag of f, and its content (up to the
+// remaining budget) when withContent is set. Files that cannot be read, and anything
+// but regular files, are listed without content.
+func readUntracked(f *UntrackedFile, path string, withContent bool, budget *int) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	f.Size = info.Size()
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	limit := int64(binarySniffBytes)
+	if withContent && int64(*budget) > limit {
+		limit = int64(*budget)
+	}
+	head, err := io.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return
+	}
+	sniff := head
+	if len(sniff) > binarySniffBytes {
+		sniff = sniff[:binarySniffBytes]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		f.Binary = true
+		return
+	}
+	if !withContent {
+		return
+	}
+	if len(head) > *budget {
+		head = head[:*budget]
+	}
+	f.Content = string(head)
+	f.Truncated = int64(len(head)) < f.Size
+	*budget -= len(head)
+}
@@ -0,0 +1,53 @@
+package receipt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestCollect_SectionsWithNonASCIIPaths(t *testing.T) {
+	repo := initRepo(t)
+	b, ok, err := Snapshot(context.Background(), repo, SnapshotOptions{})
+	if err != nil || !ok {
+		t.Fatalf("Snapshot() = %v, %v", ok, err)
+	}
+
+	writeFile(t, repo, "café.txt", "new\n")
+	writeFile(t, repo, "naïve.txt", "staged\n")
+	git(t, repo, "add", "naïve.txt")
+
+	r := Collect(context.Background(), repo, CollectOptions{Baseline: &b})
+	if r.ReceiptError != "" {
+		t.Fatalf("ReceiptError=%q", r.ReceiptError)
+	}
+	paths := map[string]bool{}
+	for _, f := range r.ChangedFiles {
+		paths[f.Path] = true
+	}
+	if !paths["café.txt"] || !paths["naïve.txt"] {
+		t.Fatalf("ChangedFiles=%+v, want unquoted paths", r.ChangedFiles)
+	}
+	if len(r.Untracked) != 1 || r.Untracked[0].Path != "café.txt" {
+		t.Fatalf("Untracked=%+v, want café.txt", r.Untracked)
+	}
+	if r.Staged == nil || len(r.Staged.Files) != 1 || r.Staged.Files[0].Path != "naïve.txt" {
+		t.Fatalf("Staged=%+v, want naïve.txt", r.Staged)
+	}
+}
+
+func TestParseNumStat_BinaryHasNoLineCounts(t *testing.T) {
+	stats := parseNumStat("3\t1\tmain.go\n-\t-\tlogo.png\n")
+	if len(stats) != 2 {
+		t.Fatalf("stats=%+v", stats)
+	}
+	if s := stats[0]; s.Added == nil || *s.Added != 3 || s.Removed == nil || *s.Removed != 1 || s.Binary {
+		t.Fatalf("text stat=%+v", s)
+	}
+	if s := stats[1]; s.Added != nil || s.Removed != nil || !s.Binary {
+		t.Fatalf("binary stat=%+v, want no line counts", s)
+	}
+	if b, _ := json.Marshal(stats[1]); string(b) != `{"path":"logo.png","binary":true}` {
+		t.Fatalf("binary stat JSON=%s", b)
+	}
+}
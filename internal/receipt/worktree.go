@@ -0,0 +1,99 @@
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WorktreeBranchPrefix prefixes the branches of isolated worktrees.
+const WorktreeBranchPrefix = "codex-mcp/"
+
+// ErrNoCommits is returned by AddWorktree for a repository without commits to branch
+// from.
+var ErrNoCommits = errors.New("repository has no commits")
+
+// Worktree is a linked Git worktree a run was isolated in.
+type Worktree struct {
+	// Repo is the root of the main work tree the worktree was added to.
+	Repo       string `json:"repo"`
+	Path       string `json:"path"`
+	Branch     string `json:"branch"`
+	BaseCommit string `json:"base_commit"`
+	// Removed reports whether the worktree was cleaned up after the run (BranchDeleted
+	// whether its branch went with it); KeptReason explains why a requested cleanup did
+	// not remove it.
+	Removed       bool   `json:"removed,omitempty"`
+	BranchDeleted bool   `json:"branch_deleted,omitempty"`
+	KeptReason    string `json:"kept_reason,omitempty"`
+}
+
+// DefaultWorktreeDir returns where isolated worktrees of the repository at gitRoot are
+// created by default: codex-mcp/worktrees in its common Git directory, which keeps them
+// out of the user's tree and is shared by all of its worktrees.
+func DefaultWorktreeDir(ctx context.Context, gitRoot string, timeout time.Duration) (string, error) {
+	dir, err := runGit(ctx, gitRoot, timeout, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	if dir = strings.TrimSpace(dir); !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitRoot, dir)
+	}
+	return filepath.Join(dir, "codex-mcp", "worktrees"), nil
+}
+
+// AddWorktree creates a worktree at path on a new branch off HEAD of the repository at
+// gitRoot. The main work tree, its index and its current branch are left alone.
+func AddWorktree(ctx context.Context, gitRoot string, path string, branch string, timeout time.Duration) (Worktree, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	head, err := runGit(ctx, gitRoot, timeout, "rev-parse", "--verify", "-q", "HEAD^{commit}")
+	if err != nil || strings.TrimSpace(head) == "" {
+		return Worktree{}, ErrNoCommits
+	}
+	head = strings.TrimSpace(head)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Worktree{}, err
+	}
+	if _, err := runGit(ctx, gitRoot, timeout, "worktree", "add", "-b", branch, path, head); err != nil {
+		return Worktree{}, fmt.Errorf("git worktree add failed: %w", err)
+	}
+	return Worktree{Repo: gitRoot, Path: path, Branch: branch, BaseCommit: head}, nil
+}
+
+// RemoveWorktree removes w unless it has uncommitted changes (recorded in
+// w.KeptReason), so no work is lost. Its branch is deleted too when nothing was
+// committed on it.
+func RemoveWorktree(ctx context.Context, w *Worktree, timeout time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	status, err := runGit(ctx, w.Path, timeout, "status", "--porcelain=v1")
+	if err != nil {
+		return fmt.Errorf("git status failed: %w", err)
+	}
+	if strings.TrimSpace(status) != "" {
+		w.KeptReason = "worktree has uncommitted changes"
+		return nil
+	}
+	tip, err := runGit(ctx, w.Path, timeout, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	if _, err := runGit(ctx, w.Repo, timeout, "worktree", "remove", w.Path); err != nil {
+		return fmt.Errorf("git worktree remove failed: %w", err)
+	}
+	w.Removed = true
+	if strings.TrimSpace(tip) == w.BaseCommit {
+		if _, err := runGit(ctx, w.Repo, timeout, "branch", "-D", w.Branch); err != nil {
+			return fmt.Errorf("git branch -D failed: %w", err)
+		}
+		w.BranchDeleted = true
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+// Package replay records codex CLI runs to disk and plays them back as a fake codex binary.
+//
+// A recording captures the argv, a safe subset of the environment, the raw output lines
+// with their offsets from process start, and the exit code. Playing a recording writes the
+// same lines with the original timing (scaled by a factor), so a reported run can be
+// reproduced deterministically against the server.
+//
+// Recordings are JSON Lines, written as the run goes: a Header, one Line per output line,
+// and a trailer with the exit code and duration.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FormatVersion is the version of the recording file format.
+const FormatVersion = 2
+
+// Output streams.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Environment variables understood by RunFake.
+const (
+	// EnvFile points at the recording to play back.
+	EnvFile = "CODEX_MCP_REPLAY_FILE"
+	// EnvScale scales the recorded delays (1 = original timing, 0 = no delays).
+	EnvScale = "CODEX_MCP_REPLAY_SCALE"
+)
+
+// envAllowlist lists the environment variables captured in recordings.
+// Secrets (API keys, tokens) must never be recorded.
+var envAllowlist = []string{
+	"CODEX_HOME",
+	"RUST_LOG",
+	"LANG",
+	"LC_ALL",
+	"TERM",
+	"TZ",
+}
+
+// Line is a single output line.
+type Line struct {
+	// OffsetMs is the time since process start, in milliseconds.
+	OffsetMs int64  `json:"offset_ms"`
+	Stream   string `json:"stream"`
+	Text     string `json:"text"`
+}
+
+// Header is the part of a recording known when the run starts.
+type Header struct {
+	FormatVersion int               `json:"format_version"`
+	CodexVersion  string            `json:"codex_version,omitempty"`
+	Argv          []string          `json:"argv"`
+	Env           map[string]string `json:"env,omitempty"`
+	WorkingDir    string            `json:"working_dir,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+}
+
+// Recording is a captured codex run.
+type Recording struct {
+	Header
+	DurationMs int64  `json:"duration_ms"`
+	ExitCode   int    `json:"exit_code"`
+	Lines      []Line `json:"lines"`
+}
+
+// trailer is the last entry of a recording file.
+type trailer struct {
+	DurationMs int64 `json:"duration_ms"`
+	ExitCode   int   `json:"exit_code"`
+}
+
+// entry is any entry after the header: a Line, or the trailer (no stream).
+type entry struct {
+	Line
+	DurationMs int64 `json:"duration_ms"`
+	ExitCode   int   `json:"exit_code"`
+}
+
+// Load reads a recording from path.
+func Load(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var rec Recording
+	if err := dec.Decode(&rec.Header); err != nil {
+		return nil, fmt.Errorf("parse recording %s: %w", path, err)
+	}
+	if rec.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("recording %s has unsupported format_version %d", path, rec.FormatVersion)
+	}
+	rec.Lines = make([]Line, 0)
+	for {
+		var e entry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("parse recording %s: %w", path, err)
+		}
+		if e.Stream == "" {
+			rec.DurationMs = e.DurationMs
+			rec.ExitCode = e.ExitCode
+			continue
+		}
+		rec.Lines = append(rec.Lines, e.Line)
+	}
+	return &rec, nil
+}
+
+var recordSeq atomic.Uint64
+
+// Recorder captures a single run, streaming it to a temporary file that Finish moves
+// into place. It is safe for concurrent use.
+type Recorder struct {
+	dir   string
+	start time.Time
+
+	mu   sync.Mutex
+	tmp  *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	err  error
+	done bool
+}
+
+// Start begins recording a run whose file will be written under dir.
+// argv[0] is the executable path.
+func Start(dir string, argv []string, workingDir string, codexVersion string) *Recorder {
+	now := time.Now()
+	r := &Recorder{dir: dir, start: now}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		r.err = err
+		return r
+	}
+	tmp, err := os.CreateTemp(dir, ".codex-recording-*.tmp")
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		r.fail(err)
+		return r
+	}
+	r.tmp = tmp
+	r.w = bufio.NewWriter(tmp)
+	r.enc = json.NewEncoder(r.w)
+	r.write(Header{
+		FormatVersion: FormatVersion,
+		CodexVersion:  codexVersion,
+		Argv:          append([]string(nil), argv...),
+		Env:           captureEnv(),
+		WorkingDir:    workingDir,
+		StartedAt:     now.UTC(),
+	})
+	return r
+}
+
+// Line records an output line on the given stream.
+func (r *Recorder) Line(stream string, text []byte) {
+	if r == nil {
+		return
+	}
+	offset := time.Since(r.start).Milliseconds()
+	r.mu.Lock()
+	r.write(Line{OffsetMs: offset, Stream: stream, Text: string(text)})
+	r.mu.Unlock()
+}
+
+// write appends v to the file; after the first error the recording is abandoned.
+func (r *Recorder) write(v any) {
+	if r.err != nil || r.done {
+		return
+	}
+	if err := r.enc.Encode(v); err != nil {
+		r.fail(err)
+	}
+}
+
+func (r *Recorder) fail(err error) {
+	r.err = err
+	if r.tmp != nil {
+		_ = r.tmp.Close()
+		_ = os.Remove(r.tmp.Name())
+	}
+}
+
+// Finish writes the trailer, moves the recording into place and returns its path.
+func (r *Recorder) Finish(exitCode int) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(trailer{DurationMs: time.Since(r.start).Milliseconds(), ExitCode: exitCode})
+	r.done = true
+	if r.err != nil {
+		return "", r.err
+	}
+	if err := r.w.Flush(); err != nil {
+		r.fail(err)
+		return "", err
+	}
+	if err := r.tmp.Close(); err != nil {
+		r.fail(err)
+		return "", err
+	}
+	name := fmt.Sprintf("codex-%s-%d-%d.jsonl", r.start.UTC().Format("20060102T150405.000Z"), os.Getpid(), recordSeq.Add(1))
+	path := filepath.Join(r.dir, name)
+	if err := os.Rename(r.tmp.Name(), path); err != nil {
+		_ = os.Remove(r.tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+func captureEnv() map[string]string {
+	env := make(map[string]string)
+	for _, key := range envAllowlist {
+		if v, ok := os.LookupEnv(key); ok {
+			env[key] = v
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return env
+}
+
+// Play writes the recorded lines to stdout/stderr, sleeping between lines to reproduce
+// the original timing multiplied by scale (scale <= 0 disables delays).
+// It returns the recorded exit code.
+func Play(ctx context.Context, rec *Recording, stdout io.Writer, stderr io.Writer, scale float64) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	for _, line := range rec.Lines {
+		if scale > 0 {
+			due := start.Add(time.Duration(float64(line.OffsetMs)*scale) * time.Millisecond)
+			if wait := time.Until(due); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return 1, ctx.Err()
+				}
+			}
+		}
+		w := stdout
+		if line.Stream == StreamStderr {
+			w = stderr
+		}
+		if _, err := io.WriteString(w, line.Text+"\n"); err != nil {
+			return 1, err
+		}
+	}
+	return rec.ExitCode, nil
+}
+
+// RunFake behaves like the codex CLI using the recording named by path:
+// `--version` prints the recorded version and `exec ...` plays the recording.
+// `exec --help` isn't recorded and fails, which leaves every feature assumed.
+// It returns the process exit code.
+func RunFake(ctx context.Context, path string, args []string, stdout io.Writer, stderr io.Writer, scale float64) int {
+	rec, err := Load(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "replay: %v\n", err)
+		return 1
+	}
+	if len(args) > 0 && args[0] == "--version" {
+		version := rec.CodexVersion
+		if version == "" {
+			version = "unknown"
+		}
+		fmt.Fprintf(stdout, "codex-cli %s\n", version)
+		return 0
+	}
+	if len(args) > 1 && args[0] == "exec" && args[1] == "--help" {
+		fmt.Fprintln(stderr, "replay: exec --help is not recorded")
+		return 2
+	}
+	code, err := Play(ctx, rec, stdout, stderr, scale)
+	if err != nil {
+		fmt.Fprintf(stderr, "replay: %v\n", err)
+	}
+	return code
+}
+
+// ParseScale parses a time scale factor. Empty input means 1 (original timing).
+func ParseScale(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 1, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid replay scale %q", s)
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("replay scale must be >= 0, got %q", s)
+	}
+	return f, nil
+}
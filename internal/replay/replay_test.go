@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	t.Setenv("CODEX_HOME", "/tmp/codex-home")
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	dir := t.TempDir()
+
+	r := Start(dir, []string{"codex", "exec", "--json", "hi"}, "/work", "0.46.0")
+	r.Line(StreamStdout, []byte(`{"thread_id":"t-1"}`))
+	r.Line(StreamStderr, []byte("warning: something"))
+	path, err := r.Finish(3)
+	if err != nil {
+		t.Fatalf("Finish() failed: %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Fatalf("recording path=%q, want under %q", path, dir)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("recording stat=%v err=%v, want mode 0600", info, err)
+	}
+
+	rec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if rec.ExitCode != 3 || rec.CodexVersion != "0.46.0" || rec.WorkingDir != "/work" {
+		t.Fatalf("recording=%+v, unexpected metadata", rec)
+	}
+	if len(rec.Lines) != 2 || rec.Lines[1].Stream != StreamStderr {
+		t.Fatalf("Lines=%+v, want stdout+stderr", rec.Lines)
+	}
+	if rec.Env["CODEX_HOME"] != "/tmp/codex-home" {
+		t.Fatalf("Env[CODEX_HOME]=%q, want recorded", rec.Env["CODEX_HOME"])
+	}
+	if _, ok := rec.Env["OPENAI_API_KEY"]; ok {
+		t.Fatalf("Env contains OPENAI_API_KEY; secrets must not be recorded")
+	}
+}
+
+func TestRecorder_StreamsLines(t *testing.T) {
+	dir := t.TempDir()
+	r := Start(dir, []string{"codex", "exec"}, "", "")
+	r.Line(StreamStdout, []byte("first"))
+	r.mu.Lock()
+	_ = r.w.Flush()
+	r.mu.Unlock()
+
+	// Lines are on disk before the run finishes, not buffered until Finish.
+	tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmps) != 1 {
+		t.Fatalf("temporary recordings=%v, want 1", tmps)
+	}
+	if data, err := os.ReadFile(tmps[0]); err != nil || !strings.Contains(string(data), `"text":"first"`) {
+		t.Fatalf("temporary recording=%q, %v", data, err)
+	}
+
+	path, err := r.Finish(0)
+	if err != nil {
+		t.Fatalf("Finish() failed: %v", err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Fatalf("temporary recordings left behind: %v", tmps)
+	}
+	if rec, err := Load(path); err != nil || len(rec.Lines) != 1 || rec.FormatVersion != FormatVersion {
+		t.Fatalf("Load()=%+v, %v", rec, err)
+	}
+}
+
+func TestPlay_ScalesTiming(t *testing.T) {
+	rec := &Recording{
+		ExitCode: 2,
+		Lines: []Line{
+			{OffsetMs: 0, Stream: StreamStdout, Text: "a"},
+			{OffsetMs: 200, Stream: StreamStderr, Text: "b"},
+			{OffsetMs: 400, Stream: StreamStdout, Text: "c"},
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	code, err := Play(context.Background(), rec, &stdout, &stderr, 0.5)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if code != 2 {
+		t.Fatalf("exit code=%d, want 2", code)
+	}
+	if stdout.String() != "a\nc\n" || stderr.String() != "b\n" {
+		t.Fatalf("stdout=%q stderr=%q", stdout.String(), stderr.String())
+	}
+	if elapsed < 180*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("elapsed=%v, want ~200ms at scale 0.5", elapsed)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	start = time.Now()
+	if _, err := Play(context.Background(), rec, &stdout, &stderr, 0); err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("elapsed=%v with scale 0, want no delays", elapsed)
+	}
+}
+
+func TestRunFake_Version(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Start(dir, []string{"codex", "exec"}, "", "0.44.1").Finish(0)
+	if err != nil {
+		t.Fatalf("Finish() failed: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := RunFake(context.Background(), path, []string{"--version"}, &stdout, &stderr, 0); code != 0 {
+		t.Fatalf("RunFake(--version) code=%d stderr=%q", code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "codex-cli 0.44.1" {
+		t.Fatalf("stdout=%q, want %q", stdout.String(), "codex-cli 0.44.1")
+	}
+}
+
+func TestParseScale(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "", want: 1},
+		{in: "0", want: 0},
+		{in: "0.25", want: 0.25},
+		{in: "-1", wantErr: true},
+		{in: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScale(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseScale(%q) err=%v, wantErr=%v", tt.in, err, tt.wantErr)
+		}
+		if err == nil && got != tt.want {
+			t.Fatalf("ParseScale(%q)=%v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
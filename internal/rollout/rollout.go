@@ -0,0 +1,218 @@
+// Package rollout locates and copies Codex CLI rollout files.
+//
+// Codex stores every thread as a JSONL "rollout" under its sessions directory
+// ($CODEX_HOME/sessions, default ~/.codex/sessions), in date folders and named
+// rollout-<timestamp>-<thread id>.jsonl. `codex exec resume <id>` finds a thread by
+// that file name, so copying a rollout under a new ID creates an independent thread
+// that starts from the same conversation state.
+package rollout
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no rollout file exists for a thread ID.
+var ErrNotFound = errors.New("rollout not found")
+
+// fileTimeLayout is the timestamp format Codex uses in rollout file names.
+const fileTimeLayout = "2006-01-02T15-04-05"
+
+var threadIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// DefaultDir returns the Codex sessions directory ($CODEX_HOME/sessions, falling back
+// to ~/.codex/sessions). It returns "" when the home directory is unknown.
+func DefaultDir() string {
+	if home := strings.TrimSpace(os.Getenv("CODEX_HOME")); home != "" {
+		return filepath.Join(home, "sessions")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".codex", "sessions")
+}
+
+// IsThreadID reports whether id looks like a Codex thread ID (a UUID).
+func IsThreadID(id string) bool {
+	return threadIDPattern.MatchString(id)
+}
+
+// NewThreadID returns a random (version 4) UUID.
+func NewThreadID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// Find returns the path of the rollout file for threadID under dir.
+func Find(dir string, threadID string) (string, error) {
+	if !IsThreadID(threadID) {
+		return "", fmt.Errorf("invalid thread id %q", threadID)
+	}
+	suffix := "-" + strings.ToLower(threadID) + ".jsonl"
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable subdirectories
+		}
+		name := strings.ToLower(d.Name())
+		if !d.IsDir() && strings.HasPrefix(name, "rollout-") && strings.HasSuffix(name, suffix) {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	if found == "" {
+		return "", ErrNotFound
+	}
+	return found, nil
+}
+
+// Fork is the result of copying a rollout.
+type Fork struct {
+	ThreadID   string
+	Path       string
+	SourcePath string
+}
+
+// Copy forks the thread sourceID into a new thread: the rollout is copied into today's
+// date folder under a new ID, and the session metadata is rewritten to that ID.
+func Copy(dir string, sourceID string, now time.Time) (Fork, error) {
+	src, err := Find(dir, sourceID)
+	if err != nil {
+		return Fork{}, err
+	}
+	newID, err := NewThreadID()
+	if err != nil {
+		return Fork{}, fmt.Errorf("generate thread id: %w", err)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return Fork{}, err
+	}
+	defer in.Close()
+
+	now = now.UTC()
+	destDir := filepath.Join(dir, now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return Fork{}, err
+	}
+	dest := filepath.Join(destDir, "rollout-"+now.Format(fileTimeLayout)+"-"+newID+".jsonl")
+	tmp, err := os.CreateTemp(destDir, ".fork-*.jsonl")
+	if err != nil {
+		return Fork{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := copyRewriting(tmp, in, sourceID, newID); err != nil {
+		tmp.Close()
+		return Fork{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Fork{}, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return Fork{}, err
+	}
+	return Fork{ThreadID: newID, Path: dest, SourcePath: src}, nil
+}
+
+// copyRewriting copies a rollout line by line, replacing the thread ID in session
+// metadata lines. Other lines (messages, tool calls) are copied verbatim.
+func copyRewriting(w io.Writer, r io.Reader, oldID, newID string) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	rewritten := false
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if out, ok := rewriteMeta(line, oldID, newID); ok {
+				line = out
+				rewritten = true
+			}
+			if _, werr := bw.Write(line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !rewritten {
+		return fmt.Errorf("rollout has no session metadata for thread %s", oldID)
+	}
+	return bw.Flush()
+}
+
+// rewriteMeta replaces the thread ID in a session metadata line. Current Codex versions
+// write {"type":"session_meta","payload":{"id":...}}; older ones put the id at the top
+// level of the first line.
+func rewriteMeta(line []byte, oldID, newID string) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(line)
+	if !bytes.Contains(trimmed, []byte(oldID)) {
+		return nil, false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &obj); err != nil {
+		return nil, false
+	}
+
+	target, nested := obj, false
+	if typ, ok := obj["type"]; ok {
+		var t string
+		if json.Unmarshal(typ, &t) != nil || t != "session_meta" {
+			return nil, false
+		}
+		var payload map[string]json.RawMessage
+		if err := json.Unmarshal(obj["payload"], &payload); err != nil {
+			return nil, false
+		}
+		target, nested = payload, true
+	}
+	var id string
+	if json.Unmarshal(target["id"], &id) != nil || !strings.EqualFold(id, oldID) {
+		return nil, false
+	}
+	target["id"], _ = json.Marshal(newID)
+	if nested {
+		payload, err := json.Marshal(target)
+		if err != nil {
+			return nil, false
+		}
+		obj["payload"] = payload
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false
+	}
+	return append(out, '\n'), true
+}
@@ -0,0 +1,99 @@
+package rollout
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sourceID = "0199a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b"
+
+func writeRollout(t *testing.T, dir string, lines ...string) string {
+	t.Helper()
+	day := filepath.Join(dir, "2025", "01", "02")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(day, "rollout-2025-01-02T10-00-00-"+sourceID+".jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCopy_RewritesSessionMeta(t *testing.T) {
+	dir := t.TempDir()
+	msg := `{"timestamp":"2025-01-02T10:00:01Z","type":"response_item","payload":{"type":"message","content":"mentions ` + sourceID + `"}}`
+	src := writeRollout(t, dir,
+		`{"timestamp":"2025-01-02T10:00:00Z","type":"session_meta","payload":{"id":"`+sourceID+`","cwd":"/repo","cli_version":"0.46.0"}}`,
+		msg,
+	)
+
+	now := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	fork, err := Copy(dir, sourceID, now)
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	if fork.SourcePath != src || !IsThreadID(fork.ThreadID) || fork.ThreadID == sourceID {
+		t.Fatalf("fork=%+v", fork)
+	}
+	want := filepath.Join(dir, "2025", "03", "04", "rollout-2025-03-04T05-06-07-"+fork.ThreadID+".jsonl")
+	if fork.Path != want {
+		t.Fatalf("Path=%q, want %q", fork.Path, want)
+	}
+	if found, err := Find(dir, fork.ThreadID); err != nil || found != fork.Path {
+		t.Fatalf("Find(fork)=%q, %v", found, err)
+	}
+
+	data, err := os.ReadFile(fork.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var meta struct {
+		Type    string         `json:"type"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Type != "session_meta" || meta.Payload["id"] != fork.ThreadID || meta.Payload["cwd"] != "/repo" {
+		t.Fatalf("meta=%+v", meta)
+	}
+	if lines[1] != msg {
+		t.Fatalf("non-metadata line changed: %s", lines[1])
+	}
+}
+
+func TestCopy_LegacyFormat(t *testing.T) {
+	dir := t.TempDir()
+	writeRollout(t, dir, `{"id":"`+sourceID+`","timestamp":"2025-01-02T10:00:00Z"}`, `{"type":"message"}`)
+
+	fork, err := Copy(dir, sourceID, time.Now())
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	data, _ := os.ReadFile(fork.Path)
+	if !strings.Contains(string(data), `"id":"`+fork.ThreadID+`"`) {
+		t.Fatalf("legacy metadata not rewritten: %s", data)
+	}
+}
+
+func TestFind_NotFound(t *testing.T) {
+	if _, err := Find(t.TempDir(), sourceID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err=%v, want ErrNotFound", err)
+	}
+	if _, err := Find(filepath.Join(t.TempDir(), "missing"), sourceID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing dir: err=%v, want ErrNotFound", err)
+	}
+	if _, err := Find(t.TempDir(), "../etc"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected invalid thread id error, got %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package rollout
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// headerMaxBytes and headerMaxLines bound how much of a rollout ReadThread parses;
+	// the metadata it needs is at the start of the file.
+	headerMaxBytes = 4 << 20
+	headerMaxLines = 64
+	// PreviewRunes is the length of Thread.FirstUserMessage.
+	PreviewRunes = 200
+)
+
+// Thread describes a Codex thread from the head of its rollout file.
+type Thread struct {
+	ID        string
+	Path      string
+	CreatedAt time.Time
+	// Cwd is the working directory the thread was started in.
+	Cwd string
+	// FirstUserMessage is a preview of the first prompt (see PreviewRunes).
+	FirstUserMessage string
+	// Model and Sandbox are from the first turn context (empty when not recorded).
+	Model   string
+	Sandbox string
+	// CLIVersion and Originator identify the Codex client that wrote the rollout
+	// (e.g. "codex_cli_rs" for the TUI, "codex_exec" for `codex exec`).
+	CLIVersion string
+	Originator string
+}
+
+// Files returns the rollout files under dir, newest first (by the timestamp in their
+// name). A missing dir has no files.
+func Files(dir string) ([]string, error) {
+	type file struct {
+		path  string
+		stamp string
+	}
+	var files []file
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable subdirectories
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, stamp, ok := parseFileName(d.Name()); ok {
+			files = append(files, file{path: path, stamp: stamp})
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].stamp != files[j].stamp {
+			return files[i].stamp > files[j].stamp
+		}
+		return files[i].path > files[j].path
+	})
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+// ListOptions selects a page of threads for List.
+type ListOptions struct {
+	// Cwd keeps threads started in this directory or below it.
+	Cwd    string
+	Offset int
+	Limit  int
+}
+
+// List returns a page of the threads under dir, newest first, and the number of
+// threads matching opts. Rollouts that cannot be read are skipped.
+func List(dir string, opts ListOptions) ([]Thread, int, error) {
+	files, err := Files(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	cwd := strings.TrimSpace(opts.Cwd)
+	if cwd == "" {
+		// Only the page needs parsing.
+		total := len(files)
+		files = page(files, opts.Offset, opts.Limit)
+		threads := make([]Thread, 0, len(files))
+		for _, path := range files {
+			if t, err := ReadThread(path); err == nil {
+				threads = append(threads, t)
+			}
+		}
+		return threads, total, nil
+	}
+
+	cwd = filepath.Clean(cwd)
+	var matched []Thread
+	for _, path := range files {
+		t, err := ReadThread(path)
+		if err != nil || t.Cwd == "" || !withinDir(filepath.Clean(t.Cwd), cwd) {
+			continue
+		}
+		matched = append(matched, t)
+	}
+	return page(matched, opts.Offset, opts.Limit), len(matched), nil
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func withinDir(path string, dir string) bool {
+	if path == dir {
+		return true
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseFileName splits "rollout-<timestamp>-<thread id>.jsonl".
+func parseFileName(name string) (id, stamp string, ok bool) {
+	rest, found := strings.CutPrefix(name, "rollout-")
+	if !found {
+		return "", "", false
+	}
+	rest, found = strings.CutSuffix(rest, ".jsonl")
+	if !found || len(rest) < len(fileTimeLayout)+1+36 {
+		return "", "", false
+	}
+	id = rest[len(rest)-36:]
+	stamp = rest[:len(rest)-37]
+	if rest[len(rest)-37] != '-' || !IsThreadID(id) {
+		return "", "", false
+	}
+	return strings.ToLower(id), stamp, true
+}
+
+// rolloutLine covers both layouts: current Codex versions wrap every record as
+// {"type":...,"payload":{...}}; older ones write the metadata and items directly.
+type rolloutLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+
+	// Legacy metadata (first line) and items.
+	ID      string           `json:"id"`
+	Role    string           `json:"role"`
+	Content []messageContent `json:"content"`
+}
+
+type messageContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type sessionMeta struct {
+	ID         string `json:"id"`
+	Timestamp  string `json:"timestamp"`
+	Cwd        string `json:"cwd"`
+	CLIVersion string `json:"cli_version"`
+	Originator string `json:"originator"`
+}
+
+type turnContext struct {
+	Cwd           string          `json:"cwd"`
+	Model         string          `json:"model"`
+	SandboxPolicy json.RawMessage `json:"sandbox_policy"`
+}
+
+type payloadItem struct {
+	Type    string           `json:"type"`
+	Role    string           `json:"role"`
+	Content []messageContent `json:"content"`
+	Message string           `json:"message"`
+}
+
+// ReadThread parses the thread metadata from the head of the rollout at path.
+func ReadThread(path string) (Thread, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Thread{}, err
+	}
+	defer f.Close()
+
+	t := Thread{Path: path}
+	if id, stamp, ok := parseFileName(filepath.Base(path)); ok {
+		t.ID = id
+		if ts, err := time.ParseInLocation(fileTimeLayout, stamp, time.Local); err == nil {
+			t.CreatedAt = ts
+		}
+	}
+
+	br := bufio.NewReader(io.LimitReader(f, headerMaxBytes))
+	for n := 0; n < headerMaxLines; n++ {
+		if t.Cwd != "" && t.Model != "" && t.Sandbox != "" && t.FirstUserMessage != "" {
+			break
+		}
+		line, readErr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var l rolloutLine
+			if json.Unmarshal(line, &l) == nil {
+				t.apply(l, n == 0)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if t.ID == "" {
+		return Thread{}, errors.New("rollout has no thread id")
+	}
+	return t, nil
+}
+
+func (t *Thread) apply(l rolloutLine, first bool) {
+	switch l.Type {
+	case "session_meta":
+		var meta sessionMeta
+		if json.Unmarshal(l.Payload, &meta) != nil {
+			return
+		}
+		if IsThreadID(meta.ID) {
+			t.ID = strings.ToLower(meta.ID)
+		}
+		t.setCreated(meta.Timestamp)
+		if t.Cwd == "" {
+			t.Cwd = meta.Cwd
+		}
+		t.CLIVersion = meta.CLIVersion
+		t.Originator = meta.Originator
+	case "turn_context":
+		var tc turnContext
+		if json.Unmarshal(l.Payload, &tc) != nil {
+			return
+		}
+		if t.Cwd == "" {
+			t.Cwd = tc.Cwd
+		}
+		if t.Model == "" {
+			t.Model = tc.Model
+		}
+		if t.Sandbox == "" {
+			t.Sandbox = sandboxMode(tc.SandboxPolicy)
+		}
+	case "event_msg":
+		// The user's own text, without the environment context codex prepends.
+		var item payloadItem
+		if json.Unmarshal(l.Payload, &item) == nil && item.Type == "user_message" {
+			t.setFirstUserMessage(item.Message)
+		}
+	case "response_item":
+		var item payloadItem
+		if json.Unmarshal(l.Payload, &item) == nil && item.Type == "message" && item.Role == "user" {
+			t.setFirstUserMessage(userText(item.Content))
+		}
+	case "", "message":
+		if first && IsThreadID(l.ID) {
+			t.ID = strings.ToLower(l.ID)
+			t.setCreated(l.Timestamp)
+		} else if l.Role == "user" {
+			t.setFirstUserMessage(userText(l.Content))
+		}
+	}
+}
+
+func (t *Thread) setCreated(ts string) {
+	if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		t.CreatedAt = parsed
+	}
+}
+
+func (t *Thread) setFirstUserMessage(text string) {
+	if t.FirstUserMessage != "" {
+		return
+	}
+	t.FirstUserMessage = preview(text, PreviewRunes)
+}
+
+// userText joins the input text of a user message, skipping the context blocks codex
+// injects as user messages (e.g. <environment_context>, <user_instructions>).
+func userText(content []messageContent) string {
+	var parts []string
+	for _, c := range content {
+		text := strings.TrimSpace(c.Text)
+		if c.Type != "input_text" || text == "" || strings.HasPrefix(text, "<") {
+			continue
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// sandboxMode returns the mode of a sandbox policy ({"mode":"workspace-write",...} or
+// a bare string).
+func sandboxMode(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var mode string
+	if json.Unmarshal(raw, &mode) == nil {
+		return mode
+	}
+	var policy struct {
+		Mode string `json:"mode"`
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(raw, &policy) != nil {
+		return ""
+	}
+	if policy.Mode != "" {
+		return policy.Mode
+	}
+	return policy.Type
+}
+
+func preview(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max]) + "…"
+}
@@ -0,0 +1,107 @@
+package rollout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadThread_CurrentFormat(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRollout(t, dir,
+		`{"timestamp":"2025-01-02T10:00:00.5Z","type":"session_meta","payload":{"id":"`+sourceID+`","timestamp":"2025-01-02T10:00:00.5Z","cwd":"/repo","originator":"codex_cli_rs","cli_version":"0.46.0"}}`,
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>cwd</environment_context>"}]}}`,
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"turn_context","payload":{"cwd":"/repo","model":"gpt-5-codex","sandbox_policy":{"mode":"workspace-write","network_access":false}}}`,
+		`{"timestamp":"2025-01-02T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"  fix the `+strings.Repeat("x", PreviewRunes)+`"}}`,
+	)
+
+	th, err := ReadThread(path)
+	if err != nil {
+		t.Fatalf("ReadThread() failed: %v", err)
+	}
+	if th.ID != sourceID || th.Cwd != "/repo" || th.Model != "gpt-5-codex" || th.Sandbox != "workspace-write" {
+		t.Fatalf("thread=%+v", th)
+	}
+	if th.Originator != "codex_cli_rs" || th.CLIVersion != "0.46.0" {
+		t.Fatalf("client=%q %q", th.Originator, th.CLIVersion)
+	}
+	if want := time.Date(2025, 1, 2, 10, 0, 0, 5e8, time.UTC); !th.CreatedAt.Equal(want) {
+		t.Fatalf("CreatedAt=%v, want %v", th.CreatedAt, want)
+	}
+	if !strings.HasPrefix(th.FirstUserMessage, "fix the xxx") || !strings.HasSuffix(th.FirstUserMessage, "…") {
+		t.Fatalf("FirstUserMessage=%q, want a trimmed preview", th.FirstUserMessage)
+	}
+}
+
+func TestReadThread_LegacyFormat(t *testing.T) {
+	path := writeRollout(t, t.TempDir(),
+		`{"id":"`+sourceID+`","timestamp":"2025-01-02T10:00:00Z","instructions":null}`,
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"<user_instructions>be brief</user_instructions>"},{"type":"input_text","text":"hello"}]}`,
+	)
+
+	th, err := ReadThread(path)
+	if err != nil {
+		t.Fatalf("ReadThread() failed: %v", err)
+	}
+	if th.ID != sourceID || th.FirstUserMessage != "hello" || th.Cwd != "" {
+		t.Fatalf("thread=%+v", th)
+	}
+}
+
+func TestFiles_NewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	older := writeRollout(t, dir, `{}`)
+	newerDir := filepath.Join(dir, "2025", "02", "01")
+	if err := os.MkdirAll(newerDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	newer := filepath.Join(newerDir, "rollout-2025-02-01T08-00-00-0199a1b2-c3d4-7e5f-8a9b-000000000000.jsonl")
+	for _, p := range []string{newer, filepath.Join(newerDir, "notes.jsonl"), filepath.Join(newerDir, "rollout-bad.jsonl")} {
+		if err := os.WriteFile(p, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatalf("Files() failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != newer || files[1] != older {
+		t.Fatalf("Files()=%v, want [%s %s]", files, newer, older)
+	}
+	if files, err := Files(filepath.Join(dir, "missing")); err != nil || len(files) != 0 {
+		t.Fatalf("missing dir: %v, %v", files, err)
+	}
+}
+
+func TestList_FiltersByCwd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(day, id, cwd string) {
+		t.Helper()
+		d := filepath.Join(dir, "2025", "01", day)
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		line := `{"timestamp":"2025-01-` + day + `T10:00:00Z","type":"session_meta","payload":{"id":"` + id + `","cwd":"` + cwd + `"}}`
+		if err := os.WriteFile(filepath.Join(d, "rollout-2025-01-"+day+"T10-00-00-"+id+".jsonl"), []byte(line+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("01", "0199a1b2-c3d4-7e5f-8a9b-000000000001", "/repo")
+	write("02", "0199a1b2-c3d4-7e5f-8a9b-000000000002", "/other")
+	write("03", "0199a1b2-c3d4-7e5f-8a9b-000000000003", "/repo/sub")
+
+	threads, total, err := List(dir, ListOptions{Cwd: "/repo/", Limit: 1})
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if total != 2 || len(threads) != 1 || threads[0].Cwd != "/repo/sub" {
+		t.Fatalf("List()=%+v, total=%d", threads, total)
+	}
+	threads, total, _ = List(dir, ListOptions{Offset: 2, Limit: 5})
+	if total != 3 || len(threads) != 1 || threads[0].Cwd != "/repo" {
+		t.Fatalf("page 2=%+v, total=%d", threads, total)
+	}
+}
@@ -0,0 +1,160 @@
+package session
+
+import (
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// Budget limits what a thread may consume across all of its turns. Zero fields are
+// unlimited.
+type Budget struct {
+	MaxTokens      int64 `json:"max_tokens,omitempty"`
+	MaxExecutionMs int64 `json:"max_execution_ms,omitempty"`
+	MaxTurns       int   `json:"max_turns,omitempty"`
+}
+
+// IsZero reports whether no limit is set.
+func (b Budget) IsZero() bool {
+	return b == Budget{}
+}
+
+// Merge returns b with the non-zero limits of override applied.
+func (b Budget) Merge(override Budget) Budget {
+	if override.MaxTokens > 0 {
+		b.MaxTokens = override.MaxTokens
+	}
+	if override.MaxExecutionMs > 0 {
+		b.MaxExecutionMs = override.MaxExecutionMs
+	}
+	if override.MaxTurns > 0 {
+		b.MaxTurns = override.MaxTurns
+	}
+	return b
+}
+
+// Cap lowers b to the non-zero limits of caps; a capped limit that is unset in b
+// becomes the cap.
+func (b Budget) Cap(caps Budget) Budget {
+	if caps.MaxTokens > 0 && (b.MaxTokens <= 0 || b.MaxTokens > caps.MaxTokens) {
+		b.MaxTokens = caps.MaxTokens
+	}
+	if caps.MaxExecutionMs > 0 && (b.MaxExecutionMs <= 0 || b.MaxExecutionMs > caps.MaxExecutionMs) {
+		b.MaxExecutionMs = caps.MaxExecutionMs
+	}
+	if caps.MaxTurns > 0 && (b.MaxTurns <= 0 || b.MaxTurns > caps.MaxTurns) {
+		b.MaxTurns = caps.MaxTurns
+	}
+	return b
+}
+
+// BudgetUsage is what a thread has consumed so far.
+type BudgetUsage struct {
+	Tokens      int64 `json:"tokens"`
+	ExecutionMs int64 `json:"execution_ms"`
+	Turns       int   `json:"turns"`
+}
+
+// BudgetRemaining is what a thread may still consume (nil = unlimited).
+type BudgetRemaining struct {
+	Tokens      *int64 `json:"tokens,omitempty"`
+	ExecutionMs *int64 `json:"execution_ms,omitempty"`
+	Turns       *int   `json:"turns,omitempty"`
+}
+
+// BudgetStatus reports a thread's limits, usage and remaining budget.
+type BudgetStatus struct {
+	Limits    Budget          `json:"limits"`
+	Used      BudgetUsage     `json:"used"`
+	Remaining BudgetRemaining `json:"remaining"`
+}
+
+func newBudgetStatus(limits Budget, used BudgetUsage) BudgetStatus {
+	s := BudgetStatus{Limits: limits, Used: used}
+	if limits.MaxTokens > 0 {
+		v := max(limits.MaxTokens-used.Tokens, 0)
+		s.Remaining.Tokens = &v
+	}
+	if limits.MaxExecutionMs > 0 {
+		v := max(limits.MaxExecutionMs-used.ExecutionMs, 0)
+		s.Remaining.ExecutionMs = &v
+	}
+	if limits.MaxTurns > 0 {
+		v := max(limits.MaxTurns-used.Turns, 0)
+		s.Remaining.Turns = &v
+	}
+	return s
+}
+
+// Exhausted returns a BudgetExceeded error when no further turn may start.
+func (s BudgetStatus) Exhausted() *cerrors.Error {
+	switch {
+	case s.Limits.MaxTokens > 0 && s.Used.Tokens >= s.Limits.MaxTokens:
+		return cerrors.ErrBudgetExceeded("tokens", s.Used.Tokens, s.Limits.MaxTokens)
+	case s.Limits.MaxExecutionMs > 0 && s.Used.ExecutionMs >= s.Limits.MaxExecutionMs:
+		return cerrors.ErrBudgetExceeded("execution_ms", s.Used.ExecutionMs, s.Limits.MaxExecutionMs)
+	case s.Limits.MaxTurns > 0 && s.Used.Turns >= s.Limits.MaxTurns:
+		return cerrors.ErrBudgetExceeded("turns", int64(s.Used.Turns), int64(s.Limits.MaxTurns))
+	}
+	return nil
+}
+
+func (r *Record) budgetUsage() BudgetUsage {
+	return BudgetUsage{Tokens: r.usedTokens, ExecutionMs: r.usedExecutionMs, Turns: r.nextTurnIndex}
+}
+
+// ResolveBudget returns the budget for the next run on sessionID: the thread's stored
+// limits overridden by requested, then capped by caps. It fails with BudgetExceeded
+// when the thread has already used up the resulting budget.
+func (m *Manager) ResolveBudget(sessionID string, requested Budget, caps Budget) (Budget, error) {
+	sessionID = stringsTrim(sessionID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if sessionID == "" || !ok {
+		return requested.Cap(caps), nil
+	}
+	limits := rec.budget.Merge(requested).Cap(caps)
+	if cerr := newBudgetStatus(limits, rec.budgetUsage()).Exhausted(); cerr != nil {
+		return limits, cerr.WithData("SESSION_ID", rec.ID)
+	}
+	return limits, nil
+}
+
+// SetBudget stores the thread's budget limits.
+func (m *Manager) SetBudget(sessionID string, b Budget) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return false
+	}
+	rec.budget = b
+	m.persistLocked(rec)
+	return true
+}
+
+// BudgetStatus returns the thread's budget and usage. While a turn is running, its
+// execution time is not yet included.
+func (m *Manager) BudgetStatus(sessionID string) (BudgetStatus, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return BudgetStatus{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return BudgetStatus{}, false
+	}
+	return newBudgetStatus(rec.budget, rec.budgetUsage()), true
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func TestBudget_MergeAndCap(t *testing.T) {
+	stored := Budget{MaxTokens: 1000, MaxTurns: 5}
+	got := stored.Merge(Budget{MaxTurns: 10, MaxExecutionMs: 60_000}).Cap(Budget{MaxTurns: 8, MaxTokens: 500})
+	want := Budget{MaxTokens: 500, MaxExecutionMs: 60_000, MaxTurns: 8}
+	if got != want {
+		t.Fatalf("budget=%+v, want %+v", got, want)
+	}
+	if (Budget{}).Cap(Budget{MaxTurns: 3}) != (Budget{MaxTurns: 3}) {
+		t.Fatalf("caps should apply when no limit was requested")
+	}
+}
+
+func TestManager_BudgetAcrossTurns(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limits, err := m.ResolveBudget("t1", Budget{MaxTokens: 200, MaxTurns: 3}, Budget{})
+	if err != nil {
+		t.Fatalf("ResolveBudget() failed: %v", err)
+	}
+	if _, err := m.Start("t1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.SetBudget("t1", limits)
+	m.BeginTurn("t1", TurnStart{Prompt: "one"})
+	m.SetTurnResult("t1", "ok", &Usage{InputTokens: 100, CachedInputTokens: 80, OutputTokens: 20})
+	m.MarkCompleted("t1", 1, 0)
+
+	status, ok := m.BudgetStatus("t1")
+	if !ok || status.Used.Tokens != 120 || status.Used.Turns != 1 {
+		t.Fatalf("status=%+v", status)
+	}
+	if status.Remaining.Tokens == nil || *status.Remaining.Tokens != 80 || status.Remaining.ExecutionMs != nil {
+		t.Fatalf("remaining=%+v", status.Remaining)
+	}
+
+	// Resuming keeps the limits and usage; a smaller request still applies.
+	if _, err := m.Start("t1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start(resume) failed: %v", err)
+	}
+	m.BeginTurn("t1", TurnStart{Prompt: "two"})
+	m.SetTurnResult("t1", "ok", &Usage{InputTokens: 90, OutputTokens: 10})
+	m.MarkCompleted("t1", 1, 0)
+	if v, _ := m.Get("t1"); v.Budget == nil || v.Budget.Used.Tokens != 220 || v.Budget.Limits.MaxTurns != 3 {
+		t.Fatalf("view budget=%+v", v.Budget)
+	}
+
+	_, err = m.ResolveBudget("t1", Budget{}, Budget{})
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) || cerr.Code != cerrors.BudgetExceeded || cerr.Data["limit"] != "tokens" {
+		t.Fatalf("expected tokens BudgetExceeded, got %v", err)
+	}
+	// Raising the limit allows further turns unless the server cap forbids it.
+	if _, err := m.ResolveBudget("t1", Budget{MaxTokens: 1000}, Budget{}); err != nil {
+		t.Fatalf("ResolveBudget(raised) failed: %v", err)
+	}
+	if _, err := m.ResolveBudget("t1", Budget{MaxTokens: 1000}, Budget{MaxTurns: 2}); err == nil {
+		t.Fatalf("expected turns cap to be enforced")
+	}
+}
+
+func TestManager_QueueTimeIsNotBilled(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 1, TTL: time.Hour})
+
+	enqueue(t, m, "s1", QueueOptions{})
+	t2 := enqueue(t, m, "s2", QueueOptions{})
+	m.BeginTurn("s2", TurnStart{Prompt: "queued"})
+	time.Sleep(50 * time.Millisecond)
+	m.MarkCompleted("s1", 1, 0)
+	waitGranted(t, t2)
+	m.MarkCompleted("s2", 1, 0)
+
+	if status, _ := m.BudgetStatus("s2"); status.Used.ExecutionMs >= 50 {
+		t.Fatalf("execution_ms=%d includes time spent queued", status.Used.ExecutionMs)
+	}
+}
+
+func TestManager_BudgetExcludesSuspendedTime(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("t1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.SetBudget("t1", Budget{MaxExecutionMs: 60_000})
+	m.BeginTurn("t1", TurnStart{Prompt: "one"})
+	m.SetProcessControl("t1", &fakeControl{})
+
+	m.Suspend("t1")
+	time.Sleep(100 * time.Millisecond)
+	m.Resume("t1")
+	m.Suspend("t1")
+	time.Sleep(100 * time.Millisecond)
+	// Ending while suspended counts the last suspension too.
+	m.MarkCompleted("t1", 1, 0)
+
+	turns, _, _, _ := m.History("t1", 0, 10)
+	if len(turns) != 1 || turns[0].SuspendedMs < 200 || turns[0].DurationMs < turns[0].SuspendedMs {
+		t.Fatalf("turns=%+v, want >= 200ms suspended", turns)
+	}
+	status, _ := m.BudgetStatus("t1")
+	if want := turns[0].DurationMs - turns[0].SuspendedMs; status.Used.ExecutionMs != want {
+		t.Fatalf("used execution_ms=%d, want %d (suspended time excluded)", status.Used.ExecutionMs, want)
+	}
+}
@@ -0,0 +1,385 @@
+package session
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diagSegmentPrefix = "diag-"
+	diagSegmentSuffix = ".jsonl"
+)
+
+// DiagnosticsLogOptions bounds the on-disk diagnostics log. Zero values use the
+// defaults from DefaultDiagnosticsLogOptions.
+type DiagnosticsLogOptions struct {
+	// MaxFileBytes rotates a session's active file once it reaches this size.
+	MaxFileBytes int64
+	// MaxFiles caps the files kept per session (oldest are deleted first).
+	MaxFiles int
+	// MaxTotalBytes caps all files across sessions (oldest are deleted first).
+	MaxTotalBytes int64
+}
+
+func DefaultDiagnosticsLogOptions() DiagnosticsLogOptions {
+	return DiagnosticsLogOptions{
+		MaxFileBytes:  4 << 20,
+		MaxFiles:      8,
+		MaxTotalBytes: 256 << 20,
+	}
+}
+
+// DiagnosticsLog keeps every diagnostic entry, untruncated, in rotated per-session
+// JSONL files under a directory: <dir>/<session>/diag-<first seq>.jsonl. Naming a file
+// by its first seq lets Read skip whole files. It is safe for concurrent use.
+type DiagnosticsLog struct {
+	mu       sync.Mutex
+	dir      string
+	opts     DiagnosticsLogOptions
+	sessions map[string][]diagSegment // keyed by directory name, ordered by first seq
+	total    int64
+}
+
+type diagSegment struct {
+	firstSeq uint64
+	size     int64
+	modTime  time.Time
+}
+
+// OpenDiagnosticsLog opens (or creates) the log in dir and indexes existing files.
+func OpenDiagnosticsLog(dir string, opts DiagnosticsLogOptions) (*DiagnosticsLog, error) {
+	if dir == "" {
+		return nil, errors.New("diagnostics dir is required")
+	}
+	def := DefaultDiagnosticsLogOptions()
+	if opts.MaxFileBytes <= 0 {
+		opts.MaxFileBytes = def.MaxFileBytes
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = def.MaxFiles
+	}
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = def.MaxTotalBytes
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create diagnostics dir: %w", err)
+	}
+	l := &DiagnosticsLog{dir: dir, opts: opts, sessions: make(map[string][]diagSegment)}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read diagnostics dir: %w", err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, d.Name()))
+		if err != nil {
+			continue
+		}
+		var segs []diagSegment
+		for _, f := range files {
+			seq, ok := parseSegmentName(f.Name())
+			if !ok {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			segs = append(segs, diagSegment{firstSeq: seq, size: info.Size(), modTime: info.ModTime()})
+			l.total += info.Size()
+		}
+		if len(segs) > 0 {
+			sort.Slice(segs, func(i, j int) bool { return segs[i].firstSeq < segs[j].firstSeq })
+			l.sessions[d.Name()] = segs
+		}
+	}
+	l.enforceTotalLocked("")
+	return l, nil
+}
+
+// Append writes e (with its full message) to the session's log.
+func (l *DiagnosticsLog) Append(sessionID string, e DiagnosticEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	name := diagDirName(sessionID)
+	segs := l.sessions[name]
+	if n := len(segs); n > 0 && e.Seq <= segs[n-1].firstSeq {
+		// Numbering restarted: what is on disk belongs to an earlier session.
+		for _, seg := range segs {
+			l.removeSegmentLocked(name, seg)
+		}
+		segs = nil
+	}
+	if n := len(segs); n == 0 || segs[n-1].size >= l.opts.MaxFileBytes {
+		if err := os.MkdirAll(filepath.Join(l.dir, name), 0o700); err != nil {
+			return err
+		}
+		segs = append(segs, diagSegment{firstSeq: e.Seq})
+	}
+	cur := &segs[len(segs)-1]
+	f, err := os.OpenFile(l.segmentPath(name, cur.firstSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	n, werr := f.Write(line)
+	cerr := f.Close()
+	cur.size += int64(n)
+	cur.modTime = time.Now()
+	l.total += int64(n)
+
+	for len(segs) > l.opts.MaxFiles {
+		l.removeSegmentLocked(name, segs[0])
+		segs = segs[1:]
+	}
+	l.sessions[name] = segs
+	l.enforceTotalLocked(name)
+
+	if werr != nil {
+		return werr
+	}
+	return cerr
+}
+
+// Read returns up to limit entries with seq > cursor, in order, together with the
+// oldest seq still on disk (0 when the session has no log).
+func (l *DiagnosticsLog) Read(sessionID string, cursor uint64, limit int) ([]DiagnosticEntry, uint64, error) {
+	l.mu.Lock()
+	name := diagDirName(sessionID)
+	segs := append([]diagSegment(nil), l.sessions[name]...)
+	l.mu.Unlock()
+
+	if len(segs) == 0 {
+		return nil, 0, nil
+	}
+	oldest := segs[0].firstSeq
+
+	var out []DiagnosticEntry
+	for i, seg := range segs {
+		// The next file starts after cursor: nothing in this one is needed.
+		if i+1 < len(segs) && segs[i+1].firstSeq <= cursor+1 {
+			continue
+		}
+		f, err := os.Open(l.segmentPath(name, seg.firstSeq))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed by rotation since the snapshot above.
+				continue
+			}
+			return out, oldest, err
+		}
+		r := bufio.NewReader(f)
+		for len(out) < limit {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				var e DiagnosticEntry
+				if json.Unmarshal(line, &e) == nil && e.Seq > cursor {
+					out = append(out, e)
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, oldest, nil
+}
+
+// LastSeq returns the highest seq on disk for the session (0 when it has no log), so
+// numbering can continue after a restart (see Manager.Restore).
+func (l *DiagnosticsLog) LastSeq(sessionID string) (uint64, error) {
+	l.mu.Lock()
+	name := diagDirName(sessionID)
+	segs := append([]diagSegment(nil), l.sessions[name]...)
+	l.mu.Unlock()
+
+	// Later files hold later entries: only the newest non-empty one is read.
+	for i := len(segs) - 1; i >= 0; i-- {
+		f, err := os.Open(l.segmentPath(name, segs[i].firstSeq))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+		var last uint64
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				var e DiagnosticEntry
+				if json.Unmarshal(line, &e) == nil && e.Seq > last {
+					last = e.Seq
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+		if last > 0 {
+			return last, nil
+		}
+	}
+	return 0, nil
+}
+
+// All returns every entry still on disk for the session, in order.
+func (l *DiagnosticsLog) All(sessionID string) ([]DiagnosticEntry, error) {
+	entries, _, err := l.Read(sessionID, 0, math.MaxInt)
+	return entries, err
+}
+
+// ReadDiagnosticsLog returns every entry logged for sessionID in dir without opening
+// the log for writing, so it is safe to use while a server owns the log.
+func ReadDiagnosticsLog(dir, sessionID string) ([]DiagnosticEntry, error) {
+	if dir == "" {
+		return nil, errors.New("diagnostics dir is required")
+	}
+	name := diagDirName(sessionID)
+	files, err := os.ReadDir(filepath.Join(dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read diagnostics dir: %w", err)
+	}
+	var segs []diagSegment
+	for _, f := range files {
+		if seq, ok := parseSegmentName(f.Name()); ok {
+			segs = append(segs, diagSegment{firstSeq: seq})
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].firstSeq < segs[j].firstSeq })
+	l := &DiagnosticsLog{dir: dir, sessions: map[string][]diagSegment{name: segs}}
+	return l.All(sessionID)
+}
+
+// Rename moves a session's log to a new ID (see Manager.UpdateID).
+func (l *DiagnosticsLog) Rename(oldID, newID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	oldName, newName := diagDirName(oldID), diagDirName(newID)
+	segs, ok := l.sessions[oldName]
+	if !ok || oldName == newName {
+		return nil
+	}
+	// A leftover log under the new ID (e.g. from before a restart) is stale.
+	for _, seg := range l.sessions[newName] {
+		l.total -= seg.size
+	}
+	delete(l.sessions, newName)
+	if err := os.RemoveAll(filepath.Join(l.dir, newName)); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(l.dir, oldName), filepath.Join(l.dir, newName)); err != nil {
+		return err
+	}
+	delete(l.sessions, oldName)
+	l.sessions[newName] = segs
+	return nil
+}
+
+// Remove deletes a session's log.
+func (l *DiagnosticsLog) Remove(sessionID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	name := diagDirName(sessionID)
+	for _, seg := range l.sessions[name] {
+		l.total -= seg.size
+	}
+	delete(l.sessions, name)
+	return os.RemoveAll(filepath.Join(l.dir, name))
+}
+
+// enforceTotalLocked deletes the least recently written files until the total size
+// fits MaxTotalBytes. The active file of keep is never deleted.
+func (l *DiagnosticsLog) enforceTotalLocked(keep string) {
+	for l.total > l.opts.MaxTotalBytes {
+		victim, idx := "", -1
+		var oldest time.Time
+		for name, segs := range l.sessions {
+			for i, seg := range segs {
+				if name == keep && i == len(segs)-1 {
+					continue
+				}
+				if idx < 0 || seg.modTime.Before(oldest) {
+					victim, idx, oldest = name, i, seg.modTime
+				}
+			}
+		}
+		if idx < 0 {
+			return
+		}
+		segs := l.sessions[victim]
+		l.removeSegmentLocked(victim, segs[idx])
+		segs = append(segs[:idx:idx], segs[idx+1:]...)
+		if len(segs) == 0 {
+			delete(l.sessions, victim)
+			_ = os.Remove(filepath.Join(l.dir, victim))
+		} else {
+			l.sessions[victim] = segs
+		}
+	}
+}
+
+func (l *DiagnosticsLog) removeSegmentLocked(name string, seg diagSegment) {
+	l.total -= seg.size
+	_ = os.Remove(l.segmentPath(name, seg.firstSeq))
+}
+
+func (l *DiagnosticsLog) segmentPath(name string, firstSeq uint64) string {
+	return filepath.Join(l.dir, name, fmt.Sprintf("%s%020d%s", diagSegmentPrefix, firstSeq, diagSegmentSuffix))
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, diagSegmentPrefix) || !strings.HasSuffix(name, diagSegmentSuffix) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, diagSegmentPrefix), diagSegmentSuffix), 10, 64)
+	return seq, err == nil
+}
+
+// diagDirName maps a session ID to a directory name; IDs that are not plain file
+// names are hashed.
+func diagDirName(id string) string {
+	plain := id != "" && len(id) <= 128 && id != "." && id != ".."
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return "x-" + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,236 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func appendN(t *testing.T, l *DiagnosticsLog, id string, from, to uint64, msg string) {
+	t.Helper()
+	for seq := from; seq <= to; seq++ {
+		if err := l.Append(id, DiagnosticEntry{Seq: seq, At: time.Now(), Kind: DiagnosticOutput, Message: msg}); err != nil {
+			t.Fatalf("Append(%d) failed: %v", seq, err)
+		}
+	}
+}
+
+func TestDiagnosticsLog_RotatesAndCapsFiles(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenDiagnosticsLog(dir, DiagnosticsLogOptions{MaxFileBytes: 512, MaxFiles: 3, MaxTotalBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+	}
+	appendN(t, l, "s1", 1, 40, strings.Repeat("x", 100))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "s1", "diag-*.jsonl"))
+	if len(files) != 3 {
+		t.Fatalf("files=%v, want 3 after rotation", files)
+	}
+	entries, oldest, err := l.Read("s1", 0, 200)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if oldest <= 1 || len(entries) == 0 || entries[0].Seq != oldest || entries[len(entries)-1].Seq != 40 {
+		t.Fatalf("oldest=%d entries=%d first=%+v", oldest, len(entries), entries[0])
+	}
+
+	// Reading from a cursor skips older files and honours the limit.
+	entries, _, _ = l.Read("s1", 37, 2)
+	if len(entries) != 2 || entries[0].Seq != 38 || entries[1].Seq != 39 {
+		t.Fatalf("entries after cursor=%+v", entries)
+	}
+
+	// The index survives reopening.
+	reopened, err := OpenDiagnosticsLog(dir, DiagnosticsLogOptions{MaxFileBytes: 512, MaxFiles: 3, MaxTotalBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if _, o, _ := reopened.Read("s1", 0, 1); o != oldest {
+		t.Fatalf("reopened oldest=%d, want %d", o, oldest)
+	}
+}
+
+func TestDiagnosticsLog_TotalCapEvictsOldestFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Four ~290-byte entries fill a file; two sessions of two files each exceed the cap.
+	l, err := OpenDiagnosticsLog(dir, DiagnosticsLogOptions{MaxFileBytes: 1024, MaxFiles: 8, MaxTotalBytes: 4096})
+	if err != nil {
+		t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+	}
+	appendN(t, l, "old", 1, 8, strings.Repeat("a", 200))
+	time.Sleep(10 * time.Millisecond)
+	appendN(t, l, "new", 1, 8, strings.Repeat("b", 200))
+
+	if _, oldest, _ := l.Read("old", 0, 10); oldest != 5 {
+		t.Fatalf("old session oldest=%d, want its first file evicted", oldest)
+	}
+	if entries, _, _ := l.Read("new", 0, 10); len(entries) != 8 {
+		t.Fatalf("new session entries=%d, want 8", len(entries))
+	}
+}
+
+func TestDiagnosticsLog_HashesUnsafeIDs(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenDiagnosticsLog(dir, DiagnosticsLogOptions{})
+	if err != nil {
+		t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+	}
+	appendN(t, l, "../escape", 1, 1, "m")
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); err == nil {
+		t.Fatalf("session ID escaped the diagnostics dir")
+	}
+	if entries, _, _ := l.Read("../escape", 0, 10); len(entries) != 1 {
+		t.Fatalf("entries=%v", entries)
+	}
+}
+
+func TestManager_TailReadsDroppedEntriesFromDisk(t *testing.T) {
+	diagLog, err := OpenDiagnosticsLog(t.TempDir(), DiagnosticsLogOptions{})
+	if err != nil {
+		t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+	}
+	m := NewManager(Options{
+		MaxRunning:               1,
+		TTL:                      time.Hour,
+		DiagnosticsMaxEntries:    5,
+		DiagnosticsMaxEntryBytes: 8,
+		DiagnosticsLog:           diagLog,
+	})
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("tmp", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.AppendDiagnostic("tmp", DiagnosticOutput, "first failure: "+strings.Repeat("z", 50))
+	for i := 0; i < 9; i++ {
+		m.AppendDiagnostic("tmp", DiagnosticProgress, "tick")
+	}
+	if ok, err := m.UpdateID("tmp", "thread"); !ok || err != nil {
+		t.Fatalf("UpdateID() = %v, %v", ok, err)
+	}
+
+	// Memory only holds seq 6-10; older cursors come from disk, truncated like memory.
+	tail := m.TailDiagnostics("thread", 0, 2, false)
+	if !tail.FromDisk || len(tail.Entries) != 2 || tail.Entries[0].Seq != 1 || tail.NextCursor != 2 {
+		t.Fatalf("tail=%+v", tail)
+	}
+	if e := tail.Entries[0]; len(e.Message) > 8 || !e.Truncated {
+		t.Fatalf("entry=%+v, want truncated", e)
+	}
+
+	full := m.TailDiagnostics("thread", 0, 1, true)
+	if !full.FromDisk || !strings.HasSuffix(full.Entries[0].Message, strings.Repeat("z", 50)) || full.Entries[0].Truncated {
+		t.Fatalf("full entry=%+v", full.Entries)
+	}
+
+	recent := m.TailDiagnostics("thread", 9, 10, false)
+	if recent.FromDisk || len(recent.Entries) != 1 || recent.Entries[0].Seq != 10 {
+		t.Fatalf("recent tail=%+v", recent)
+	}
+
+	// Resuming keeps numbering, so earlier turns stay readable.
+	m.MarkCompleted("thread", 1, 0)
+	if _, err := m.Start("thread", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start(resume) failed: %v", err)
+	}
+	m.AppendDiagnostic("thread", DiagnosticSystem, "resumed")
+	if tail := m.TailDiagnostics("thread", 10, 10, false); len(tail.Entries) != 1 || tail.Entries[0].Seq != 11 {
+		t.Fatalf("tail after resume=%+v", tail)
+	}
+	if tail := m.TailDiagnostics("thread", 0, 1, false); !tail.FromDisk || tail.Entries[0].Seq != 1 {
+		t.Fatalf("earlier turn not readable after resume: %+v", tail)
+	}
+}
+
+func TestManager_ConcurrentAppendsReachLogInOrder(t *testing.T) {
+	diagLog, err := OpenDiagnosticsLog(t.TempDir(), DiagnosticsLogOptions{MaxFileBytes: 1024, MaxFiles: 100})
+	if err != nil {
+		t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 1, TTL: time.Hour, DiagnosticsLog: diagLog})
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("s", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				m.AppendDiagnostic("s", DiagnosticOutput, "line")
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := m.LoggedDiagnostics("s")
+	if err != nil || len(entries) != 200 {
+		t.Fatalf("LoggedDiagnostics() = %d entries, %v", len(entries), err)
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			t.Fatalf("entry %d has seq %d; log is out of order", i, e.Seq)
+		}
+	}
+}
+
+func TestManager_RestoreContinuesDiagnosticsNumbering(t *testing.T) {
+	stateDir, diagDir := t.TempDir(), t.TempDir()
+	open := func() *Manager {
+		t.Helper()
+		store, err := OpenJSONLStore(stateDir)
+		if err != nil {
+			t.Fatalf("OpenJSONLStore() failed: %v", err)
+		}
+		diagLog, err := OpenDiagnosticsLog(diagDir, DiagnosticsLogOptions{})
+		if err != nil {
+			t.Fatalf("OpenDiagnosticsLog() failed: %v", err)
+		}
+		return NewManager(Options{MaxRunning: 1, TTL: time.Hour, Store: store, DiagnosticsLog: diagLog})
+	}
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := open()
+	if _, err := m.Start("s", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		m.AppendDiagnostic("s", DiagnosticStderr, "before the crash")
+	}
+	// Simulate a crash mid-run: nothing persists the diagnostics numbering.
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	restored := open()
+	defer restored.Close()
+	if _, err := restored.Restore(time.Now()); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if _, err := restored.Start("s", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start(resume) failed: %v", err)
+	}
+	restored.AppendDiagnostic("s", DiagnosticSystem, "resumed")
+
+	entries, err := restored.LoggedDiagnostics("s")
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("LoggedDiagnostics() = %+v, %v; want the crash diagnostics kept", entries, err)
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			t.Fatalf("entry %d has seq %d, want %d", i, e.Seq, i+1)
+		}
+	}
+	if entries[3].Message != "resumed" {
+		t.Fatalf("last entry=%+v", entries[3])
+	}
+}
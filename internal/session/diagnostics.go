@@ -12,28 +12,37 @@ const (
 	DiagnosticSystem   DiagnosticKind = "system"
 	DiagnosticProgress DiagnosticKind = "progress"
 	DiagnosticOutput   DiagnosticKind = "output"
+	// DiagnosticStderr is a codex stderr line; Level carries its severity (info/warning/error).
+	DiagnosticStderr DiagnosticKind = "stderr"
 )
 
 type DiagnosticEntry struct {
-	Seq     uint64
-	At      time.Time
-	Kind    DiagnosticKind
-	Message string
-}
-
-type DiagnosticEntryView struct {
 	Seq     uint64         `json:"seq"`
-	At      string         `json:"ts"`
+	At      time.Time      `json:"ts"`
 	Kind    DiagnosticKind `json:"kind"`
+	Level   string         `json:"level,omitempty"`
 	Message string         `json:"message"`
+	// Truncated reports that Message was cut to the in-memory size limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type DiagnosticEntryView struct {
+	Seq       uint64         `json:"seq"`
+	At        string         `json:"ts"`
+	Kind      DiagnosticKind `json:"kind"`
+	Level     string         `json:"level,omitempty"`
+	Message   string         `json:"message"`
+	Truncated bool           `json:"truncated,omitempty"`
 }
 
 func (e DiagnosticEntry) View() DiagnosticEntryView {
 	return DiagnosticEntryView{
-		Seq:     e.Seq,
-		At:      e.At.UTC().Format(time.RFC3339),
-		Kind:    e.Kind,
-		Message: e.Message,
+		Seq:       e.Seq,
+		At:        e.At.UTC().Format(time.RFC3339),
+		Kind:      e.Kind,
+		Level:     e.Level,
+		Message:   e.Message,
+		Truncated: e.Truncated,
 	}
 }
 
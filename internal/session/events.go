@@ -0,0 +1,60 @@
+package session
+
+import (
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+// EventType is a session lifecycle transition reported through Options.OnEvent.
+// The server attaches a run's change receipt (EventReceiptReady) before it finishes
+// the session, so the terminal event carries the receipt too.
+type EventType string
+
+const (
+	EventStarted      EventType = "started"
+	EventCompleted    EventType = "completed"
+	EventFailed       EventType = "failed"
+	EventCancelled    EventType = "cancelled"
+	EventReceiptReady EventType = "receipt-ready"
+)
+
+// Event describes a lifecycle transition of a session.
+type Event struct {
+	Type    EventType
+	At      time.Time
+	Session View
+	// PreviousIDs are earlier IDs of the session (e.g. the temporary ID it was
+	// started under before codex reported the thread ID).
+	PreviousIDs   []string
+	ChangeReceipt *receipt.ChangeReceipt
+}
+
+// emitLocked reports a transition of rec to Options.OnEvent.
+func (m *Manager) emitLocked(rec *Record, typ EventType, now time.Time) {
+	if m.opts.OnEvent == nil || rec == nil {
+		return
+	}
+	ev := Event{
+		Type:          typ,
+		At:            now,
+		Session:       rec.View(),
+		ChangeReceipt: rec.ChangeReceipt,
+	}
+	if len(rec.aliases) > 0 {
+		ev.PreviousIDs = append([]string(nil), rec.aliases...)
+	}
+	m.opts.OnEvent(ev)
+}
+
+func finishEvent(state State) (EventType, bool) {
+	switch state {
+	case StateCompleted:
+		return EventCompleted, true
+	case StateFailed:
+		return EventFailed, true
+	case StateCancelled:
+		return EventCancelled, true
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+func TestManager_EmitsLifecycleEvents(t *testing.T) {
+	var events []Event
+	m := NewManager(Options{
+		MaxRunning: 1,
+		QueueSize:  4,
+		TTL:        time.Minute,
+		OnEvent:    func(ev Event) { events = append(events, ev) },
+	})
+
+	enqueue(t, m, "tmp", QueueOptions{})
+	queued := enqueue(t, m, "s2", QueueOptions{})
+	enqueue(t, m, "s3", QueueOptions{})
+	if ok, err := m.UpdateID("tmp", "s1"); !ok || err != nil {
+		t.Fatalf("UpdateID() = %v, %v", ok, err)
+	}
+	m.SetChangeReceipt("s1", receipt.ChangeReceipt{ReceiptAvailable: true})
+	m.MarkCompleted("s1", 10, 0) // grants s2
+	waitGranted(t, queued)
+	if _, err := m.Cancel("s3"); err != nil {
+		t.Fatalf("Cancel() failed: %v", err)
+	}
+	m.MarkFailed("s2", errors.New("boom"))
+	// A late finish of a cancelled session does not report it twice.
+	m.MarkCancelled("s3", "cancelled")
+
+	type seen struct {
+		typ EventType
+		id  string
+	}
+	var got []seen
+	for _, ev := range events {
+		got = append(got, seen{ev.Type, ev.Session.SessionID})
+	}
+	want := []seen{
+		{EventStarted, "tmp"},
+		{EventReceiptReady, "s1"},
+		{EventCompleted, "s1"},
+		{EventStarted, "s2"},
+		{EventCancelled, "s3"},
+		{EventFailed, "s2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events=%v, want %v", got, want)
+	}
+
+	completed := events[2]
+	if completed.Session.State != StateCompleted || completed.ChangeReceipt == nil || !reflect.DeepEqual(completed.PreviousIDs, []string{"tmp"}) {
+		t.Fatalf("completed event=%+v", completed)
+	}
+	if events[5].Session.Error != "boom" {
+		t.Fatalf("failed event=%+v", events[5])
+	}
+}
+
+func TestManager_ReportsExpiredSessions(t *testing.T) {
+	var expired []string
+	m := NewManager(Options{
+		MaxRunning: 2,
+		TTL:        time.Minute,
+		OnExpire:   func(snap Snapshot) { expired = append(expired, snap.ID) },
+	})
+	enqueue(t, m, "old", QueueOptions{})
+	enqueue(t, m, "running", QueueOptions{})
+	m.MarkCompleted("old", 10, 0)
+
+	if n := m.CleanupExpired(time.Now()); n != 0 || len(expired) != 0 {
+		t.Fatalf("CleanupExpired() before the TTL removed %d, expired=%v", n, expired)
+	}
+	if n := m.CleanupExpired(time.Now().Add(2 * time.Minute)); n != 1 || !reflect.DeepEqual(expired, []string{"old"}) {
+		t.Fatalf("CleanupExpired() removed %d, expired=%v, want [old]", n, expired)
+	}
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// ImportSpec describes a codex thread started outside the server (e.g. in the codex TUI).
+type ImportSpec struct {
+	ID        string
+	WorkDir   string
+	Sandbox   string
+	CreatedAt time.Time
+	Title     string
+	Labels    map[string]string
+}
+
+// Import tracks an existing codex thread as a completed session. Its origin is the
+// thread's workdir and sandbox, so resuming it gets the same workdir and sandbox checks
+// as threads started by the server. It reports false, with the current view, when the
+// thread is already tracked.
+func (m *Manager) Import(spec ImportSpec) (View, bool, error) {
+	spec.ID = stringsTrim(spec.ID)
+	if spec.ID == "" {
+		return View{}, false, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	if stringsTrim(spec.WorkDir) == "" {
+		return View{}, false, cerrors.ErrInvalidParams("thread has no recorded working directory").
+			WithData("SESSION_ID", spec.ID)
+	}
+	if err := ValidateTitle(spec.Title); err != nil {
+		return View{}, false, err
+	}
+	if err := ValidateLabels(spec.Labels); err != nil {
+		return View{}, false, err
+	}
+
+	now := time.Now()
+	started := spec.CreatedAt
+	if started.IsZero() {
+		started = now
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, exists := m.lookupLocked(spec.ID); exists {
+		return rec.View(), false, nil
+	}
+	rec := &Record{
+		ID:        spec.ID,
+		State:     StateCompleted,
+		WorkDir:   spec.WorkDir,
+		Sandbox:   spec.Sandbox,
+		StartedAt: started,
+		EndedAt:   &now,
+		Title:     spec.Title,
+		origin:    Origin{WorkDir: spec.WorkDir, Sandbox: spec.Sandbox},
+	}
+	if len(spec.Labels) > 0 {
+		rec.Labels = copyLabels(spec.Labels)
+	}
+	m.sessions[rec.ID] = rec
+	m.persistLocked(rec)
+	return rec.View(), true, nil
+}
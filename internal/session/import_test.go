@@ -0,0 +1,37 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManager_Import(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour})
+	created := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	if _, _, err := m.Import(ImportSpec{ID: "thread"}); err == nil {
+		t.Fatalf("expected import without a workdir to fail")
+	}
+	view, created1, err := m.Import(ImportSpec{
+		ID:        "thread",
+		WorkDir:   "/repo",
+		Sandbox:   "workspace-write",
+		CreatedAt: created,
+		Labels:    map[string]string{"source": "tui"},
+	})
+	if err != nil || !created1 {
+		t.Fatalf("Import()=%v, %v", created1, err)
+	}
+	if view.State != StateCompleted || view.WorkDir != "/repo" || view.StartedAt != "2025-01-02T10:00:00Z" || view.Labels["source"] != "tui" {
+		t.Fatalf("view=%+v", view)
+	}
+	if o, ok := m.Origin("thread"); !ok || o.WorkDir != "/repo" || o.Sandbox != "workspace-write" {
+		t.Fatalf("Origin()=%+v, %v", o, ok)
+	}
+
+	// Importing again leaves the tracked session alone.
+	again, created2, err := m.Import(ImportSpec{ID: "thread", WorkDir: "/elsewhere", Sandbox: "danger-full-access"})
+	if err != nil || created2 || again.WorkDir != "/repo" {
+		t.Fatalf("re-Import()=%+v, %v, %v", again, created2, err)
+	}
+}
@@ -0,0 +1,409 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	jsonlStoreFile = "sessions.jsonl"
+
+	// Compact once the log holds this many lines more than there are live records.
+	jsonlCompactSlack = 512
+	// Lines larger than this are skipped on load (best-effort corruption guard).
+	jsonlMaxLineBytes = 16 << 20
+	// At most this many skipped lines are described by SkippedLines.
+	jsonlMaxSkippedReports = 10
+)
+
+type jsonlOp struct {
+	Op     string    `json:"op"`
+	ID     string    `json:"id,omitempty"`
+	Record *Snapshot `json:"record,omitempty"`
+
+	// A put leaves out the parts of Record that are unchanged since the previous put of
+	// the same ID: the turns listed in SameTurns, the receipt and result when flagged,
+	// and the previous diagnostics with DiagSince <= Seq <= DiagUntil (which go before
+	// Record's).
+	SameTurns   []int  `json:"same_turns,omitempty"`
+	SameReceipt bool   `json:"same_receipt,omitempty"`
+	SameResult  bool   `json:"same_result,omitempty"`
+	DiagSince   uint64 `json:"diag_since,omitempty"`
+	DiagUntil   uint64 `json:"diag_until,omitempty"`
+}
+
+// jsonlWritten holds hashes of the parts of a record the log holds since its last full
+// put, so that later puts only append what changed.
+type jsonlWritten struct {
+	turns   map[int]uint64
+	receipt uint64
+	result  uint64
+	diags   map[uint64]uint64
+}
+
+// JSONLStore is an append-only JSONL Store under a state directory.
+// Every Put/Delete appends one line, holding only the parts of the record that
+// changed; the log is rewritten (compacted) with full records when it grows well
+// beyond the number of live records. Torn or corrupt lines, e.g. from a crash
+// mid-write, are skipped on load and reported by SkippedLines.
+type JSONLStore struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	live    map[string]Snapshot
+	written map[string]*jsonlWritten
+	lines   int
+	torn    bool
+	skipped []string
+	nSkip   int
+}
+
+// OpenJSONLStore opens (or creates) the store in dir.
+func OpenJSONLStore(dir string) (*JSONLStore, error) {
+	if dir == "" {
+		return nil, errors.New("state dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create state dir: %w", err)
+	}
+	s := &JSONLStore{
+		path:    filepath.Join(dir, jsonlStoreFile),
+		live:    make(map[string]Snapshot),
+		written: make(map[string]*jsonlWritten),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open session store: %w", err)
+	}
+	s.f = f
+	if s.torn {
+		if err := s.compactLocked(); err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("compact session store: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// ReadJSONLStore returns the snapshots persisted in dir without opening the store
+// for writing, so it is safe to use while a server owns the store.
+func ReadJSONLStore(dir string) ([]Snapshot, error) {
+	if dir == "" {
+		return nil, errors.New("state dir is required")
+	}
+	s := &JSONLStore{
+		path: filepath.Join(dir, jsonlStoreFile),
+		live: make(map[string]Snapshot),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s.Load()
+}
+
+func (s *JSONLStore) replay() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("open session store: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			s.lines++
+			if reason := s.applyLine(line); reason != "" {
+				s.skip(fmt.Sprintf("line %d: %s", s.lines, reason))
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// A torn final line (crash mid-write) would corrupt the next append.
+				s.torn = len(line) > 0
+				return nil
+			}
+			return fmt.Errorf("read session store: %w", err)
+		}
+	}
+}
+
+// applyLine applies one log line, returning why it was skipped ("" when applied).
+func (s *JSONLStore) applyLine(line []byte) string {
+	if len(line) > jsonlMaxLineBytes {
+		return fmt.Sprintf("%d bytes exceeds the %d byte limit", len(line), jsonlMaxLineBytes)
+	}
+	var op jsonlOp
+	if err := json.Unmarshal(line, &op); err != nil {
+		return "invalid JSON: " + err.Error()
+	}
+	switch op.Op {
+	case "put":
+		if op.Record == nil || op.Record.ID == "" {
+			return "put without a record"
+		}
+		snap := *op.Record
+		if prev, ok := s.live[snap.ID]; ok {
+			mergeUnchanged(&snap, prev, op)
+		}
+		s.live[snap.ID] = snap
+	case "delete":
+		delete(s.live, op.ID)
+	default:
+		return fmt.Sprintf("unknown op %q", op.Op)
+	}
+	return ""
+}
+
+func (s *JSONLStore) skip(reason string) {
+	s.nSkip++
+	if len(s.skipped) < jsonlMaxSkippedReports {
+		s.skipped = append(s.skipped, reason)
+	}
+}
+
+// SkippedLines describes the log lines that could not be applied on load; the
+// records (or changes) they held are lost.
+func (s *JSONLStore) SkippedLines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]string(nil), s.skipped...)
+	if more := s.nSkip - len(s.skipped); more > 0 {
+		out = append(out, fmt.Sprintf("and %d more", more))
+	}
+	return out
+}
+
+// mergeUnchanged fills in the parts a delta put left out from prev.
+func mergeUnchanged(snap *Snapshot, prev Snapshot, op jsonlOp) {
+	if len(op.SameTurns) > 0 {
+		same := make(map[int]bool, len(op.SameTurns))
+		for _, i := range op.SameTurns {
+			same[i] = true
+		}
+		for _, t := range prev.Turns {
+			if same[t.Index] {
+				snap.Turns = append(snap.Turns, t)
+			}
+		}
+		sort.SliceStable(snap.Turns, func(i, j int) bool { return snap.Turns[i].Index < snap.Turns[j].Index })
+	}
+	if op.SameReceipt {
+		snap.ChangeReceipt = prev.ChangeReceipt
+	}
+	if op.SameResult {
+		snap.Result = prev.Result
+	}
+	if op.DiagSince > 0 {
+		kept := make([]DiagnosticEntry, 0, len(prev.Diagnostics)+len(snap.Diagnostics))
+		for _, d := range prev.Diagnostics {
+			if d.Seq >= op.DiagSince && d.Seq <= op.DiagUntil {
+				kept = append(kept, d)
+			}
+		}
+		snap.Diagnostics = append(kept, snap.Diagnostics...)
+	}
+}
+
+func (s *JSONLStore) Load() ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Snapshot, 0, len(s.live))
+	for _, snap := range s.live {
+		out = append(out, snap)
+	}
+	return out, nil
+}
+
+func (s *JSONLStore) Put(snap Snapshot) error {
+	if snap.ID == "" {
+		return errors.New("snapshot id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live[snap.ID] = snap
+	op, written := putOp(snap, s.written[snap.ID])
+	if err := s.appendLocked(op); err != nil {
+		delete(s.written, snap.ID)
+		return err
+	}
+	s.written[snap.ID] = written
+	return nil
+}
+
+// putOp returns the put for snap, leaving out the parts prev says the log already
+// holds, and the hashes describing snap once it is written.
+func putOp(snap Snapshot, prev *jsonlWritten) (jsonlOp, *jsonlWritten) {
+	next := &jsonlWritten{
+		turns:   make(map[int]uint64, len(snap.Turns)),
+		receipt: hashJSON(snap.ChangeReceipt),
+		result:  hashJSON(snap.Result),
+		diags:   make(map[uint64]uint64, len(snap.Diagnostics)),
+	}
+	rec := snap
+	op := jsonlOp{Op: "put", Record: &rec}
+
+	rec.Turns = nil
+	for _, t := range snap.Turns {
+		h := hashJSON(t)
+		next.turns[t.Index] = h
+		if prevH, ok := prev.turnHash(t.Index); ok && prevH == h {
+			op.SameTurns = append(op.SameTurns, t.Index)
+			continue
+		}
+		rec.Turns = append(rec.Turns, t)
+	}
+	if prev != nil && snap.ChangeReceipt != nil && prev.receipt == next.receipt {
+		op.SameReceipt = true
+		rec.ChangeReceipt = nil
+	}
+	if prev != nil && snap.Result != nil && prev.result == next.result {
+		op.SameResult = true
+		rec.Result = nil
+	}
+
+	// Diagnostics only grow at the end: keep the leading entries the log already holds.
+	kept := 0
+	for _, d := range snap.Diagnostics {
+		h := hashJSON(d)
+		next.diags[d.Seq] = h
+		if kept == len(next.diags)-1 && prev != nil && prev.diags[d.Seq] == h {
+			kept++
+		}
+	}
+	if kept > 0 {
+		op.DiagSince = snap.Diagnostics[0].Seq
+		op.DiagUntil = snap.Diagnostics[kept-1].Seq
+		rec.Diagnostics = snap.Diagnostics[kept:]
+	}
+	return op, next
+}
+
+func (w *jsonlWritten) turnHash(index int) (uint64, bool) {
+	if w == nil {
+		return 0, false
+	}
+	h, ok := w.turns[index]
+	return h, ok
+}
+
+func hashJSON(v any) uint64 {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
+
+func (s *JSONLStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.live[id]; !ok {
+		return nil
+	}
+	delete(s.live, id)
+	delete(s.written, id)
+	return s.appendLocked(jsonlOp{Op: "delete", ID: id})
+}
+
+func (s *JSONLStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	_ = s.compactLocked()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *JSONLStore) appendLocked(op jsonlOp) error {
+	if s.f == nil {
+		return errors.New("session store is closed")
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.lines++
+	if s.lines > len(s.live)+jsonlCompactSlack {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked rewrites the log with one full put per live record.
+func (s *JSONLStore) compactLocked() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, snap := range s.live {
+		snap := snap
+		b, err := json.Marshal(jsonlOp{Op: "put", Record: &snap})
+		if err != nil {
+			continue
+		}
+		_, _ = w.Write(append(b, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	// Close the append handle first: renaming over an open file fails on Windows.
+	if s.f != nil {
+		_ = s.f.Close()
+		s.f = nil
+	}
+	renameErr := os.Rename(tmp, s.path)
+	if renameErr != nil {
+		_ = os.Remove(tmp)
+	}
+	nf, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	s.f = nf
+	if renameErr != nil {
+		return renameErr
+	}
+	s.lines = len(s.live)
+	// The log now holds full records; later puts start over from them.
+	s.written = make(map[string]*jsonlWritten)
+	return nil
+}
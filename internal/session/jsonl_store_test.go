@@ -0,0 +1,345 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+func TestJSONLStore_PersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	if err := s.Put(Snapshot{ID: "a", State: StateRunning}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if err := s.Put(Snapshot{ID: "a", State: StateCompleted}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if err := s.Put(Snapshot{ID: "b", State: StateFailed}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if err := s.Delete("b"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	s, err = OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	snaps, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(snaps) != 1 || snaps[0].ID != "a" || snaps[0].State != StateCompleted {
+		t.Fatalf("snapshots=%+v, want only a=completed", snaps)
+	}
+}
+
+func TestReadJSONLStore_DoesNotWrite(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	defer s.Close()
+	if err := s.Put(Snapshot{ID: "a", State: StateCompleted}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	before, _ := os.ReadFile(filepath.Join(dir, jsonlStoreFile))
+
+	snaps, err := ReadJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("ReadJSONLStore() failed: %v", err)
+	}
+	if len(snaps) != 1 || snaps[0].ID != "a" {
+		t.Fatalf("snapshots=%+v, want a", snaps)
+	}
+	if after, _ := os.ReadFile(filepath.Join(dir, jsonlStoreFile)); string(after) != string(before) {
+		t.Fatalf("ReadJSONLStore() modified the store")
+	}
+	if snaps, err := ReadJSONLStore(t.TempDir()); err != nil || len(snaps) != 0 {
+		t.Fatalf("empty dir: snapshots=%v err=%v", snaps, err)
+	}
+}
+
+func TestJSONLStore_CompactsLog(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	defer s.Close()
+	for i := 0; i < jsonlCompactSlack*2; i++ {
+		if err := s.Put(Snapshot{ID: "a", State: StateRunning, ToolCallCount: i}); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, jsonlStoreFile))
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > jsonlCompactSlack+1 {
+		t.Fatalf("log has %d lines, want compaction to keep it under %d", lines, jsonlCompactSlack+1)
+	}
+}
+
+func TestJSONLStore_SkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, jsonlStoreFile)
+	content := `{"op":"put","record":{"id":"a","state":"completed","started_at":"2025-01-01T00:00:00Z"}}` + "\n" + `{"op":"put","record":{"id":"b"`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	if err := s.Put(Snapshot{ID: "c", State: StateFailed}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	s.Close()
+
+	s, err = OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	snaps, _ := s.Load()
+	ids := make(map[string]bool)
+	for _, snap := range snaps {
+		ids[snap.ID] = true
+	}
+	if !ids["a"] || !ids["c"] || ids["b"] {
+		t.Fatalf("ids=%v, want a and c", ids)
+	}
+}
+
+func TestJSONLStore_ReportsSkippedLines(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"op":"put","record":{"id":"a"}}` + "\n" + `not json` + "\n" + `{"op":"put","record":{"id":"b"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, jsonlStoreFile), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	defer s.Close()
+	if skipped := s.SkippedLines(); len(skipped) != 1 || !strings.HasPrefix(skipped[0], "line 2: invalid JSON") {
+		t.Fatalf("SkippedLines()=%q, want line 2", skipped)
+	}
+	if snaps, _ := s.Load(); len(snaps) != 2 {
+		t.Fatalf("snapshots=%+v, want a and b", snaps)
+	}
+}
+
+func TestJSONLStore_AppendsOnlyChanges(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	defer s.Close()
+
+	big := strings.Repeat("x", 4096)
+	snap := Snapshot{
+		ID:            "a",
+		State:         StateRunning,
+		ChangeReceipt: &receipt.ChangeReceipt{ReceiptAvailable: true, Diff: big},
+		Turns: []Turn{
+			{Index: 0, State: StateCompleted, AgentMessage: big},
+			{Index: 1, State: StateRunning},
+		},
+		Diagnostics: []DiagnosticEntry{{Seq: 1, Message: big}, {Seq: 2, Message: "two"}},
+		Result:      &Result{Output: []byte(`{"big":"` + big + `"}`)},
+	}
+	if err := s.Put(snap); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	snap.State = StateCompleted
+	snap.Turns = []Turn{snap.Turns[0], {Index: 1, State: StateCompleted, AgentMessage: "done"}}
+	snap.Diagnostics = []DiagnosticEntry{snap.Diagnostics[1], {Seq: 3, Message: "three"}}
+	if err := s.Put(snap); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, jsonlStoreFile))
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || strings.Contains(lines[1], big) {
+		t.Fatalf("second put repeats unchanged parts (%d bytes)", len(lines[len(lines)-1]))
+	}
+
+	snaps, err := ReadJSONLStore(dir)
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("ReadJSONLStore()=%+v, %v", snaps, err)
+	}
+	got := snaps[0]
+	if got.State != StateCompleted || got.ChangeReceipt == nil || got.ChangeReceipt.Diff != big || got.Result == nil {
+		t.Fatalf("restored snapshot lost its receipt or result: %+v", got)
+	}
+	if len(got.Turns) != 2 || got.Turns[0].AgentMessage != big || got.Turns[1].AgentMessage != "done" {
+		t.Fatalf("restored turns=%+v", got.Turns)
+	}
+	if len(got.Diagnostics) != 2 || got.Diagnostics[0].Seq != 2 || got.Diagnostics[1].Message != "three" {
+		t.Fatalf("restored diagnostics=%+v", got.Diagnostics)
+	}
+}
+
+func TestManager_PersistCapsReceipts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+	defer m.Close()
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("a", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	diff := strings.Repeat("+line\n", PersistedReceiptTextBytes)
+	m.SetChangeReceipt("a", receipt.ChangeReceipt{ReceiptAvailable: true, Diff: diff})
+
+	snaps, err := ReadJSONLStore(dir)
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("ReadJSONLStore()=%+v, %v", snaps, err)
+	}
+	if cr := snaps[0].ChangeReceipt; cr == nil || len(cr.Diff) != PersistedReceiptTextBytes || !cr.DiffTruncated {
+		t.Fatalf("persisted receipt diff is not capped: %d bytes", len(cr.Diff))
+	}
+	if v, _ := m.GetDetail("a", 0); v.ChangeReceipt == nil || v.ChangeReceipt.Diff != diff {
+		t.Fatalf("in-memory receipt was capped too")
+	}
+}
+
+func TestManager_PersistsStoredResultOutput(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+	defer m.Close()
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("a", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	full := json.RawMessage(`{"all_messages":["` + strings.Repeat("x", PersistedReceiptTextBytes) + `"]}`)
+	m.SetResult("a", Result{Output: full, StoredOutput: json.RawMessage(`{}`)})
+
+	snaps, err := ReadJSONLStore(dir)
+	if err != nil || len(snaps) != 1 || snaps[0].Result == nil {
+		t.Fatalf("ReadJSONLStore()=%+v, %v", snaps, err)
+	}
+	if got := string(snaps[0].Result.Output); got != `{}` {
+		t.Fatalf("persisted output=%.40q, want the stored form", got)
+	}
+	if res, _, _ := m.Result("a"); res == nil || string(res.Output) != string(full) {
+		t.Fatalf("in-memory result was reduced too")
+	}
+}
+
+func TestManager_RestoreFromStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("done", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.AppendDiagnostic("done", DiagnosticSystem, "session started")
+	m.SetChangeReceipt("done", receipt.ChangeReceipt{ReceiptAvailable: true, GitRoot: "/tmp"})
+	m.MarkCompleted("done", 10, 1)
+	m.SetResult("done", Result{Output: []byte(`{"success":true}`)})
+	if _, err := m.Start("live", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	// Simulate a crash: the store is closed without "live" ever finishing.
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	store, err = OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	restored := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+	defer restored.Close()
+	n, err := restored.Restore(time.Now())
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("restored=%d, want 2", n)
+	}
+
+	done, ok := restored.GetDetail("done", 10)
+	if !ok || done.State != StateCompleted {
+		t.Fatalf("done=%+v found=%v, want completed", done, ok)
+	}
+	if done.ChangeReceipt == nil || !done.ChangeReceipt.ReceiptAvailable {
+		t.Fatalf("change_receipt=%+v, want restored receipt", done.ChangeReceipt)
+	}
+	if len(done.Recent) != 1 {
+		t.Fatalf("recent_entries=%+v, want restored diagnostics", done.Recent)
+	}
+
+	if res, _, _ := restored.Result("done"); res == nil || string(res.Output) != `{"success":true}` {
+		t.Fatalf("result=%+v, want restored result", res)
+	}
+
+	live, ok := restored.Get("live")
+	if !ok || live.State != StateInterrupted {
+		t.Fatalf("live=%+v found=%v, want interrupted", live, ok)
+	}
+	if live.EndedAt == "" {
+		t.Fatalf("interrupted session should have ended_at")
+	}
+}
+
+func TestManager_RestoreDropsExpired(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	ended := time.Now().Add(-2 * time.Hour)
+	if err := store.Put(Snapshot{ID: "old", State: StateCompleted, StartedAt: ended, EndedAt: &ended}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+	defer m.Close()
+	if n, err := m.Restore(time.Now()); err != nil || n != 0 {
+		t.Fatalf("Restore()=(%d,%v), want (0,nil)", n, err)
+	}
+	if snaps, _ := store.Load(); len(snaps) != 0 {
+		t.Fatalf("store still holds %d expired snapshots", len(snaps))
+	}
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+const (
+	MaxLabels        = 32
+	MaxLabelKeyLen   = 64
+	MaxLabelValueLen = 256
+	MaxTitleLen      = 200
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// MetadataUpdate edits a session's title and labels. Nil/empty fields are left unchanged.
+type MetadataUpdate struct {
+	Title *string
+	// Set adds or overwrites labels.
+	Set map[string]string
+	// Remove deletes labels by key (applied after Set).
+	Remove []string
+}
+
+// ValidateTitle checks a session title supplied by a client.
+func ValidateTitle(title string) error {
+	if utf8.RuneCountInString(title) > MaxTitleLen {
+		return cerrors.ErrInvalidParams("title is too long").WithData("max_chars", MaxTitleLen)
+	}
+	return nil
+}
+
+// ValidateLabels checks label keys and values supplied by a client.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return cerrors.ErrInvalidParams("too many labels").WithData("max_labels", MaxLabels)
+	}
+	for k, v := range labels {
+		if len(k) > MaxLabelKeyLen || !labelKeyPattern.MatchString(k) {
+			return cerrors.ErrInvalidParams("invalid label key: "+k).
+				WithData("label", k).
+				WithData("pattern", labelKeyPattern.String()).
+				WithData("max_key_length", MaxLabelKeyLen)
+		}
+		if utf8.RuneCountInString(v) > MaxLabelValueLen {
+			return cerrors.ErrInvalidParams("label value is too long: "+k).
+				WithData("label", k).
+				WithData("max_value_length", MaxLabelValueLen)
+		}
+	}
+	return nil
+}
+
+// UpdateMetadata applies u to the session and returns its updated view.
+func (m *Manager) UpdateMetadata(sessionID string, u MetadataUpdate) (View, bool, error) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return View{}, false, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	if u.Title != nil {
+		t := strings.TrimSpace(*u.Title)
+		if err := ValidateTitle(t); err != nil {
+			return View{}, false, err
+		}
+		u.Title = &t
+	}
+	if err := ValidateLabels(u.Set); err != nil {
+		return View{}, false, err
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.sessions[sessionID]
+	if !ok {
+		return View{}, false, nil
+	}
+	labels := make(map[string]string, len(rec.Labels)+len(u.Set))
+	for k, v := range rec.Labels {
+		labels[k] = v
+	}
+	for k, v := range u.Set {
+		labels[k] = v
+	}
+	for _, k := range u.Remove {
+		delete(labels, k)
+	}
+	if len(labels) > MaxLabels {
+		return View{}, true, cerrors.ErrInvalidParams("too many labels").WithData("max_labels", MaxLabels)
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	rec.Labels = labels
+	if u.Title != nil {
+		rec.Title = *u.Title
+	}
+	m.persistLocked(rec)
+	return rec.View(), true, nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
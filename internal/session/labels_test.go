@@ -0,0 +1,91 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_UpdateMetadata(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Minute})
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := m.Start("s1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	title := "  Fix login  "
+	v, found, err := m.UpdateMetadata("s1", MetadataUpdate{
+		Title: &title,
+		Set:   map[string]string{"ticket": "ABC-1", "owner": "alice"},
+	})
+	if err != nil || !found {
+		t.Fatalf("UpdateMetadata()=(found %v, err %v)", found, err)
+	}
+	if v.Title != "Fix login" || v.Labels["ticket"] != "ABC-1" || v.Labels["owner"] != "alice" {
+		t.Fatalf("unexpected view: %+v", v)
+	}
+
+	v, _, err = m.UpdateMetadata("s1", MetadataUpdate{
+		Set:    map[string]string{"ticket": "ABC-2"},
+		Remove: []string{"owner"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata() failed: %v", err)
+	}
+	if v.Title != "Fix login" || len(v.Labels) != 1 || v.Labels["ticket"] != "ABC-2" {
+		t.Fatalf("unexpected view after update: %+v", v)
+	}
+
+	// Metadata survives a resume of the same thread.
+	m.MarkCompleted("s1", 1, 0)
+	if _, err := m.Start("s1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() resume failed: %v", err)
+	}
+	if got, _ := m.Get("s1"); got.Title != "Fix login" || got.Labels["ticket"] != "ABC-2" {
+		t.Fatalf("metadata lost on resume: %+v", got)
+	}
+
+	views, _, _, err := m.Query(ListFilter{Labels: map[string]string{"ticket": "*"}}, ListPage{})
+	if err != nil || len(views) != 1 {
+		t.Fatalf("Query(labels)=(%d views, err %v)", len(views), err)
+	}
+	views, _, _, _ = m.Query(ListFilter{Labels: map[string]string{"ticket": "ABC-1"}}, ListPage{})
+	if len(views) != 0 {
+		t.Fatalf("Query(stale label) returned %d views", len(views))
+	}
+
+	if _, found, _ := m.UpdateMetadata("missing", MetadataUpdate{}); found {
+		t.Fatalf("UpdateMetadata() should not find unknown session")
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"ok", map[string]string{"team/owner": "alice", "ticket.id": "ABC-1"}, false},
+		{"empty key", map[string]string{"": "x"}, true},
+		{"bad key", map[string]string{"has space": "x"}, true},
+		{"long key", map[string]string{strings.Repeat("k", MaxLabelKeyLen+1): "x"}, true},
+		{"long value", map[string]string{"k": strings.Repeat("v", MaxLabelValueLen+1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateLabels(tt.labels); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLabels() err=%v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	many := make(map[string]string, MaxLabels+1)
+	for i := 0; i <= MaxLabels; i++ {
+		many[strings.Repeat("k", i+1)] = "v"
+	}
+	if err := ValidateLabels(many); err == nil {
+		t.Fatalf("expected error for too many labels")
+	}
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// ForkSpec describes a new session forked from an existing thread.
+type ForkSpec struct {
+	ParentID string
+	ChildID  string
+	// WorkDir and Sandbox are used when the parent is not tracked by the manager.
+	WorkDir string
+	Sandbox string
+	// Title overrides the parent's title; Labels are merged over the parent's labels.
+	Title  string
+	Labels map[string]string
+}
+
+// RegisterFork tracks spec.ChildID as a fork of spec.ParentID. The fork starts as a
+// completed session that inherits the parent's workdir, metadata and turn history, and
+// is linked both ways so get_session can show the lineage.
+func (m *Manager) RegisterFork(spec ForkSpec) (View, error) {
+	spec.ParentID = stringsTrim(spec.ParentID)
+	spec.ChildID = stringsTrim(spec.ChildID)
+	if spec.ParentID == "" || spec.ChildID == "" {
+		return View{}, cerrors.ErrInvalidParams("parent and child SESSION_ID are required")
+	}
+	if err := ValidateTitle(spec.Title); err != nil {
+		return View{}, err
+	}
+	if err := ValidateLabels(spec.Labels); err != nil {
+		return View{}, err
+	}
+
+	now := time.Now()
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.lookupLocked(spec.ChildID); exists {
+		return View{}, cerrors.ErrInvalidParams("session already exists").WithData("SESSION_ID", spec.ChildID)
+	}
+
+	child := &Record{
+		ID:        spec.ChildID,
+		State:     StateCompleted,
+		WorkDir:   spec.WorkDir,
+		Sandbox:   spec.Sandbox,
+		StartedAt: now,
+		EndedAt:   &now,
+		Title:     spec.Title,
+		ParentID:  spec.ParentID,
+		origin:    Origin{WorkDir: spec.WorkDir, Sandbox: spec.Sandbox},
+	}
+	labels := make(map[string]string)
+	parent, hasParent := m.lookupLocked(spec.ParentID)
+	if hasParent {
+		if parent.State.Active() || parent.State == StateQueued {
+			return View{}, cerrors.ErrInvalidParams("cannot fork a running session").WithData("SESSION_ID", parent.ID)
+		}
+		child.ParentID = parent.ID
+		child.WorkDir = parent.WorkDir
+		child.GitRoot = parent.GitRoot
+		child.Sandbox = parent.Sandbox
+		child.origin = parent.origin
+		if child.Title == "" {
+			child.Title = parent.Title
+		}
+		for k, v := range parent.Labels {
+			labels[k] = v
+		}
+		child.turns = append([]Turn(nil), parent.turns...)
+		child.nextTurnIndex = parent.nextTurnIndex
+		// The fork continues the same conversation, so it inherits its spend.
+		child.budget = parent.budget
+		child.usedTokens = parent.usedTokens
+		child.usedExecutionMs = parent.usedExecutionMs
+	}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	if len(labels) > MaxLabels {
+		return View{}, cerrors.ErrInvalidParams("too many labels").WithData("max_labels", MaxLabels)
+	}
+	if len(labels) > 0 {
+		child.Labels = labels
+	}
+
+	if hasParent {
+		parent.Children = append(parent.Children, child.ID)
+		m.persistLocked(parent)
+	}
+	m.sessions[child.ID] = child
+	m.persistLocked(child)
+	return child.View(), nil
+}
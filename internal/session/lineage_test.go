@@ -0,0 +1,91 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager_RegisterFork(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("parent", "/repo", "workspace-write", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.SetGitRoot("parent", "/repo")
+	title := "base"
+	if _, _, err := m.UpdateMetadata("parent", MetadataUpdate{Title: &title, Set: map[string]string{"team": "core"}}); err != nil {
+		t.Fatalf("UpdateMetadata() failed: %v", err)
+	}
+	m.BeginTurn("parent", TurnStart{Prompt: "explore", Sandbox: "workspace-write"})
+
+	if _, err := m.RegisterFork(ForkSpec{ParentID: "parent", ChildID: "child"}); err == nil {
+		t.Fatalf("expected fork of a running session to fail")
+	}
+	m.MarkCompleted("parent", 10, 0)
+
+	view, err := m.RegisterFork(ForkSpec{ParentID: "parent", ChildID: "child", Labels: map[string]string{"approach": "b"}})
+	if err != nil {
+		t.Fatalf("RegisterFork() failed: %v", err)
+	}
+	if view.ParentID != "parent" || view.State != StateCompleted || view.WorkDir != "/repo" || view.GitRoot != "/repo" {
+		t.Fatalf("fork view=%+v", view)
+	}
+	if view.Title != "base" || view.Labels["team"] != "core" || view.Labels["approach"] != "b" || view.TurnCount != 1 {
+		t.Fatalf("fork metadata=%+v", view)
+	}
+	if p, _ := m.Get("parent"); len(p.Children) != 1 || p.Children[0] != "child" {
+		t.Fatalf("parent children=%v", p.Children)
+	}
+	if _, err := m.RegisterFork(ForkSpec{ParentID: "parent", ChildID: "child"}); err == nil {
+		t.Fatalf("expected duplicate fork ID to fail")
+	}
+
+	// Resuming the fork keeps its lineage.
+	if _, err := m.Start("child", "/repo", "workspace-write", cancel); err != nil {
+		t.Fatalf("Start(child) failed: %v", err)
+	}
+	m.MarkCompleted("child", 5, 0)
+	if c, _ := m.Get("child"); c.ParentID != "parent" || c.TurnCount != 1 {
+		t.Fatalf("resumed fork=%+v", c)
+	}
+
+	// Lineage survives a restart.
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	store, err = OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	restored := NewManager(Options{MaxRunning: 2, TTL: time.Hour, Store: store})
+	defer restored.Close()
+	if _, err := restored.Restore(time.Now()); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if p, _ := restored.Get("parent"); len(p.Children) != 1 {
+		t.Fatalf("restored parent children=%v", p.Children)
+	}
+	if c, _ := restored.Get("child"); c.ParentID != "parent" {
+		t.Fatalf("restored child parent=%q", c.ParentID)
+	}
+}
+
+func TestManager_RegisterForkUntrackedParent(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, TTL: time.Hour})
+
+	view, err := m.RegisterFork(ForkSpec{ParentID: "external", ChildID: "child", WorkDir: "/w", Sandbox: "read-only"})
+	if err != nil {
+		t.Fatalf("RegisterFork() failed: %v", err)
+	}
+	if view.ParentID != "external" || view.WorkDir != "/w" || view.Sandbox != "read-only" {
+		t.Fatalf("fork view=%+v", view)
+	}
+}
@@ -21,22 +21,61 @@ const (
 	StateCompleted State = "completed"
 	StateFailed    State = "failed"
 	StateCancelled State = "cancelled"
+	// StateQueued marks a session waiting for a free slot (see Enqueue).
+	StateQueued State = "queued"
+	// StateInterrupted marks a session that was running when the server stopped.
+	StateInterrupted State = "interrupted"
+	// StateSuspended marks a running session whose codex process is stopped (see Suspend).
+	StateSuspended State = "suspended"
 )
 
+// Active reports whether a codex process belongs to the session (running or suspended).
+func (s State) Active() bool {
+	return s == StateRunning || s == StateSuspended
+}
+
 type Options struct {
 	MaxRunning int
 	TTL        time.Duration
 
+	// QueueSize bounds sessions waiting for a slot once MaxRunning is reached
+	// (0 = reject immediately). MaxQueueWait bounds each wait (0 = until cancelled).
+	QueueSize    int
+	MaxQueueWait time.Duration
+
 	DiagnosticsMaxEntries    int
 	DiagnosticsMaxEntryBytes int
+
+	// MaxTurns bounds the turn history kept per session (see DefaultMaxTurns).
+	MaxTurns int
+
+	// Store persists records across restarts (nil = in-memory only). See Restore.
+	Store Store
+
+	// DiagnosticsLog receives every diagnostic entry untruncated (nil = disabled).
+	// TailDiagnostics reads it for entries no longer held in memory.
+	DiagnosticsLog *DiagnosticsLog
+
+	// OnEvent is called on lifecycle transitions (see Event) with the manager lock
+	// held: it must not block or call back into the Manager.
+	OnEvent func(Event)
+
+	// OnExpire is called with the final snapshot of each session dropped once its TTL
+	// has passed (including persisted sessions dropped by Restore), e.g. to delete
+	// resources kept for it outside the Manager. Like OnEvent it is called with the
+	// manager lock held: it must not block or call back into the Manager.
+	OnExpire func(Snapshot)
 }
 
 func DefaultOptions() Options {
 	return Options{
 		MaxRunning:               4,
 		TTL:                      time.Hour,
+		QueueSize:                16,
+		MaxQueueWait:             10 * time.Minute,
 		DiagnosticsMaxEntries:    200,
 		DiagnosticsMaxEntryBytes: 2048,
+		MaxTurns:                 DefaultMaxTurns,
 	}
 }
 
@@ -44,8 +83,16 @@ type Record struct {
 	ID      string
 	State   State
 	WorkDir string
+	GitRoot string
 	Sandbox string
 
+	// origin is where the thread was first started (see Origin).
+	origin Origin
+
+	// Title and Labels are client-supplied metadata (see UpdateMetadata).
+	Title  string
+	Labels map[string]string
+
 	StartedAt time.Time
 	EndedAt   *time.Time
 
@@ -55,6 +102,10 @@ type Record struct {
 	Error string
 
 	cancel context.CancelFunc
+	// control suspends and resumes the codex process (see Suspend); suspendedAt is
+	// set while it is suspended.
+	control     ProcessControl
+	suspendedAt *time.Time
 
 	ChangeReceipt *receipt.ChangeReceipt
 
@@ -62,18 +113,54 @@ type Record struct {
 	diagnostics  []DiagnosticEntry
 	lastEventAt  *time.Time
 	lastOutputAt *time.Time
+
+	// turns is the ordered turn history; nextTurnIndex counts every turn ever started.
+	turns         []Turn
+	nextTurnIndex int
+
+	// aliases are previous IDs (e.g. the temporary ID before the thread ID was known).
+	aliases []string
+	// result is the outcome of the last run; done is closed once it is stored.
+	result *Result
+	done   chan struct{}
+
+	// shareKey groups sessions for fair scheduling (see QueueOptions).
+	shareKey string
+
+	// ParentID is the session this one was forked from; Children are its forks.
+	ParentID string
+	Children []string
+
+	// budget limits the thread across turns; usedTokens and usedExecutionMs accumulate
+	// over all of its turns (see BudgetStatus).
+	budget          Budget
+	usedTokens      int64
+	usedExecutionMs int64
 }
 
 type View struct {
 	SessionID string `json:"SESSION_ID"`
 	State     State  `json:"state"`
 	WorkDir   string `json:"cd"`
+	GitRoot   string `json:"git_root,omitempty"`
 	Sandbox   string `json:"sandbox"`
+	Model     string `json:"model,omitempty"`
 	StartedAt string `json:"started_at"`
 	EndedAt   string `json:"ended_at,omitempty"`
+	// SuspendedAt is set while the session is suspended.
+	SuspendedAt string `json:"suspended_at,omitempty"`
+
+	Title  string            `json:"title,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	ParentID string   `json:"parent_session_id,omitempty"`
+	Children []string `json:"child_session_ids,omitempty"`
+
+	Budget *BudgetStatus `json:"budget,omitempty"`
 
 	ExecutionTimeMs int64 `json:"execution_time_ms,omitempty"`
 	ToolCallCount   int   `json:"tool_call_count,omitempty"`
+	TurnCount       int   `json:"turn_count,omitempty"`
 
 	Error string `json:"error,omitempty"`
 }
@@ -86,15 +173,33 @@ func (r *Record) View() View {
 		SessionID:       r.ID,
 		State:           r.State,
 		WorkDir:         r.WorkDir,
+		GitRoot:         r.GitRoot,
 		Sandbox:         r.Sandbox,
 		StartedAt:       r.StartedAt.UTC().Format(time.RFC3339),
 		ExecutionTimeMs: r.ExecutionTimeMs,
 		ToolCallCount:   r.ToolCallCount,
+		TurnCount:       r.nextTurnIndex,
 		Error:           r.Error,
+		Title:           r.Title,
+		Labels:          copyLabels(r.Labels),
+		ParentID:        r.ParentID,
+	}
+	if len(r.Children) > 0 {
+		v.Children = append([]string(nil), r.Children...)
+	}
+	if !r.budget.IsZero() {
+		s := newBudgetStatus(r.budget, r.budgetUsage())
+		v.Budget = &s
+	}
+	if t := r.currentTurn(); t != nil {
+		v.Model = t.Model
 	}
 	if r.EndedAt != nil {
 		v.EndedAt = r.EndedAt.UTC().Format(time.RFC3339)
 	}
+	if r.suspendedAt != nil {
+		v.SuspendedAt = r.suspendedAt.UTC().Format(time.RFC3339)
+	}
 	return v
 }
 
@@ -105,6 +210,8 @@ type DetailView struct {
 	LastOutputAt  string                 `json:"last_output_at,omitempty"`
 	Recent        []DiagnosticEntryView  `json:"recent_entries,omitempty"`
 	ChangeReceipt *receipt.ChangeReceipt `json:"change_receipt,omitempty"`
+	// Timings are the phase timings of the latest run.
+	Timings *TimingsView `json:"timings,omitempty"`
 }
 
 func (r *Record) DetailView(limit int) DetailView {
@@ -120,6 +227,9 @@ func (r *Record) DetailView(limit int) DetailView {
 		Recent:        entries,
 		ChangeReceipt: r.ChangeReceipt,
 	}
+	if t := r.currentTurn(); t != nil {
+		dv.Timings = t.Timings.View()
+	}
 	if r.lastEventAt != nil {
 		dv.LastEventAt = r.lastEventAt.UTC().Format(time.RFC3339)
 	}
@@ -133,6 +243,18 @@ type Manager struct {
 	mu       sync.Mutex
 	opts     Options
 	sessions map[string]*Record
+	aliases  map[string]string
+
+	// queue holds sessions waiting for a slot, in arrival order.
+	queue    []*waiter
+	queueSeq uint64
+
+	// storeOps and diagOps hold store and diagnostics log writes recorded under mu, in
+	// order. They are applied by flushWrites once mu is released, so file I/O never
+	// holds mu; flushMu keeps a single flusher so the order is preserved.
+	storeOps []storeOp
+	diagOps  []diagLogOp
+	flushMu  sync.Mutex
 }
 
 func NewManager(opts Options) *Manager {
@@ -148,9 +270,13 @@ func NewManager(opts Options) *Manager {
 	if opts.DiagnosticsMaxEntryBytes == 0 {
 		opts.DiagnosticsMaxEntryBytes = DefaultOptions().DiagnosticsMaxEntryBytes
 	}
+	if opts.MaxTurns == 0 {
+		opts.MaxTurns = DefaultOptions().MaxTurns
+	}
 	return &Manager{
 		opts:     opts,
 		sessions: make(map[string]*Record),
+		aliases:  make(map[string]string),
 	}
 }
 
@@ -162,47 +288,17 @@ func NewTemporaryID() string {
 	return fmt.Sprintf("tmp_%d", time.Now().UnixNano())
 }
 
+// Start registers a running session, waiting in the queue (if configured) for a free
+// slot. See Enqueue for non-blocking admission with priorities and fair sharing.
 func (m *Manager) Start(sessionID string, workDir string, sandbox string, cancel context.CancelFunc) (*Record, error) {
-	if stringsTrim(sessionID) == "" {
-		return nil, cerrors.ErrInvalidParams("SESSION_ID is required")
-	}
-	if cancel == nil {
-		return nil, cerrors.ErrInvalidParams("cancel func is required")
-	}
-
-	now := time.Now()
-
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.cleanupExpiredLocked(now)
-
-	if r, ok := m.sessions[sessionID]; ok && r.State == StateRunning {
-		return nil, cerrors.ErrInvalidParams("session is already running")
-	}
-
-	running := 0
-	for _, r := range m.sessions {
-		if r.State == StateRunning {
-			running++
-		}
-	}
-	if m.opts.MaxRunning > 0 && running >= m.opts.MaxRunning {
-		return nil, cerrors.New(cerrors.SessionLimitExceeded, "too many concurrent sessions").
-			WithData("max_running", m.opts.MaxRunning).
-			WithData("running", running)
+	t, err := m.Enqueue(sessionID, workDir, sandbox, cancel, QueueOptions{})
+	if err != nil {
+		return nil, err
 	}
-
-	rec := &Record{
-		ID:        sessionID,
-		State:     StateRunning,
-		WorkDir:   workDir,
-		Sandbox:   sandbox,
-		StartedAt: now,
-		cancel:    cancel,
+	if err := t.Wait(context.Background(), nil); err != nil {
+		return nil, err
 	}
-	m.sessions[sessionID] = rec
-	return rec, nil
+	return t.rec, nil
 }
 
 func (m *Manager) UpdateID(oldID string, newID string) (bool, error) {
@@ -212,6 +308,7 @@ func (m *Manager) UpdateID(oldID string, newID string) (bool, error) {
 		return false, nil
 	}
 
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -225,7 +322,12 @@ func (m *Manager) UpdateID(oldID string, newID string) (bool, error) {
 
 	delete(m.sessions, oldID)
 	rec.ID = newID
+	rec.aliases = append(rec.aliases, oldID)
 	m.sessions[newID] = rec
+	m.addAliasesLocked(rec)
+	m.deletePersistedLocked(oldID)
+	m.persistLocked(rec)
+	m.queueDiagnosticsLogLocked(diagLogOp{id: oldID, renameTo: newID})
 	return true, nil
 }
 
@@ -251,6 +353,7 @@ func (m *Manager) SetChangeReceipt(sessionID string, receipt receipt.ChangeRecei
 		return false
 	}
 
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -260,10 +363,169 @@ func (m *Manager) SetChangeReceipt(sessionID string, receipt receipt.ChangeRecei
 	}
 	r := receipt
 	rec.ChangeReceipt = &r
+	if t := rec.currentTurn(); t != nil {
+		t.ChangeReceipt = &r
+	}
+	m.persistLocked(rec)
+	m.emitLocked(rec, EventReceiptReady, time.Now())
 	return true
 }
 
+// SetGitRoot records the git repository root containing the session's cd.
+func (m *Manager) SetGitRoot(sessionID string, root string) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.sessions[sessionID]
+	if !ok {
+		return false
+	}
+	rec.GitRoot = root
+	if rec.origin.GitRoot == "" && rec.WorkDir == rec.origin.WorkDir {
+		rec.origin.GitRoot = root
+	}
+	m.persistLocked(rec)
+	return true
+}
+
+// BeginTurn appends a turn to the session's history and returns its index.
+func (m *Manager) BeginTurn(sessionID string, start TurnStart) (int, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return 0, false
+	}
+
+	now := time.Now()
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.sessions[sessionID]
+	if !ok {
+		return 0, false
+	}
+	t := newTurn(rec.nextTurnIndex, start, now)
+	rec.nextTurnIndex++
+	rec.turns = append(rec.turns, t)
+	if max := m.opts.MaxTurns; max > 0 && len(rec.turns) > max {
+		rec.turns = rec.turns[len(rec.turns)-max:]
+	}
+	m.persistLocked(rec)
+	return t.Index, true
+}
+
+// SetTurnResult records the final agent message and token usage of the current turn.
+func (m *Manager) SetTurnResult(sessionID string, agentMessage string, usage *Usage) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.sessions[sessionID]
+	if !ok {
+		return false
+	}
+	t := rec.currentTurn()
+	if t == nil {
+		return false
+	}
+	t.AgentMessage = truncate(agentMessage, turnAgentMessageMaxSize)
+	if usage != nil {
+		if t.Usage != nil {
+			rec.usedTokens -= t.Usage.Total()
+		}
+		u := *usage
+		t.Usage = &u
+		rec.usedTokens += u.Total()
+	}
+	m.persistLocked(rec)
+	return true
+}
+
+// History returns up to limit turns starting at turn index offset (oldest first).
+// total counts every turn ever started; turns older than the retention bound are gone,
+// in which case the page starts at the oldest retained turn.
+func (m *Manager) History(sessionID string, offset int, limit int) (turns []TurnView, total int, nextOffset int, found bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return nil, 0, offset, false
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, 0, offset, false
+	}
+	total = rec.nextTurnIndex
+	nextOffset = offset
+	turns = make([]TurnView, 0, limit)
+	for _, t := range rec.turns {
+		if t.Index < offset {
+			continue
+		}
+		turns = append(turns, t.View())
+		nextOffset = t.Index + 1
+		if len(turns) >= limit {
+			break
+		}
+	}
+	return turns, total, nextOffset, true
+}
+
+// currentTurn returns the most recent turn, if any.
+func (r *Record) currentTurn() *Turn {
+	if len(r.turns) == 0 {
+		return nil
+	}
+	return &r.turns[len(r.turns)-1]
+}
+
+// endTurn closes the current turn with the record's final state. Time spent suspended
+// does not count against the execution budget.
+func (r *Record) endTurn(now time.Time) {
+	t := r.currentTurn()
+	if t == nil || t.State != StateRunning {
+		return
+	}
+	if r.suspendedAt != nil {
+		t.SuspendedMs += now.Sub(*r.suspendedAt).Milliseconds()
+	}
+	t.State = r.State
+	t.Error = r.Error
+	t.EndedAt = &now
+	t.DurationMs = now.Sub(t.StartedAt).Milliseconds()
+	t.ToolCallCount = r.ToolCallCount
+	r.usedExecutionMs += max(t.DurationMs-t.SuspendedMs, 0)
+}
+
 func (m *Manager) AppendDiagnostic(sessionID string, kind DiagnosticKind, message string) bool {
+	return m.AppendDiagnosticLevel(sessionID, kind, "", message)
+}
+
+// AppendDiagnosticLevel is AppendDiagnostic with a severity level (e.g. for DiagnosticStderr).
+func (m *Manager) AppendDiagnosticLevel(sessionID string, kind DiagnosticKind, level string, message string) bool {
 	sessionID = stringsTrim(sessionID)
 	if sessionID == "" {
 		return false
@@ -271,6 +533,7 @@ func (m *Manager) AppendDiagnostic(sessionID string, kind DiagnosticKind, messag
 
 	now := time.Now()
 
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -284,8 +547,13 @@ func (m *Manager) AppendDiagnostic(sessionID string, kind DiagnosticKind, messag
 		Seq:     rec.diagNextSeq,
 		At:      now,
 		Kind:    kind,
-		Message: truncate(message, m.opts.DiagnosticsMaxEntryBytes),
+		Level:   level,
+		Message: message,
 	}
+	full := entry
+	m.queueDiagnosticsLogLocked(diagLogOp{id: rec.ID, entry: &full})
+	entry.Message = truncate(message, m.opts.DiagnosticsMaxEntryBytes)
+	entry.Truncated = len(entry.Message) < len(message)
 	rec.diagnostics = append(rec.diagnostics, entry)
 	if max := m.opts.DiagnosticsMaxEntries; max > 0 && len(rec.diagnostics) > max {
 		rec.diagnostics = rec.diagnostics[len(rec.diagnostics)-max:]
@@ -297,10 +565,27 @@ func (m *Manager) AppendDiagnostic(sessionID string, kind DiagnosticKind, messag
 	return true
 }
 
-func (m *Manager) TailDiagnostics(sessionID string, cursor uint64, limit int) (entries []DiagnosticEntryView, nextCursor uint64, dropped bool, droppedBefore uint64, state State, found bool) {
+// DiagnosticsTail is a page of diagnostic entries returned by TailDiagnostics.
+type DiagnosticsTail struct {
+	Entries    []DiagnosticEntryView
+	NextCursor uint64
+	// Dropped reports that entries after the cursor are gone; DroppedBefore is the
+	// oldest seq still available.
+	Dropped       bool
+	DroppedBefore uint64
+	// FromDisk reports that the entries were read from the diagnostics log.
+	FromDisk bool
+	State    State
+	Found    bool
+}
+
+// TailDiagnostics returns up to limit entries with seq > cursor. Entries older than
+// those held in memory, and untruncated entries when full is set, are read from the
+// diagnostics log when one is configured.
+func (m *Manager) TailDiagnostics(sessionID string, cursor uint64, limit int, full bool) DiagnosticsTail {
 	sessionID = stringsTrim(sessionID)
 	if sessionID == "" {
-		return nil, cursor, false, 0, "", false
+		return DiagnosticsTail{NextCursor: cursor}
 	}
 
 	if limit <= 0 {
@@ -311,43 +596,81 @@ func (m *Manager) TailDiagnostics(sessionID string, cursor uint64, limit int) (e
 	}
 
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	rec, ok := m.sessions[sessionID]
+	rec, ok := m.lookupLocked(sessionID)
 	if !ok {
-		return nil, cursor, false, 0, "", false
+		m.mu.Unlock()
+		return DiagnosticsTail{NextCursor: cursor}
 	}
-	state = rec.State
-	found = true
+	id := rec.ID
+	out := tailMemory(rec.diagnostics, cursor, limit)
+	out.State = rec.State
+	out.Found = true
+	inMemory := uint64(0)
+	if len(rec.diagnostics) > 0 {
+		inMemory = rec.diagnostics[0].Seq
+	}
+	m.mu.Unlock()
 
-	if len(rec.diagnostics) == 0 {
-		return nil, cursor, false, 0, state, true
+	diagLog := m.opts.DiagnosticsLog
+	if diagLog == nil || !(full || cursor+1 < inMemory) {
+		return out
+	}
+	m.flushWrites()
+	entries, oldest, err := diagLog.Read(id, cursor, limit)
+	if err != nil || oldest == 0 {
+		return out
 	}
 
-	oldest := rec.diagnostics[0].Seq
+	disk := DiagnosticsTail{State: out.State, Found: true, FromDisk: true, NextCursor: cursor}
+	if cursor+1 < oldest {
+		disk.Dropped = true
+		disk.DroppedBefore = oldest
+	}
+	disk.Entries = make([]DiagnosticEntryView, 0, len(entries))
+	for _, e := range entries {
+		if !full {
+			msg := truncate(e.Message, m.opts.DiagnosticsMaxEntryBytes)
+			e.Truncated = len(msg) < len(e.Message)
+			e.Message = msg
+		}
+		disk.Entries = append(disk.Entries, e.View())
+	}
+	if len(disk.Entries) > 0 {
+		disk.NextCursor = disk.Entries[len(disk.Entries)-1].Seq
+	}
+	return disk
+}
+
+func tailMemory(diagnostics []DiagnosticEntry, cursor uint64, limit int) DiagnosticsTail {
+	out := DiagnosticsTail{NextCursor: cursor}
+	if len(diagnostics) == 0 {
+		return out
+	}
+
+	oldest := diagnostics[0].Seq
 	if cursor != 0 && cursor < oldest {
-		dropped = true
-		droppedBefore = oldest
+		out.Dropped = true
+		out.DroppedBefore = oldest
 		// Reset cursor so the client can resume from what we still have.
 		cursor = oldest - 1
 	}
 
-	entries = make([]DiagnosticEntryView, 0, limit)
-	for _, e := range rec.diagnostics {
+	out.Entries = make([]DiagnosticEntryView, 0, limit)
+	for _, e := range diagnostics {
 		if e.Seq <= cursor {
 			continue
 		}
-		entries = append(entries, e.View())
-		if len(entries) >= limit {
+		out.Entries = append(out.Entries, e.View())
+		if len(out.Entries) >= limit {
 			break
 		}
 	}
 
-	nextCursor = cursor
-	if len(entries) > 0 {
-		nextCursor = entries[len(entries)-1].Seq
+	out.NextCursor = cursor
+	if len(out.Entries) > 0 {
+		out.NextCursor = out.Entries[len(out.Entries)-1].Seq
 	}
-	return entries, nextCursor, dropped, droppedBefore, state, true
+	return out
 }
 
 func (m *Manager) GetDetail(sessionID string, recentLimit int) (DetailView, bool) {
@@ -374,6 +697,7 @@ func (m *Manager) Cancel(sessionID string) (bool, error) {
 
 	now := time.Now()
 
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -381,19 +705,35 @@ func (m *Manager) Cancel(sessionID string) (bool, error) {
 	if !ok {
 		return false, cerrors.New(cerrors.SessionNotFound, "session not found").WithData("SESSION_ID", sessionID)
 	}
-	if rec.State != StateRunning {
+	if !rec.State.Active() && rec.State != StateQueued {
 		return false, nil
 	}
+	if rec.State == StateQueued {
+		for _, w := range m.queue {
+			if w.rec == rec {
+				m.removeWaiterLocked(w)
+				close(w.removed)
+				break
+			}
+		}
+	}
 
 	rec.State = StateCancelled
 	rec.Error = "cancel requested"
 	rec.ExecutionTimeMs = 0
 	rec.ToolCallCount = 0
 	rec.EndedAt = &now
+	rec.endTurn(now)
 	if rec.cancel != nil {
+		// Killing the process tree also ends a suspended (stopped) codex.
 		rec.cancel()
 		rec.cancel = nil
 	}
+	rec.control = nil
+	rec.suspendedAt = nil
+	m.persistLocked(rec)
+	m.emitLocked(rec, EventCancelled, now)
+	m.dispatchLocked()
 	return true, nil
 }
 
@@ -432,6 +772,7 @@ func (m *Manager) List() []View {
 }
 
 func (m *Manager) CleanupExpired(now time.Time) int {
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return m.cleanupExpiredLocked(now)
@@ -443,11 +784,15 @@ func (m *Manager) cleanupExpiredLocked(now time.Time) int {
 	}
 	removed := 0
 	for id, r := range m.sessions {
-		if r.State == StateRunning || r.EndedAt == nil {
+		if r.State.Active() || r.EndedAt == nil {
 			continue
 		}
 		if now.Sub(*r.EndedAt) > m.opts.TTL {
+			m.dropAliasesLocked(r)
 			delete(m.sessions, id)
+			m.deletePersistedLocked(id)
+			m.removeDiagnosticsLogLocked(id)
+			m.expireLocked(r)
 			removed++
 		}
 	}
@@ -483,6 +828,7 @@ func (m *Manager) finish(sessionID string, state State, errMsg string, execution
 
 	now := time.Now()
 
+	defer m.flushWrites()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -493,6 +839,7 @@ func (m *Manager) finish(sessionID string, state State, errMsg string, execution
 
 	// Don't override an explicit cancellation (e.g. cancel_session).
 	if rec.State == StateCancelled {
+		m.dispatchLocked()
 		return true
 	}
 
@@ -502,11 +849,185 @@ func (m *Manager) finish(sessionID string, state State, errMsg string, execution
 	rec.ToolCallCount = toolCallCount
 	rec.EndedAt = &now
 	rec.cancel = nil
+	rec.control = nil
+	rec.endTurn(now)
+	rec.suspendedAt = nil
+	m.persistLocked(rec)
+	if typ, ok := finishEvent(state); ok {
+		m.emitLocked(rec, typ, now)
+	}
 
 	m.cleanupExpiredLocked(now)
+	m.dispatchLocked()
 	return true
 }
 
+// Restore loads persisted records from the store. Sessions that were still running
+// when the previous server process stopped are marked StateInterrupted; expired
+// records are dropped. It returns the number of restored records.
+func (m *Manager) Restore(now time.Time) (int, error) {
+	if m == nil || m.opts.Store == nil {
+		return 0, nil
+	}
+	snaps, err := m.opts.Store.Load()
+	if err != nil {
+		return 0, err
+	}
+	// A crash can leave the log ahead of the persisted numbering; continue after it
+	// so a resumed session neither repeats nor (by restarting) discards those entries.
+	lastSeq := make(map[string]uint64)
+	if m.opts.DiagnosticsLog != nil {
+		for _, snap := range snaps {
+			if seq, err := m.opts.DiagnosticsLog.LastSeq(snap.ID); err == nil && seq > 0 {
+				lastSeq[snap.ID] = seq
+			}
+		}
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restored := 0
+	for _, snap := range snaps {
+		if snap.ID == "" {
+			continue
+		}
+		if _, exists := m.sessions[snap.ID]; exists {
+			continue
+		}
+		rec := recordFromSnapshot(snap)
+		rec.diagNextSeq = max(rec.diagNextSeq, lastSeq[rec.ID])
+		if rec.State.Active() || rec.State == StateQueued {
+			rec.State = StateInterrupted
+			rec.Error = "server stopped while the session was running"
+			rec.EndedAt = &now
+			rec.endTurn(now)
+			m.persistLocked(rec)
+		}
+		if m.opts.TTL >= 0 && rec.EndedAt != nil && now.Sub(*rec.EndedAt) > m.opts.TTL {
+			m.deletePersistedLocked(rec.ID)
+			m.removeDiagnosticsLogLocked(rec.ID)
+			m.expireLocked(rec)
+			continue
+		}
+		m.sessions[rec.ID] = rec
+		m.addAliasesLocked(rec)
+		restored++
+	}
+	return restored, nil
+}
+
+// Close releases the store, if any.
+func (m *Manager) Close() error {
+	if m == nil || m.opts.Store == nil {
+		return nil
+	}
+	m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.opts.Store.Close()
+}
+
+// storeOp is one pending store write: a put when snap is set, otherwise a delete of id.
+type storeOp struct {
+	id   string
+	snap *Snapshot
+}
+
+// persistLocked queues a write of rec to the store (best-effort: persistence failures
+// never fail a run). Receipt diffs are capped and the result is stored in its reduced
+// form, so a session with many turns stays small on disk. Callers must flush after
+// releasing mu.
+func (m *Manager) persistLocked(rec *Record) {
+	if m.opts.Store == nil || rec == nil {
+		return
+	}
+	snap := rec.snapshot()
+	snap.ChangeReceipt = snap.ChangeReceipt.Capped(PersistedReceiptTextBytes)
+	for i := range snap.Turns {
+		snap.Turns[i].ChangeReceipt = snap.Turns[i].ChangeReceipt.Capped(PersistedReceiptTextBytes)
+	}
+	if snap.Result != nil && snap.Result.StoredOutput != nil {
+		r := *snap.Result
+		r.Output, r.StoredOutput = r.StoredOutput, nil
+		snap.Result = &r
+	}
+	m.storeOps = append(m.storeOps, storeOp{id: snap.ID, snap: &snap})
+}
+
+// deletePersistedLocked queues the removal of id from the store. Callers must flush
+// after releasing mu.
+func (m *Manager) deletePersistedLocked(id string) {
+	if m.opts.Store == nil {
+		return
+	}
+	m.storeOps = append(m.storeOps, storeOp{id: id})
+}
+
+// expireLocked reports an expired session to Options.OnExpire.
+func (m *Manager) expireLocked(rec *Record) {
+	if m.opts.OnExpire != nil {
+		m.opts.OnExpire(rec.snapshot())
+	}
+}
+
+// diagLogOp is one pending diagnostics log write: an append when entry is set, a rename
+// when renameTo is set, otherwise a removal.
+type diagLogOp struct {
+	id       string
+	entry    *DiagnosticEntry
+	renameTo string
+}
+
+func (m *Manager) removeDiagnosticsLogLocked(id string) {
+	m.queueDiagnosticsLogLocked(diagLogOp{id: id})
+}
+
+// queueDiagnosticsLogLocked records op for the next flushWrites. Callers must flush
+// after releasing mu.
+func (m *Manager) queueDiagnosticsLogLocked(op diagLogOp) {
+	if m.opts.DiagnosticsLog == nil {
+		return
+	}
+	m.diagOps = append(m.diagOps, op)
+}
+
+// flushWrites applies the queued store and diagnostics log writes. It must be called
+// without mu held. Writes are best-effort: a failing disk never fails the run.
+func (m *Manager) flushWrites() {
+	if m.opts.Store == nil && m.opts.DiagnosticsLog == nil {
+		return
+	}
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	storeOps := m.storeOps
+	diagOps := m.diagOps
+	m.storeOps = nil
+	m.diagOps = nil
+	m.mu.Unlock()
+
+	for _, op := range storeOps {
+		if op.snap != nil {
+			_ = m.opts.Store.Put(*op.snap)
+		} else {
+			_ = m.opts.Store.Delete(op.id)
+		}
+	}
+	for _, op := range diagOps {
+		switch {
+		case op.entry != nil:
+			_ = m.opts.DiagnosticsLog.Append(op.id, *op.entry)
+		case op.renameTo != "":
+			_ = m.opts.DiagnosticsLog.Rename(op.id, op.renameTo)
+		default:
+			_ = m.opts.DiagnosticsLog.Remove(op.id)
+		}
+	}
+}
+
 func stringsTrim(s string) string {
 	return strings.TrimSpace(s)
 }
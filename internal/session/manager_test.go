@@ -134,3 +134,111 @@ func TestManager_CleanupExpired(t *testing.T) {
 		t.Fatalf("session should be cleaned up")
 	}
 }
+
+func TestManager_TurnHistoryAcrossResume(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Minute})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := m.Start("s1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if idx, ok := m.BeginTurn("s1", TurnStart{Prompt: "first prompt", Sandbox: "read-only"}); !ok || idx != 0 {
+		t.Fatalf("BeginTurn()=(%d,%v), want (0,true)", idx, ok)
+	}
+	m.SetTurnResult("s1", "first answer", &Usage{InputTokens: 10, OutputTokens: 5})
+	m.MarkCompleted("s1", 100, 1)
+
+	if _, err := m.Start("s1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() resume failed: %v", err)
+	}
+	if idx, ok := m.BeginTurn("s1", TurnStart{Prompt: "second prompt"}); !ok || idx != 1 {
+		t.Fatalf("BeginTurn()=(%d,%v), want (1,true)", idx, ok)
+	}
+	m.MarkFailed("s1", stderrors.New("boom"))
+
+	if got, _ := m.Get("s1"); got.TurnCount != 2 {
+		t.Fatalf("turn_count=%d, want 2", got.TurnCount)
+	}
+
+	turns, total, next, found := m.History("s1", 0, 1)
+	if !found || total != 2 || len(turns) != 1 || next != 1 {
+		t.Fatalf("History(0,1)=(len %d,total %d,next %d,found %v)", len(turns), total, next, found)
+	}
+	first := turns[0]
+	if first.State != StateCompleted || first.PromptPreview != "first prompt" || first.AgentMessage != "first answer" {
+		t.Fatalf("unexpected first turn: %+v", first)
+	}
+	if first.Usage == nil || first.Usage.InputTokens != 10 || first.Usage.OutputTokens != 5 {
+		t.Fatalf("unexpected usage: %+v", first.Usage)
+	}
+
+	turns, _, next, _ = m.History("s1", 1, 10)
+	if len(turns) != 1 || next != 2 {
+		t.Fatalf("History(1,10)=(len %d,next %d)", len(turns), next)
+	}
+	if turns[0].State != StateFailed || turns[0].Error != "boom" {
+		t.Fatalf("unexpected second turn: %+v", turns[0])
+	}
+
+	if _, _, _, found := m.History("missing", 0, 10); found {
+		t.Fatalf("History() should not find unknown session")
+	}
+}
+
+// blockingStore holds every Put until release is closed.
+type blockingStore struct {
+	entered chan string
+	release chan struct{}
+}
+
+func (s *blockingStore) Load() ([]Snapshot, error) { return nil, nil }
+func (s *blockingStore) Delete(string) error       { return nil }
+func (s *blockingStore) Close() error              { return nil }
+
+func (s *blockingStore) Put(snap Snapshot) error {
+	select {
+	case s.entered <- snap.ID:
+	default:
+	}
+	<-s.release
+	return nil
+}
+
+func TestManager_StoreWritesDoNotHoldLock(t *testing.T) {
+	store := &blockingStore{entered: make(chan string, 1), release: make(chan struct{})}
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Minute, Store: store})
+
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Start("s1", "/tmp", "read-only", cancel)
+		done <- err
+	}()
+
+	select {
+	case <-store.entered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("store was not written")
+	}
+	got := make(chan bool, 1)
+	go func() {
+		_, ok := m.Get("s1")
+		got <- ok
+	}()
+	select {
+	case ok := <-got:
+		if !ok {
+			t.Fatalf("Get() did not find the session")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Get() blocked while the store was writing")
+	}
+
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+}
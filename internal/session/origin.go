@@ -0,0 +1,27 @@
+package session
+
+// Origin is where a thread was first started. Resumes default to it, and the codex
+// tool rejects resumes elsewhere unless explicitly allowed.
+type Origin struct {
+	WorkDir string `json:"cd"`
+	GitRoot string `json:"git_root,omitempty"`
+	Sandbox string `json:"sandbox"`
+}
+
+// Origin returns the original workdir, git root and sandbox of a session. sessionID
+// may be an alias.
+func (m *Manager) Origin(sessionID string) (Origin, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return Origin{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return Origin{}, false
+	}
+	return rec.origin, true
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManager_OriginSurvivesResumeAndRestore(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Hour})
+	if _, err := m.Start("tmp", "/repo/sub", "read-only", func() {}); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.SetGitRoot("tmp", "/repo")
+	m.UpdateID("tmp", "thread")
+	m.MarkCompleted("thread", 1, 0)
+
+	if _, err := m.Start("thread", "/elsewhere", "workspace-write", func() {}); err != nil {
+		t.Fatalf("Start(resume) failed: %v", err)
+	}
+	m.SetGitRoot("thread", "")
+	want := Origin{WorkDir: "/repo/sub", GitRoot: "/repo", Sandbox: "read-only"}
+	if o, ok := m.Origin("tmp"); !ok || o != want {
+		t.Fatalf("Origin()=%+v, %v, want %+v", o, ok, want)
+	}
+
+	snap, _ := m.Snapshot("thread")
+	if got := recordFromSnapshot(snap).origin; got != want {
+		t.Fatalf("restored origin=%+v, want %+v", got, want)
+	}
+	// Records stored before origins were tracked fall back to their last run.
+	snap.Origin = Origin{}
+	if got := recordFromSnapshot(snap).origin; got.WorkDir != "/elsewhere" || got.Sandbox != "workspace-write" {
+		t.Fatalf("legacy origin=%+v", got)
+	}
+}
@@ -0,0 +1,244 @@
+package session
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// ValidStates lists every session state, for input validation.
+var ValidStates = []State{StateQueued, StateRunning, StateSuspended, StateCompleted, StateFailed, StateCancelled, StateInterrupted}
+
+// ListOrder is the sort order used by Query.
+type ListOrder string
+
+const (
+	OrderStartedDesc ListOrder = "started_desc"
+	OrderStartedAsc  ListOrder = "started_asc"
+)
+
+// ValidListOrders lists the orders accepted by Query.
+var ValidListOrders = []ListOrder{OrderStartedDesc, OrderStartedAsc}
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// ListFilter selects sessions in Query. Zero-valued fields match everything.
+type ListFilter struct {
+	States []State
+	// WorkDir matches sessions whose cd is the directory or below it.
+	WorkDir string
+	GitRoot string
+	Sandbox string
+	// Model matches the model of any turn in the session.
+	Model string
+	// Labels must all be present with equal values ("*" matches any value).
+	Labels map[string]string
+	// Tags are label keys that must all be present, with any value.
+	Tags []string
+
+	StartedAfter  time.Time
+	StartedBefore time.Time
+
+	// Query is a case-insensitive substring match on the title, prompt previews and the error.
+	Query string
+}
+
+// ListPage controls ordering and pagination in Query.
+type ListPage struct {
+	Order ListOrder
+	// Cursor is the opaque NextCursor of a previous page ("" = first page).
+	Cursor string
+	Limit  int
+}
+
+// Query returns one page of sessions matching filter, the total number of matches
+// and the cursor for the next page ("" when there are no more results).
+// Cursors are keyset-based, so sessions added between calls do not shift pages.
+func (m *Manager) Query(filter ListFilter, page ListPage) (views []View, total int, nextCursor string, err error) {
+	if page.Order == "" {
+		page.Order = OrderStartedDesc
+	}
+	if page.Order != OrderStartedDesc && page.Order != OrderStartedAsc {
+		return nil, 0, "", cerrors.ErrInvalidParams("invalid order").
+			WithData("order", string(page.Order)).
+			WithData("valid", ValidListOrders)
+	}
+	if page.Limit <= 0 {
+		page.Limit = defaultListLimit
+	}
+	if page.Limit > maxListLimit {
+		page.Limit = maxListLimit
+	}
+	var after *listCursor
+	if page.Cursor != "" {
+		c, err := decodeListCursor(page.Cursor)
+		if err != nil || c.order != page.Order {
+			return nil, 0, "", cerrors.ErrInvalidParams("invalid cursor").WithData("cursor", page.Cursor)
+		}
+		after = &c
+	}
+	filter.WorkDir = cleanFilterPath(filter.WorkDir)
+	filter.GitRoot = cleanFilterPath(filter.GitRoot)
+	filter.Query = strings.ToLower(strings.TrimSpace(filter.Query))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	matched := make([]*Record, 0, len(m.sessions))
+	for _, r := range m.sessions {
+		if filter.matches(r) {
+			matched = append(matched, r)
+		}
+	}
+	less := func(a, b listCursor) bool {
+		if !a.started.Equal(b.started) {
+			if page.Order == OrderStartedAsc {
+				return a.started.Before(b.started)
+			}
+			return a.started.After(b.started)
+		}
+		return a.id < b.id
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(cursorOf(matched[i], page.Order), cursorOf(matched[j], page.Order))
+	})
+
+	total = len(matched)
+	views = make([]View, 0, page.Limit)
+	for i, r := range matched {
+		if after != nil && !less(*after, cursorOf(r, page.Order)) {
+			continue
+		}
+		if len(views) == page.Limit {
+			nextCursor = encodeListCursor(cursorOf(matched[i-1], page.Order))
+			break
+		}
+		views = append(views, r.View())
+	}
+	return views, total, nextCursor, nil
+}
+
+func (f ListFilter) matches(r *Record) bool {
+	if len(f.States) > 0 {
+		ok := false
+		for _, s := range f.States {
+			if r.State == s {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.WorkDir != "" && !withinDir(filepath.Clean(r.WorkDir), f.WorkDir) {
+		return false
+	}
+	if f.GitRoot != "" && filepath.Clean(r.GitRoot) != f.GitRoot {
+		return false
+	}
+	if f.Sandbox != "" && r.Sandbox != f.Sandbox {
+		return false
+	}
+	if f.Model != "" {
+		ok := false
+		for _, t := range r.turns {
+			if t.Model == f.Model {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for k, want := range f.Labels {
+		got, ok := r.Labels[k]
+		if !ok || (want != "*" && got != want) {
+			return false
+		}
+	}
+	for _, k := range f.Tags {
+		if _, ok := r.Labels[k]; !ok {
+			return false
+		}
+	}
+	if !f.StartedAfter.IsZero() && r.StartedAt.Before(f.StartedAfter) {
+		return false
+	}
+	if !f.StartedBefore.IsZero() && !r.StartedAt.Before(f.StartedBefore) {
+		return false
+	}
+	if f.Query != "" {
+		if strings.Contains(strings.ToLower(r.Title), f.Query) || strings.Contains(strings.ToLower(r.Error), f.Query) {
+			return true
+		}
+		for _, t := range r.turns {
+			if strings.Contains(strings.ToLower(t.PromptPreview), f.Query) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func cleanFilterPath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+	return filepath.Clean(p)
+}
+
+func withinDir(path string, dir string) bool {
+	if path == dir {
+		return true
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+type listCursor struct {
+	order   ListOrder
+	started time.Time
+	id      string
+}
+
+func cursorOf(r *Record, order ListOrder) listCursor {
+	return listCursor{order: order, started: r.StartedAt, id: r.ID}
+}
+
+// Cursor format (base64url): "v1|<order>|<started unix nanos>|<session id>".
+func encodeListCursor(c listCursor) string {
+	raw := fmt.Sprintf("v1|%s|%d|%s", c.order, c.started.UnixNano(), c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeListCursor(s string) (listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return listCursor{}, err
+	}
+	parts := strings.SplitN(string(b), "|", 4)
+	if len(parts) != 4 || parts[0] != "v1" {
+		return listCursor{}, fmt.Errorf("malformed cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return listCursor{}, err
+	}
+	return listCursor{order: ListOrder(parts[1]), started: time.Unix(0, nanos), id: parts[3]}, nil
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func newQueryTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManager(Options{MaxRunning: 10, TTL: time.Hour})
+	_, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sessions := []struct {
+		id      string
+		cd      string
+		gitRoot string
+		sandbox string
+		model   string
+		prompt  string
+		finish  func(id string)
+	}{
+		{"a", "/repo/one", "/repo", "read-only", "gpt-5", "fix the login bug", func(id string) { m.MarkCompleted(id, 1, 0) }},
+		{"b", "/repo/two", "/repo", "workspace-write", "o3", "add metrics", func(id string) { m.MarkFailed(id, stderrors.New("rate limited")) }},
+		{"c", "/other", "", "read-only", "gpt-5", "write docs", func(string) {}},
+	}
+	for i, s := range sessions {
+		if _, err := m.Start(s.id, s.cd, s.sandbox, cancel); err != nil {
+			t.Fatalf("Start(%s) failed: %v", s.id, err)
+		}
+		if s.gitRoot != "" {
+			m.SetGitRoot(s.id, s.gitRoot)
+		}
+		m.BeginTurn(s.id, TurnStart{Prompt: s.prompt, Sandbox: s.sandbox, Model: s.model})
+		s.finish(s.id)
+		m.mu.Lock()
+		m.sessions[s.id].StartedAt = base.Add(time.Duration(i) * time.Minute)
+		m.mu.Unlock()
+	}
+	for id, labels := range map[string]map[string]string{
+		"a": {"team": "auth", "urgent": "yes"},
+		"b": {"team": "infra"},
+	} {
+		if _, _, err := m.UpdateMetadata(id, MetadataUpdate{Set: labels}); err != nil {
+			t.Fatalf("UpdateMetadata(%s) failed: %v", id, err)
+		}
+	}
+	return m
+}
+
+func queryIDs(t *testing.T, m *Manager, f ListFilter, p ListPage) ([]string, int, string) {
+	t.Helper()
+	views, total, next, err := m.Query(f, p)
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	ids := make([]string, 0, len(views))
+	for _, v := range views {
+		ids = append(ids, v.SessionID)
+	}
+	return ids, total, next
+}
+
+func TestManager_QueryFilters(t *testing.T) {
+	m := newQueryTestManager(t)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   []string
+	}{
+		{"all", ListFilter{}, []string{"c", "b", "a"}},
+		{"state", ListFilter{States: []State{StateCompleted, StateFailed}}, []string{"b", "a"}},
+		{"cd subtree", ListFilter{WorkDir: "/repo"}, []string{"b", "a"}},
+		{"cd exact", ListFilter{WorkDir: "/repo/one/"}, []string{"a"}},
+		{"git root", ListFilter{GitRoot: "/repo"}, []string{"b", "a"}},
+		{"sandbox", ListFilter{Sandbox: "read-only"}, []string{"c", "a"}},
+		{"model", ListFilter{Model: "o3"}, []string{"b"}},
+		{"tags", ListFilter{Tags: []string{"team"}}, []string{"b", "a"}},
+		{"tags all", ListFilter{Tags: []string{"team", "urgent"}}, []string{"a"}},
+		{"time range", ListFilter{StartedAfter: base.Add(time.Minute), StartedBefore: base.Add(2 * time.Minute)}, []string{"b"}},
+		{"query prompt", ListFilter{Query: "LOGIN"}, []string{"a"}},
+		{"query error", ListFilter{Query: "rate limit"}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, _ := queryIDs(t, m, tt.filter, ListPage{})
+			if total != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("got %v (total %d), want %v", got, total, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_QueryPagination(t *testing.T) {
+	m := newQueryTestManager(t)
+
+	page := ListPage{Order: OrderStartedAsc, Limit: 2}
+	got, total, next := queryIDs(t, m, ListFilter{}, page)
+	if total != 3 || len(got) != 2 || got[0] != "a" || got[1] != "b" || next == "" {
+		t.Fatalf("first page=%v total=%d next=%q", got, total, next)
+	}
+
+	page.Cursor = next
+	got, total, next = queryIDs(t, m, ListFilter{}, page)
+	if total != 3 || len(got) != 1 || got[0] != "c" || next != "" {
+		t.Fatalf("second page=%v total=%d next=%q", got, total, next)
+	}
+
+	// A cursor is bound to the order it was issued for.
+	_, _, _, err := m.Query(ListFilter{}, ListPage{Order: OrderStartedDesc, Cursor: page.Cursor})
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) || cerr.Code != cerrors.InvalidParams {
+		t.Fatalf("expected InvalidParams for mismatched cursor, got %v", err)
+	}
+	if _, _, _, err := m.Query(ListFilter{}, ListPage{Cursor: "not-a-cursor"}); err == nil {
+		t.Fatalf("expected error for malformed cursor")
+	}
+	if _, _, _, err := m.Query(ListFilter{}, ListPage{Order: "newest"}); err == nil {
+		t.Fatalf("expected error for unknown order")
+	}
+}
@@ -0,0 +1,151 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// Result is the final outcome of a run. It is kept on the record until the session
+// is evicted (or replaced by the next run on the same thread).
+type Result struct {
+	// Output is the tool output of a successful run, as returned to the client.
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  *cerrors.Error  `json:"error,omitempty"`
+	// StoredOutput, when set, is written to the Store instead of Output, so a large run
+	// (full diffs, untracked file contents, all messages) does not bloat it. A result
+	// restored after a restart has it as Output.
+	StoredOutput json.RawMessage `json:"-"`
+}
+
+// SetResult stores the final outcome of the current run and wakes up Wait callers.
+func (m *Manager) SetResult(sessionID string, result Result) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return false
+	}
+	r := result
+	rec.result = &r
+	if rec.done != nil {
+		select {
+		case <-rec.done:
+		default:
+			close(rec.done)
+		}
+	}
+	m.persistLocked(rec)
+	return true
+}
+
+// Result returns the stored outcome of the session's last run (nil while it is still
+// running or when the outcome is unknown, e.g. after an interrupted restart).
+func (m *Manager) Result(sessionID string) (*Result, View, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return nil, View{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return nil, View{}, false
+	}
+	if rec.result == nil {
+		return nil, rec.View(), true
+	}
+	r := *rec.result
+	return &r, rec.View(), true
+}
+
+// Wait blocks until the session's current run has stored its result, or ctx is done.
+// It returns the latest view and whether the result is available.
+func (m *Manager) Wait(ctx context.Context, sessionID string) (view View, done bool, found bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return View{}, false, false
+	}
+
+	m.mu.Lock()
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		m.mu.Unlock()
+		return View{}, false, false
+	}
+	ch := rec.done
+	if ch == nil || rec.result != nil {
+		view, done = rec.View(), rec.result != nil || !rec.State.Active()
+		m.mu.Unlock()
+		return view, done, true
+	}
+	id := rec.ID
+	m.mu.Unlock()
+
+	select {
+	case <-ch:
+		done = true
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rec, ok := m.lookupLocked(id); ok {
+		return rec.View(), done, true
+	}
+	return View{}, done, false
+}
+
+// Resolve maps a session ID (including a temporary ID that was later replaced by the
+// real thread ID) to the current ID.
+func (m *Manager) Resolve(sessionID string) (string, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return "", false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return "", false
+	}
+	return rec.ID, true
+}
+
+// lookupLocked finds a record by ID or by a previous (temporary) ID.
+func (m *Manager) lookupLocked(sessionID string) (*Record, bool) {
+	if rec, ok := m.sessions[sessionID]; ok {
+		return rec, true
+	}
+	if target, ok := m.aliases[sessionID]; ok {
+		rec, ok := m.sessions[target]
+		return rec, ok
+	}
+	return nil, false
+}
+
+func (m *Manager) addAliasesLocked(rec *Record) {
+	for _, alias := range rec.aliases {
+		m.aliases[alias] = rec.ID
+	}
+}
+
+func (m *Manager) dropAliasesLocked(rec *Record) {
+	for _, alias := range rec.aliases {
+		if m.aliases[alias] == rec.ID {
+			delete(m.aliases, alias)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func TestManager_WaitAndResultFollowAliases(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, TTL: time.Minute})
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := m.Start("tmp_1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if ok, err := m.UpdateID("tmp_1", "thread-1"); err != nil || !ok {
+		t.Fatalf("UpdateID() ok=%v err=%v", ok, err)
+	}
+	if id, ok := m.Resolve("tmp_1"); !ok || id != "thread-1" {
+		t.Fatalf("Resolve(tmp_1)=(%q,%v), want thread-1", id, ok)
+	}
+
+	// Wait returns when ctx expires while the run is still going.
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer shortCancel()
+	if _, done, found := m.Wait(shortCtx, "tmp_1"); !found || done {
+		t.Fatalf("Wait() while running: done=%v found=%v", done, found)
+	}
+	if res, v, found := m.Result("tmp_1"); !found || res != nil || v.State != StateRunning {
+		t.Fatalf("Result() while running: res=%v state=%v found=%v", res, v.State, found)
+	}
+
+	waited := make(chan bool, 1)
+	go func() {
+		_, done, _ := m.Wait(context.Background(), "tmp_1")
+		waited <- done
+	}()
+	m.MarkCompleted("thread-1", 10, 0)
+	m.SetResult("thread-1", Result{Output: json.RawMessage(`{"success":true}`)})
+
+	select {
+	case done := <-waited:
+		if !done {
+			t.Fatalf("Wait() returned done=false after SetResult")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Wait() did not return after SetResult")
+	}
+	res, v, found := m.Result("tmp_1")
+	if !found || res == nil || string(res.Output) != `{"success":true}` || v.SessionID != "thread-1" {
+		t.Fatalf("Result()=(%v, %+v, %v)", res, v, found)
+	}
+
+	// A resumed run starts without a result and keeps the alias.
+	if _, err := m.Start("thread-1", "/tmp", "read-only", cancel); err != nil {
+		t.Fatalf("Start() resume failed: %v", err)
+	}
+	if res, _, _ := m.Result("tmp_1"); res != nil {
+		t.Fatalf("resumed run should not expose the previous result")
+	}
+	m.SetResult("thread-1", Result{Error: cerrors.ErrInvalidParams("boom")})
+	if res, _, _ := m.Result("thread-1"); res == nil || res.Error == nil || res.Error.Message != "boom" {
+		t.Fatalf("unexpected error result: %+v", res)
+	}
+
+	// Evicting the session drops its aliases.
+	m.MarkCompleted("thread-1", 1, 0)
+	m.CleanupExpired(time.Now().Add(time.Hour))
+	if _, ok := m.Resolve("tmp_1"); ok {
+		t.Fatalf("alias should be dropped with the session")
+	}
+}
@@ -0,0 +1,293 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// QueueOptions control how a session waits for a run slot when MaxRunning is reached.
+type QueueOptions struct {
+	// Priority orders waiters; higher runs first.
+	Priority int
+	// ShareKey groups sessions for fair sharing (e.g. by client or workdir). Within a
+	// priority, waiters whose group has fewer running sessions go first.
+	ShareKey string
+}
+
+// QueuePosition is a queued session's 1-based position among Queued waiters.
+type QueuePosition struct {
+	Position int
+	Queued   int
+}
+
+type waiter struct {
+	rec      *Record
+	seq      uint64
+	priority int
+	granted  chan struct{}
+	// removed is closed when the session is cancelled while queued.
+	removed chan struct{}
+	// position holds the latest position (buffered; newer values replace older ones).
+	position chan QueuePosition
+}
+
+// Ticket is a session admitted by Enqueue. Wait blocks until it may run.
+type Ticket struct {
+	m   *Manager
+	rec *Record
+	w   *waiter // nil when the session started immediately
+}
+
+// Queued reports whether the session had to wait for a slot.
+func (t *Ticket) Queued() bool {
+	return t != nil && t.w != nil
+}
+
+// Enqueue registers a run for sessionID. The session starts immediately when a slot is
+// free; otherwise it joins the bounded queue in StateQueued (see Ticket.Wait). When the
+// queue is disabled or full it fails with SessionLimitExceeded.
+func (m *Manager) Enqueue(sessionID string, workDir string, sandbox string, cancel context.CancelFunc, q QueueOptions) (*Ticket, error) {
+	if stringsTrim(sessionID) == "" {
+		return nil, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+	if cancel == nil {
+		return nil, cerrors.ErrInvalidParams("cancel func is required")
+	}
+
+	now := time.Now()
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanupExpiredLocked(now)
+
+	prev, hasPrev := m.sessions[sessionID]
+	if hasPrev && (prev.State.Active() || prev.State == StateQueued) {
+		return nil, cerrors.ErrInvalidParams("session is already running")
+	}
+
+	running := m.runningLocked()
+	full := m.opts.MaxRunning > 0 && (running >= m.opts.MaxRunning || len(m.queue) > 0)
+	if full && (m.opts.QueueSize <= 0 || len(m.queue) >= m.opts.QueueSize) {
+		return nil, cerrors.New(cerrors.SessionLimitExceeded, "too many concurrent sessions").
+			WithData("max_running", m.opts.MaxRunning).
+			WithData("running", running).
+			WithData("queue_size", m.opts.QueueSize).
+			WithData("queued", len(m.queue))
+	}
+
+	rec := &Record{
+		ID:        sessionID,
+		State:     StateRunning,
+		WorkDir:   workDir,
+		Sandbox:   sandbox,
+		StartedAt: now,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		shareKey:  q.ShareKey,
+	}
+	if hasPrev {
+		// Resuming a thread: keep its metadata and turn history.
+		rec.aliases = prev.aliases
+		rec.origin = prev.origin
+		rec.Title = prev.Title
+		rec.Labels = prev.Labels
+		rec.turns = prev.turns
+		rec.nextTurnIndex = prev.nextTurnIndex
+		rec.ParentID = prev.ParentID
+		rec.Children = prev.Children
+		rec.budget = prev.budget
+		rec.usedTokens = prev.usedTokens
+		rec.usedExecutionMs = prev.usedExecutionMs
+		// Keep numbering diagnostics so cursors stay valid across turns (older
+		// entries remain readable from the diagnostics log).
+		rec.diagNextSeq = prev.diagNextSeq
+	} else {
+		rec.origin = Origin{WorkDir: workDir, Sandbox: sandbox}
+		m.removeDiagnosticsLogLocked(sessionID)
+	}
+	t := &Ticket{m: m, rec: rec}
+	if full {
+		m.queueSeq++
+		t.w = &waiter{
+			rec:      rec,
+			seq:      m.queueSeq,
+			priority: q.Priority,
+			granted:  make(chan struct{}),
+			removed:  make(chan struct{}),
+			position: make(chan QueuePosition, 1),
+		}
+		rec.State = StateQueued
+		m.queue = append(m.queue, t.w)
+	}
+	m.sessions[sessionID] = rec
+	m.persistLocked(rec)
+	if full {
+		m.notifyPositionsLocked()
+	} else {
+		m.emitLocked(rec, EventStarted, now)
+	}
+	return t, nil
+}
+
+// Wait blocks until the session may run. onPosition (optional) is called from the
+// calling goroutine whenever the queue position changes. If ctx ends or the wait
+// exceeds Options.MaxQueueWait, the session leaves the queue and is marked cancelled
+// or failed respectively.
+func (t *Ticket) Wait(ctx context.Context, onPosition func(QueuePosition)) error {
+	if t == nil || t.w == nil {
+		return nil
+	}
+	w := t.w
+
+	var timeout <-chan time.Time
+	if t.m.opts.MaxQueueWait > 0 {
+		timer := time.NewTimer(t.m.opts.MaxQueueWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	queuedAt := time.Now()
+	// Always report the initial position, even if the slot is granted right away.
+	select {
+	case pos := <-w.position:
+		if onPosition != nil {
+			onPosition(pos)
+		}
+	default:
+	}
+	for {
+		select {
+		case <-w.granted:
+			return nil
+		case <-w.removed:
+			return cerrors.New(cerrors.CodexExecutionFailed, "cancelled while waiting for a free session slot")
+		case pos := <-w.position:
+			if onPosition != nil {
+				onPosition(pos)
+			}
+		case <-timeout:
+			waited := time.Since(queuedAt)
+			if t.m.abandon(w, StateFailed, "timed out waiting for a free session slot") {
+				return cerrors.New(cerrors.SessionLimitExceeded, "timed out waiting for a free session slot").
+					WithData("max_running", t.m.opts.MaxRunning).
+					WithData("queue_wait_ms", waited.Milliseconds())
+			}
+		case <-ctx.Done():
+			if t.m.abandon(w, StateCancelled, "cancelled while queued") {
+				return cerrors.Wrap(cerrors.CodexExecutionFailed, "cancelled while waiting for a free session slot", ctx.Err())
+			}
+		}
+	}
+}
+
+// abandon removes w from the queue and finishes its record. It reports false when w
+// was granted concurrently (the caller then observes w.granted).
+func (m *Manager) abandon(w *waiter, state State, reason string) bool {
+	now := time.Now()
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return false
+	default:
+	}
+	m.removeWaiterLocked(w)
+	rec := w.rec
+	if rec.State == StateQueued {
+		rec.State = state
+		rec.Error = reason
+		rec.EndedAt = &now
+		rec.cancel = nil
+		rec.endTurn(now)
+		m.persistLocked(rec)
+		if typ, ok := finishEvent(state); ok {
+			m.emitLocked(rec, typ, now)
+		}
+	}
+	m.notifyPositionsLocked()
+	return true
+}
+
+func (m *Manager) runningLocked() int {
+	running := 0
+	for _, r := range m.sessions {
+		// A suspended session keeps its slot: it resumes where it stopped.
+		if r.State.Active() {
+			running++
+		}
+	}
+	return running
+}
+
+func (m *Manager) removeWaiterLocked(w *waiter) bool {
+	for i, q := range m.queue {
+		if q == w {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// orderedQueueLocked returns waiters in grant order: priority, then fair share, then FIFO.
+func (m *Manager) orderedQueueLocked() []*waiter {
+	runningByKey := make(map[string]int)
+	for _, r := range m.sessions {
+		if r.State.Active() {
+			runningByKey[r.shareKey]++
+		}
+	}
+	ordered := append([]*waiter(nil), m.queue...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		if ra, rb := runningByKey[a.rec.shareKey], runningByKey[b.rec.shareKey]; ra != rb {
+			return ra < rb
+		}
+		return a.seq < b.seq
+	})
+	return ordered
+}
+
+// dispatchLocked grants free slots to queued sessions.
+func (m *Manager) dispatchLocked() {
+	if len(m.queue) == 0 {
+		return
+	}
+	now := time.Now()
+	for len(m.queue) > 0 && (m.opts.MaxRunning <= 0 || m.runningLocked() < m.opts.MaxRunning) {
+		w := m.orderedQueueLocked()[0]
+		m.removeWaiterLocked(w)
+		w.rec.State = StateRunning
+		w.rec.StartedAt = now
+		// Time spent queued does not count towards the turn (or the budget).
+		if t := w.rec.currentTurn(); t != nil && t.State == StateRunning {
+			t.StartedAt = now
+		}
+		close(w.granted)
+		m.persistLocked(w.rec)
+		m.emitLocked(w.rec, EventStarted, now)
+	}
+	m.notifyPositionsLocked()
+}
+
+func (m *Manager) notifyPositionsLocked() {
+	ordered := m.orderedQueueLocked()
+	for i, w := range ordered {
+		pos := QueuePosition{Position: i + 1, Queued: len(ordered)}
+		select {
+		case <-w.position:
+		default:
+		}
+		w.position <- pos
+	}
+}
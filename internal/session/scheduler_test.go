@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+func enqueue(t *testing.T, m *Manager, id string, q QueueOptions) *Ticket {
+	t.Helper()
+	ticket, err := m.Enqueue(id, "/tmp", "read-only", func() {}, q)
+	if err != nil {
+		t.Fatalf("Enqueue(%s) failed: %v", id, err)
+	}
+	return ticket
+}
+
+func waitGranted(t *testing.T, ticket *Ticket) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ticket.Wait(ctx, nil); err != nil {
+		t.Fatalf("Wait() failed: %v", err)
+	}
+}
+
+func assertState(t *testing.T, m *Manager, id string, want State) {
+	t.Helper()
+	v, ok := m.Get(id)
+	if !ok {
+		t.Fatalf("Get(%s) should find session", id)
+	}
+	if v.State != want {
+		t.Fatalf("%s state=%v, want %v", id, v.State, want)
+	}
+}
+
+func TestManager_QueueGrantsOnFinish(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 4, TTL: time.Minute})
+
+	if enqueue(t, m, "s1", QueueOptions{}).Queued() {
+		t.Fatalf("s1 should start immediately")
+	}
+	t2 := enqueue(t, m, "s2", QueueOptions{})
+	if !t2.Queued() {
+		t.Fatalf("s2 should be queued")
+	}
+	assertState(t, m, "s2", StateQueued)
+
+	var positions []QueuePosition
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- t2.Wait(context.Background(), func(p QueuePosition) { positions = append(positions, p) })
+	}()
+
+	m.MarkCompleted("s1", 10, 0)
+	select {
+	case err := <-waitErr:
+		if err != nil {
+			t.Fatalf("Wait() failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("s2 was not granted after s1 finished")
+	}
+	assertState(t, m, "s2", StateRunning)
+	if len(positions) == 0 || positions[0] != (QueuePosition{Position: 1, Queued: 1}) {
+		t.Fatalf("positions=%v, want first {1 1}", positions)
+	}
+}
+
+func TestManager_QueueOrder(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 2, QueueSize: 8, TTL: time.Minute})
+
+	enqueue(t, m, "a1", QueueOptions{ShareKey: "a"})
+	enqueue(t, m, "a2", QueueOptions{ShareKey: "a"})
+	// Queued in FIFO order; b has no running sessions and "urgent" has a higher priority.
+	a3 := enqueue(t, m, "a3", QueueOptions{ShareKey: "a"})
+	b1 := enqueue(t, m, "b1", QueueOptions{ShareKey: "b"})
+	urgent := enqueue(t, m, "urgent", QueueOptions{ShareKey: "a", Priority: 5})
+
+	m.MarkCompleted("a1", 1, 0)
+	waitGranted(t, urgent)
+	assertState(t, m, "b1", StateQueued)
+
+	m.MarkCompleted("a2", 1, 0)
+	waitGranted(t, b1)
+	assertState(t, m, "a3", StateQueued)
+
+	m.MarkCompleted("urgent", 1, 0)
+	waitGranted(t, a3)
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 1, TTL: time.Minute})
+
+	enqueue(t, m, "s1", QueueOptions{})
+	enqueue(t, m, "s2", QueueOptions{})
+	_, err := m.Enqueue("s3", "/tmp", "read-only", func() {}, QueueOptions{})
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) || cerr.Code != cerrors.SessionLimitExceeded {
+		t.Fatalf("expected SessionLimitExceeded, got %v", err)
+	}
+	if _, ok := m.Get("s3"); ok {
+		t.Fatalf("rejected session should not be tracked")
+	}
+}
+
+func TestManager_QueueWaitTimeout(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 1, MaxQueueWait: 20 * time.Millisecond, TTL: time.Minute})
+
+	enqueue(t, m, "s1", QueueOptions{})
+	t2 := enqueue(t, m, "s2", QueueOptions{})
+	err := t2.Wait(context.Background(), nil)
+	var cerr *cerrors.Error
+	if !stderrors.As(err, &cerr) || cerr.Code != cerrors.SessionLimitExceeded {
+		t.Fatalf("expected SessionLimitExceeded, got %v", err)
+	}
+	assertState(t, m, "s2", StateFailed)
+
+	// The slot freed by s1 must not be handed to the abandoned waiter.
+	m.MarkCompleted("s1", 1, 0)
+	assertState(t, m, "s2", StateFailed)
+}
+
+func TestManager_CancelQueued(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 2, TTL: time.Minute})
+
+	enqueue(t, m, "s1", QueueOptions{})
+	t2 := enqueue(t, m, "s2", QueueOptions{})
+	t3 := enqueue(t, m, "s3", QueueOptions{})
+
+	if ok, err := m.Cancel("s2"); err != nil || !ok {
+		t.Fatalf("Cancel(s2) ok=%v err=%v", ok, err)
+	}
+	assertState(t, m, "s2", StateCancelled)
+	if err := t2.Wait(context.Background(), nil); err == nil {
+		t.Fatalf("Wait() of a cancelled session should fail")
+	}
+
+	m.MarkCompleted("s1", 1, 0)
+	waitGranted(t, t3)
+}
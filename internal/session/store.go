@@ -0,0 +1,167 @@
+package session
+
+import (
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+// PersistedReceiptTextBytes caps each diff and untracked file content of the receipts
+// written to the Store; the in-memory record keeps them whole until it is evicted.
+const PersistedReceiptTextBytes = 16 << 10
+
+// Store persists session records so they survive server restarts.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns every persisted snapshot.
+	Load() ([]Snapshot, error)
+	// Put inserts or replaces the snapshot with the same ID.
+	Put(s Snapshot) error
+	// Delete removes a snapshot; deleting an unknown ID is not an error.
+	Delete(id string) error
+	// Close flushes and releases the store.
+	Close() error
+}
+
+// Snapshot is the persisted form of a Record.
+type Snapshot struct {
+	ID      string `json:"id"`
+	State   State  `json:"state"`
+	WorkDir string `json:"cd"`
+	GitRoot string `json:"git_root,omitempty"`
+	Sandbox string `json:"sandbox"`
+	Origin  Origin `json:"origin,omitzero"`
+
+	Title  string            `json:"title,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+
+	ExecutionTimeMs int64 `json:"execution_time_ms,omitempty"`
+	ToolCallCount   int   `json:"tool_call_count,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	ChangeReceipt *receipt.ChangeReceipt `json:"change_receipt,omitempty"`
+
+	DiagNextSeq  uint64            `json:"diag_next_seq,omitempty"`
+	Diagnostics  []DiagnosticEntry `json:"diagnostics,omitempty"`
+	LastEventAt  *time.Time        `json:"last_event_at,omitempty"`
+	LastOutputAt *time.Time        `json:"last_output_at,omitempty"`
+
+	Turns         []Turn `json:"turns,omitempty"`
+	NextTurnIndex int    `json:"next_turn_index,omitempty"`
+
+	Aliases []string `json:"aliases,omitempty"`
+	Result  *Result  `json:"result,omitempty"`
+
+	ParentID string   `json:"parent_id,omitempty"`
+	Children []string `json:"children,omitempty"`
+
+	Budget          Budget `json:"budget,omitzero"`
+	UsedTokens      int64  `json:"used_tokens,omitempty"`
+	UsedExecutionMs int64  `json:"used_execution_ms,omitempty"`
+}
+
+func (r *Record) snapshot() Snapshot {
+	s := Snapshot{
+		ID:              r.ID,
+		State:           r.State,
+		WorkDir:         r.WorkDir,
+		GitRoot:         r.GitRoot,
+		Sandbox:         r.Sandbox,
+		Origin:          r.origin,
+		Title:           r.Title,
+		Labels:          copyLabels(r.Labels),
+		StartedAt:       r.StartedAt,
+		EndedAt:         r.EndedAt,
+		ExecutionTimeMs: r.ExecutionTimeMs,
+		ToolCallCount:   r.ToolCallCount,
+		Error:           r.Error,
+		ChangeReceipt:   r.ChangeReceipt,
+		DiagNextSeq:     r.diagNextSeq,
+		LastEventAt:     r.lastEventAt,
+		LastOutputAt:    r.lastOutputAt,
+		NextTurnIndex:   r.nextTurnIndex,
+		Aliases:         r.aliases,
+		Result:          r.result,
+		ParentID:        r.ParentID,
+		Children:        r.Children,
+		Budget:          r.budget,
+		UsedTokens:      r.usedTokens,
+		UsedExecutionMs: r.usedExecutionMs,
+	}
+	if len(r.diagnostics) > 0 {
+		s.Diagnostics = append([]DiagnosticEntry(nil), r.diagnostics...)
+	}
+	if len(r.turns) > 0 {
+		s.Turns = append([]Turn(nil), r.turns...)
+	}
+	return s
+}
+
+func recordFromSnapshot(s Snapshot) *Record {
+	if s.Origin == (Origin{}) {
+		// Stored before origins were tracked: the last run is the best guess.
+		s.Origin = Origin{WorkDir: s.WorkDir, GitRoot: s.GitRoot, Sandbox: s.Sandbox}
+	}
+	return &Record{
+		ID:              s.ID,
+		State:           s.State,
+		WorkDir:         s.WorkDir,
+		GitRoot:         s.GitRoot,
+		Sandbox:         s.Sandbox,
+		origin:          s.Origin,
+		Title:           s.Title,
+		Labels:          s.Labels,
+		StartedAt:       s.StartedAt,
+		EndedAt:         s.EndedAt,
+		ExecutionTimeMs: s.ExecutionTimeMs,
+		ToolCallCount:   s.ToolCallCount,
+		Error:           s.Error,
+		ChangeReceipt:   s.ChangeReceipt,
+		diagNextSeq:     s.DiagNextSeq,
+		diagnostics:     s.Diagnostics,
+		lastEventAt:     s.LastEventAt,
+		lastOutputAt:    s.LastOutputAt,
+		turns:           s.Turns,
+		nextTurnIndex:   s.NextTurnIndex,
+		aliases:         s.Aliases,
+		result:          s.Result,
+		ParentID:        s.ParentID,
+		Children:        s.Children,
+		budget:          s.Budget,
+		usedTokens:      s.UsedTokens,
+		usedExecutionMs: s.UsedExecutionMs,
+	}
+}
+
+// Snapshot returns a copy of the session's full state (turns, diagnostics, receipt).
+// sessionID may be an alias.
+func (m *Manager) Snapshot(sessionID string) (Snapshot, bool) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return Snapshot{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return Snapshot{}, false
+	}
+	return rec.snapshot(), true
+}
+
+// LoggedDiagnostics returns every untruncated diagnostic entry still in the
+// diagnostics log for the session (nil when no log is configured). Unlike
+// Snapshot.Diagnostics it is not bounded by the in-memory limits.
+func (m *Manager) LoggedDiagnostics(sessionID string) ([]DiagnosticEntry, error) {
+	if m.opts.DiagnosticsLog == nil {
+		return nil, nil
+	}
+	m.flushWrites()
+	return m.opts.DiagnosticsLog.All(sessionID)
+}
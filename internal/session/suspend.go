@@ -0,0 +1,98 @@
+package session
+
+import (
+	"time"
+
+	cerrors "github.com/w31r4/codex-mcp-go/internal/errors"
+)
+
+// ProcessControl suspends and resumes the codex process behind a running session.
+// Both report whether the process changed state; errors are returned to the caller
+// wrapped in an InternalError.
+type ProcessControl interface {
+	Suspend() (bool, error)
+	Resume() (bool, error)
+}
+
+// SetProcessControl attaches the process control of a running session (see Suspend).
+func (m *Manager) SetProcessControl(sessionID string, c ProcessControl) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return false
+	}
+	rec.control = c
+	return true
+}
+
+// Suspend stops a running session's codex process and marks it StateSuspended. It
+// reports false when the session was already suspended.
+func (m *Manager) Suspend(sessionID string) (View, bool, error) {
+	return m.setSuspended(sessionID, true)
+}
+
+// Resume continues a suspended session. It reports false when the session was not
+// suspended.
+func (m *Manager) Resume(sessionID string) (View, bool, error) {
+	return m.setSuspended(sessionID, false)
+}
+
+func (m *Manager) setSuspended(sessionID string, suspend bool) (View, bool, error) {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return View{}, false, cerrors.ErrInvalidParams("SESSION_ID is required")
+	}
+
+	now := time.Now()
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return View{}, false, cerrors.ErrSessionNotFound(sessionID)
+	}
+	if !rec.State.Active() {
+		return View{}, false, cerrors.ErrInvalidParams("session is not running").
+			WithData("SESSION_ID", rec.ID).
+			WithData("state", string(rec.State))
+	}
+	if (rec.State == StateSuspended) == suspend {
+		return rec.View(), false, nil
+	}
+	if rec.control == nil {
+		return View{}, false, cerrors.ErrInvalidParams("session has not started codex yet").
+			WithData("SESSION_ID", rec.ID)
+	}
+
+	var err error
+	if suspend {
+		_, err = rec.control.Suspend()
+	} else {
+		_, err = rec.control.Resume()
+	}
+	if err != nil {
+		return View{}, false, cerrors.Wrap(cerrors.InternalError, "failed to signal codex process", err).
+			WithData("SESSION_ID", rec.ID)
+	}
+	if suspend {
+		rec.State = StateSuspended
+		rec.suspendedAt = &now
+	} else {
+		if t := rec.currentTurn(); t != nil && rec.suspendedAt != nil {
+			t.SuspendedMs += now.Sub(*rec.suspendedAt).Milliseconds()
+		}
+		rec.State = StateRunning
+		rec.suspendedAt = nil
+	}
+	m.persistLocked(rec)
+	return rec.View(), true, nil
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeControl struct{ suspends, resumes int }
+
+func (c *fakeControl) Suspend() (bool, error) { c.suspends++; return true, nil }
+func (c *fakeControl) Resume() (bool, error)  { c.resumes++; return true, nil }
+
+func TestManager_SuspendAndResume(t *testing.T) {
+	m := NewManager(Options{MaxRunning: 1, QueueSize: 2, TTL: time.Minute})
+	enqueue(t, m, "s1", QueueOptions{})
+
+	if _, _, err := m.Suspend("s1"); err == nil {
+		t.Fatalf("Suspend() without a process should fail")
+	}
+	c := &fakeControl{}
+	m.SetProcessControl("s1", c)
+
+	v, changed, err := m.Suspend("s1")
+	if err != nil || !changed || v.State != StateSuspended || v.SuspendedAt == "" || c.suspends != 1 {
+		t.Fatalf("Suspend()=%+v, %v, %v (suspends=%d)", v, changed, err, c.suspends)
+	}
+	if _, changed, _ := m.Suspend("s1"); changed || c.suspends != 1 {
+		t.Fatalf("second Suspend() should be a no-op")
+	}
+	// A suspended session keeps its slot.
+	if enqueue(t, m, "s2", QueueOptions{}).Queued() == false {
+		t.Fatalf("s2 should queue behind the suspended session")
+	}
+	if _, err := m.Enqueue("s1", "/tmp", "read-only", func() {}, QueueOptions{}); err == nil {
+		t.Fatalf("resuming the thread of a suspended session should fail")
+	}
+
+	v, changed, err = m.Resume("s1")
+	if err != nil || !changed || v.State != StateRunning || v.SuspendedAt != "" || c.resumes != 1 {
+		t.Fatalf("Resume()=%+v, %v, %v", v, changed, err)
+	}
+
+	m.Suspend("s1")
+	if ok, err := m.Cancel("s1"); !ok || err != nil {
+		t.Fatalf("Cancel() of a suspended session = %v, %v", ok, err)
+	}
+	assertState(t, m, "s1", StateCancelled)
+	if _, _, err := m.Resume("s1"); err == nil {
+		t.Fatalf("Resume() of a cancelled session should fail")
+	}
+}
+
+func TestManager_RestoreInterruptsSuspended(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("OpenJSONLStore() failed: %v", err)
+	}
+	m := NewManager(Options{MaxRunning: 1, TTL: time.Hour, Store: store})
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := m.Start("s1", "/w", "read-only", cancel); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	m.SetProcessControl("s1", &fakeControl{})
+	m.Suspend("s1")
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	store, err = OpenJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	restored := NewManager(Options{MaxRunning: 1, TTL: time.Hour, Store: store})
+	defer restored.Close()
+	if _, err := restored.Restore(time.Now()); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	assertState(t, restored, "s1", StateInterrupted)
+}
@@ -0,0 +1,162 @@
+package session
+
+import "time"
+
+// Phase names of a run, in the order they are normally reached.
+const (
+	PhaseLockWait          = "lock_wait"
+	PhaseQueueWait         = "queue_wait"
+	PhaseBaseline          = "baseline"
+	PhaseSpawn             = "spawn"
+	PhaseFirstOutput       = "first_output"
+	PhaseThreadID          = "thread_id"
+	PhaseFirstAgentMessage = "first_agent_message"
+	PhaseProcessExit       = "process_exit"
+	PhaseReceipt           = "receipt"
+)
+
+// Phases lists every phase in order.
+var Phases = []string{
+	PhaseLockWait,
+	PhaseQueueWait,
+	PhaseBaseline,
+	PhaseSpawn,
+	PhaseFirstOutput,
+	PhaseThreadID,
+	PhaseFirstAgentMessage,
+	PhaseProcessExit,
+	PhaseReceipt,
+}
+
+// Timings are the phase timestamps of one run (one turn). A zero time means the run
+// never reached that phase.
+type Timings struct {
+	// Start is when the codex tool call was received.
+	Start             time.Time `json:"start"`
+	LockAcquired      time.Time `json:"lock_acquired,omitzero"`
+	SlotGranted       time.Time `json:"slot_granted,omitzero"`
+	BaselineTaken     time.Time `json:"baseline_taken,omitzero"`
+	Spawned           time.Time `json:"spawned,omitzero"`
+	FirstOutput       time.Time `json:"first_output,omitzero"`
+	ThreadID          time.Time `json:"thread_id,omitzero"`
+	FirstAgentMessage time.Time `json:"first_agent_message,omitzero"`
+	ProcessExited     time.Time `json:"process_exited,omitzero"`
+	ReceiptCollected  time.Time `json:"receipt_collected,omitzero"`
+}
+
+// PhaseDuration is the time a run spent in one phase.
+type PhaseDuration struct {
+	Phase    string
+	Duration time.Duration
+}
+
+// Durations returns the phases the run reached, in order. Each phase lasts from the
+// end of the previous reached phase (or Start) to its own timestamp.
+func (t Timings) Durations() []PhaseDuration {
+	if t.Start.IsZero() {
+		return nil
+	}
+	marks := []time.Time{
+		t.LockAcquired,
+		t.SlotGranted,
+		t.BaselineTaken,
+		t.Spawned,
+		t.FirstOutput,
+		t.ThreadID,
+		t.FirstAgentMessage,
+		t.ProcessExited,
+		t.ReceiptCollected,
+	}
+	out := make([]PhaseDuration, 0, len(marks))
+	prev := t.Start
+	for i, at := range marks {
+		if at.IsZero() {
+			continue
+		}
+		d := at.Sub(prev)
+		if d < 0 {
+			// Phases can interleave (e.g. the thread ID arrives with the first output).
+			d = 0
+		} else {
+			prev = at
+		}
+		out = append(out, PhaseDuration{Phase: Phases[i], Duration: d})
+	}
+	return out
+}
+
+// end returns the latest recorded timestamp.
+func (t Timings) end() time.Time {
+	end := t.Start
+	for _, d := range t.Durations() {
+		end = end.Add(d.Duration)
+	}
+	return end
+}
+
+// TimingsView is the JSON view of Timings: the milliseconds spent in each reached
+// phase (see Timings.Durations). Phases that were not reached are omitted.
+type TimingsView struct {
+	StartedAt           string `json:"started_at"`
+	LockWaitMs          *int64 `json:"lock_wait_ms,omitempty"`
+	QueueWaitMs         *int64 `json:"queue_wait_ms,omitempty"`
+	BaselineMs          *int64 `json:"baseline_ms,omitempty"`
+	SpawnMs             *int64 `json:"spawn_ms,omitempty"`
+	FirstOutputMs       *int64 `json:"first_output_ms,omitempty"`
+	ThreadIDMs          *int64 `json:"thread_id_ms,omitempty"`
+	FirstAgentMessageMs *int64 `json:"first_agent_message_ms,omitempty"`
+	ProcessExitMs       *int64 `json:"process_exit_ms,omitempty"`
+	ReceiptMs           *int64 `json:"receipt_ms,omitempty"`
+	TotalMs             int64  `json:"total_ms"`
+}
+
+// View returns the JSON view of t, or nil when nothing was recorded.
+func (t *Timings) View() *TimingsView {
+	if t == nil || t.Start.IsZero() {
+		return nil
+	}
+	v := &TimingsView{
+		StartedAt: t.Start.UTC().Format(time.RFC3339Nano),
+		TotalMs:   t.end().Sub(t.Start).Milliseconds(),
+	}
+	fields := map[string]**int64{
+		PhaseLockWait:          &v.LockWaitMs,
+		PhaseQueueWait:         &v.QueueWaitMs,
+		PhaseBaseline:          &v.BaselineMs,
+		PhaseSpawn:             &v.SpawnMs,
+		PhaseFirstOutput:       &v.FirstOutputMs,
+		PhaseThreadID:          &v.ThreadIDMs,
+		PhaseFirstAgentMessage: &v.FirstAgentMessageMs,
+		PhaseProcessExit:       &v.ProcessExitMs,
+		PhaseReceipt:           &v.ReceiptMs,
+	}
+	for _, d := range t.Durations() {
+		ms := d.Duration.Milliseconds()
+		*fields[d.Phase] = &ms
+	}
+	return v
+}
+
+// SetTimings records the phase timings of the session's current turn.
+func (m *Manager) SetTimings(sessionID string, t Timings) bool {
+	sessionID = stringsTrim(sessionID)
+	if sessionID == "" {
+		return false
+	}
+
+	defer m.flushWrites()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.lookupLocked(sessionID)
+	if !ok {
+		return false
+	}
+	turn := rec.currentTurn()
+	if turn == nil {
+		return false
+	}
+	turn.Timings = &t
+	m.persistLocked(rec)
+	return true
+}
@@ -0,0 +1,50 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimings_Durations(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	tm := Timings{
+		Start:        start,
+		LockAcquired: at(5),
+		SlotGranted:  at(5),
+		Spawned:      at(30),
+		FirstOutput:  at(100),
+		// Out-of-order stamps must not produce a negative phase.
+		ThreadID:         at(99),
+		ProcessExited:    at(1000),
+		ReceiptCollected: at(1200),
+	}
+
+	want := []PhaseDuration{
+		{PhaseLockWait, 5 * time.Millisecond},
+		{PhaseQueueWait, 0},
+		{PhaseSpawn, 25 * time.Millisecond},
+		{PhaseFirstOutput, 70 * time.Millisecond},
+		{PhaseThreadID, 0},
+		// No agent message: process_exit is measured from the last reached phase.
+		{PhaseProcessExit, 900 * time.Millisecond},
+		{PhaseReceipt, 200 * time.Millisecond},
+	}
+	got := tm.Durations()
+	if len(got) != len(want) {
+		t.Fatalf("Durations()=%v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Durations()[%d]=%v, want %v", i, got[i], want[i])
+		}
+	}
+
+	v := tm.View()
+	if v.TotalMs != 1200 || v.FirstAgentMessageMs != nil || v.ReceiptMs == nil || *v.ReceiptMs != 200 {
+		t.Fatalf("View()=%+v", v)
+	}
+	if (*Timings)(nil).View() != nil || (&Timings{}).View() != nil {
+		t.Fatalf("View() of empty timings must be nil")
+	}
+}
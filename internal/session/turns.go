@@ -0,0 +1,131 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+)
+
+const (
+	// DefaultMaxTurns bounds the turns kept per session (oldest are dropped first).
+	DefaultMaxTurns = 100
+
+	turnPromptPreviewRunes  = 200
+	turnAgentMessageMaxSize = 64 * 1024
+)
+
+// Usage is the token usage reported by codex for one turn.
+type Usage struct {
+	InputTokens           int64 `json:"input_tokens"`
+	CachedInputTokens     int64 `json:"cached_input_tokens,omitempty"`
+	OutputTokens          int64 `json:"output_tokens"`
+	ReasoningOutputTokens int64 `json:"reasoning_output_tokens,omitempty"`
+}
+
+// Total returns the billable token count (cached input and reasoning output are
+// subsets of input and output).
+func (u Usage) Total() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// TurnStart describes a new turn (one codex invocation on a thread).
+type TurnStart struct {
+	Prompt  string
+	Sandbox string
+	Model   string
+}
+
+// Turn is one codex invocation on a thread. Prompts are kept only as a hash and a
+// short preview. SuspendedMs is the part of DurationMs the process spent suspended.
+type Turn struct {
+	Index         int                    `json:"index"`
+	State         State                  `json:"state"`
+	PromptPreview string                 `json:"prompt_preview"`
+	PromptSHA256  string                 `json:"prompt_sha256"`
+	PromptChars   int                    `json:"prompt_chars"`
+	Sandbox       string                 `json:"sandbox,omitempty"`
+	Model         string                 `json:"model,omitempty"`
+	StartedAt     time.Time              `json:"started_at"`
+	EndedAt       *time.Time             `json:"ended_at,omitempty"`
+	DurationMs    int64                  `json:"duration_ms,omitempty"`
+	SuspendedMs   int64                  `json:"suspended_ms,omitempty"`
+	ToolCallCount int                    `json:"tool_call_count,omitempty"`
+	AgentMessage  string                 `json:"agent_message,omitempty"`
+	Usage         *Usage                 `json:"usage,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	ChangeReceipt *receipt.ChangeReceipt `json:"change_receipt,omitempty"`
+	Timings       *Timings               `json:"timings,omitempty"`
+}
+
+// TurnView is the JSON view of a Turn.
+type TurnView struct {
+	Index         int                    `json:"index"`
+	State         State                  `json:"state"`
+	PromptPreview string                 `json:"prompt_preview"`
+	PromptSHA256  string                 `json:"prompt_sha256"`
+	PromptChars   int                    `json:"prompt_chars"`
+	Sandbox       string                 `json:"sandbox,omitempty"`
+	Model         string                 `json:"model,omitempty"`
+	StartedAt     string                 `json:"started_at"`
+	EndedAt       string                 `json:"ended_at,omitempty"`
+	DurationMs    int64                  `json:"duration_ms,omitempty"`
+	SuspendedMs   int64                  `json:"suspended_ms,omitempty"`
+	ToolCallCount int                    `json:"tool_call_count,omitempty"`
+	AgentMessage  string                 `json:"agent_message,omitempty"`
+	Usage         *Usage                 `json:"usage,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	ChangeReceipt *receipt.ChangeReceipt `json:"change_receipt,omitempty"`
+	Timings       *TimingsView           `json:"timings,omitempty"`
+}
+
+func (t Turn) View() TurnView {
+	v := TurnView{
+		Index:         t.Index,
+		State:         t.State,
+		PromptPreview: t.PromptPreview,
+		PromptSHA256:  t.PromptSHA256,
+		PromptChars:   t.PromptChars,
+		Sandbox:       t.Sandbox,
+		Model:         t.Model,
+		StartedAt:     t.StartedAt.UTC().Format(time.RFC3339),
+		DurationMs:    t.DurationMs,
+		SuspendedMs:   t.SuspendedMs,
+		ToolCallCount: t.ToolCallCount,
+		AgentMessage:  t.AgentMessage,
+		Usage:         t.Usage,
+		Error:         t.Error,
+		ChangeReceipt: t.ChangeReceipt,
+		Timings:       t.Timings.View(),
+	}
+	if t.EndedAt != nil {
+		v.EndedAt = t.EndedAt.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+func newTurn(index int, start TurnStart, now time.Time) Turn {
+	sum := sha256.Sum256([]byte(start.Prompt))
+	return Turn{
+		Index:         index,
+		State:         StateRunning,
+		PromptPreview: previewRunes(start.Prompt, turnPromptPreviewRunes),
+		PromptSHA256:  hex.EncodeToString(sum[:]),
+		PromptChars:   utf8.RuneCountInString(start.Prompt),
+		Sandbox:       start.Sandbox,
+		Model:         start.Model,
+		StartedAt:     now,
+	}
+}
+
+func previewRunes(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max]) + "…"
+}
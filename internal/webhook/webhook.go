@@ -0,0 +1,352 @@
+// Package webhook delivers session lifecycle events (see session.Event) to the
+// HTTP endpoints configured in [[webhooks]].
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/config"
+	"github.com/w31r4/codex-mcp-go/internal/logging"
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+// Delivery headers. The signature is "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the hook's secret (see Sign).
+const (
+	HeaderEvent     = "X-Codex-MCP-Event"
+	HeaderDelivery  = "X-Codex-MCP-Delivery"
+	HeaderTimestamp = "X-Codex-MCP-Timestamp"
+	HeaderSignature = "X-Codex-MCP-Signature"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultMaxBackoff  = 60 * time.Second
+	defaultTimeout     = 10 * time.Second
+	queueSize          = 256
+)
+
+// Hook is a delivery target.
+type Hook struct {
+	URL string
+	// Events filters delivered events (empty = all).
+	Events []session.EventType
+	// Secret signs deliveries (empty = unsigned).
+	Secret string
+
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles per attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+}
+
+// FromConfig converts a [[webhooks]] entry, applying defaults for zero values.
+func FromConfig(c config.WebhookConfig) Hook {
+	h := Hook{
+		URL:         strings.TrimSpace(c.URL),
+		Secret:      c.Secret,
+		MaxAttempts: c.MaxAttempts,
+		Backoff:     time.Duration(c.BackoffSeconds) * time.Second,
+		Timeout:     time.Duration(c.TimeoutSeconds) * time.Second,
+	}
+	if env := strings.TrimSpace(c.SecretEnv); env != "" {
+		h.Secret = os.Getenv(env)
+	}
+	for _, ev := range c.Events {
+		h.Events = append(h.Events, session.EventType(ev))
+	}
+	return h.withDefaults()
+}
+
+func (h Hook) withDefaults() Hook {
+	if h.MaxAttempts <= 0 {
+		h.MaxAttempts = defaultMaxAttempts
+	}
+	if h.Backoff <= 0 {
+		h.Backoff = defaultBackoff
+	}
+	if h.MaxBackoff <= 0 {
+		h.MaxBackoff = defaultMaxBackoff
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = defaultTimeout
+	}
+	return h
+}
+
+func (h Hook) wants(typ session.EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, ev := range h.Events {
+		if ev == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string            `json:"id"`
+	Event     session.EventType `json:"event"`
+	Timestamp string            `json:"timestamp"`
+	Session   session.View      `json:"session"`
+	// PreviousSessionIDs are earlier IDs of the session (e.g. the temporary ID a run
+	// was started under before codex reported its thread ID).
+	PreviousSessionIDs []string        `json:"previous_session_ids,omitempty"`
+	ChangeReceipt      *ReceiptSummary `json:"change_receipt,omitempty"`
+}
+
+// ReceiptSummary is the change receipt without the diff body.
+type ReceiptSummary struct {
+	ReceiptAvailable bool                 `json:"receipt_available"`
+	GitRoot          string               `json:"git_root,omitempty"`
+	DiffStat         string               `json:"diff_stat,omitempty"`
+	ChangedFiles     []receipt.FileChange `json:"changed_files,omitempty"`
+	FileCount        int                  `json:"file_count"`
+	DiffTruncated    bool                 `json:"diff_truncated,omitempty"`
+	CodexVersion     string               `json:"codex_version,omitempty"`
+	ReceiptError     string               `json:"receipt_error,omitempty"`
+}
+
+func summarize(r *receipt.ChangeReceipt) *ReceiptSummary {
+	if r == nil {
+		return nil
+	}
+	return &ReceiptSummary{
+		ReceiptAvailable: r.ReceiptAvailable,
+		GitRoot:          r.GitRoot,
+		DiffStat:         r.DiffStat,
+		ChangedFiles:     r.ChangedFiles,
+		FileCount:        len(r.ChangedFiles),
+		DiffTruncated:    r.DiffTruncated,
+		CodexVersion:     r.CodexVersion,
+		ReceiptError:     r.ReceiptError,
+	}
+}
+
+// Sign returns the X-Codex-MCP-Signature value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type delivery struct {
+	id    string
+	event session.EventType
+	body  []byte
+}
+
+type worker struct {
+	hook  Hook
+	queue chan delivery
+}
+
+// Dispatcher delivers events to its hooks in the background: each hook has its own
+// queue and goroutine, so a slow endpoint only delays its own deliveries.
+type Dispatcher struct {
+	client *http.Client
+	logger logging.Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	workers []*worker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New starts a dispatcher for hooks. A nil logger uses logging.GetLogger().
+func New(hooks []Hook, logger logging.Logger) *Dispatcher {
+	if logger == nil {
+		logger = logging.GetLogger()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		client: &http.Client{},
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, h := range hooks {
+		w := &worker{hook: h.withDefaults(), queue: make(chan delivery, queueSize)}
+		d.workers = append(d.workers, w)
+		d.wg.Add(1)
+		go d.run(w)
+	}
+	return d
+}
+
+// Notify queues ev for every hook that wants it. It never blocks (it is called with
+// the session manager lock held): when a hook's queue is full the event is dropped.
+func (d *Dispatcher) Notify(ev session.Event) {
+	if d == nil {
+		return
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	var body []byte
+	var id string
+	for _, w := range d.workers {
+		if !w.hook.wants(ev.Type) {
+			continue
+		}
+		if body == nil {
+			id = newDeliveryID()
+			var err error
+			body, err = json.Marshal(Payload{
+				ID:                 id,
+				Event:              ev.Type,
+				Timestamp:          ev.At.UTC().Format(time.RFC3339Nano),
+				Session:            ev.Session,
+				PreviousSessionIDs: ev.PreviousIDs,
+				ChangeReceipt:      summarize(ev.ChangeReceipt),
+			})
+			if err != nil {
+				d.logger.Warn("webhook payload encoding failed", "event", ev.Type, "error", err)
+				return
+			}
+		}
+		select {
+		case w.queue <- delivery{id: id, event: ev.Type, body: body}:
+		default:
+			d.logger.Warn("webhook queue full, dropping event", "url", w.hook.URL, "event", ev.Type, "SESSION_ID", ev.Session.SessionID)
+		}
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries until ctx ends, after
+// which pending retries are abandoned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, w := range d.workers {
+			close(w.queue)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run(w *worker) {
+	defer d.wg.Done()
+	for del := range w.queue {
+		d.deliver(w.hook, del)
+	}
+}
+
+// deliver POSTs del, retrying network errors, 429 and 5xx responses with backoff.
+func (d *Dispatcher) deliver(h Hook, del delivery) {
+	backoff := h.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= h.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-d.ctx.Done():
+				timer.Stop()
+				d.logger.Warn("webhook delivery abandoned", "url", h.URL, "event", del.event, "delivery", del.id, "error", lastErr)
+				return
+			}
+			if backoff *= 2; backoff > h.MaxBackoff {
+				backoff = h.MaxBackoff
+			}
+		}
+		retry, err := d.post(h, del)
+		if err == nil {
+			d.logger.Debug("webhook delivered", "url", h.URL, "event", del.event, "delivery", del.id, "attempt", attempt)
+			return
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	d.logger.Warn("webhook delivery failed", "url", h.URL, "event", del.event, "delivery", del.id, "error", lastErr)
+}
+
+func (d *Dispatcher) post(h Hook, del delivery) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(d.ctx, h.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return false, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "codex-mcp-go-webhook")
+	req.Header.Set(HeaderEvent, string(del.event))
+	req.Header.Set(HeaderDelivery, del.id)
+	req.Header.Set(HeaderTimestamp, ts)
+	if h.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(h.Secret, ts, del.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func newDeliveryID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w31r4/codex-mcp-go/internal/receipt"
+	"github.com/w31r4/codex-mcp-go/internal/session"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []received
+	failures int // respond 500 to this many requests first
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, received{header: req.Header.Clone(), body: body})
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *recorder) snapshot() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+func closeDispatcher(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	d := New([]Hook{{URL: srv.URL, Secret: "s3cret"}}, nil)
+	d.Notify(session.Event{
+		Type:        session.EventReceiptReady,
+		At:          time.Now(),
+		Session:     session.View{SessionID: "t-1", State: session.StateRunning},
+		PreviousIDs: []string{"tmp-1"},
+		ChangeReceipt: &receipt.ChangeReceipt{
+			ReceiptAvailable: true,
+			DiffStat:         " a.go | 2 +-",
+			ChangedFiles:     []receipt.FileChange{{Path: "a.go"}},
+			Diff:             "diff --git a/a.go b/a.go",
+		},
+	})
+	closeDispatcher(t, d)
+
+	reqs := rec.snapshot()
+	if len(reqs) != 1 {
+		t.Fatalf("requests=%d, want 1", len(reqs))
+	}
+	got := reqs[0]
+	if got.header.Get(HeaderEvent) != "receipt-ready" || got.header.Get(HeaderDelivery) == "" {
+		t.Fatalf("headers=%v", got.header)
+	}
+	if want := Sign("s3cret", got.header.Get(HeaderTimestamp), got.body); got.header.Get(HeaderSignature) != want {
+		t.Fatalf("signature=%q, want %q", got.header.Get(HeaderSignature), want)
+	}
+
+	var p Payload
+	if err := json.Unmarshal(got.body, &p); err != nil {
+		t.Fatalf("payload does not decode: %v", err)
+	}
+	if p.Session.SessionID != "t-1" || len(p.PreviousSessionIDs) != 1 || p.ID != got.header.Get(HeaderDelivery) {
+		t.Fatalf("payload=%+v", p)
+	}
+	if p.ChangeReceipt == nil || p.ChangeReceipt.FileCount != 1 || p.ChangeReceipt.DiffStat == "" {
+		t.Fatalf("receipt summary=%+v", p.ChangeReceipt)
+	}
+	var raw map[string]map[string]any
+	_ = json.Unmarshal(got.body, &raw)
+	if _, ok := raw["change_receipt"]["diff"]; ok {
+		t.Fatalf("payload must not include the diff body: %s", got.body)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	rec := &recorder{failures: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	d := New([]Hook{{URL: srv.URL, MaxAttempts: 3, Backoff: 10 * time.Millisecond}}, nil)
+	d.Notify(session.Event{Type: session.EventCompleted, At: time.Now(), Session: session.View{SessionID: "t-1"}})
+	closeDispatcher(t, d)
+
+	reqs := rec.snapshot()
+	if len(reqs) != 3 {
+		t.Fatalf("requests=%d, want 3 (two failures, then success)", len(reqs))
+	}
+	if reqs[0].header.Get(HeaderDelivery) != reqs[2].header.Get(HeaderDelivery) {
+		t.Fatalf("retries must reuse the delivery ID")
+	}
+	if reqs[0].header.Get(HeaderSignature) != "" {
+		t.Fatalf("unsigned hook sent a signature")
+	}
+}
+
+func TestDispatcher_GivesUpOnClientErrors(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	d := New([]Hook{{URL: srv.URL, MaxAttempts: 5, Backoff: time.Millisecond}}, nil)
+	d.Notify(session.Event{Type: session.EventFailed, At: time.Now()})
+	closeDispatcher(t, d)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("calls=%d, want 1 (4xx is not retried)", calls)
+	}
+}
+
+func TestDispatcher_FiltersEvents(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	d := New([]Hook{{URL: srv.URL, Events: []session.EventType{session.EventCompleted, session.EventFailed}}}, nil)
+	for _, typ := range []session.EventType{session.EventStarted, session.EventCompleted, session.EventReceiptReady, session.EventFailed} {
+		d.Notify(session.Event{Type: typ, At: time.Now()})
+	}
+	closeDispatcher(t, d)
+	// Events after Close are dropped.
+	d.Notify(session.Event{Type: session.EventCompleted, At: time.Now()})
+
+	reqs := rec.snapshot()
+	if len(reqs) != 2 || reqs[0].header.Get(HeaderEvent) != "completed" || reqs[1].header.Get(HeaderEvent) != "failed" {
+		t.Fatalf("delivered=%d events, want completed and failed", len(reqs))
+	}
+}